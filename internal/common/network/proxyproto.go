@@ -0,0 +1,149 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PROXY protocol versions accepted by --proxy-protocol.
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
+// proxyV2Signature is the fixed 12-byte prefix of every PROXY protocol v2 header.
+var proxyV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// ValidateProxyProtocol checks the --proxy-protocol and --proxy-source flag
+// values. An empty version disables the feature; --proxy-source is then
+// rejected since it would be silently ignored.
+func ValidateProxyProtocol(version, source string) error {
+	switch strings.ToLower(version) {
+	case "":
+		if source != "" {
+			return fmt.Errorf("-proxy-source requires -proxy-protocol")
+		}
+		return nil
+	case ProxyProtocolV1, ProxyProtocolV2:
+	default:
+		return fmt.Errorf("invalid -proxy-protocol: %s (must be one of: v1, v2)", version)
+	}
+
+	if source != "" {
+		if _, err := ParseProxySource(source, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseProxySource parses a --proxy-source value of the form "ip" or
+// "ip:port" (IPv6 with a port must be bracketed, e.g. "[2001:db8::1]:4000").
+// If no port is given, defaultPort is used.
+func ParseProxySource(source string, defaultPort int) (*net.TCPAddr, error) {
+	host, portStr, err := net.SplitHostPort(source)
+	if err != nil {
+		// No port: the whole value must be an IP literal
+		host = strings.Trim(source, "[]")
+		portStr = strconv.Itoa(defaultPort)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid -proxy-source %q: not an IP address", source)
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid -proxy-source %q: bad port", source)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// BuildProxyHeader builds a HAProxy PROXY protocol header (v1 text or v2
+// binary) announcing a TCP connection from src to dst. Both addresses must
+// belong to the same address family.
+func BuildProxyHeader(version string, src, dst *net.TCPAddr) ([]byte, error) {
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("PROXY header requires both source and destination addresses")
+	}
+
+	srcV4, dstV4 := src.IP.To4(), dst.IP.To4()
+	if (srcV4 == nil) != (dstV4 == nil) {
+		return nil, fmt.Errorf("PROXY source %s and destination %s are of different address families", src.IP, dst.IP)
+	}
+
+	switch strings.ToLower(version) {
+	case ProxyProtocolV1:
+		family, srcIP, dstIP := "TCP4", srcV4.String(), dstV4.String()
+		if srcV4 == nil {
+			family, srcIP, dstIP = "TCP6", src.IP.String(), dst.IP.String()
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, srcIP, dstIP, src.Port, dst.Port)), nil
+
+	case ProxyProtocolV2:
+		var buf bytes.Buffer
+		buf.Write(proxyV2Signature)
+		buf.WriteByte(0x21) // version 2, PROXY command
+
+		var srcIP, dstIP net.IP
+		if srcV4 != nil {
+			buf.WriteByte(0x11) // AF_INET, STREAM
+			srcIP, dstIP = srcV4, dstV4
+		} else {
+			buf.WriteByte(0x21) // AF_INET6, STREAM
+			srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		}
+
+		addrLen := uint16(2*len(srcIP) + 4)
+		_ = binary.Write(&buf, binary.BigEndian, addrLen)
+		buf.Write(srcIP)
+		buf.Write(dstIP)
+		_ = binary.Write(&buf, binary.BigEndian, uint16(src.Port))
+		_ = binary.Write(&buf, binary.BigEndian, uint16(dst.Port))
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol version: %s", version)
+	}
+}
+
+// WriteProxyHeader sends a PROXY protocol header on a freshly dialed
+// connection, before any application data is exchanged. The destination is
+// the connection's remote address; the source is --proxy-source if set, or
+// the connection's local address otherwise (a source without a port reuses
+// the local port). It returns the header that was written so callers can
+// log it.
+func WriteProxyHeader(conn net.Conn, version, source string) ([]byte, error) {
+	dst, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("PROXY protocol requires a TCP connection")
+	}
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return nil, fmt.Errorf("PROXY protocol requires a TCP connection")
+	}
+
+	src := local
+	if source != "" {
+		var err error
+		src, err = ParseProxySource(source, local.Port)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := BuildProxyHeader(version, src, dst)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to send PROXY header: %w", err)
+	}
+	return header, nil
+}
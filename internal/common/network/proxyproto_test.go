@@ -0,0 +1,171 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestValidateProxyProtocol(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		source  string
+		wantErr bool
+	}{
+		{"disabled", "", "", false},
+		{"v1", "v1", "", false},
+		{"v2 uppercase", "V2", "", false},
+		{"v1 with IPv4 source", "v1", "198.51.100.7", false},
+		{"v2 with IPv6 source and port", "v2", "[2001:db8::7]:40000", false},
+		{"unknown version", "v3", "", true},
+		{"source without version", "", "198.51.100.7", true},
+		{"hostname source", "v1", "client.example.com", true},
+		{"bad port", "v1", "198.51.100.7:99999", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateProxyProtocol(tt.version, tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseProxySource_DefaultPort(t *testing.T) {
+	addr, err := ParseProxySource("198.51.100.7", 51000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr.Port != 51000 {
+		t.Errorf("port = %d, want default 51000", addr.Port)
+	}
+
+	addr, err = ParseProxySource("198.51.100.7:4000", 51000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr.Port != 4000 {
+		t.Errorf("port = %d, want explicit 4000", addr.Port)
+	}
+}
+
+func TestBuildProxyHeader_V1(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.25"), Port: 25}
+
+	got, err := BuildProxyHeader("v1", src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "PROXY TCP4 198.51.100.7 192.0.2.25 40000 25\r\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	src6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 40000}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("2001:db8::25"), Port: 993}
+	got, err = BuildProxyHeader("v1", src6, dst6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "PROXY TCP6 2001:db8::7 2001:db8::25 40000 993\r\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBuildProxyHeader_V2IPv4(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("192.0.2.25"), Port: 25}
+
+	got, err := BuildProxyHeader("v2", src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := append([]byte{}, proxyV2Signature...)
+	want = append(want, 0x21, 0x11, 0x00, 0x0C)
+	want = append(want, 198, 51, 100, 7, 192, 0, 2, 25)
+	want = append(want, 0x9C, 0x40, 0x00, 0x19)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x\nwant % x", got, want)
+	}
+}
+
+func TestBuildProxyHeader_V2IPv6(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("2001:db8::7"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::25"), Port: 993}
+
+	got, err := BuildProxyHeader("v2", src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 16+36 {
+		t.Fatalf("header length = %d, want %d", len(got), 16+36)
+	}
+	if got[13] != 0x21 {
+		t.Errorf("family byte = %#x, want 0x21 (TCP over IPv6)", got[13])
+	}
+	if got[14] != 0x00 || got[15] != 0x24 {
+		t.Errorf("address length = %d, want 36", int(got[14])<<8|int(got[15]))
+	}
+	if !net.IP(got[16:32]).Equal(src.IP) || !net.IP(got[32:48]).Equal(dst.IP) {
+		t.Errorf("addresses not encoded correctly: % x", got[16:48])
+	}
+}
+
+func TestBuildProxyHeader_FamilyMismatch(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::25"), Port: 25}
+
+	for _, version := range []string{"v1", "v2"} {
+		if _, err := BuildProxyHeader(version, src, dst); err == nil {
+			t.Errorf("%s: expected error for mixed address families, got nil", version)
+		}
+	}
+}
+
+func TestWriteProxyHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 256)
+		n, _ := conn.Read(buf)
+		received <- buf[:n]
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	header, err := WriteProxyHeader(conn, "v1", "203.0.113.9:12345")
+	if err != nil {
+		t.Fatalf("WriteProxyHeader() error = %v", err)
+	}
+
+	dstPort := ln.Addr().(*net.TCPAddr).Port
+	want := "PROXY TCP4 203.0.113.9 127.0.0.1 12345 " + strconv.Itoa(dstPort) + "\r\n"
+	if string(header) != want {
+		t.Errorf("header = %q, want %q", header, want)
+	}
+	if got := <-received; string(got) != want {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+}
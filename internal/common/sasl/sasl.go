@@ -0,0 +1,159 @@
+// Package sasl provides the SASL mechanisms shared by the SMTP, IMAP and
+// POP3 clients that go-sasl does not implement: NTLM and GSSAPI (Kerberos 5).
+// The clients implement go-sasl's Client; SMTP adapts them to smtp.Auth.
+package sasl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/go-ntlmssp"
+	gosasl "github.com/emersion/go-sasl"
+	krb5client "github.com/jcmturner/gokrb5/v8/client"
+	krb5config "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+)
+
+// NTLMClient implements NTLM (NTLMv2) authentication, as used by Exchange
+// with Integrated Windows authentication.
+// The exchange is negotiate (type 1) → challenge (type 2) → authenticate (type 3).
+// Username may be in DOMAIN\user format; the library handles domain extraction.
+type NTLMClient struct {
+	Username string
+	Password string
+}
+
+var _ gosasl.Client = (*NTLMClient)(nil)
+
+func (a *NTLMClient) Start() (string, []byte, error) {
+	negotiate, err := ntlmssp.NewNegotiateMessage("", "")
+	if err != nil {
+		return "", nil, fmt.Errorf("NTLM negotiate: %w", err)
+	}
+	return "NTLM", negotiate, nil
+}
+
+func (a *NTLMClient) Next(challenge []byte) ([]byte, error) {
+	authenticate, err := ntlmssp.NewAuthenticateMessage(challenge, a.Username, a.Password, nil)
+	if err != nil {
+		return nil, fmt.Errorf("NTLM authenticate: %w", err)
+	}
+	return authenticate, nil
+}
+
+// ParseKerberosCredentials splits a Kerberos username into the user and realm parts.
+// Supports user@REALM.COM and DOMAIN\user formats.
+func ParseKerberosCredentials(username string) (user, realm string) {
+	if idx := strings.Index(username, "@"); idx >= 0 {
+		return username[:idx], strings.ToUpper(username[idx+1:])
+	}
+	if idx := strings.Index(username, "\\"); idx >= 0 {
+		return username[idx+1:], strings.ToUpper(username[:idx])
+	}
+	return username, ""
+}
+
+// GSSAPIClient implements SASL GSSAPI (RFC 4752) using Kerberos 5 with the
+// service principal <Service>/<Target>.
+// Exchange: AP_REQ → [AP_REP] → security-layer negotiation (no-security-layer selected).
+// Username may be in user@REALM or DOMAIN\user format; realm is extracted automatically
+// or overridden via Realm. KDC is discovered via DNS SRV unless KDCAddress is set.
+// Close releases the Kerberos client once the exchange is over.
+type GSSAPIClient struct {
+	Username   string
+	Password   string
+	Realm      string // Kerberos realm override (e.g. CONTOSO.COM)
+	KDCAddress string // optional KDC host:port override
+	Service    string // SPN service, e.g. "smtp" or "imap"
+	Target     string // server hostname for the SPN
+
+	krb5Client *krb5client.Client
+	sessionKey types.EncryptionKey
+	step       int
+}
+
+var _ gosasl.Client = (*GSSAPIClient)(nil)
+
+func (a *GSSAPIClient) Start() (string, []byte, error) {
+	user, realm := ParseKerberosCredentials(a.Username)
+	if a.Realm != "" {
+		realm = a.Realm
+	}
+	if realm == "" {
+		return "", nil, fmt.Errorf("GSSAPI: Kerberos realm required (use user@REALM format or --realm)")
+	}
+
+	krb5Cfg := krb5config.New()
+	krb5Cfg.LibDefaults.DefaultRealm = realm
+	if a.KDCAddress != "" {
+		krb5Cfg.Realms = []krb5config.Realm{{Realm: realm, KDC: []string{a.KDCAddress}}}
+	} else {
+		krb5Cfg.LibDefaults.DNSLookupKDC = true
+	}
+
+	a.krb5Client = krb5client.NewWithPassword(user, realm, a.Password, krb5Cfg,
+		krb5client.DisablePAFXFAST(true))
+	if err := a.krb5Client.Login(); err != nil {
+		return "", nil, fmt.Errorf("GSSAPI: Kerberos login failed: %w", err)
+	}
+
+	spn := fmt.Sprintf("%s/%s", a.Service, a.Target)
+	tkt, skey, err := a.krb5Client.GetServiceTicket(spn)
+	if err != nil {
+		a.Close()
+		return "", nil, fmt.Errorf("GSSAPI: service ticket for %s failed: %w", spn, err)
+	}
+	a.sessionKey = skey
+
+	gssToken, err := spnego.NewKRB5TokenAPREQ(a.krb5Client, tkt, skey,
+		[]int{gssapi.ContextFlagMutual}, []int{})
+	if err != nil {
+		a.Close()
+		return "", nil, fmt.Errorf("GSSAPI: AP_REQ token failed: %w", err)
+	}
+
+	b, err := gssToken.Marshal()
+	if err != nil {
+		a.Close()
+		return "", nil, fmt.Errorf("GSSAPI: AP_REQ marshal failed: %w", err)
+	}
+
+	a.step = 1
+	return "GSSAPI", b, nil
+}
+
+func (a *GSSAPIClient) Next(challenge []byte) ([]byte, error) {
+	switch a.step {
+	case 1:
+		// Server optionally sends AP_REP (mutual auth); skip verification (testing tool)
+		a.step = 2
+		return []byte{}, nil
+
+	case 2:
+		// Server sends GSS-wrapped security layer options: [flags, buf_hi, buf_mid, buf_lo]
+		// Respond with no-security-layer: bitmask=1, max buf size=0
+		secLayer := []byte{0x01, 0x00, 0x00, 0x00}
+		wt, err := gssapi.NewInitiatorWrapToken(secLayer, a.sessionKey)
+		if err != nil {
+			return nil, fmt.Errorf("GSSAPI: security layer wrap failed: %w", err)
+		}
+		b, err := wt.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("GSSAPI: security layer marshal failed: %w", err)
+		}
+		a.step = 3
+		return b, nil
+	}
+
+	return []byte{}, nil
+}
+
+// Close releases the Kerberos client. It is safe to call more than once.
+func (a *GSSAPIClient) Close() {
+	if a.krb5Client != nil {
+		a.krb5Client.Destroy()
+		a.krb5Client = nil
+	}
+}
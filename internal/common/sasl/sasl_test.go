@@ -0,0 +1,54 @@
+package sasl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNTLMClient(t *testing.T) {
+	c := &NTLMClient{Username: `CONTOSO\user`, Password: "secret"}
+	mech, ir, err := c.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if mech != "NTLM" {
+		t.Errorf("mechanism = %q, want NTLM", mech)
+	}
+	// NTLM messages start with the "NTLMSSP\0" signature and type 1
+	if !bytes.HasPrefix(ir, []byte("NTLMSSP\x00\x01\x00\x00\x00")) {
+		t.Errorf("initial response is not an NTLM negotiate message: % x", ir)
+	}
+
+	if _, err := c.Next([]byte("not a challenge")); err == nil {
+		t.Error("Next() with a malformed challenge should fail")
+	}
+}
+
+func TestGSSAPIClient_RequiresRealm(t *testing.T) {
+	c := &GSSAPIClient{Username: "user", Password: "secret", Service: "imap", Target: "imap.example.com"}
+	_, _, err := c.Start()
+	if err == nil || !strings.Contains(err.Error(), "realm required") {
+		t.Errorf("Start() error = %v, want realm required error", err)
+	}
+	c.Close()
+}
+
+func TestParseKerberosCredentials(t *testing.T) {
+	tests := []struct {
+		username  string
+		wantUser  string
+		wantRealm string
+	}{
+		{"user@contoso.com", "user", "CONTOSO.COM"},
+		{`CONTOSO\user`, "user", "CONTOSO"},
+		{"user", "user", ""},
+	}
+
+	for _, tt := range tests {
+		user, realm := ParseKerberosCredentials(tt.username)
+		if user != tt.wantUser || realm != tt.wantRealm {
+			t.Errorf("ParseKerberosCredentials(%q) = (%q, %q), want (%q, %q)", tt.username, user, realm, tt.wantUser, tt.wantRealm)
+		}
+	}
+}
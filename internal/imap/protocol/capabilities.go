@@ -15,6 +15,7 @@ const (
 	CapabilityIDLE       = "IDLE"
 	CapabilityNAMESPACE  = "NAMESPACE"
 	CapabilityQUOTA      = "QUOTA"
+	CapabilityACL        = "ACL"
 	CapabilitySORT       = "SORT"
 	CapabilitySEARCH     = "SEARCH"
 	CapabilityTHREAD     = "THREAD"
@@ -28,6 +29,9 @@ const (
 	CapabilitySASLIR     = "SASL-IR"
 	CapabilityID         = "ID"
 	CapabilityENABLE     = "ENABLE"
+	CapabilitySTATUSSIZE = "STATUS=SIZE"
+	CapabilityCOMPRESSDEFLATE = "COMPRESS=DEFLATE"
+	CapabilityUTF8ACCEPT = "UTF8=ACCEPT"
 )
 
 // Capabilities represents IMAP server capabilities.
@@ -132,6 +136,31 @@ func (c *Capabilities) SupportsPlain() bool {
 	return false
 }
 
+// SupportsOAUTHBEARER returns true if OAUTHBEARER (RFC 7628) is supported.
+func (c *Capabilities) SupportsOAUTHBEARER() bool {
+	return c.hasAuthMechanism("OAUTHBEARER")
+}
+
+// SupportsNTLM returns true if NTLM authentication is supported.
+func (c *Capabilities) SupportsNTLM() bool {
+	return c.hasAuthMechanism("NTLM")
+}
+
+// SupportsGSSAPI returns true if GSSAPI (Kerberos) authentication is supported.
+func (c *Capabilities) SupportsGSSAPI() bool {
+	return c.hasAuthMechanism("GSSAPI")
+}
+
+// hasAuthMechanism returns true if the given SASL mechanism is advertised.
+func (c *Capabilities) hasAuthMechanism(name string) bool {
+	for _, mech := range c.authMechanisms {
+		if strings.EqualFold(mech, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // SupportsLogin returns true if LOGIN authentication is supported.
 // Note: This is different from AUTH=LOGIN - it checks if direct LOGIN command works.
 func (c *Capabilities) SupportsLogin() bool {
@@ -154,6 +183,17 @@ func (c *Capabilities) SupportsQUOTA() bool {
 	return c.Has(CapabilityQUOTA)
 }
 
+// SupportsACL returns true if the ACL extension (RFC 4314) is supported.
+func (c *Capabilities) SupportsACL() bool {
+	return c.Has(CapabilityACL)
+}
+
+// SupportsSTATUSSIZE returns true if the STATUS=SIZE extension (RFC 8438) is supported.
+// IMAP4rev2 servers support the SIZE status item without advertising it.
+func (c *Capabilities) SupportsSTATUSSIZE() bool {
+	return c.Has(CapabilitySTATUSSIZE)
+}
+
 // SupportsSORT returns true if the SORT extension is supported.
 func (c *Capabilities) SupportsSORT() bool {
 	return c.Has(CapabilitySORT)
@@ -174,6 +214,21 @@ func (c *Capabilities) SupportsCONDSTORE() bool {
 	return c.Has(CapabilityCONDSTORE)
 }
 
+// SupportsQRESYNC returns true if the QRESYNC extension (RFC 7162) is supported.
+func (c *Capabilities) SupportsQRESYNC() bool {
+	return c.Has(CapabilityQRESYNC)
+}
+
+// SupportsUTF8ACCEPT returns true if the UTF8=ACCEPT extension (RFC 6855) is supported.
+func (c *Capabilities) SupportsUTF8ACCEPT() bool {
+	return c.Has(CapabilityUTF8ACCEPT)
+}
+
+// SupportsCOMPRESSDEFLATE returns true if the COMPRESS=DEFLATE extension (RFC 4978) is supported.
+func (c *Capabilities) SupportsCOMPRESSDEFLATE() bool {
+	return c.Has(CapabilityCOMPRESSDEFLATE)
+}
+
 // SupportsSASLIR returns true if SASL Initial Response is supported.
 // This allows sending the initial auth response with the AUTH command.
 func (c *Capabilities) SupportsSASLIR() bool {
@@ -191,19 +246,29 @@ func (c *Capabilities) SupportsENABLE() bool {
 }
 
 // SelectBestAuthMechanism selects the best available auth mechanism.
-// Priority: XOAUTH2 (if token provided) > PLAIN > LOGIN
+// Priority with a token: XOAUTH2 > OAUTHBEARER; then (and without a token)
+// GSSAPI > NTLM > PLAIN > LOGIN, matching the SMTP auto-selection order.
 func (c *Capabilities) SelectBestAuthMechanism(hasAccessToken bool) string {
-	if hasAccessToken && c.SupportsXOAUTH2() {
-		return "XOAUTH2"
+	if hasAccessToken {
+		if c.SupportsXOAUTH2() {
+			return "XOAUTH2"
+		}
+		if c.SupportsOAUTHBEARER() {
+			return "OAUTHBEARER"
+		}
+	}
+	if c.SupportsGSSAPI() {
+		return "GSSAPI"
+	}
+	if c.SupportsNTLM() {
+		return "NTLM"
 	}
 	if c.SupportsPlain() {
 		return "PLAIN"
 	}
 	// Check for AUTH=LOGIN
-	for _, mech := range c.authMechanisms {
-		if strings.EqualFold(mech, "LOGIN") {
-			return "LOGIN"
-		}
+	if c.hasAuthMechanism("LOGIN") {
+		return "LOGIN"
 	}
 	// Fallback to direct LOGIN command if available
 	if c.SupportsLogin() {
@@ -183,6 +183,47 @@ func TestCapabilities_SupportsNAMESPACE(t *testing.T) {
 	}
 }
 
+func TestCapabilities_SupportsACL(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     []string
+		expected bool
+	}{
+		{"has ACL", []string{"IMAP4rev1", "ACL", "RIGHTS=texk"}, true},
+		{"no ACL", []string{"IMAP4rev1", "QUOTA"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := NewCapabilities(tt.caps)
+			if caps.SupportsACL() != tt.expected {
+				t.Errorf("SupportsACL() = %v, want %v", caps.SupportsACL(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestCapabilities_SupportsSTATUSSIZE(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     []string
+		expected bool
+	}{
+		{"has STATUS=SIZE", []string{"IMAP4rev1", "STATUS=SIZE"}, true},
+		{"lowercase", []string{"IMAP4rev1", "status=size"}, true},
+		{"no STATUS=SIZE", []string{"IMAP4rev1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := NewCapabilities(tt.caps)
+			if caps.SupportsSTATUSSIZE() != tt.expected {
+				t.Errorf("SupportsSTATUSSIZE() = %v, want %v", caps.SupportsSTATUSSIZE(), tt.expected)
+			}
+		})
+	}
+}
+
 func TestCapabilities_SupportsCONDSTORE(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -203,6 +244,42 @@ func TestCapabilities_SupportsCONDSTORE(t *testing.T) {
 	}
 }
 
+func TestCapabilities_SupportsCOMPRESSDEFLATE(t *testing.T) {
+	tests := []struct {
+		name     string
+		caps     []string
+		expected bool
+	}{
+		{"has COMPRESS=DEFLATE", []string{"IMAP4rev1", "COMPRESS=DEFLATE"}, true},
+		{"lowercase", []string{"IMAP4rev1", "compress=deflate"}, true},
+		{"no COMPRESS", []string{"IMAP4rev1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := NewCapabilities(tt.caps)
+			if caps.SupportsCOMPRESSDEFLATE() != tt.expected {
+				t.Errorf("SupportsCOMPRESSDEFLATE() = %v, want %v", caps.SupportsCOMPRESSDEFLATE(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestCapabilities_SupportsQRESYNCAndUTF8ACCEPT(t *testing.T) {
+	caps := NewCapabilities([]string{"IMAP4rev1", "ENABLE", "QRESYNC", "UTF8=ACCEPT"})
+	if !caps.SupportsQRESYNC() {
+		t.Error("SupportsQRESYNC() = false, want true")
+	}
+	if !caps.SupportsUTF8ACCEPT() {
+		t.Error("SupportsUTF8ACCEPT() = false, want true")
+	}
+
+	caps = NewCapabilities([]string{"IMAP4rev1", "UTF8=ONLY"})
+	if caps.SupportsQRESYNC() || caps.SupportsUTF8ACCEPT() {
+		t.Error("QRESYNC/UTF8=ACCEPT reported without being advertised")
+	}
+}
+
 func TestCapabilities_GetAuthMechanisms(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -316,6 +393,36 @@ func TestCapabilities_SelectBestAuthMechanism(t *testing.T) {
 			hasAccessToken: false,
 			expected:       "LOGIN",
 		},
+		{
+			name:           "OAUTHBEARER with token when XOAUTH2 absent",
+			caps:           []string{"AUTH=PLAIN", "AUTH=OAUTHBEARER"},
+			hasAccessToken: true,
+			expected:       "OAUTHBEARER",
+		},
+		{
+			name:           "OAUTHBEARER ignored without token",
+			caps:           []string{"AUTH=PLAIN", "AUTH=OAUTHBEARER"},
+			hasAccessToken: false,
+			expected:       "PLAIN",
+		},
+		{
+			name:           "Exchange integrated auth prefers GSSAPI",
+			caps:           []string{"AUTH=NTLM", "AUTH=GSSAPI", "AUTH=PLAIN"},
+			hasAccessToken: false,
+			expected:       "GSSAPI",
+		},
+		{
+			name:           "NTLM over PLAIN",
+			caps:           []string{"AUTH=PLAIN", "AUTH=NTLM"},
+			hasAccessToken: false,
+			expected:       "NTLM",
+		},
+		{
+			name:           "nothing usable when LOGIN disabled",
+			caps:           []string{"LOGINDISABLED"},
+			hasAccessToken: false,
+			expected:       "",
+		},
 	}
 
 	for _, tt := range tests {
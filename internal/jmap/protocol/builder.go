@@ -0,0 +1,194 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Builder builds a request of several method calls. Calls get the call ids
+// "0", "1", ... in the order they are added, and a call can take arguments
+// from the results of earlier calls with ResultReferences (RFC 8620
+// Section 3.7):
+//
+//	b := NewBuilder(MailCapability)
+//	query := b.Add(MethodEmailQuery, QueryRequest{AccountId: accountId})
+//	get := b.AddWithRefs(MethodEmailGet, GetRequest{AccountId: accountId}, Refs{"ids": query.Ref("/ids")})
+//
+// After sending b.Request(), b.Results(response) returns the results by
+// call.
+type Builder struct {
+	using []string
+	calls []MethodCall
+}
+
+// Call identifies a method call added to a Builder.
+type Call struct {
+	Name   string
+	CallId string
+}
+
+// Refs maps argument names to the results they are taken from. Each is sent
+// as the argument name prefixed with "#", replacing the plain argument.
+type Refs map[string]ResultReference
+
+// NewBuilder creates a Builder for a request using the core capability and
+// the given ones.
+func NewBuilder(capabilities ...string) *Builder {
+	b := &Builder{using: []string{CoreCapability}}
+	return b.Use(capabilities...)
+}
+
+// Use adds capabilities to the request's using list. Each is listed once.
+func (b *Builder) Use(capabilities ...string) *Builder {
+	for _, capability := range capabilities {
+		used := false
+		for _, u := range b.using {
+			if u == capability {
+				used = true
+				break
+			}
+		}
+		if !used {
+			b.using = append(b.using, capability)
+		}
+	}
+	return b
+}
+
+// Add appends a method call with the given arguments.
+func (b *Builder) Add(name string, arguments interface{}) Call {
+	return b.AddWithRefs(name, arguments, nil)
+}
+
+// AddWithRefs appends a method call whose arguments named in refs are taken
+// from the results of earlier calls.
+func (b *Builder) AddWithRefs(name string, arguments interface{}, refs Refs) Call {
+	call := Call{Name: name, CallId: strconv.Itoa(len(b.calls))}
+	if len(refs) > 0 {
+		arguments = referencedArguments{arguments: arguments, refs: refs}
+	}
+	b.calls = append(b.calls, MethodCall{Name: name, Arguments: arguments, CallId: call.CallId})
+	return call
+}
+
+// Len returns the number of method calls added.
+func (b *Builder) Len() int {
+	return len(b.calls)
+}
+
+// Ref returns a reference to the value at path in the result of c. The path
+// is a JSON Pointer in which "*" stands for every item of an array, e.g.
+// "/ids" or "/list/*/threadId".
+func (c Call) Ref(path string) ResultReference {
+	return ResultReference{ResultOf: c.CallId, Name: c.Name, Path: path}
+}
+
+// Request returns the built request.
+func (b *Builder) Request() *Request {
+	return &Request{
+		Using:       append([]string(nil), b.using...),
+		MethodCalls: append([]MethodCall(nil), b.calls...),
+	}
+}
+
+// Results returns the results of response, the server's response to the
+// built request.
+func (b *Builder) Results(response *Response) *Results {
+	return b.Request().Results(response)
+}
+
+// Call returns the i-th method call of r, for looking up its result.
+func (r *Request) Call(i int) Call {
+	return Call{Name: r.MethodCalls[i].Name, CallId: r.MethodCalls[i].CallId}
+}
+
+// Results returns the results of response, the server's response to r.
+func (r *Request) Results(response *Response) *Results {
+	methods := make(map[string]string, len(r.MethodCalls))
+	for _, call := range r.MethodCalls {
+		methods[call.CallId] = call.Name
+	}
+	return &Results{Response: response, methods: methods}
+}
+
+// referencedArguments are method arguments some of which are taken from
+// earlier results.
+type referencedArguments struct {
+	arguments interface{}
+	refs      Refs
+}
+
+// MarshalJSON marshals the arguments, which must marshal to a JSON object,
+// with each referenced argument replaced by its "#"-prefixed reference.
+func (a referencedArguments) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{})
+	if a.arguments != nil {
+		data, err := json.Marshal(a.arguments)
+		if err != nil {
+			return nil, err
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, fmt.Errorf("arguments with references must be a JSON object: %w", err)
+		}
+		for name, value := range object {
+			fields[name] = value
+		}
+	}
+	for name, ref := range a.refs {
+		delete(fields, name)
+		fields["#"+name] = ref
+	}
+	return json.Marshal(fields)
+}
+
+// Results holds the responses to a request by method call.
+type Results struct {
+	Response *Response
+	methods  map[string]string // method names by call id
+}
+
+// Get unmarshals the result of call into v. A method error response to the
+// call is returned as an *Error.
+func (r *Results) Get(call Call, v interface{}) error {
+	resp, err := r.Find(call)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.Arguments, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", call.Name, err)
+	}
+	return nil
+}
+
+// Find returns the response to call. A method error response to the call
+// is returned as an *Error, and a call the server did not answer is an
+// error too.
+func (r *Results) Find(call Call) (*MethodResponse, error) {
+	for i := range r.Response.MethodResponses {
+		resp := &r.Response.MethodResponses[i]
+		if resp.CallId != call.CallId {
+			continue
+		}
+		if IsErrorResponse(resp.Name) {
+			return nil, ParseError(resp, call.Name)
+		}
+		if resp.Name == call.Name {
+			return resp, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s response to call %s in %d method response(s)", call.Name, call.CallId, len(r.Response.MethodResponses))
+}
+
+// Errors returns the method errors among the responses, in response order.
+func (r *Results) Errors() []*Error {
+	var errs []*Error
+	for i := range r.Response.MethodResponses {
+		resp := &r.Response.MethodResponses[i]
+		if IsErrorResponse(resp.Name) {
+			errs = append(errs, ParseError(resp, r.methods[resp.CallId]))
+		}
+	}
+	return errs
+}
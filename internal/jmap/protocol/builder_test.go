@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(MailCapability, SubmissionCapability).Use(MailCapability, CoreCapability)
+	query := b.Add(MethodEmailQuery, QueryRequest{AccountId: "A1"})
+	get := b.AddWithRefs(MethodEmailGet, GetRequest{AccountId: "A1", Ids: []Id{"ignored"}, Properties: []string{"threadId"}},
+		Refs{"ids": query.Ref("/ids")})
+	threads := b.AddWithRefs("Thread/get", GetRequest{AccountId: "A1"}, Refs{"ids": get.Ref("/list/*/threadId")})
+
+	if query.CallId != "0" || get.CallId != "1" || threads.CallId != "2" || b.Len() != 3 {
+		t.Errorf("call ids = %s, %s, %s, want 0, 1, 2", query.CallId, get.CallId, threads.CallId)
+	}
+
+	req := b.Request()
+	if got := strings.Join(req.Using, " "); got != CoreCapability+" "+MailCapability+" "+SubmissionCapability {
+		t.Errorf("Using = %s, want core, mail and submission once each", got)
+	}
+
+	data, err := json.Marshal(req.MethodCalls[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `["Email/get",{"#ids":{"resultOf":"0","name":"Email/query","path":"/ids"},"accountId":"A1","properties":["threadId"]},"1"]`
+	if string(data) != want {
+		t.Errorf("Email/get call = %s, want %s", data, want)
+	}
+
+	data, _ = json.Marshal(req.MethodCalls[2].Arguments)
+	if !strings.Contains(string(data), `"#ids":{"resultOf":"1","name":"Email/get","path":"/list/*/threadId"}`) {
+		t.Errorf("Thread/get arguments = %s, want a reference to the Email/get threadIds", data)
+	}
+
+	// Calls added after Request are not in the returned request
+	b.Add(MethodMailboxGet, GetRequest{AccountId: "A1"})
+	if len(req.MethodCalls) != 3 {
+		t.Errorf("MethodCalls length = %d, want 3", len(req.MethodCalls))
+	}
+}
+
+func TestBuilder_RefsNeedObjectArguments(t *testing.T) {
+	b := NewBuilder()
+	query := b.Add(MethodEmailQuery, QueryRequest{AccountId: "A1"})
+	b.AddWithRefs(MethodEmailGet, []string{"not", "an", "object"}, Refs{"ids": query.Ref("/ids")})
+
+	if _, err := json.Marshal(b.Request()); err == nil {
+		t.Error("Marshal() accepted references on array arguments")
+	}
+}
+
+func TestResults(t *testing.T) {
+	b := NewBuilder(MailCapability)
+	mailboxes := b.Add(MethodMailboxGet, GetRequest{AccountId: "A1"})
+	query := b.Add(MethodEmailQuery, QueryRequest{AccountId: "A1"})
+	get := b.AddWithRefs(MethodEmailGet, GetRequest{AccountId: "A1"}, Refs{"ids": query.Ref("/ids")})
+	unanswered := b.Add(MethodIdentityGet, GetRequest{AccountId: "A1"})
+
+	var response Response
+	if err := json.Unmarshal([]byte(`{"methodResponses":[
+		["Mailbox/get",{"accountId":"A1","state":"m1","list":[{"id":"MB1","name":"Inbox"}]},"0"],
+		["error",{"type":"unsupportedFilter","description":"no such filter"},"1"],
+		["error",{"type":"invalidResultReference"},"2"]
+	],"sessionState":"s1"}`), &response); err != nil {
+		t.Fatal(err)
+	}
+	results := b.Results(&response)
+
+	var got GetMailboxesResponse
+	if err := results.Get(mailboxes, &got); err != nil {
+		t.Fatalf("Get(Mailbox/get) error: %v", err)
+	}
+	if got.State != "m1" || len(got.List) != 1 || got.List[0].Name != "Inbox" {
+		t.Errorf("Mailbox/get result = %+v", got)
+	}
+
+	err := results.Get(query, &QueryEmailsResponse{})
+	var methodErr *Error
+	if !errors.As(err, &methodErr) || methodErr.Type != "unsupportedFilter" || methodErr.Method != MethodEmailQuery || methodErr.CallId != "1" {
+		t.Errorf("Get(Email/query) error = %v, want an unsupportedFilter *Error", err)
+	}
+	if err.Error() != "Email/query error: unsupportedFilter (no such filter)" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if _, err := results.Find(get); err == nil || err.Error() != "Email/get error: invalidResultReference" {
+		t.Errorf("Find(Email/get) error = %v, want invalidResultReference", err)
+	}
+	if _, err := results.Find(unanswered); err == nil || errors.As(err, &methodErr) {
+		t.Errorf("Find(Identity/get) error = %v, want a missing response", err)
+	}
+
+	errs := results.Errors()
+	if len(errs) != 2 || errs[0].Method != MethodEmailQuery || errs[1].Method != MethodEmailGet {
+		t.Errorf("Errors() = %v, want the Email/query and Email/get errors", errs)
+	}
+}
+
+func TestRequestResults(t *testing.T) {
+	request := NewChangesGetRequest("Email", "A1", "s1", 0, nil)
+	changes, created := request.Call(0), request.Call(1)
+	if changes != (Call{Name: "Email/changes", CallId: "0"}) || created != (Call{Name: MethodEmailGet, CallId: "1"}) {
+		t.Fatalf("Call(0), Call(1) = %+v, %+v", changes, created)
+	}
+
+	var response Response
+	if err := json.Unmarshal([]byte(`{"methodResponses":[
+		["error",{"type":"cannotCalculateChanges"},"0"],
+		["error",{"type":"invalidResultReference"},"1"]
+	],"sessionState":"s1"}`), &response); err != nil {
+		t.Fatal(err)
+	}
+	results := request.Results(&response)
+
+	err := results.Get(changes, &ChangesResponse{})
+	var methodErr *Error
+	if !errors.As(err, &methodErr) || methodErr.Type != "cannotCalculateChanges" || methodErr.Method != "Email/changes" {
+		t.Errorf("Get(Email/changes) error = %v, want a cannotCalculateChanges *Error", err)
+	}
+	if errs := results.Errors(); len(errs) != 2 || errs[1].Method != MethodEmailGet {
+		t.Errorf("Errors() = %v, want the Email/changes and Email/get errors", errs)
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 // Request represents a JMAP API request.
@@ -89,10 +90,34 @@ func (m *MethodResponse) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// Error represents a JMAP method-level error.
+// Error represents a JMAP method-level error (RFC 8620 Section 3.6.2).
 type Error struct {
 	Type        string `json:"type"`
 	Description string `json:"description,omitempty"`
+
+	// Method and CallId identify the call that failed; they are not part
+	// of the error response.
+	Method string `json:"-"`
+	CallId string `json:"-"`
+}
+
+// Error implements the error interface, naming the method that failed.
+func (e *Error) Error() string {
+	method := e.Method
+	if method == "" {
+		method = "method"
+	}
+	if e.Description != "" {
+		return fmt.Sprintf("%s error: %s (%s)", method, e.Type, e.Description)
+	}
+	return fmt.Sprintf("%s error: %s", method, e.Type)
+}
+
+// ParseError parses an error response to a call of method.
+func ParseError(resp *MethodResponse, method string) *Error {
+	e := &Error{Method: method, CallId: resp.CallId}
+	_ = json.Unmarshal(resp.Arguments, e)
+	return e
 }
 
 // Common capability URIs.
@@ -100,8 +125,16 @@ const (
 	CoreCapability    = "urn:ietf:params:jmap:core"
 	MailCapability    = "urn:ietf:params:jmap:mail"
 	SubmissionCapability = "urn:ietf:params:jmap:submission"
+	WebSocketCapability = "urn:ietf:params:jmap:websocket"
+	VacationResponseCapability = "urn:ietf:params:jmap:vacationresponse"
+	QuotaCapability = "urn:ietf:params:jmap:quota"
+	SieveCapability = "urn:ietf:params:jmap:sieve"
 )
 
+// ErrorLimit is the problem type of a request or upload that exceeded a
+// server limit such as maxSizeUpload (RFC 8620 Section 3.6.1).
+const ErrorLimit = "urn:ietf:params:jmap:error:limit"
+
 // Common method names.
 const (
 	MethodMailboxGet   = "Mailbox/get"
@@ -109,6 +142,18 @@ const (
 	MethodEmailGet     = "Email/get"
 	MethodEmailQuery   = "Email/query"
 	MethodEmailSet     = "Email/set"
+	MethodEmailImport  = "Email/import"
+
+	MethodMailboxChanges = "Mailbox/changes"
+	MethodEmailChanges   = "Email/changes"
+
+	MethodIdentityGet        = "Identity/get"
+	MethodEmailSubmissionGet = "EmailSubmission/get"
+	MethodEmailSubmissionSet = "EmailSubmission/set"
+
+	MethodVacationResponseGet = "VacationResponse/get"
+	MethodVacationResponseSet = "VacationResponse/set"
+	MethodQuotaGet            = "Quota/get"
 )
 
 // GetRequest creates arguments for a /get method.
@@ -210,3 +255,316 @@ func ParseEmailQueryResponse(resp *MethodResponse) (*QueryEmailsResponse, error)
 func IsErrorResponse(name string) bool {
 	return name == "error"
 }
+
+// ResultReference refers to a value in the result of an earlier method call
+// in the same request. See RFC 8620 Section 3.7.
+type ResultReference struct {
+	ResultOf string `json:"resultOf"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+}
+
+// GetByReferenceRequest creates arguments for a /get method whose ids come
+// from an earlier method call.
+type GetByReferenceRequest struct {
+	AccountId  Id              `json:"accountId"`
+	IdsRef     ResultReference `json:"#ids"`
+	Properties []string        `json:"properties,omitempty"`
+}
+
+// NewEmailQueryGetRequest creates a request that queries emails and fetches
+// the matching ones in the same round trip: Email/get takes its ids from
+// the Email/query result.
+func NewEmailQueryGetRequest(accountId Id, filter interface{}, sort []SortOrder, limit uint32, properties []string) *Request {
+	b := NewBuilder(MailCapability)
+	query := b.Add(MethodEmailQuery, QueryRequest{
+		AccountId:      accountId,
+		Filter:         filter,
+		Sort:           sort,
+		Limit:          &limit,
+		CalculateTotal: true,
+	})
+	b.Add(MethodEmailGet, GetByReferenceRequest{
+		AccountId:  accountId,
+		IdsRef:     query.Ref("/ids"),
+		Properties: properties,
+	})
+	return b.Request()
+}
+
+// ParseEmailGetResponse parses an Email/get response.
+func ParseEmailGetResponse(resp *MethodResponse) (*GetEmailsResponse, error) {
+	var result GetEmailsResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SetRequest creates arguments for a /set method that creates objects,
+// keyed by creation id, and destroys objects by id.
+type SetRequest struct {
+	AccountId Id                     `json:"accountId"`
+	Create    map[string]interface{} `json:"create,omitempty"`
+	Destroy   []Id                   `json:"destroy,omitempty"`
+}
+
+// EmailSubmissionSetRequest creates arguments for EmailSubmission/set. The
+// onSuccessUpdateEmail patches apply to the email of each submission that
+// was created, keyed by "#" + creation id (RFC 8621 Section 7.5).
+type EmailSubmissionSetRequest struct {
+	AccountId            Id                                `json:"accountId"`
+	Create               map[string]EmailSubmissionCreate  `json:"create,omitempty"`
+	OnSuccessUpdateEmail map[string]map[string]interface{} `json:"onSuccessUpdateEmail,omitempty"`
+}
+
+// NewEmailSubmitRequest creates a request that creates email in the drafts
+// mailbox and submits it with identityId in the same round trip. When the
+// submission succeeds, the email is moved from drafts to sent and loses
+// its $draft keyword. An empty sentId leaves the email in drafts.
+func NewEmailSubmitRequest(accountId Id, email EmailCreate, identityId Id, envelope *Envelope, draftsId, sentId Id) *Request {
+	email.MailboxIds = map[Id]bool{draftsId: true}
+	email.Keywords = map[string]bool{"$draft": true, "$seen": true}
+
+	update := map[string]interface{}{"keywords/$draft": nil}
+	if sentId != "" {
+		update["mailboxIds/"+string(draftsId)] = nil
+		update["mailboxIds/"+string(sentId)] = true
+	}
+
+	b := NewBuilder(MailCapability, SubmissionCapability)
+	b.Add(MethodEmailSet, SetRequest{
+		AccountId: accountId,
+		Create:    map[string]interface{}{"draft": email},
+	})
+	b.Add(MethodEmailSubmissionSet, EmailSubmissionSetRequest{
+		AccountId: accountId,
+		Create: map[string]EmailSubmissionCreate{
+			"send": {IdentityId: identityId, EmailId: "#draft", Envelope: envelope},
+		},
+		OnSuccessUpdateEmail: map[string]map[string]interface{}{"#send": update},
+	})
+	return b.Request()
+}
+
+// NewIdentityGetRequest creates a request to get all sending identities.
+func NewIdentityGetRequest(accountId Id) *Request {
+	return &Request{
+		Using: []string{CoreCapability, SubmissionCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      MethodIdentityGet,
+				Arguments: GetRequest{AccountId: accountId},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// NewEmailSubmissionGetRequest creates a request to get email submissions.
+func NewEmailSubmissionGetRequest(accountId Id, ids []Id) *Request {
+	return &Request{
+		Using: []string{CoreCapability, SubmissionCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      MethodEmailSubmissionGet,
+				Arguments: GetRequest{AccountId: accountId, Ids: ids},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// ParseSetResponse parses a /set response.
+func ParseSetResponse(resp *MethodResponse) (*SetResponse, error) {
+	var result SetResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ChangesRequest creates arguments for a /changes method.
+// See RFC 8620 Section 5.2.
+type ChangesRequest struct {
+	AccountId  Id     `json:"accountId"`
+	SinceState string `json:"sinceState"`
+	MaxChanges uint32 `json:"maxChanges,omitempty"`
+}
+
+// NewStateRequest creates a request that fetches the current state of each
+// data type with a /get call for no ids: "Email" calls Email/get, and so on.
+// Call ids are the indexes of types.
+func NewStateRequest(accountId Id, types ...string) *Request {
+	b := NewBuilder(MailCapability)
+	for _, dataType := range types {
+		b.Add(dataType+"/get", map[string]interface{}{"accountId": accountId, "ids": []Id{}})
+	}
+	return b.Request()
+}
+
+// NewChangesRequest creates a request for the ids that changed in dataType
+// (e.g. "Email") since sinceState, without fetching the objects.
+func NewChangesRequest(dataType string, accountId Id, sinceState string, maxChanges uint32) *Request {
+	return &Request{
+		Using: []string{CoreCapability, MailCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      dataType + "/changes",
+				Arguments: ChangesRequest{AccountId: accountId, SinceState: sinceState, MaxChanges: maxChanges},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// NewEmailIdsRequest creates a request for one page of the ids of all
+// emails: call "0" is Email/get for no ids, which returns the Email state,
+// and call "1" is Email/query for up to limit ids after anchor, or from the
+// start when anchor is nil, with the total count. Paging by anchor rather than position keeps a
+// page from skipping ids when emails are destroyed between pages.
+func NewEmailIdsRequest(accountId Id, anchor *Id, limit uint32) *Request {
+	query := QueryRequest{AccountId: accountId, Limit: &limit, CalculateTotal: true}
+	if anchor != nil {
+		query.Anchor = anchor
+		query.AnchorOffset = 1
+	}
+	b := NewBuilder(MailCapability)
+	b.Add(MethodEmailGet, map[string]interface{}{"accountId": accountId, "ids": []Id{}})
+	b.Add(MethodEmailQuery, query)
+	return b.Request()
+}
+
+// NewChangesGetRequest creates a request for the changes to dataType (e.g.
+// "Email") since sinceState, fetching the created and updated objects in
+// the same round trip: call "0" is dataType/changes, call "1" gets the
+// created objects and call "2" the updated ones.
+func NewChangesGetRequest(dataType string, accountId Id, sinceState string, maxChanges uint32, properties []string) *Request {
+	b := NewBuilder(MailCapability)
+	changes := b.Add(dataType+"/changes", ChangesRequest{AccountId: accountId, SinceState: sinceState, MaxChanges: maxChanges})
+	for _, path := range []string{"/created", "/updated"} {
+		b.Add(dataType+"/get", GetByReferenceRequest{
+			AccountId:  accountId,
+			IdsRef:     changes.Ref(path),
+			Properties: properties,
+		})
+	}
+	return b.Request()
+}
+
+// ParseChangesResponse parses a /changes response.
+func ParseChangesResponse(resp *MethodResponse) (*ChangesResponse, error) {
+	var result ChangesResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NewEmailImportRequest creates a request that imports uploaded messages
+// with Email/import, keyed by creation id.
+func NewEmailImportRequest(accountId Id, emails map[string]EmailImport) *Request {
+	return &Request{
+		Using: []string{CoreCapability, MailCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      MethodEmailImport,
+				Arguments: map[string]interface{}{"accountId": accountId, "emails": emails},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// ParseEmailImportResponse parses an Email/import response.
+func ParseEmailImportResponse(resp *MethodResponse) (*EmailImportResponse, error) {
+	var result EmailImportResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ParseProblemDetails parses a request-level error body. It reports false
+// if data is not problem details.
+func ParseProblemDetails(data []byte) (*ProblemDetails, bool) {
+	var problem ProblemDetails
+	if err := json.Unmarshal(data, &problem); err != nil || problem.Type == "" {
+		return nil, false
+	}
+	return &problem, true
+}
+
+// ParseIdentityGetResponse parses an Identity/get response.
+func ParseIdentityGetResponse(resp *MethodResponse) (*GetIdentitiesResponse, error) {
+	var result GetIdentitiesResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NewVacationResponseGetRequest creates a request for the account's
+// VacationResponse.
+func NewVacationResponseGetRequest(accountId Id) *Request {
+	return &Request{
+		Using: []string{CoreCapability, VacationResponseCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      MethodVacationResponseGet,
+				Arguments: GetRequest{AccountId: accountId},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// NewVacationResponseSetRequest creates a request that updates the
+// account's VacationResponse, the "singleton", with update.
+func NewVacationResponseSetRequest(accountId Id, update VacationResponseUpdate) *Request {
+	return &Request{
+		Using: []string{CoreCapability, VacationResponseCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name: MethodVacationResponseSet,
+				Arguments: map[string]interface{}{
+					"accountId": accountId,
+					"update":    map[Id]VacationResponseUpdate{"singleton": update},
+				},
+				CallId: "0",
+			},
+		},
+	}
+}
+
+// ParseVacationResponseGetResponse parses a VacationResponse/get response.
+func ParseVacationResponseGetResponse(resp *MethodResponse) (*GetVacationResponseResponse, error) {
+	var result GetVacationResponseResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// NewQuotaGetRequest creates a request for all quotas of an account.
+func NewQuotaGetRequest(accountId Id) *Request {
+	return &Request{
+		Using: []string{CoreCapability, QuotaCapability},
+		MethodCalls: []MethodCall{
+			{
+				Name:      MethodQuotaGet,
+				Arguments: GetRequest{AccountId: accountId},
+				CallId:    "0",
+			},
+		},
+	}
+}
+
+// ParseQuotaGetResponse parses a Quota/get response.
+func ParseQuotaGetResponse(resp *MethodResponse) (*GetQuotasResponse, error) {
+	var result GetQuotasResponse
+	if err := json.Unmarshal(resp.Arguments, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
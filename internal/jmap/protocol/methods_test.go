@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -257,6 +258,246 @@ func TestParseEmailQueryResponse(t *testing.T) {
 	}
 }
 
+func TestNewEmailQueryGetRequest(t *testing.T) {
+	filter := EmailFilterCondition{InMailbox: "mb1", HasKeyword: "$flagged"}
+	sort := []SortOrder{{Property: "receivedAt", IsAscending: false}}
+	req := NewEmailQueryGetRequest("A123", filter, sort, 20, []string{"id", "subject"})
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+
+	var decoded struct {
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if len(decoded.MethodCalls) != 2 {
+		t.Fatalf("MethodCalls length = %d, want 2", len(decoded.MethodCalls))
+	}
+
+	var query map[string]interface{}
+	if err := json.Unmarshal(decoded.MethodCalls[0][1], &query); err != nil {
+		t.Fatalf("Unmarshal query arguments: %v", err)
+	}
+	if query["limit"] != float64(20) {
+		t.Errorf("limit = %v, want 20", query["limit"])
+	}
+	if f, _ := query["filter"].(map[string]interface{}); f["inMailbox"] != "mb1" || f["hasKeyword"] != "$flagged" || len(f) != 2 {
+		t.Errorf("filter = %v, want inMailbox and hasKeyword only", query["filter"])
+	}
+
+	var get struct {
+		Ids        []Id            `json:"ids"`
+		IdsRef     ResultReference `json:"#ids"`
+		Properties []string        `json:"properties"`
+	}
+	if err := json.Unmarshal(decoded.MethodCalls[1][1], &get); err != nil {
+		t.Fatalf("Unmarshal get arguments: %v", err)
+	}
+	want := ResultReference{ResultOf: "0", Name: MethodEmailQuery, Path: "/ids"}
+	if get.IdsRef != want {
+		t.Errorf("#ids = %+v, want %+v", get.IdsRef, want)
+	}
+	if get.Ids != nil {
+		t.Errorf("ids = %v, want only the #ids reference", get.Ids)
+	}
+	if len(get.Properties) != 2 {
+		t.Errorf("properties = %v, want [id subject]", get.Properties)
+	}
+}
+
+func TestParseEmailGetResponse(t *testing.T) {
+	mr := &MethodResponse{
+		Name:      "Email/get",
+		Arguments: json.RawMessage(`{"accountId": "A123", "state": "s1", "list": [{"id": "e1", "blobId": "b1", "subject": "Hello"}], "notFound": ["e2"]}`),
+		CallId:    "1",
+	}
+
+	result, err := ParseEmailGetResponse(mr)
+	if err != nil {
+		t.Fatalf("ParseEmailGetResponse() error: %v", err)
+	}
+	if len(result.List) != 1 || result.List[0].BlobId != "b1" || result.List[0].Subject != "Hello" {
+		t.Errorf("List = %+v, want e1 with blob b1", result.List)
+	}
+	if len(result.NotFound) != 1 {
+		t.Errorf("NotFound = %v, want [e2]", result.NotFound)
+	}
+}
+
+func TestNewEmailSubmitRequest(t *testing.T) {
+	email := EmailCreate{
+		From:       []EmailAddress{{Email: "me@example.com"}},
+		To:         []EmailAddress{{Email: "you@example.com"}},
+		Subject:    "Hello",
+		TextBody:   []EmailBodyPart{{PartId: "text", Type: "text/plain"}},
+		BodyValues: map[string]EmailBodyValue{"text": {Value: "Hi"}},
+	}
+	envelope := &Envelope{MailFrom: Address{Email: "me@example.com"}, RcptTo: []Address{{Email: "you@example.com"}}}
+	req := NewEmailSubmitRequest("A123", email, "I1", envelope, "drafts", "sent")
+
+	if len(req.Using) != 3 || req.Using[2] != SubmissionCapability {
+		t.Errorf("Using = %v, want core, mail and submission", req.Using)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	var decoded struct {
+		MethodCalls [][]json.RawMessage `json:"methodCalls"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if len(decoded.MethodCalls) != 2 {
+		t.Fatalf("MethodCalls length = %d, want 2", len(decoded.MethodCalls))
+	}
+
+	var set struct {
+		Create map[string]struct {
+			MailboxIds map[string]bool `json:"mailboxIds"`
+			Keywords   map[string]bool `json:"keywords"`
+		} `json:"create"`
+	}
+	if err := json.Unmarshal(decoded.MethodCalls[0][1], &set); err != nil {
+		t.Fatalf("Unmarshal Email/set arguments: %v", err)
+	}
+	draft := set.Create["draft"]
+	if !draft.MailboxIds["drafts"] || len(draft.MailboxIds) != 1 || !draft.Keywords["$draft"] {
+		t.Errorf("draft = %+v, want it in drafts with $draft", draft)
+	}
+
+	var submission struct {
+		Create               map[string]EmailSubmissionCreate  `json:"create"`
+		OnSuccessUpdateEmail map[string]map[string]interface{} `json:"onSuccessUpdateEmail"`
+	}
+	if err := json.Unmarshal(decoded.MethodCalls[1][1], &submission); err != nil {
+		t.Fatalf("Unmarshal EmailSubmission/set arguments: %v", err)
+	}
+	send := submission.Create["send"]
+	if send.EmailId != "#draft" || send.IdentityId != "I1" || send.Envelope == nil || len(send.Envelope.RcptTo) != 1 {
+		t.Errorf("submission = %+v, want #draft with identity I1 and the envelope", send)
+	}
+	update := submission.OnSuccessUpdateEmail["#send"]
+	if v, ok := update["mailboxIds/drafts"]; !ok || v != nil {
+		t.Errorf("update = %v, want mailboxIds/drafts removed", update)
+	}
+	if update["mailboxIds/sent"] != true {
+		t.Errorf("update = %v, want mailboxIds/sent added", update)
+	}
+	if v, ok := update["keywords/$draft"]; !ok || v != nil {
+		t.Errorf("update = %v, want keywords/$draft removed", update)
+	}
+
+	// Without a Sent mailbox the email stays in drafts
+	noSent := NewEmailSubmitRequest("A123", email, "I1", nil, "drafts", "")
+	update = noSent.MethodCalls[1].Arguments.(EmailSubmissionSetRequest).OnSuccessUpdateEmail["#send"]
+	if len(update) != 1 {
+		t.Errorf("update = %v, want only keywords/$draft without a sent mailbox", update)
+	}
+}
+
+func TestParseSetResponse(t *testing.T) {
+	mr := &MethodResponse{
+		Name: "EmailSubmission/set",
+		Arguments: json.RawMessage(`{
+			"accountId": "A123",
+			"newState": "s2",
+			"created": {"send": {"id": "S1", "undoStatus": "pending"}},
+			"notCreated": {"other": {"type": "forbiddenFrom", "description": "not your address"}}
+		}`),
+		CallId: "1",
+	}
+
+	result, err := ParseSetResponse(mr)
+	if err != nil {
+		t.Fatalf("ParseSetResponse() error: %v", err)
+	}
+	var created EmailSubmission
+	if err := json.Unmarshal(result.Created["send"], &created); err != nil || created.Id != "S1" || created.UndoStatus != "pending" {
+		t.Errorf("Created[send] = %s, want S1 pending", result.Created["send"])
+	}
+	if result.NotCreated["other"].Type != "forbiddenFrom" {
+		t.Errorf("NotCreated = %+v, want forbiddenFrom", result.NotCreated)
+	}
+}
+
+func TestNewStateRequest(t *testing.T) {
+	data, err := json.Marshal(NewStateRequest("A1", "Email", "Mailbox"))
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+
+	want := `"methodCalls":[["Email/get",{"accountId":"A1","ids":[]},"0"],["Mailbox/get",{"accountId":"A1","ids":[]},"1"]]`
+	if !strings.Contains(string(data), want) {
+		t.Errorf("request = %s, want it to contain %s", data, want)
+	}
+}
+
+func TestNewChangesGetRequest(t *testing.T) {
+	req := NewChangesGetRequest("Email", "A1", "s1", 50, []string{"id", "subject"})
+	if len(req.MethodCalls) != 3 {
+		t.Fatalf("MethodCalls length = %d, want 3", len(req.MethodCalls))
+	}
+
+	changes, ok := req.MethodCalls[0].Arguments.(ChangesRequest)
+	if !ok || req.MethodCalls[0].Name != MethodEmailChanges {
+		t.Fatalf("call 0 = %s %T, want Email/changes", req.MethodCalls[0].Name, req.MethodCalls[0].Arguments)
+	}
+	if changes.SinceState != "s1" || changes.MaxChanges != 50 {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	for i, path := range []string{"/created", "/updated"} {
+		call := req.MethodCalls[i+1]
+		get, ok := call.Arguments.(GetByReferenceRequest)
+		if !ok || call.Name != MethodEmailGet {
+			t.Fatalf("call %d = %s %T, want Email/get by reference", i+1, call.Name, call.Arguments)
+		}
+		want := ResultReference{ResultOf: "0", Name: MethodEmailChanges, Path: path}
+		if get.IdsRef != want {
+			t.Errorf("call %d #ids = %+v, want %+v", i+1, get.IdsRef, want)
+		}
+	}
+}
+
+func TestNewEmailIdsRequest(t *testing.T) {
+	first := NewEmailIdsRequest("A1", nil, 500)
+	if len(first.MethodCalls) != 2 || first.MethodCalls[0].Name != MethodEmailGet || first.MethodCalls[1].Name != MethodEmailQuery {
+		t.Fatalf("MethodCalls = %+v, want Email/get and Email/query", first.MethodCalls)
+	}
+	query := first.MethodCalls[1].Arguments.(QueryRequest)
+	if query.Anchor != nil || query.Limit == nil || *query.Limit != 500 {
+		t.Errorf("first page query = %+v, want no anchor and limit 500", query)
+	}
+
+	anchor := Id("E500")
+	next := NewEmailIdsRequest("A1", &anchor, 500)
+	query = next.MethodCalls[1].Arguments.(QueryRequest)
+	if query.Anchor == nil || *query.Anchor != "E500" || query.AnchorOffset != 1 {
+		t.Errorf("next page query = %+v, want anchor E500 with offset 1", query)
+	}
+}
+
+func TestParseChangesResponse(t *testing.T) {
+	resp := &MethodResponse{
+		Name:      MethodMailboxChanges,
+		Arguments: json.RawMessage(`{"accountId":"A1","oldState":"s1","newState":"s2","hasMoreChanges":true,"created":["M3"],"updated":[],"destroyed":["M1"]}`),
+	}
+
+	result, err := ParseChangesResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseChangesResponse() error: %v", err)
+	}
+	if result.NewState != "s2" || !result.HasMoreChanges || len(result.Created) != 1 || len(result.Destroyed) != 1 {
+		t.Errorf("ParseChangesResponse() = %+v", result)
+	}
+}
+
 func TestIsErrorResponse(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -284,9 +525,21 @@ func TestConstants(t *testing.T) {
 	if MailCapability != "urn:ietf:params:jmap:mail" {
 		t.Errorf("MailCapability = %q, want %q", MailCapability, "urn:ietf:params:jmap:mail")
 	}
+	if WebSocketCapability != "urn:ietf:params:jmap:websocket" {
+		t.Errorf("WebSocketCapability = %q, want %q", WebSocketCapability, "urn:ietf:params:jmap:websocket")
+	}
 	if SubmissionCapability != "urn:ietf:params:jmap:submission" {
 		t.Errorf("SubmissionCapability = %q, want %q", SubmissionCapability, "urn:ietf:params:jmap:submission")
 	}
+	if VacationResponseCapability != "urn:ietf:params:jmap:vacationresponse" {
+		t.Errorf("VacationResponseCapability = %q, want %q", VacationResponseCapability, "urn:ietf:params:jmap:vacationresponse")
+	}
+	if QuotaCapability != "urn:ietf:params:jmap:quota" {
+		t.Errorf("QuotaCapability = %q, want %q", QuotaCapability, "urn:ietf:params:jmap:quota")
+	}
+	if SieveCapability != "urn:ietf:params:jmap:sieve" {
+		t.Errorf("SieveCapability = %q, want %q", SieveCapability, "urn:ietf:params:jmap:sieve")
+	}
 
 	// Verify method constants
 	if MethodMailboxGet != "Mailbox/get" {
@@ -296,3 +549,170 @@ func TestConstants(t *testing.T) {
 		t.Errorf("MethodEmailQuery = %q, want %q", MethodEmailQuery, "Email/query")
 	}
 }
+
+func TestNewEmailImportRequest(t *testing.T) {
+	req := NewEmailImportRequest("A1", map[string]EmailImport{
+		"m1": {BlobId: "B1", MailboxIds: map[Id]bool{"MB1": true}, Keywords: map[string]bool{"$seen": true}, ReceivedAt: "2026-01-15T08:00:00Z"},
+	})
+	if len(req.MethodCalls) != 1 || req.MethodCalls[0].Name != MethodEmailImport {
+		t.Fatalf("MethodCalls = %+v, want one Email/import", req.MethodCalls)
+	}
+
+	data, err := json.Marshal(req.MethodCalls[0].Arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"accountId":"A1","emails":{"m1":{"blobId":"B1","mailboxIds":{"MB1":true},"keywords":{"$seen":true},"receivedAt":"2026-01-15T08:00:00Z"}}}`
+	if string(data) != want {
+		t.Errorf("arguments = %s, want %s", data, want)
+	}
+}
+
+func TestParseEmailImportResponse(t *testing.T) {
+	resp := &MethodResponse{
+		Name: MethodEmailImport,
+		Arguments: json.RawMessage(`{"accountId":"A1","newState":"s2",
+			"created":{"m1":{"id":"E1","blobId":"B1","threadId":"T1","size":120}},
+			"notCreated":{"m2":{"type":"alreadyExists","existingId":"E7"}}}`),
+	}
+
+	result, err := ParseEmailImportResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseEmailImportResponse() error: %v", err)
+	}
+	if email := result.Created["m1"]; email.Id != "E1" || email.BlobId != "B1" || email.Size != 120 {
+		t.Errorf("created = %+v", email)
+	}
+	if setErr := result.NotCreated["m2"]; setErr.Type != "alreadyExists" || setErr.ExistingId != "E7" {
+		t.Errorf("notCreated = %+v", setErr)
+	}
+}
+
+func TestParseProblemDetails(t *testing.T) {
+	problem, ok := ParseProblemDetails([]byte(`{"type":"urn:ietf:params:jmap:error:limit","limit":"maxSizeUpload","status":400,"detail":"too big"}`))
+	if !ok || problem.Type != ErrorLimit || problem.Limit != "maxSizeUpload" || problem.Status != 400 {
+		t.Errorf("ParseProblemDetails() = %+v, %v", problem, ok)
+	}
+	if _, ok := ParseProblemDetails([]byte("Internal Server Error")); ok {
+		t.Error("ParseProblemDetails() accepted a plain text body")
+	}
+}
+
+func TestParseIdentityGetResponse(t *testing.T) {
+	resp := &MethodResponse{
+		Name: MethodIdentityGet,
+		Arguments: json.RawMessage(`{"accountId":"A1","state":"i1","list":[
+			{"id":"I1","name":"Alice","email":"alice@example.com","replyTo":[{"email":"support@example.com"}],
+			 "bcc":null,"textSignature":"-- Alice","htmlSignature":"","mayDelete":false}]}`),
+	}
+
+	result, err := ParseIdentityGetResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseIdentityGetResponse() error: %v", err)
+	}
+	if len(result.List) != 1 {
+		t.Fatalf("List length = %d, want 1", len(result.List))
+	}
+	identity := result.List[0]
+	if identity.Id != "I1" || identity.Email != "alice@example.com" || identity.TextSignature != "-- Alice" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if len(identity.ReplyTo) != 1 || identity.ReplyTo[0].Email != "support@example.com" || identity.Bcc != nil {
+		t.Errorf("replyTo = %+v, bcc = %+v", identity.ReplyTo, identity.Bcc)
+	}
+}
+
+func TestNewVacationResponseGetRequest(t *testing.T) {
+	req := NewVacationResponseGetRequest("A1")
+	if len(req.Using) != 2 || req.Using[1] != VacationResponseCapability {
+		t.Errorf("Using = %v, want core and vacationresponse", req.Using)
+	}
+	if len(req.MethodCalls) != 1 || req.MethodCalls[0].Name != MethodVacationResponseGet {
+		t.Errorf("MethodCalls = %+v, want one VacationResponse/get", req.MethodCalls)
+	}
+}
+
+func TestNewVacationResponseSetRequest(t *testing.T) {
+	subject := "Out of office"
+	req := NewVacationResponseSetRequest("A1", VacationResponseUpdate{IsEnabled: true, Subject: &subject})
+	if len(req.MethodCalls) != 1 || req.MethodCalls[0].Name != MethodVacationResponseSet {
+		t.Fatalf("MethodCalls = %+v, want one VacationResponse/set", req.MethodCalls)
+	}
+
+	data, err := json.Marshal(req.MethodCalls[0].Arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"accountId":"A1","update":{"singleton":{"isEnabled":true,"subject":"Out of office"}}}`
+	if string(data) != want {
+		t.Errorf("arguments = %s, want %s", data, want)
+	}
+
+	data, _ = json.Marshal(NewVacationResponseSetRequest("A1", VacationResponseUpdate{}).MethodCalls[0].Arguments)
+	if want := `{"accountId":"A1","update":{"singleton":{"isEnabled":false}}}`; string(data) != want {
+		t.Errorf("disable arguments = %s, want %s", data, want)
+	}
+}
+
+func TestParseVacationResponseGetResponse(t *testing.T) {
+	resp := &MethodResponse{
+		Name: MethodVacationResponseGet,
+		Arguments: json.RawMessage(`{"accountId":"A1","state":"v1","list":[
+			{"id":"singleton","isEnabled":true,"fromDate":"2026-07-01T00:00:00Z","toDate":null,
+			 "subject":"Away","textBody":"Back on Monday","htmlBody":null}],"notFound":[]}`),
+	}
+
+	result, err := ParseVacationResponseGetResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseVacationResponseGetResponse() error: %v", err)
+	}
+	if len(result.List) != 1 {
+		t.Fatalf("List length = %d, want 1", len(result.List))
+	}
+	vacation := result.List[0]
+	if vacation.Id != "singleton" || !vacation.IsEnabled || vacation.Subject == nil || *vacation.Subject != "Away" {
+		t.Errorf("vacation = %+v", vacation)
+	}
+	if vacation.FromDate == nil || *vacation.FromDate != "2026-07-01T00:00:00Z" || vacation.ToDate != nil || vacation.HTMLBody != nil {
+		t.Errorf("vacation dates and bodies = %v, %v, %v", vacation.FromDate, vacation.ToDate, vacation.HTMLBody)
+	}
+}
+
+func TestNewQuotaGetRequest(t *testing.T) {
+	req := NewQuotaGetRequest("A1")
+	if len(req.Using) != 2 || req.Using[1] != QuotaCapability {
+		t.Errorf("Using = %v, want core and quota", req.Using)
+	}
+	if len(req.MethodCalls) != 1 || req.MethodCalls[0].Name != MethodQuotaGet {
+		t.Errorf("MethodCalls = %+v, want one Quota/get", req.MethodCalls)
+	}
+}
+
+func TestParseQuotaGetResponse(t *testing.T) {
+	resp := &MethodResponse{
+		Name: MethodQuotaGet,
+		Arguments: json.RawMessage(`{"accountId":"A1","state":"q1","list":[
+			{"id":"Q1","resourceType":"octets","used":1073741824,"hardLimit":5368709120,"warnLimit":4294967296,
+			 "scope":"account","name":"alice@example.com","types":["Mail","Calendar"]},
+			{"id":"Q2","resourceType":"count","used":10,"hardLimit":1000,"scope":"domain","name":"example.com","types":["Mail"],
+			 "description":"Domain message limit"}],"notFound":[]}`),
+	}
+
+	result, err := ParseQuotaGetResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseQuotaGetResponse() error: %v", err)
+	}
+	if len(result.List) != 2 {
+		t.Fatalf("List length = %d, want 2", len(result.List))
+	}
+	octets := result.List[0]
+	if octets.ResourceType != "octets" || octets.Used != 1073741824 || octets.HardLimit != 5368709120 {
+		t.Errorf("octets quota = %+v", octets)
+	}
+	if octets.WarnLimit == nil || *octets.WarnLimit != 4294967296 || octets.SoftLimit != nil || len(octets.Types) != 2 {
+		t.Errorf("octets limits = %v, %v, types %v", octets.WarnLimit, octets.SoftLimit, octets.Types)
+	}
+	if count := result.List[1]; count.Scope != "domain" || count.Description == nil || *count.Description != "Domain message limit" {
+		t.Errorf("count quota = %+v", count)
+	}
+}
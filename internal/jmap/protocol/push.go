@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StateChange is a push notification that the state of one or more data
+// types changed. Changed maps account ids to data type names (e.g. "Email",
+// "Mailbox") and their new state strings. See RFC 8620 Section 7.1.
+type StateChange struct {
+	Type      string                   `json:"@type"`
+	Changed   map[Id]map[string]string `json:"changed"`
+	PushState string                   `json:"pushState,omitempty"`
+}
+
+// Event is one server-sent event from an EventSource stream.
+type Event struct {
+	Type string // the "event" field; "message" when the server sent none
+	Data string
+	Id   string
+}
+
+// EventReader reads server-sent events (text/event-stream) as used by the
+// JMAP EventSource push channel (RFC 8620 Section 7.3).
+type EventReader struct {
+	r *bufio.Reader
+}
+
+// NewEventReader returns an EventReader that reads events from r.
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event. Comment lines and events without data are
+// skipped; io.EOF is returned when the stream ends.
+func (er *EventReader) Next() (*Event, error) {
+	event := &Event{}
+	var data []string
+	hasData := false
+	for {
+		line, err := er.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if hasData {
+				if event.Type == "" {
+					event.Type = "message"
+				}
+				event.Data = strings.Join(data, "\n")
+				return event, nil
+			}
+			event, data = &Event{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "id":
+			event.Id = value
+		}
+	}
+}
+
+// ParseStateChange parses a StateChange object, such as the data of an
+// EventSource "state" event.
+func ParseStateChange(data []byte) (*StateChange, error) {
+	var change StateChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, err
+	}
+	if change.Type != "StateChange" {
+		return nil, fmt.Errorf("not a StateChange: @type %q", change.Type)
+	}
+	return &change, nil
+}
+
+// WebSocketPushEnable asks the server to push StateChange objects over a
+// JMAP WebSocket. Empty DataTypes means all types. See RFC 8887 Section 4.3.5.
+type WebSocketPushEnable struct {
+	Type      string   `json:"@type"`
+	DataTypes []string `json:"dataTypes"`
+	PushState string   `json:"pushState,omitempty"`
+}
+
+// NewWebSocketPushEnable creates a WebSocketPushEnable for dataTypes.
+func NewWebSocketPushEnable(dataTypes []string) *WebSocketPushEnable {
+	return &WebSocketPushEnable{Type: "WebSocketPushEnable", DataTypes: dataTypes}
+}
+
+// WebSocketMessage is the part common to all messages a server sends over a
+// JMAP WebSocket; Type tells how to parse the rest of Raw.
+type WebSocketMessage struct {
+	Type      string          `json:"@type"`
+	RequestId string          `json:"requestId,omitempty"`
+	Raw       json.RawMessage `json:"-"`
+}
+
+// ParseWebSocketMessage parses a text message received over a JMAP
+// WebSocket.
+func ParseWebSocketMessage(data []byte) (*WebSocketMessage, error) {
+	var msg WebSocketMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Type == "" {
+		return nil, fmt.Errorf("message has no @type")
+	}
+	msg.Raw = append(json.RawMessage(nil), data...)
+	return &msg, nil
+}
@@ -0,0 +1,71 @@
+package protocol
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEventReader(t *testing.T) {
+	stream := ": comment to keep the connection open\r\n" +
+		"event: state\r\n" +
+		"data: {\"@type\":\"StateChange\",\r\n" +
+		"data: \"changed\":{}}\r\n" +
+		"id: 42\r\n" +
+		"\r\n" +
+		"event: ping\n" +
+		"\n" +
+		"event: ping\n" +
+		"data: {\"interval\":30}\n" +
+		"\n" +
+		"data:plain\n" +
+		"\n"
+	reader := NewEventReader(strings.NewReader(stream))
+
+	want := []Event{
+		{Type: "state", Data: "{\"@type\":\"StateChange\",\n\"changed\":{}}", Id: "42"},
+		{Type: "ping", Data: "{\"interval\":30}"},
+		{Type: "message", Data: "plain"},
+	}
+	for i, w := range want {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next() #%d error: %v", i, err)
+		}
+		if *event != w {
+			t.Errorf("Next() #%d = %+v, want %+v", i, *event, w)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() at end = %v, want io.EOF", err)
+	}
+}
+
+func TestParseStateChange(t *testing.T) {
+	change, err := ParseStateChange([]byte(`{"@type":"StateChange","changed":{"A1":{"Email":"s2","Mailbox":"m5"}},"pushState":"p1"}`))
+	if err != nil {
+		t.Fatalf("ParseStateChange() error: %v", err)
+	}
+	if change.Changed["A1"]["Email"] != "s2" || change.Changed["A1"]["Mailbox"] != "m5" || change.PushState != "p1" {
+		t.Errorf("ParseStateChange() = %+v", change)
+	}
+
+	if _, err := ParseStateChange([]byte(`{"@type":"Response"}`)); err == nil {
+		t.Error("ParseStateChange() expected an error for another @type")
+	}
+}
+
+func TestParseWebSocketMessage(t *testing.T) {
+	data := `{"@type":"Response","requestId":"r1","methodResponses":[]}`
+	msg, err := ParseWebSocketMessage([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseWebSocketMessage() error: %v", err)
+	}
+	if msg.Type != "Response" || msg.RequestId != "r1" || string(msg.Raw) != data {
+		t.Errorf("ParseWebSocketMessage() = %+v", msg)
+	}
+
+	if _, err := ParseWebSocketMessage([]byte(`{"requestId":"r1"}`)); err == nil {
+		t.Error("ParseWebSocketMessage() expected an error without @type")
+	}
+}
@@ -4,6 +4,8 @@ package protocol
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -61,6 +63,15 @@ func (s *Session) GetPrimaryMailAccountId() (Id, bool) {
 	return id, ok
 }
 
+// GetPrimaryAccountId returns the primary account ID for a capability,
+// falling back to the primary mail account when the server names none.
+func (s *Session) GetPrimaryAccountId(capability string) (Id, bool) {
+	if id, ok := s.PrimaryAccounts[capability]; ok {
+		return id, true
+	}
+	return s.GetPrimaryMailAccountId()
+}
+
 // GetAccountCount returns the number of accounts.
 func (s *Session) GetAccountCount() int {
 	return len(s.Accounts)
@@ -75,6 +86,95 @@ func (s *Session) GetAccountNames() []string {
 	return names
 }
 
+// BlobDownloadURL fills in the session's downloadUrl template
+// (RFC 8620 Section 6.2) for one blob.
+func (s *Session) BlobDownloadURL(accountId, blobId Id, name, contentType string) (string, error) {
+	if s.DownloadURL == "" {
+		return "", fmt.Errorf("session has no downloadUrl")
+	}
+	for _, variable := range []string{"{accountId}", "{blobId}"} {
+		if !strings.Contains(s.DownloadURL, variable) {
+			return "", fmt.Errorf("downloadUrl %s lacks %s", s.DownloadURL, variable)
+		}
+	}
+	return strings.NewReplacer(
+		"{accountId}", url.PathEscape(string(accountId)),
+		"{blobId}", url.PathEscape(string(blobId)),
+		"{name}", url.PathEscape(name),
+		"{type}", url.QueryEscape(contentType),
+	).Replace(s.DownloadURL), nil
+}
+
+// EventSourceURLFor fills in the session's eventSourceUrl template
+// (RFC 8620 Section 7.3): types is a comma-separated list of data types or
+// "*", closeAfter is "state" or "no", and ping is the ping interval in
+// seconds (0 disables pings).
+func (s *Session) EventSourceURLFor(types, closeAfter string, ping int) (string, error) {
+	if s.EventSourceURL == "" {
+		return "", fmt.Errorf("session has no eventSourceUrl")
+	}
+	return strings.NewReplacer(
+		"{types}", url.QueryEscape(types),
+		"{closeafter}", url.QueryEscape(closeAfter),
+		"{ping}", strconv.Itoa(ping),
+	).Replace(s.EventSourceURL), nil
+}
+
+// WebSocketCapabilityInfo contains parsed WebSocket capability information
+// (RFC 8887 Section 4).
+type WebSocketCapabilityInfo struct {
+	URL          string `json:"url"`
+	SupportsPush bool   `json:"supportsPush"`
+}
+
+// GetWebSocketCapability parses and returns the WebSocket capability
+// information.
+func (s *Session) GetWebSocketCapability() (*WebSocketCapabilityInfo, error) {
+	raw, ok := s.Capabilities[WebSocketCapability]
+	if !ok {
+		return nil, fmt.Errorf("websocket capability not found")
+	}
+	var info WebSocketCapabilityInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse websocket capability: %w", err)
+	}
+	if info.URL == "" {
+		return nil, fmt.Errorf("websocket capability has no url")
+	}
+	return &info, nil
+}
+
+// SieveCapabilityInfo contains parsed Sieve account capability information
+// (RFC 9661 Section 2). Nil limits are not advertised.
+type SieveCapabilityInfo struct {
+	Implementation      string   `json:"implementation"`
+	MaxSizeScriptName   *int64   `json:"maxSizeScriptName"`
+	MaxSizeScript       *int64   `json:"maxSizeScript"`
+	MaxNumberScripts    *int64   `json:"maxNumberScripts"`
+	MaxNumberRedirects  *int64   `json:"maxNumberRedirects"`
+	SieveExtensions     []string `json:"sieveExtensions"`
+	NotificationMethods []string `json:"notificationMethods"`
+	ExternalLists       []string `json:"externalLists"`
+}
+
+// GetSieveCapability parses and returns the Sieve capability of an
+// account. Unlike the session capabilities, it is advertised per account.
+func (s *Session) GetSieveCapability(accountId Id) (*SieveCapabilityInfo, error) {
+	account, ok := s.Accounts[accountId]
+	if !ok {
+		return nil, fmt.Errorf("account %s not found", accountId)
+	}
+	raw, ok := account.AccountCapabilities[SieveCapability]
+	if !ok {
+		return nil, fmt.Errorf("sieve capability not found")
+	}
+	var info SieveCapabilityInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse sieve capability: %w", err)
+	}
+	return &info, nil
+}
+
 // CoreCapabilityInfo contains parsed core capability information.
 type CoreCapabilityInfo struct {
 	MaxSizeUpload         int64    `json:"maxSizeUpload"`
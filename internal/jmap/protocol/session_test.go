@@ -423,3 +423,110 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestSession_BlobDownloadURL(t *testing.T) {
+	session := &Session{DownloadURL: "https://jmap.example.com/download/{accountId}/{blobId}/{name}?type={type}"}
+
+	got, err := session.BlobDownloadURL("A 1", "B/2", "message.eml", "message/rfc822")
+	if err != nil {
+		t.Fatalf("BlobDownloadURL() error: %v", err)
+	}
+	want := "https://jmap.example.com/download/A%201/B%2F2/message.eml?type=message%2Frfc822"
+	if got != want {
+		t.Errorf("BlobDownloadURL() = %q, want %q", got, want)
+	}
+
+	if _, err := (&Session{}).BlobDownloadURL("A1", "B1", "", ""); err == nil {
+		t.Error("BlobDownloadURL() expected an error without downloadUrl")
+	}
+	if _, err := (&Session{DownloadURL: "https://jmap.example.com/download/{blobId}"}).BlobDownloadURL("A1", "B1", "", ""); err == nil {
+		t.Error("BlobDownloadURL() expected an error for a template without {accountId}")
+	}
+}
+
+func TestSession_EventSourceURLFor(t *testing.T) {
+	session := &Session{EventSourceURL: "https://jmap.example.com/events/?types={types}&closeafter={closeafter}&ping={ping}"}
+
+	got, err := session.EventSourceURLFor("Email,Mailbox", "no", 30)
+	if err != nil {
+		t.Fatalf("EventSourceURLFor() error: %v", err)
+	}
+	want := "https://jmap.example.com/events/?types=Email%2CMailbox&closeafter=no&ping=30"
+	if got != want {
+		t.Errorf("EventSourceURLFor() = %q, want %q", got, want)
+	}
+
+	if _, err := (&Session{}).EventSourceURLFor("*", "no", 0); err == nil {
+		t.Error("EventSourceURLFor() expected an error without eventSourceUrl")
+	}
+}
+
+func TestSession_GetWebSocketCapability(t *testing.T) {
+	session := &Session{Capabilities: map[string]json.RawMessage{
+		WebSocketCapability: json.RawMessage(`{"url":"wss://jmap.example.com/ws","supportsPush":true}`),
+	}}
+
+	info, err := session.GetWebSocketCapability()
+	if err != nil {
+		t.Fatalf("GetWebSocketCapability() error: %v", err)
+	}
+	if info.URL != "wss://jmap.example.com/ws" || !info.SupportsPush {
+		t.Errorf("GetWebSocketCapability() = %+v", info)
+	}
+
+	if _, err := (&Session{Capabilities: map[string]json.RawMessage{}}).GetWebSocketCapability(); err == nil {
+		t.Error("GetWebSocketCapability() expected an error when the capability is missing")
+	}
+	noURL := &Session{Capabilities: map[string]json.RawMessage{WebSocketCapability: json.RawMessage(`{"supportsPush":true}`)}}
+	if _, err := noURL.GetWebSocketCapability(); err == nil {
+		t.Error("GetWebSocketCapability() expected an error without a url")
+	}
+}
+
+func TestSession_GetPrimaryAccountId(t *testing.T) {
+	session := &Session{PrimaryAccounts: map[string]Id{
+		MailCapability:  "A1",
+		QuotaCapability: "A2",
+	}}
+
+	if id, ok := session.GetPrimaryAccountId(QuotaCapability); !ok || id != "A2" {
+		t.Errorf("GetPrimaryAccountId(quota) = %q, %v, want A2", id, ok)
+	}
+	if id, ok := session.GetPrimaryAccountId(VacationResponseCapability); !ok || id != "A1" {
+		t.Errorf("GetPrimaryAccountId(vacationresponse) = %q, %v, want the mail account A1", id, ok)
+	}
+	if _, ok := (&Session{}).GetPrimaryAccountId(QuotaCapability); ok {
+		t.Error("GetPrimaryAccountId() ok = true without primary accounts")
+	}
+}
+
+func TestSession_GetSieveCapability(t *testing.T) {
+	session := &Session{Accounts: map[Id]Account{
+		"A1": {AccountCapabilities: map[string]json.RawMessage{
+			SieveCapability: json.RawMessage(`{"implementation":"Stalwart v0.11","maxSizeScriptName":512,"maxSizeScript":102400,` +
+				`"maxNumberScripts":null,"maxNumberRedirects":1,"sieveExtensions":["fileinto","vacation"],"notificationMethods":null,"externalLists":null}`),
+		}},
+		"A2": {AccountCapabilities: map[string]json.RawMessage{}},
+	}}
+
+	info, err := session.GetSieveCapability("A1")
+	if err != nil {
+		t.Fatalf("GetSieveCapability() error: %v", err)
+	}
+	if info.Implementation != "Stalwart v0.11" || info.MaxSizeScript == nil || *info.MaxSizeScript != 102400 {
+		t.Errorf("GetSieveCapability() = %+v", info)
+	}
+	if info.MaxNumberScripts != nil {
+		t.Errorf("MaxNumberScripts = %d, want nil for null", *info.MaxNumberScripts)
+	}
+	if len(info.SieveExtensions) != 2 || info.SieveExtensions[1] != "vacation" {
+		t.Errorf("SieveExtensions = %v", info.SieveExtensions)
+	}
+
+	if _, err := session.GetSieveCapability("A2"); err == nil {
+		t.Error("GetSieveCapability() expected an error when the capability is missing")
+	}
+	if _, err := session.GetSieveCapability("A9"); err == nil {
+		t.Error("GetSieveCapability() expected an error for an unknown account")
+	}
+}
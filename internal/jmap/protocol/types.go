@@ -197,3 +197,232 @@ type GetEmailsResponse struct {
 	List      []Email `json:"list"`
 	NotFound  []Id    `json:"notFound"`
 }
+
+// EmailFilterCondition is an Email/query filter (RFC 8621 Section 4.4.1).
+// Empty fields are left out of the filter.
+type EmailFilterCondition struct {
+	InMailbox  Id     `json:"inMailbox,omitempty"`
+	After      string `json:"after,omitempty"` // UTCDate, e.g. 2026-01-02T00:00:00Z
+	HasKeyword string `json:"hasKeyword,omitempty"`
+	From       string `json:"from,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+}
+
+// EmailBodyPart is a body part of an email being created. Text parts refer
+// to BodyValues by PartId; attachments refer to an uploaded blob.
+type EmailBodyPart struct {
+	PartId      string `json:"partId,omitempty"`
+	BlobId      Id     `json:"blobId,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Disposition string `json:"disposition,omitempty"`
+}
+
+// EmailBodyValue is the content of a text body part.
+type EmailBodyValue struct {
+	Value string `json:"value"`
+}
+
+// EmailCreate is an Email object for Email/set create (RFC 8621 Section 4.6).
+type EmailCreate struct {
+	MailboxIds  map[Id]bool               `json:"mailboxIds"`
+	Keywords    map[string]bool           `json:"keywords,omitempty"`
+	From        []EmailAddress            `json:"from,omitempty"`
+	To          []EmailAddress            `json:"to,omitempty"`
+	Cc          []EmailAddress            `json:"cc,omitempty"`
+	Subject     string                    `json:"subject"`
+	TextBody    []EmailBodyPart           `json:"textBody,omitempty"`
+	HTMLBody    []EmailBodyPart           `json:"htmlBody,omitempty"`
+	Attachments []EmailBodyPart           `json:"attachments,omitempty"`
+	BodyValues  map[string]EmailBodyValue `json:"bodyValues,omitempty"`
+}
+
+// Identity is a sending identity (RFC 8621 Section 6).
+type Identity struct {
+	Id            Id             `json:"id"`
+	Name          string         `json:"name"`
+	Email         string         `json:"email"` // May be a wildcard such as *@example.com
+	ReplyTo       []EmailAddress `json:"replyTo"`
+	Bcc           []EmailAddress `json:"bcc"`
+	TextSignature string         `json:"textSignature"`
+	HTMLSignature string         `json:"htmlSignature"`
+	MayDelete     bool           `json:"mayDelete"`
+}
+
+// GetIdentitiesResponse represents the response from Identity/get.
+type GetIdentitiesResponse struct {
+	AccountId Id         `json:"accountId"`
+	State     string     `json:"state"`
+	List      []Identity `json:"list"`
+	NotFound  []Id       `json:"notFound"`
+}
+
+// Address is an SMTP envelope address.
+type Address struct {
+	Email string `json:"email"`
+}
+
+// Envelope is the SMTP envelope of a submission. Without one the server
+// derives it from the email's headers.
+type Envelope struct {
+	MailFrom Address   `json:"mailFrom"`
+	RcptTo   []Address `json:"rcptTo"`
+}
+
+// EmailSubmissionCreate is an EmailSubmission object for EmailSubmission/set
+// create. EmailId may refer to an email created earlier in the same request
+// as "#" + its creation id.
+type EmailSubmissionCreate struct {
+	IdentityId Id        `json:"identityId"`
+	EmailId    Id        `json:"emailId"`
+	Envelope   *Envelope `json:"envelope,omitempty"`
+}
+
+// EmailSubmission represents a JMAP email submission (RFC 8621 Section 7).
+type EmailSubmission struct {
+	Id             Id                        `json:"id"`
+	IdentityId     Id                        `json:"identityId"`
+	EmailId        Id                        `json:"emailId"`
+	ThreadId       Id                        `json:"threadId"`
+	SendAt         string                    `json:"sendAt"`
+	UndoStatus     string                    `json:"undoStatus"` // pending, final or canceled
+	DeliveryStatus map[string]DeliveryStatus `json:"deliveryStatus"`
+}
+
+// DeliveryStatus is the delivery state of one recipient of a submission.
+type DeliveryStatus struct {
+	SmtpReply string `json:"smtpReply"`
+	Delivered string `json:"delivered"` // queued, yes, no or unknown
+	Displayed string `json:"displayed"` // unknown or yes
+}
+
+// GetEmailSubmissionsResponse represents the response from EmailSubmission/get.
+type GetEmailSubmissionsResponse struct {
+	AccountId Id                `json:"accountId"`
+	State     string            `json:"state"`
+	List      []EmailSubmission `json:"list"`
+	NotFound  []Id              `json:"notFound"`
+}
+
+// SetError explains why a /set operation failed for one object.
+type SetError struct {
+	Type        string   `json:"type"`
+	Description string   `json:"description,omitempty"`
+	Properties  []string `json:"properties,omitempty"`
+	ExistingId  Id       `json:"existingId,omitempty"` // alreadyExists: the existing object
+}
+
+// SetResponse represents the response from a /set method. Created objects
+// hold only the properties the server set, so they are left as raw JSON.
+type SetResponse struct {
+	AccountId    Id                         `json:"accountId"`
+	OldState     string                     `json:"oldState"`
+	NewState     string                     `json:"newState"`
+	Created      map[string]json.RawMessage `json:"created"`
+	Updated      map[Id]json.RawMessage     `json:"updated"`
+	Destroyed    []Id                       `json:"destroyed"`
+	NotCreated   map[string]SetError        `json:"notCreated"`
+	NotUpdated   map[Id]SetError            `json:"notUpdated"`
+	NotDestroyed map[Id]SetError            `json:"notDestroyed"`
+}
+
+// UploadResponse is the response to a blob upload (RFC 8620 Section 6.1).
+type UploadResponse struct {
+	AccountId Id     `json:"accountId"`
+	BlobId    Id     `json:"blobId"`
+	Type      string `json:"type"`
+	Size      int64  `json:"size"`
+}
+
+// EmailImport is one email for Email/import: an uploaded RFC 5322 message
+// and where to file it (RFC 8621 Section 4.8).
+type EmailImport struct {
+	BlobId     Id              `json:"blobId"`
+	MailboxIds map[Id]bool     `json:"mailboxIds"`
+	Keywords   map[string]bool `json:"keywords,omitempty"`
+	ReceivedAt string          `json:"receivedAt,omitempty"` // UTCDate; the server uses the import time if empty
+}
+
+// EmailImportResponse represents the response from Email/import.
+type EmailImportResponse struct {
+	AccountId  Id                  `json:"accountId"`
+	OldState   string              `json:"oldState"`
+	NewState   string              `json:"newState"`
+	Created    map[string]Email    `json:"created"`
+	NotCreated map[string]SetError `json:"notCreated"`
+}
+
+// ProblemDetails is a request-level error (RFC 7807), as returned for a
+// rejected API request or upload. Limit names the server limit that was
+// exceeded when Type is ErrorLimit.
+type ProblemDetails struct {
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	Limit  string `json:"limit,omitempty"`
+}
+
+// VacationResponse is the account's automatic reply (RFC 8621 Section 8).
+// Each account has exactly one, with the id "singleton".
+type VacationResponse struct {
+	Id        Id      `json:"id"`
+	IsEnabled bool    `json:"isEnabled"`
+	FromDate  *string `json:"fromDate"` // UTCDate; null means from now
+	ToDate    *string `json:"toDate"`   // UTCDate; null means until disabled
+	Subject   *string `json:"subject"`
+	TextBody  *string `json:"textBody"`
+	HTMLBody  *string `json:"htmlBody"`
+}
+
+// VacationResponseUpdate is the patch of a VacationResponse/set update.
+// Nil fields are left unchanged.
+type VacationResponseUpdate struct {
+	IsEnabled bool    `json:"isEnabled"`
+	FromDate  *string `json:"fromDate,omitempty"`
+	ToDate    *string `json:"toDate,omitempty"`
+	Subject   *string `json:"subject,omitempty"`
+	TextBody  *string `json:"textBody,omitempty"`
+}
+
+// GetVacationResponseResponse represents the response from VacationResponse/get.
+type GetVacationResponseResponse struct {
+	AccountId Id                 `json:"accountId"`
+	State     string             `json:"state"`
+	List      []VacationResponse `json:"list"`
+	NotFound  []Id               `json:"notFound"`
+}
+
+// Quota is a limit on the resources an account may use (RFC 9425
+// Section 4). ResourceType is "count" or "octets"; Scope is "account",
+// "domain" or "global".
+type Quota struct {
+	Id           Id       `json:"id"`
+	ResourceType string   `json:"resourceType"`
+	Used         uint64   `json:"used"`
+	HardLimit    uint64   `json:"hardLimit"`
+	WarnLimit    *uint64  `json:"warnLimit"`
+	SoftLimit    *uint64  `json:"softLimit"`
+	Scope        string   `json:"scope"`
+	Name         string   `json:"name"`
+	Types        []string `json:"types"` // Data types counted, e.g. "Mail"
+	Description  *string  `json:"description"`
+}
+
+// GetQuotasResponse represents the response from Quota/get.
+type GetQuotasResponse struct {
+	AccountId Id      `json:"accountId"`
+	State     string  `json:"state"`
+	List      []Quota `json:"list"`
+	NotFound  []Id    `json:"notFound"`
+}
+
+// ChangesResponse represents the response from a /changes method.
+type ChangesResponse struct {
+	AccountId      Id     `json:"accountId"`
+	OldState       string `json:"oldState"`
+	NewState       string `json:"newState"`
+	HasMoreChanges bool   `json:"hasMoreChanges"`
+	Created        []Id   `json:"created"`
+	Updated        []Id   `json:"updated"`
+	Destroyed      []Id   `json:"destroyed"`
+}
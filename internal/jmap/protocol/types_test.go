@@ -351,3 +351,27 @@ func TestGetEmailsResponse(t *testing.T) {
 		t.Errorf("List[0].Subject = %q, want %q", resp.List[0].Subject, "Test Email")
 	}
 }
+
+func TestEmailSubmission_JSON(t *testing.T) {
+	data := `{
+		"id": "S1",
+		"identityId": "I1",
+		"emailId": "E1",
+		"undoStatus": "final",
+		"deliveryStatus": {
+			"you@example.com": {"smtpReply": "250 2.0.0 OK", "delivered": "yes", "displayed": "unknown"}
+		}
+	}`
+
+	var submission EmailSubmission
+	if err := json.Unmarshal([]byte(data), &submission); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if submission.UndoStatus != "final" {
+		t.Errorf("UndoStatus = %q, want final", submission.UndoStatus)
+	}
+	status := submission.DeliveryStatus["you@example.com"]
+	if status.Delivered != "yes" || status.SmtpReply != "250 2.0.0 OK" {
+		t.Errorf("DeliveryStatus = %+v, want delivered with the SMTP reply", status)
+	}
+}
@@ -111,26 +111,66 @@ func (c *Capabilities) SupportsRESPCodes() bool {
 	return c.Has("RESP-CODES")
 }
 
-// SupportsXOAUTH2 returns true if the server supports XOAUTH2.
-func (c *Capabilities) SupportsXOAUTH2() bool {
-	mechanisms := c.GetAuthMechanisms()
-	for _, m := range mechanisms {
-		if strings.EqualFold(m, "XOAUTH2") {
+// hasAuthMechanism returns true if the SASL capability lists mechanism.
+func (c *Capabilities) hasAuthMechanism(mechanism string) bool {
+	for _, m := range c.GetAuthMechanisms() {
+		if strings.EqualFold(m, mechanism) {
 			return true
 		}
 	}
 	return false
 }
 
+// SupportsXOAUTH2 returns true if the server supports XOAUTH2.
+func (c *Capabilities) SupportsXOAUTH2() bool {
+	return c.hasAuthMechanism("XOAUTH2")
+}
+
+// SupportsOAUTHBEARER returns true if the server supports OAUTHBEARER (RFC 7628).
+func (c *Capabilities) SupportsOAUTHBEARER() bool {
+	return c.hasAuthMechanism("OAUTHBEARER")
+}
+
 // SupportsPlain returns true if the server supports PLAIN authentication.
 func (c *Capabilities) SupportsPlain() bool {
-	mechanisms := c.GetAuthMechanisms()
-	for _, m := range mechanisms {
-		if strings.EqualFold(m, "PLAIN") {
-			return true
+	return c.hasAuthMechanism("PLAIN")
+}
+
+// SupportsLOGIN returns true if the server supports SASL LOGIN authentication.
+func (c *Capabilities) SupportsLOGIN() bool {
+	return c.hasAuthMechanism("LOGIN")
+}
+
+// SupportsNTLM returns true if the server supports NTLM authentication
+// (Exchange Integrated Windows authentication).
+func (c *Capabilities) SupportsNTLM() bool {
+	return c.hasAuthMechanism("NTLM")
+}
+
+// SelectBestAuthMechanism selects the best SASL mechanism the server
+// advertises. Priority with a token: XOAUTH2 > OAUTHBEARER; then (and
+// without a token) NTLM > PLAIN > LOGIN, matching the IMAP order. Returns ""
+// when none is advertised, in which case USER/PASS is the fallback.
+func (c *Capabilities) SelectBestAuthMechanism(hasAccessToken bool) string {
+	if hasAccessToken {
+		if c.SupportsXOAUTH2() {
+			return "XOAUTH2"
+		}
+		if c.SupportsOAUTHBEARER() {
+			return "OAUTHBEARER"
 		}
+		return ""
 	}
-	return false
+	if c.SupportsNTLM() {
+		return "NTLM"
+	}
+	if c.SupportsPlain() {
+		return "PLAIN"
+	}
+	if c.SupportsLOGIN() {
+		return "LOGIN"
+	}
+	return ""
 }
 
 // GetExpirePolicy returns the EXPIRE policy if advertised.
@@ -205,6 +205,33 @@ func TestCapabilities_SupportsPlain(t *testing.T) {
 	}
 }
 
+func TestCapabilities_SelectBestAuthMechanism(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		hasToken bool
+		expected string
+	}{
+		{"token prefers XOAUTH2", []string{"SASL PLAIN OAUTHBEARER XOAUTH2"}, true, "XOAUTH2"},
+		{"token with OAUTHBEARER", []string{"SASL PLAIN OAUTHBEARER"}, true, "OAUTHBEARER"},
+		{"token without OAuth mechanisms", []string{"SASL PLAIN"}, true, ""},
+		{"Exchange", []string{"SASL NTLM GSSAPI PLAIN"}, false, "NTLM"},
+		{"PLAIN over LOGIN", []string{"SASL LOGIN PLAIN"}, false, "PLAIN"},
+		{"LOGIN only", []string{"SASL LOGIN"}, false, "LOGIN"},
+		{"password ignores OAuth mechanisms", []string{"SASL XOAUTH2 OAUTHBEARER"}, false, ""},
+		{"no SASL", []string{"USER", "UIDL"}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := NewCapabilities(tt.lines)
+			if got := caps.SelectBestAuthMechanism(tt.hasToken); got != tt.expected {
+				t.Errorf("SelectBestAuthMechanism(%v) = %q, want %q", tt.hasToken, got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCapabilities_SupportsUIDL(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -17,9 +17,10 @@ const DefaultResponseTimeout = 30 * time.Second
 
 // POP3Response represents a POP3 server response.
 type POP3Response struct {
-	Success bool     // true for +OK, false for -ERR
-	Message string   // Response message after +OK/-ERR
-	Lines   []string // Additional lines for multiline responses
+	Success      bool     // true for +OK, false for -ERR
+	Continuation bool     // true for a SASL "+ <challenge>" continuation (Success is also true)
+	Message      string   // Response message after +OK/-ERR, or the base64 challenge
+	Lines        []string // Additional lines for multiline responses
 }
 
 // IsSuccess returns true if the response indicates success.
@@ -115,6 +116,36 @@ func ReadMultilineResponse(reader *bufio.Reader) (*POP3Response, error) {
 	return resp, nil
 }
 
+// CopyMultilineBody copies the body of a multiline response (the lines after
+// the +OK status line) to w, up to the terminating ".". It undoes
+// byte-stuffing as RFC 1939 section 3 requires: a leading "." is removed from
+// every other line. Lines are written with CRLF endings, so a RETR body
+// becomes a valid RFC 5322 message. It returns the number of bytes written.
+func CopyMultilineBody(w io.Writer, reader *bufio.Reader) (int64, error) {
+	var written int64
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return written, fmt.Errorf("connection closed before end of multiline response")
+			}
+			return written, fmt.Errorf("failed to read multiline response: %w", err)
+		}
+
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "." {
+			return written, nil
+		}
+		line = strings.TrimPrefix(line, ".")
+
+		n, err := io.WriteString(w, line+"\r\n")
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
 // parseResponseLine parses a single POP3 response line.
 func parseResponseLine(line string) (*POP3Response, error) {
 	line = strings.TrimRight(line, "\r\n")
@@ -131,10 +162,12 @@ func parseResponseLine(line string) (*POP3Response, error) {
 		if len(line) > 4 {
 			resp.Message = strings.TrimPrefix(line[4:], " ")
 		}
-	} else if strings.HasPrefix(line, "+ ") {
-		// Continuation response (used in SASL AUTH)
+	} else if line == "+" || strings.HasPrefix(line, "+ ") {
+		// Continuation response (used in SASL AUTH); some servers send a
+		// bare "+" for an empty challenge
 		resp.Success = true
-		resp.Message = strings.TrimPrefix(line, "+ ")
+		resp.Continuation = true
+		resp.Message = strings.TrimPrefix(strings.TrimPrefix(line, "+"), " ")
 	} else {
 		return nil, fmt.Errorf("invalid POP3 response: %s", line)
 	}
@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCopyMultilineBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "plain message",
+			input: "Subject: hi\r\n\r\nbody\r\n.\r\n",
+			want:  "Subject: hi\r\n\r\nbody\r\n",
+		},
+		{
+			name:  "byte-stuffed lines",
+			input: "Subject: dots\r\n\r\n..\r\n...\r\n..leading dot\r\n.\r\n",
+			want:  "Subject: dots\r\n\r\n.\r\n..\r\n.leading dot\r\n",
+		},
+		{
+			name:  "bare LF endings",
+			input: "Subject: lf\n\nbody\n.\n",
+			want:  "Subject: lf\r\n\r\nbody\r\n",
+		},
+		{
+			name:  "empty body",
+			input: ".\r\n",
+			want:  "",
+		},
+		{
+			name:    "connection closed",
+			input:   "Subject: cut\r\n\r\nbody\r\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input + "+OK next\r\n"))
+			var buf bytes.Buffer
+			n, err := CopyMultilineBody(&buf, reader)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("CopyMultilineBody() expected error, got %q", buf.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CopyMultilineBody() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("CopyMultilineBody() = %q, want %q", buf.String(), tt.want)
+			}
+			if n != int64(len(tt.want)) {
+				t.Errorf("CopyMultilineBody() wrote %d bytes, want %d", n, len(tt.want))
+			}
+
+			// The reader must stop at the terminator
+			if rest, _ := reader.ReadString('\n'); rest != "+OK next\r\n" {
+				t.Errorf("next line = %q, want the following response", rest)
+			}
+		})
+	}
+}
+
+func TestParseResponseLine_Continuation(t *testing.T) {
+	tests := []struct {
+		line         string
+		success      bool
+		continuation bool
+		message      string
+	}{
+		{"+OK done\r\n", true, false, "done"},
+		{"-ERR nope\r\n", false, false, "nope"},
+		{"+ TlRMTVNTUAACAAAA\r\n", true, true, "TlRMTVNTUAACAAAA"},
+		{"+ \r\n", true, true, ""},
+		{"+\r\n", true, true, ""},
+	}
+	for _, tt := range tests {
+		resp, err := parseResponseLine(tt.line)
+		if err != nil {
+			t.Fatalf("parseResponseLine(%q) error = %v", tt.line, err)
+		}
+		if resp.Success != tt.success || resp.Continuation != tt.continuation || resp.Message != tt.message {
+			t.Errorf("parseResponseLine(%q) = %+v", tt.line, resp)
+		}
+	}
+}
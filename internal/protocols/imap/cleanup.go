@@ -0,0 +1,332 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// cleanupMarkerHeader is matched when neither --header nor --subject-prefix
+// is given. testappend and smtp testfilter set it on their messages.
+const cleanupMarkerHeader = appendProbeHeader
+
+// cleanupBatchSize is how many UIDs are flagged, expunged or moved per command.
+const cleanupBatchSize = 500
+
+// cleanupListLimit caps the matches listed on the console; the CSV log
+// lists all of them.
+const cleanupListLimit = 50
+
+// Cleanup operations reported per message
+const (
+	cleanupDeleted     = "DELETED"
+	cleanupMoved       = "MOVED"
+	cleanupMarked      = "MARKED" // \Deleted set, but not expunged
+	cleanupWouldDelete = "WOULD_DELETE"
+	cleanupWouldMove   = "WOULD_MOVE"
+)
+
+// trashNames are the mailbox names tried when no mailbox carries the
+// \Trash special-use attribute.
+var trashNames = []string{"Trash", "Deleted Items", "Deleted Messages"}
+
+// cleanupMessages removes old test messages from --mailbox: those with a
+// marker header (--header, by default X-GoMailTest-Probe) or a subject
+// prefix, received before --older-than. Matches are flagged \Deleted and
+// expunged with UID EXPUNGE, or with --move-to-trash moved to the Trash
+// mailbox. --dry-run only lists them.
+func cleanupMessages(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	now := time.Now()
+	cutoff, err := parseSince(config.OlderThan, now)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than: %w", err)
+	}
+	criteria, description, err := buildCleanupCriteria(config, cutoff)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cleaning up %s on %s:%d...\n", config.Mailbox, config.Host, config.Port)
+	fmt.Printf("Matching %s, received before %s\n", description, formatDate(cutoff))
+	if config.DryRun {
+		fmt.Println("Dry run: nothing will be changed")
+	}
+
+	columns := []string{"Action", "Status", "Server", "Port", "Mailbox", "UID", "Internal_Date", "Subject", "Size", "Operation", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(msg *imapclient.FetchMessageBuffer, operation string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		uid, date, subject, size := "", "", "", ""
+		if msg != nil {
+			uid = fmt.Sprintf("%d", msg.UID)
+			date = formatDate(msg.InternalDate)
+			if msg.Envelope != nil {
+				subject = msg.Envelope.Subject
+			}
+			size = fmt.Sprintf("%d", msg.RFC822Size)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			config.Mailbox, uid, date, subject, size, operation, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	client, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		writeRow(nil, "", err)
+		return err
+	}
+	defer func() { _ = client.Logout() }()
+
+	caps := client.RefreshCapabilities()
+	trash := ""
+	if config.MoveToTrash {
+		if !caps.SupportsMOVE() && !caps.SupportsIMAP4rev2() {
+			err := fmt.Errorf("server does not support MOVE; run without --move-to-trash to delete the messages")
+			writeRow(nil, "", err)
+			return err
+		}
+		if trash, err = findTrashMailbox(ctx, client); err != nil {
+			writeRow(nil, "", err)
+			return err
+		}
+		if folderKey(trash, "") == folderKey(config.Mailbox, "") {
+			err := fmt.Errorf("--mailbox is the Trash mailbox %q; run without --move-to-trash to delete the messages", trash)
+			writeRow(nil, "", err)
+			return err
+		}
+		fmt.Printf("✓ Trash mailbox: %s\n", trash)
+	}
+
+	// Dry runs open the mailbox read-only
+	selectData, err := client.SelectMailbox(ctx, config.Mailbox, config.DryRun)
+	if err != nil {
+		logger.LogError(slogLogger, "SELECT failed", "error", err, "mailbox", config.Mailbox)
+		writeRow(nil, "", err)
+		return err
+	}
+	fmt.Printf("✓ Opened %s (%d messages)\n", config.Mailbox, selectData.NumMessages)
+
+	uids, err := client.SearchUIDs(ctx, criteria)
+	if err != nil {
+		logger.LogError(slogLogger, "SEARCH failed", "error", err)
+		writeRow(nil, "", err)
+		return err
+	}
+
+	var matches []*imapclient.FetchMessageBuffer
+	if len(uids) > 0 {
+		messages, err := client.FetchUIDs(ctx, uids, &imap.FetchOptions{
+			UID:          true,
+			Envelope:     true,
+			InternalDate: true,
+			RFC822Size:   true,
+		})
+		if err != nil {
+			logger.LogError(slogLogger, "FETCH failed", "error", err)
+			writeRow(nil, "", err)
+			return err
+		}
+		matches = filterCleanupMatches(messages, cutoff, config.SubjectPrefix)
+	}
+
+	var totalSize int64
+	for _, msg := range matches {
+		totalSize += msg.RFC822Size
+	}
+	fmt.Printf("✓ %d message(s) match (%s)\n", len(matches), formatBytes(totalSize))
+	for i, msg := range matches {
+		if i == cleanupListLimit {
+			fmt.Printf("    ... and %d more (see the CSV log)\n", len(matches)-cleanupListLimit)
+			break
+		}
+		subject := ""
+		if msg.Envelope != nil {
+			subject = msg.Envelope.Subject
+		}
+		fmt.Printf("    UID %-8d %s  %9s  %s\n", msg.UID, formatDate(msg.InternalDate), formatBytes(msg.RFC822Size), subject)
+	}
+
+	if len(matches) == 0 {
+		logger.LogInfo(slogLogger, "Cleanup completed", "mailbox", config.Mailbox, "matched", 0)
+		fmt.Println("\n✓ Nothing to clean up")
+		return nil
+	}
+
+	if config.DryRun {
+		operation, verb := cleanupWouldDelete, "deleted"
+		if config.MoveToTrash {
+			operation, verb = cleanupWouldMove, "moved to "+trash
+		}
+		for _, msg := range matches {
+			writeRow(msg, operation, nil)
+		}
+		logger.LogInfo(slogLogger, "Cleanup dry run completed", "mailbox", config.Mailbox, "matched", len(matches))
+		fmt.Printf("\n✓ Dry run: %d message(s) (%s) would be %s\n", len(matches), formatBytes(totalSize), verb)
+		return nil
+	}
+
+	done := 0
+	for start := 0; start < len(matches); start += cleanupBatchSize {
+		batch := matches[start:min(start+cleanupBatchSize, len(matches))]
+		batchUIDs := make([]imap.UID, len(batch))
+		for i, msg := range batch {
+			batchUIDs[i] = msg.UID
+		}
+
+		operation, err := removeMessages(ctx, client, caps.SupportsUIDPLUS() || caps.SupportsIMAP4rev2(), trash, batchUIDs)
+		for _, msg := range batch {
+			writeRow(msg, operation, err)
+		}
+		if err != nil {
+			logger.LogError(slogLogger, "Cleanup failed", "error", err, "mailbox", config.Mailbox, "done", done)
+			return fmt.Errorf("cleanup stopped after %d of %d messages: %w", done, len(matches), err)
+		}
+		if operation == cleanupMarked {
+			fmt.Printf("⚠ Other \\Deleted messages are in %s and the server lacks UIDPLUS, so EXPUNGE would remove them too.\n", config.Mailbox)
+			fmt.Println("  The matches are flagged \\Deleted but not expunged.")
+		}
+		done += len(batch)
+	}
+
+	logger.LogInfo(slogLogger, "Cleanup completed",
+		"mailbox", config.Mailbox,
+		"matched", len(matches),
+		"bytes", totalSize,
+		"moved_to", trash)
+
+	if config.MoveToTrash {
+		fmt.Printf("\n✓ Moved %d message(s) (%s) to %s\n", done, formatBytes(totalSize), trash)
+	} else {
+		fmt.Printf("\n✓ Removed %d message(s) (%s)\n", done, formatBytes(totalSize))
+	}
+	return nil
+}
+
+// removeMessages moves uids to trash or, when trash is empty, flags them
+// \Deleted and expunges them. Without UIDPLUS, a plain EXPUNGE is only sent
+// when no other message in the mailbox is flagged \Deleted; otherwise the
+// messages are left flagged and cleanupMarked is returned.
+func removeMessages(ctx context.Context, client *IMAPClient, uidPlus bool, trash string, uids []imap.UID) (string, error) {
+	if trash != "" {
+		return cleanupMoved, client.MoveUIDs(ctx, uids, trash)
+	}
+
+	if err := client.AddFlags(ctx, uids, imap.FlagDeleted); err != nil {
+		return "", err
+	}
+	if uidPlus {
+		return cleanupDeleted, client.ExpungeUIDs(ctx, uids)
+	}
+
+	flagged, err := client.SearchUIDs(ctx, &imap.SearchCriteria{Flag: []imap.Flag{imap.FlagDeleted}})
+	if err != nil {
+		return "", err
+	}
+	ours := make(map[imap.UID]bool, len(uids))
+	for _, uid := range uids {
+		ours[uid] = true
+	}
+	for _, uid := range flagged {
+		if !ours[uid] {
+			return cleanupMarked, nil
+		}
+	}
+	return cleanupDeleted, client.Expunge(ctx)
+}
+
+// buildCleanupCriteria turns the marker flags into SEARCH criteria and a
+// description of them. SEARCH BEFORE only compares dates, so it is set to
+// the day after cutoff and filterCleanupMatches applies the exact time.
+func buildCleanupCriteria(config *Config, cutoff time.Time) (*imap.SearchCriteria, string, error) {
+	criteria := &imap.SearchCriteria{}
+	var parts []string
+
+	headers := config.SearchHeaders
+	if len(headers) == 0 && config.SubjectPrefix == "" {
+		headers = []string{cleanupMarkerHeader + ":"}
+	}
+	for _, h := range headers {
+		name, value, err := parseHeaderCriterion(h)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid --header: %w", err)
+		}
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: name, Value: value})
+		if value == "" {
+			parts = append(parts, fmt.Sprintf("header %s", name))
+		} else {
+			parts = append(parts, fmt.Sprintf("header %s containing %q", name, value))
+		}
+	}
+	if config.SubjectPrefix != "" {
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: "Subject", Value: config.SubjectPrefix})
+		parts = append(parts, fmt.Sprintf("subject starting with %q", config.SubjectPrefix))
+	}
+
+	day := time.Date(cutoff.Year(), cutoff.Month(), cutoff.Day(), 0, 0, 0, 0, cutoff.Location())
+	criteria.Before = day.AddDate(0, 0, 1)
+
+	return criteria, strings.Join(parts, " and "), nil
+}
+
+// filterCleanupMatches keeps the messages received before cutoff whose
+// subject starts with prefix (ignoring case; SEARCH matched it anywhere).
+func filterCleanupMatches(messages []*imapclient.FetchMessageBuffer, cutoff time.Time, prefix string) []*imapclient.FetchMessageBuffer {
+	var matches []*imapclient.FetchMessageBuffer
+	for _, msg := range messages {
+		if msg.InternalDate.IsZero() || !msg.InternalDate.Before(cutoff) {
+			continue
+		}
+		if prefix != "" {
+			if msg.Envelope == nil || !strings.HasPrefix(strings.ToLower(msg.Envelope.Subject), strings.ToLower(prefix)) {
+				continue
+			}
+		}
+		matches = append(matches, msg)
+	}
+	return matches
+}
+
+// findTrashMailbox returns the mailbox with the \Trash special-use
+// attribute or, failing that, one named as in trashNames.
+func findTrashMailbox(ctx context.Context, client *IMAPClient) (string, error) {
+	mailboxes, err := client.ListMailboxNames(ctx, "*")
+	if err != nil {
+		return "", err
+	}
+	for _, mb := range mailboxes {
+		for _, attr := range mb.Attributes {
+			if strings.EqualFold(attr, string(imap.MailboxAttrTrash)) && isSelectable(mb.Attributes) {
+				return mb.Name, nil
+			}
+		}
+	}
+	for _, name := range trashNames {
+		for _, mb := range mailboxes {
+			leaf := mb.Name
+			if mb.Delimiter != "" {
+				leaf = leaf[strings.LastIndex(leaf, mb.Delimiter)+len(mb.Delimiter):]
+			}
+			if strings.EqualFold(leaf, name) && isSelectable(mb.Attributes) {
+				return mb.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no Trash mailbox found (no \\Trash special-use mailbox or folder named %s)", strings.Join(trashNames, ", "))
+}
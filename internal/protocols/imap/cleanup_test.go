@@ -0,0 +1,207 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+const testMessageProbeRecent = `From: GoMailTest <probe@example.com>
+To: bob@example.com
+Subject: [gomailtest] recent probe
+Message-ID: <recent@example.com>
+Date: Thu, 05 Jan 2026 10:00:00 +0000
+X-GoMailTest-Probe: recent
+
+Recent probe body
+`
+
+const testMessageProbeSubject = `From: GoMailTest <probe@example.com>
+To: bob@example.com
+Subject: [gomailtest] subject probe
+Message-ID: <subject@example.com>
+Date: Thu, 05 Jan 2026 11:00:00 +0000
+
+Subject probe body
+`
+
+// newCleanupTestServer fills INBOX with an old probe (Alpha), an old
+// ordinary message (Beta), an old subject-prefixed message and a recent
+// probe, plus an empty Trash mailbox.
+func newCleanupTestServer(t *testing.T) (*Config, *imapmemserver.User) {
+	t.Helper()
+
+	user := imapmemserver.NewUser("tester", "secret")
+	for _, name := range []string{"INBOX", "Trash"} {
+		if err := user.Create(name, nil); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+	}
+	old := time.Now().Add(-72 * time.Hour)
+	appendDated(t, user, testMessageAlpha, old)
+	appendDated(t, user, testMessageBeta, old)
+	appendDated(t, user, testMessageProbeSubject, old)
+	appendDated(t, user, testMessageProbeRecent, time.Now())
+
+	config := startTestIMAPServer(t, user, nil)
+	config.Action = ActionCleanup
+	return config, user
+}
+
+func appendDated(t *testing.T, user *imapmemserver.User, message string, date time.Time) {
+	t.Helper()
+	msg := strings.ReplaceAll(message, "\n", "\r\n")
+	if _, err := user.Append("INBOX", bytes.NewReader([]byte(msg)), &imap.AppendOptions{Time: date}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+}
+
+func mailboxCount(t *testing.T, user *imapmemserver.User, mailbox string) uint32 {
+	t.Helper()
+	status, err := user.Status(mailbox, &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatalf("status %s: %v", mailbox, err)
+	}
+	return *status.NumMessages
+}
+
+func TestBuildCleanupCriteria(t *testing.T) {
+	cutoff := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	wantBefore := time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  []string
+		prefix   string
+		wantKeys []string
+	}{
+		{name: "default marker", wantKeys: []string{"X-GoMailTest-Probe"}},
+		{name: "custom header", headers: []string{"X-Test: run-1"}, wantKeys: []string{"X-Test"}},
+		{name: "subject only", prefix: "[gomailtest]", wantKeys: []string{"Subject"}},
+		{name: "header and subject", headers: []string{"X-Test:"}, prefix: "[t]", wantKeys: []string{"X-Test", "Subject"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.SearchHeaders = tt.headers
+			config.SubjectPrefix = tt.prefix
+
+			criteria, _, err := buildCleanupCriteria(config, cutoff)
+			if err != nil {
+				t.Fatalf("buildCleanupCriteria() error = %v", err)
+			}
+			var keys []string
+			for _, h := range criteria.Header {
+				keys = append(keys, h.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("header keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if !criteria.Before.Equal(wantBefore) {
+				t.Errorf("Before = %v, want %v", criteria.Before, wantBefore)
+			}
+		})
+	}
+}
+
+func TestFilterCleanupMatches(t *testing.T) {
+	cutoff := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	msg := func(uid imap.UID, date time.Time, subject string) *imapclient.FetchMessageBuffer {
+		return &imapclient.FetchMessageBuffer{UID: uid, InternalDate: date, Envelope: &imap.Envelope{Subject: subject}}
+	}
+	messages := []*imapclient.FetchMessageBuffer{
+		msg(1, cutoff.Add(-time.Hour), "[GoMailTest] old"),
+		msg(2, cutoff.Add(time.Hour), "[gomailtest] same day, later"),
+		msg(3, cutoff.Add(-time.Hour), "Re: [gomailtest] reply"),
+		msg(4, time.Time{}, "[gomailtest] no date"),
+	}
+
+	var got []imap.UID
+	for _, m := range filterCleanupMatches(messages, cutoff, "[gomailtest]") {
+		got = append(got, m.UID)
+	}
+	if len(got) != 1 || got[0] != 1 {
+		t.Errorf("filterCleanupMatches() = %v, want [1]", got)
+	}
+}
+
+func TestCleanupMessages_DryRun(t *testing.T) {
+	config, user := newCleanupTestServer(t)
+	config.DryRun = true
+
+	csvLog := &recordingLogger{}
+	if err := cleanupMessages(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("cleanupMessages() error = %v", err)
+	}
+	if n := mailboxCount(t, user, "INBOX"); n != 4 {
+		t.Errorf("INBOX has %d messages after a dry run, want 4", n)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Operation") != cleanupWouldDelete ||
+		csvLog.column(csvLog.rows[0], "Subject") != "Alpha report" {
+		t.Errorf("rows = %v, want one WOULD_DELETE row for Alpha", csvLog.rows)
+	}
+}
+
+func TestCleanupMessages_DeleteByMarker(t *testing.T) {
+	config, user := newCleanupTestServer(t)
+
+	csvLog := &recordingLogger{}
+	if err := cleanupMessages(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("cleanupMessages() error = %v", err)
+	}
+	// Only the old probe goes; the recent probe is newer than 24h
+	if n := mailboxCount(t, user, "INBOX"); n != 3 {
+		t.Errorf("INBOX has %d messages, want 3", n)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Operation") != cleanupDeleted {
+		t.Errorf("rows = %v, want one DELETED row", csvLog.rows)
+	}
+}
+
+func TestCleanupMessages_SubjectPrefix(t *testing.T) {
+	config, user := newCleanupTestServer(t)
+	config.SubjectPrefix = "[gomailtest]"
+
+	csvLog := &recordingLogger{}
+	if err := cleanupMessages(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("cleanupMessages() error = %v", err)
+	}
+	if n := mailboxCount(t, user, "INBOX"); n != 3 {
+		t.Errorf("INBOX has %d messages, want 3", n)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Subject") != "[gomailtest] subject probe" {
+		t.Errorf("rows = %v, want the old subject probe only", csvLog.rows)
+	}
+}
+
+func TestCleanupMessages_MoveToTrash(t *testing.T) {
+	config, user := newCleanupTestServer(t)
+	config.MoveToTrash = true
+	config.OlderThan = "1h"
+	config.SearchHeaders = []string{"X-GoMailTest-Probe:"}
+	config.SubjectPrefix = ""
+
+	csvLog := &recordingLogger{}
+	if err := cleanupMessages(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("cleanupMessages() error = %v", err)
+	}
+	if n := mailboxCount(t, user, "INBOX"); n != 3 {
+		t.Errorf("INBOX has %d messages, want 3", n)
+	}
+	if n := mailboxCount(t, user, "Trash"); n != 1 {
+		t.Errorf("Trash has %d messages, want 1", n)
+	}
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Operation") != cleanupMoved {
+			t.Errorf("row = %v, want MOVED", row)
+		}
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
 
-// NewCmd returns the "imap" cobra.Command with all 3 action subcommands.
+// NewCmd returns the "imap" cobra.Command with all 11 action subcommands.
 // Each subcommand shares persistent flags (server, auth, TLS, output).
 func NewCmd() *cobra.Command {
 	v := viper.New()
@@ -17,10 +17,11 @@ func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "imap",
 		Short: "IMAP server connectivity and authentication testing",
-		Long: `Test IMAP server connectivity, TLS configuration, authentication, and folder listing.
+		Long: `Test IMAP server connectivity, TLS configuration, authentication, folder listing,
+and message retrieval.
 
-Supports STARTTLS and IMAPS (implicit TLS) modes, PLAIN, LOGIN, and XOAUTH2 authentication,
-and connect-address override for load balancer testing.
+Supports STARTTLS and IMAPS (implicit TLS) modes, PLAIN, LOGIN, XOAUTH2, OAUTHBEARER, NTLM,
+and GSSAPI authentication, and connect-address override for load balancer testing.
 
 Environment variables use the IMAP prefix (e.g. IMAPHOST, IMAPPORT, IMAPUSERNAME).`,
 	}
@@ -32,6 +33,14 @@ Environment variables use the IMAP prefix (e.g. IMAPHOST, IMAPPORT, IMAPUSERNAME
 		newTestConnectCmd(v),
 		newTestAuthCmd(v),
 		newListFoldersCmd(v),
+		newFetchMailCmd(v),
+		newTestAppendCmd(v),
+		newIdleCmd(v),
+		newMailboxInfoCmd(v),
+		newExportCmd(v),
+		newCompareCmd(v),
+		newTestExtensionsCmd(v),
+		newCleanupCmd(v),
 	)
 
 	return cmd
@@ -87,7 +96,7 @@ func newTestAuthCmd(v *viper.Viper) *cobra.Command {
 		Use:   "testauth",
 		Short: "Test IMAP authentication",
 		Long: `Authenticate to the IMAP server using the configured credentials and auth method.
-Supports PLAIN, LOGIN, and XOAUTH2 (OAuth2 bearer token).
+Supports PLAIN, LOGIN, XOAUTH2 and OAUTHBEARER (OAuth2 bearer token), NTLM, and GSSAPI (Kerberos).
 Use --starttls or --imaps to establish TLS before authenticating.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			_ = v.BindPFlags(cmd.Flags())
@@ -129,11 +138,15 @@ Use --starttls or --imaps to establish TLS before authenticating.`,
 }
 
 func newListFoldersCmd(v *viper.Viper) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "listfolders",
-		Short: "List mailbox folders",
+		Short: "List mailbox folders with per-folder and total message counts",
 		Long: `Authenticate to the IMAP server and list all mailbox folders using the LIST command.
-Shows folder name, attributes, message count, and unseen count for each folder.`,
+For each selectable folder, STATUS reports the message, unseen, UIDNEXT and UIDVALIDITY
+counters and, when the server supports STATUS=SIZE or IMAP4rev2, the folder size.
+Totals are shown after the table.
+--snapshot saves the counters as JSON; --compare-with reports per-folder deltas against
+such a snapshot, e.g. one taken on the source server of a migration.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			_ = v.BindPFlags(cmd.Flags())
 			_ = v.BindPFlags(cmd.InheritedFlags())
@@ -171,4 +184,473 @@ Shows folder name, attributes, message count, and unseen count for each folder.`
 			return nil
 		},
 	}
+
+	f := cmd.Flags()
+	f.String("snapshot", "", "Save per-folder counters to this JSON file (env: IMAPSNAPSHOT)")
+	f.String("compare-with", "", "Compare per-folder counters with a JSON snapshot from --snapshot (env: IMAPCOMPAREWITH)")
+
+	return cmd
+}
+
+func newFetchMailCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetchmail",
+		Short: "Search a mailbox and show matching messages",
+		Long: `Authenticate, open a mailbox read-only (EXAMINE) and run an IMAP SEARCH built from
+--since, --from, --subject, --unseen and --header. The newest --limit matches are fetched
+and shown with envelope, flags, size and MIME structure (BODYSTRUCTURE).
+With --save-dir the full messages are written as .eml files; \Seen flags are not changed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionFetchMail
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionFetchMail, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := fetchMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "INBOX", "Mailbox to search (env: IMAPMAILBOX)")
+	f.String("since", "", "Only messages received since a date (YYYY-MM-DD) or a duration ago (e.g. 24h, 7d) (env: IMAPSINCE)")
+	f.String("from", "", "Only messages whose From header contains this text (env: IMAPFROM)")
+	f.String("subject", "", "Only messages whose Subject header contains this text (env: IMAPSUBJECT)")
+	f.Bool("unseen", false, "Only messages without the \\Seen flag (env: IMAPUNSEEN)")
+	f.StringArray("header", nil, "Only messages with a header containing a value, as \"Name: value\" (repeatable) (env: IMAPHEADER)")
+	f.Int("limit", 10, "Number of newest matching messages to fetch (env: IMAPLIMIT)")
+	f.String("save-dir", "", "Write the fetched messages as .eml files to this directory (env: IMAPSAVEDIR)")
+
+	return cmd
+}
+
+func newTestAppendCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "testappend",
+		Short: "Upload a message with APPEND and verify it round-trips",
+		Long: `Authenticate and APPEND a generated message (or --eml file) to --mailbox with optional
+--flags and --internal-date, then read it back by UID and compare it byte for byte.
+The UID comes from APPENDUID when the server supports UIDPLUS, otherwise the message is
+found by Message-ID. Use --delete to remove the message afterwards (UID EXPUNGE).
+Checks write access, quota behavior and message size limits for IMAP migration tools.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionTestAppend
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionTestAppend, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := testAppend(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "INBOX", "Mailbox to append to (env: IMAPMAILBOX)")
+	f.String("eml", "", "Message file to upload; line endings are normalized to CRLF (default: generate a test message) (env: IMAPEML)")
+	f.String("flags", "", "Comma-separated flags to set, e.g. \\Seen,\\Flagged,$Label1 (env: IMAPFLAGS)")
+	f.String("internal-date", "", "Internal date for the message, RFC 3339 or YYYY-MM-DD (default: server time) (env: IMAPINTERNALDATE)")
+	f.Int("size-kb", 4, "Approximate size of the generated message in KB (env: IMAPSIZEKB)")
+	f.Bool("delete", false, "Delete and expunge the message after verification (env: IMAPDELETE)")
+
+	return cmd
+}
+
+func newIdleCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "idle",
+		Short: "Monitor a mailbox with IDLE and measure new-mail push latency",
+		Long: `Authenticate, open --mailbox read-only and keep an IDLE session open, printing
+EXISTS, EXPUNGE and FETCH notifications as they arrive. IDLE is re-issued every --refresh
+minutes (before the 29-minute server timeout) and the session is re-established when the
+connection drops, up to --maxretries consecutive failures.
+With --probe the monitor APPENDs a probe message with a unique subject once IDLE is up,
+reports the time from the APPEND to its notification and deletes it again. With
+--expect-subject it stops at the first new message whose subject contains the text instead.
+Either way, the delays from the message's Date header and INTERNALDATE are shown too.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionIdle
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionIdle, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := monitorIdle(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "INBOX", "Mailbox to monitor (env: IMAPMAILBOX)")
+	f.Int("duration", 0, "Stop monitoring after this many seconds (0 = until Ctrl+C) (env: IMAPDURATION)")
+	f.Int("refresh", 25, "Re-issue IDLE every N minutes, 1-29 (env: IMAPREFRESH)")
+	f.String("expect-subject", "", "Stop when a new message whose subject contains this text arrives and report its push latency (env: IMAPEXPECTSUBJECT)")
+	f.Bool("probe", false, "APPEND a probe message once IDLE is up and report the time until it is pushed (env: IMAPPROBE)")
+
+	return cmd
+}
+
+func newMailboxInfoCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mailboxinfo",
+		Short: "Report namespaces, quotas and access rights of mailboxes",
+		Long: `Authenticate and report the personal, other users' and shared namespaces (NAMESPACE),
+then for each mailbox matching --pattern its quota roots with usage and limits (GETQUOTAROOT,
+when QUOTA is advertised) and the rights of the logged-in user (MYRIGHTS, when ACL is
+advertised). The full access control list (GETACL) is shown for mailboxes the user may
+administer. Useful for diagnosing shared-mailbox permission problems.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionMailboxInfo
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionMailboxInfo, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := mailboxInfo(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	cmd.Flags().String("pattern", "*", "LIST pattern selecting the mailboxes to inspect, e.g. \"Shared/*\" (env: IMAPPATTERN)")
+
+	return cmd
+}
+
+func newExportCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export a folder or folder tree to EML files, mbox or Maildir",
+		Long: `Authenticate and download every message of --mailbox (with --recursive, also the folders
+below it) into --output-dir as one .eml file per message, one mbox file per folder or one
+Maildir per folder. Messages are fetched read-only with BODY.PEEK, so \Seen flags are not
+changed, and --ratelimit applies per message.
+Every message is recorded in manifest.csv with its UID, UIDVALIDITY, flags, internal date
+and SHA-256. Running the same export again resumes after the last recorded UID.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionExport
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionExport, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := exportMailboxes(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "INBOX", "Mailbox to export (env: IMAPMAILBOX)")
+	f.Bool("recursive", false, "Also export the folders below --mailbox (env: IMAPRECURSIVE)")
+	f.String("format", "eml", "Export format: eml, mbox, maildir (env: IMAPFORMAT)")
+	f.String("output-dir", "", "Directory to export into; reuse it to resume an export (env: IMAPOUTPUTDIR)")
+
+	return cmd
+}
+
+func newCompareCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Verify a migration by comparing the messages of two mailboxes",
+		Long: `Authenticate to the source server (the usual flags) and to the target server (settings
+from --target-config, falling back to the source settings) and compare every folder
+matching --pattern. Folders are matched by name with the hierarchy delimiter normalised;
+messages are matched by Message-ID, size and Date header.
+For each folder the messages missing on the target, the extra messages on the target and
+the messages whose flags differ are reported. With --copy-missing, missing messages are
+appended to the target (creating the folder if needed) with their flags and internal date.
+The command fails if messages are still missing on the target.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionCompare
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			target, err := TargetConfigFromViper(v, config.TargetConfigFile)
+			if err != nil {
+				return err
+			}
+			target.Action = ActionCompare
+			if err := validateConfiguration(target); err != nil {
+				return fmt.Errorf("validation of --target-config failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionCompare, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port, "target_host", target.Host)
+
+			if err := compareMailboxes(ctx, config, target, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("target-config", "", "YAML config file with the target server settings; unset keys are taken from the source (env: IMAPTARGETCONFIG)")
+	f.String("pattern", "*", "LIST pattern selecting the folders to compare (env: IMAPPATTERN)")
+	f.Bool("copy-missing", false, "Append messages missing on the target, with their flags and internal date (env: IMAPCOPYMISSING)")
+
+	return cmd
+}
+
+func newTestExtensionsCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "testextensions",
+		Short: "Identify the server and check that advertised extensions work",
+		Long: `Authenticate and check the extensions the server advertises instead of only listing them:
+send ID (RFC 2971) and show the server's name, vendor and version; ENABLE CONDSTORE, QRESYNC
+and UTF8=ACCEPT one at a time; and turn on COMPRESS=DEFLATE (RFC 4978), fetching a sample of
+up to 10 messages from --mailbox before and after to measure the bytes saved.
+Extensions that are not advertised are skipped; the action fails when an advertised one does not work.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionTestExtensions
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionTestExtensions, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := testExtensions(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	cmd.Flags().String("mailbox", "INBOX", "Mailbox to open for the CONDSTORE and COMPRESS checks (env: IMAPMAILBOX)")
+
+	return cmd
+}
+
+func newCleanupCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete old test messages left behind by other actions",
+		Long: `Delete test messages from --mailbox that are older than --older-than (default 24h).
+Messages are matched by a header (--header "Name: value", repeatable; by default the
+X-GoMailTest-Probe header set by testappend and smtp testfilter) and/or a --subject-prefix.
+Matches are flagged \Deleted and removed with UID EXPUNGE, or moved to the Trash mailbox
+with --move-to-trash. Use --dry-run to list the matches without changing anything.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionCleanup
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("imaptool", ActionCleanup, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "IMAP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := cleanupMessages(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "INBOX", "Mailbox to clean up (env: IMAPMAILBOX)")
+	f.StringArray("header", nil, "Match messages with a header containing a value, as \"Name: value\" or \"Name:\" for presence (repeatable) (env: IMAPHEADER)")
+	f.String("subject-prefix", "", "Match messages whose subject starts with this prefix (env: IMAPSUBJECTPREFIX)")
+	f.String("older-than", "24h", "Only messages received before this: a date (YYYY-MM-DD), days (7d) or a duration (48h) (env: IMAPOLDERTHAN)")
+	f.Bool("dry-run", false, "List the matching messages without deleting or moving them (env: IMAPDRYRUN)")
+	f.Bool("move-to-trash", false, "Move the matches to the Trash mailbox instead of expunging them; requires MOVE (env: IMAPMOVETOTRASH)")
+
+	return cmd
 }
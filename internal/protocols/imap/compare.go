@@ -0,0 +1,455 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// compareDetailLimit caps the messages listed per folder and kind on the
+// console; the CSV log lists all of them.
+const compareDetailLimit = 20
+
+// Message comparison results, in addition to compareMissing (on the source
+// only) from the folder comparison.
+const (
+	compareExtra   = "EXTRA"   // On the target only
+	compareFlags   = "FLAGS"   // On both, with different flags
+	compareCopied  = "COPIED"  // Appended to the target by --copy-missing
+	compareSummary = "SUMMARY" // Per-folder counts
+)
+
+// comparedMessage is what compare knows about one message.
+type comparedMessage struct {
+	UID          imap.UID
+	MessageID    string
+	Subject      string
+	Date         time.Time // Date header
+	InternalDate time.Time
+	Size         int64
+	Flags        []imap.Flag
+}
+
+// key identifies a message across servers: Message-ID, size and Date header.
+func (m comparedMessage) key() string {
+	return fmt.Sprintf("%s|%d|%d", m.MessageID, m.Size, m.Date.Unix())
+}
+
+// flagMismatch is a message found on both servers with different flags.
+type flagMismatch struct {
+	Source, Target comparedMessage
+	Detail         string
+}
+
+// compareCounts accumulates per-folder or overall results.
+type compareCounts struct {
+	Source, Target, Missing, Extra, Flags, Copied int
+}
+
+func (c *compareCounts) add(o compareCounts) {
+	c.Source += o.Source
+	c.Target += o.Target
+	c.Missing += o.Missing
+	c.Extra += o.Extra
+	c.Flags += o.Flags
+	c.Copied += o.Copied
+}
+
+// mailboxComparer compares the folders of two authenticated sessions.
+type mailboxComparer struct {
+	config, target *Config
+	source, dest   *IMAPClient
+	csvLogger      logger.Logger
+	slogLogger     *slog.Logger
+}
+
+// compareMailboxes walks the folders matching --pattern on the source and on
+// the --target-config server, and reports per folder the messages missing on
+// the target, the extra ones and those with different flags. Messages are
+// matched by Message-ID, size and Date header. With --copy-missing, missing
+// messages (and folders) are created on the target with their flags and
+// internal date.
+func compareMailboxes(ctx context.Context, config, target *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Comparing %s@%s:%d with %s@%s:%d...\n",
+		maskUsername(config.Username), config.Host, config.Port,
+		maskUsername(target.Username), target.Host, target.Port)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Target_Server", "Target_Port", "Folder", "Target_Folder",
+		"Result", "Message_ID", "Date", "Size", "Subject", "Detail", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	c := &mailboxComparer{config: config, target: target, csvLogger: csvLogger, slogLogger: slogLogger}
+
+	fmt.Println("\nSource:")
+	source, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		c.writeRow("FAILURE", "", "", "", nil, "source", err)
+		return err
+	}
+	defer func() { _ = source.Logout() }()
+	c.source = source
+
+	fmt.Println("\nTarget:")
+	dest, err := openSession(ctx, target, slogLogger)
+	if err != nil {
+		c.writeRow("FAILURE", "", "", "", nil, "target", err)
+		return err
+	}
+	defer func() { _ = dest.Logout() }()
+	c.dest = dest
+
+	sourceFolders, err := listSelectable(ctx, source, config.MailboxPattern)
+	if err != nil {
+		c.writeRow("FAILURE", "", "", "", nil, "source", err)
+		return err
+	}
+	targetFolders, err := listSelectable(ctx, dest, config.MailboxPattern)
+	if err != nil {
+		c.writeRow("FAILURE", "", "", "", nil, "target", err)
+		return err
+	}
+	fmt.Printf("\n✓ %d source and %d target folders matching %q\n", len(sourceFolders), len(targetFolders), config.MailboxPattern)
+
+	targetByKey := make(map[string]MailboxInfo, len(targetFolders))
+	targetDelimiter := "/"
+	for _, f := range targetFolders {
+		targetByKey[folderKey(f.Name, f.Delimiter)] = f
+		if f.Delimiter != "" {
+			targetDelimiter = f.Delimiter
+		}
+	}
+
+	var totals compareCounts
+	failures := 0
+	for _, folder := range sourceFolders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		key := folderKey(folder.Name, folder.Delimiter)
+		targetFolder, exists := targetByKey[key]
+		delete(targetByKey, key)
+		if !exists {
+			targetFolder = MailboxInfo{Name: strings.ReplaceAll(key, "/", targetDelimiter), Delimiter: targetDelimiter}
+		}
+
+		counts, err := c.compareFolder(ctx, folder, targetFolder, exists)
+		totals.add(counts)
+		if err != nil {
+			failures++
+			logger.LogError(slogLogger, "Folder comparison failed", "folder", folder.Name, "error", err)
+			fmt.Printf("  ✗ %v\n", err)
+			c.writeRow("FAILURE", folder.Name, targetFolder.Name, compareSummary, nil, "", err)
+		}
+	}
+
+	extraFolders := make([]string, 0, len(targetByKey))
+	for _, f := range targetByKey {
+		extraFolders = append(extraFolders, f.Name)
+	}
+	sort.Strings(extraFolders)
+	for _, name := range extraFolders {
+		fmt.Printf("\n⚠ Folder %s exists only on the target\n", name)
+		c.writeRow("SUCCESS", "", name, compareExtra, nil, "folder exists only on the target", nil)
+	}
+
+	uncopied := totals.Missing - totals.Copied
+	fmt.Printf("\nSource %d messages, target %d: %d missing, %d extra, %d flag mismatches",
+		totals.Source, totals.Target, totals.Missing, totals.Extra, totals.Flags)
+	if config.CopyMissing {
+		fmt.Printf(", %d copied", totals.Copied)
+	}
+	fmt.Println()
+
+	logger.LogInfo(slogLogger, "Compare completed",
+		"source", config.Host,
+		"target", target.Host,
+		"folders", len(sourceFolders),
+		"missing", totals.Missing,
+		"extra", totals.Extra,
+		"flag_mismatches", totals.Flags,
+		"copied", totals.Copied,
+		"failures", failures)
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d folders could not be compared", failures, len(sourceFolders))
+	}
+	if uncopied > 0 {
+		return fmt.Errorf("%d messages are missing on the target", uncopied)
+	}
+	fmt.Println("✓ All source messages are present on the target")
+	return nil
+}
+
+// compareFolder compares one source folder with its target counterpart,
+// creating the target folder and copying missing messages with --copy-missing.
+func (c *mailboxComparer) compareFolder(ctx context.Context, folder, targetFolder MailboxInfo, exists bool) (compareCounts, error) {
+	var counts compareCounts
+	fmt.Printf("\n%s → %s\n", folder.Name, targetFolder.Name)
+
+	sourceMessages, err := fetchComparedMessages(ctx, c.source, folder.Name)
+	if err != nil {
+		return counts, fmt.Errorf("source: %w", err)
+	}
+	counts.Source = len(sourceMessages)
+
+	var targetMessages []comparedMessage
+	if exists {
+		if targetMessages, err = fetchComparedMessages(ctx, c.dest, targetFolder.Name); err != nil {
+			return counts, fmt.Errorf("target: %w", err)
+		}
+	} else {
+		fmt.Println("  ✗ Folder does not exist on the target")
+		c.writeRow("FAILURE", folder.Name, targetFolder.Name, compareMissing, nil, "folder does not exist on the target", nil)
+	}
+	counts.Target = len(targetMessages)
+
+	missing, extra, mismatches := matchMessages(sourceMessages, targetMessages)
+	counts.Missing, counts.Extra, counts.Flags = len(missing), len(extra), len(mismatches)
+
+	fmt.Printf("  Source %d, target %d: %d missing, %d extra, %d flag mismatches\n",
+		counts.Source, counts.Target, counts.Missing, counts.Extra, counts.Flags)
+
+	for i, m := range missing {
+		if i < compareDetailLimit {
+			fmt.Printf("  ✗ Missing  %s\n", describeComparedMessage(m))
+		}
+		c.writeRow("FAILURE", folder.Name, targetFolder.Name, compareMissing, &m, "", nil)
+	}
+	for i, m := range extra {
+		if i < compareDetailLimit {
+			fmt.Printf("  ⚠ Extra    %s\n", describeComparedMessage(m))
+		}
+		c.writeRow("SUCCESS", folder.Name, targetFolder.Name, compareExtra, &m, "", nil)
+	}
+	for i, m := range mismatches {
+		if i < compareDetailLimit {
+			fmt.Printf("  ⚠ Flags    %s: %s\n", describeComparedMessage(m.Source), m.Detail)
+		}
+		c.writeRow("SUCCESS", folder.Name, targetFolder.Name, compareFlags, &m.Source, m.Detail, nil)
+	}
+	if hidden := max(len(missing), len(extra), len(mismatches)) - compareDetailLimit; hidden > 0 {
+		fmt.Printf("  ... more in the log file\n")
+	}
+
+	if c.config.CopyMissing && len(missing) > 0 {
+		if !exists {
+			if err := c.dest.CreateMailbox(ctx, targetFolder.Name); err != nil {
+				return counts, fmt.Errorf("target: %w", err)
+			}
+			fmt.Printf("  ✓ Created %s on the target\n", targetFolder.Name)
+		}
+		for _, m := range missing {
+			if err := c.copyMessage(ctx, targetFolder.Name, m); err != nil {
+				return counts, fmt.Errorf("copy of UID %d failed: %w", m.UID, err)
+			}
+			counts.Copied++
+			c.writeRow("SUCCESS", folder.Name, targetFolder.Name, compareCopied, &m, "", nil)
+		}
+		fmt.Printf("  ✓ Copied %d missing message(s)\n", counts.Copied)
+	}
+
+	status := "SUCCESS"
+	if counts.Missing > counts.Copied {
+		status = "FAILURE"
+	}
+	c.writeRow(status, folder.Name, targetFolder.Name, compareSummary, nil,
+		fmt.Sprintf("source %d, target %d, missing %d, extra %d, flags %d, copied %d",
+			counts.Source, counts.Target, counts.Missing, counts.Extra, counts.Flags, counts.Copied), nil)
+	return counts, nil
+}
+
+// copyMessage appends a source message to the target folder with its flags
+// and internal date. The source folder must be selected.
+func (c *mailboxComparer) copyMessage(ctx context.Context, mailbox string, m comparedMessage) error {
+	bodySection := &imap.FetchItemBodySection{Peek: true}
+	fetched, err := c.source.FetchUIDs(ctx, []imap.UID{m.UID}, &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{bodySection},
+	})
+	if err != nil {
+		return err
+	}
+	if len(fetched) == 0 {
+		return fmt.Errorf("message no longer exists on the source")
+	}
+	body := fetched[0].FindBodySection(bodySection)
+	if body == nil {
+		return fmt.Errorf("server returned no message body")
+	}
+
+	_, err = c.dest.AppendMessage(ctx, mailbox, body, &imap.AppendOptions{
+		Flags: withoutRecent(m.Flags),
+		Time:  m.InternalDate,
+	})
+	return err
+}
+
+// writeRow logs one compare result. m may be nil for folder-level rows.
+func (c *mailboxComparer) writeRow(status, folder, targetFolder, result string, m *comparedMessage, detail string, err error) {
+	var messageID, date, size, subject, errMsg string
+	if m != nil {
+		messageID, date, size, subject = m.MessageID, formatDate(m.Date), fmt.Sprintf("%d", m.Size), m.Subject
+	}
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if logErr := c.csvLogger.WriteRow([]string{
+		c.config.Action, status, c.config.Host, fmt.Sprintf("%d", c.config.Port),
+		c.target.Host, fmt.Sprintf("%d", c.target.Port), folder, targetFolder,
+		result, messageID, date, size, subject, detail, errMsg,
+	}); logErr != nil {
+		logger.LogError(c.slogLogger, "Failed to write CSV row", "error", logErr)
+	}
+}
+
+// listSelectable lists the selectable mailboxes matching pattern.
+func listSelectable(ctx context.Context, client *IMAPClient, pattern string) ([]MailboxInfo, error) {
+	mailboxes, err := client.ListMailboxNames(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	selectable := mailboxes[:0]
+	for _, mb := range mailboxes {
+		if isSelectable(mb.Attributes) {
+			selectable = append(selectable, mb)
+		}
+	}
+	return selectable, nil
+}
+
+// fetchComparedMessages opens mailbox read-only and fetches the envelope,
+// size, flags and internal date of every message.
+func fetchComparedMessages(ctx context.Context, client *IMAPClient, mailbox string) ([]comparedMessage, error) {
+	data, err := client.SelectMailbox(ctx, mailbox, true)
+	if err != nil {
+		return nil, err
+	}
+	if data.NumMessages == 0 {
+		return nil, nil
+	}
+
+	fetched, err := client.FetchSeqRange(ctx, 1, data.NumMessages, &imap.FetchOptions{
+		UID:          true,
+		Envelope:     true,
+		Flags:        true,
+		InternalDate: true,
+		RFC822Size:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]comparedMessage, 0, len(fetched))
+	for _, msg := range fetched {
+		m := comparedMessage{
+			UID:          msg.UID,
+			InternalDate: msg.InternalDate,
+			Size:         msg.RFC822Size,
+			Flags:        msg.Flags,
+		}
+		if msg.Envelope != nil {
+			m.MessageID = msg.Envelope.MessageID
+			m.Subject = msg.Envelope.Subject
+			m.Date = msg.Envelope.Date
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// matchMessages pairs source and target messages by key. Duplicates are
+// matched one to one, so a message stored twice on the source and once on
+// the target counts as one missing.
+func matchMessages(source, target []comparedMessage) (missing, extra []comparedMessage, mismatches []flagMismatch) {
+	byKey := make(map[string][]comparedMessage, len(target))
+	for _, m := range target {
+		byKey[m.key()] = append(byKey[m.key()], m)
+	}
+
+	for _, s := range source {
+		candidates := byKey[s.key()]
+		if len(candidates) == 0 {
+			missing = append(missing, s)
+			continue
+		}
+		t := candidates[0]
+		byKey[s.key()] = candidates[1:]
+		if detail := flagDifference(s.Flags, t.Flags); detail != "" {
+			mismatches = append(mismatches, flagMismatch{Source: s, Target: t, Detail: detail})
+		}
+	}
+
+	for _, candidates := range byKey {
+		extra = append(extra, candidates...)
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].UID < extra[j].UID })
+	return missing, extra, mismatches
+}
+
+// flagDifference describes how the target flags differ from the source
+// flags, ignoring case and \Recent. It returns "" when they are the same.
+func flagDifference(source, target []imap.Flag) string {
+	normalize := func(flags []imap.Flag) map[string]imap.Flag {
+		set := make(map[string]imap.Flag, len(flags))
+		for _, f := range withoutRecent(flags) {
+			set[strings.ToLower(string(f))] = f
+		}
+		return set
+	}
+	s, t := normalize(source), normalize(target)
+
+	var lacking, added []string
+	for k, f := range s {
+		if _, ok := t[k]; !ok {
+			lacking = append(lacking, string(f))
+		}
+	}
+	for k, f := range t {
+		if _, ok := s[k]; !ok {
+			added = append(added, string(f))
+		}
+	}
+	sort.Strings(lacking)
+	sort.Strings(added)
+
+	var parts []string
+	if len(lacking) > 0 {
+		parts = append(parts, "target lacks "+strings.Join(lacking, " "))
+	}
+	if len(added) > 0 {
+		parts = append(parts, "target adds "+strings.Join(added, " "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// withoutRecent drops the session-only \Recent flag, which cannot be set
+// by APPEND.
+func withoutRecent(flags []imap.Flag) []imap.Flag {
+	result := make([]imap.Flag, 0, len(flags))
+	for _, f := range flags {
+		if !strings.EqualFold(string(f), `\Recent`) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// describeComparedMessage renders a message for the console.
+func describeComparedMessage(m comparedMessage) string {
+	id := m.MessageID
+	if id == "" {
+		id = fmt.Sprintf("UID %d (no Message-ID)", m.UID)
+	}
+	return fmt.Sprintf("%s  %s  %d bytes  %q", id, formatDate(m.Date), m.Size, m.Subject)
+}
@@ -0,0 +1,164 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+	"github.com/spf13/viper"
+)
+
+const testMessageGamma = `From: Dave <dave@example.com>
+To: bob@example.com
+Subject: Gamma notice
+Message-ID: <gamma@example.com>
+Date: Wed, 04 Jan 2026 10:00:00 +0000
+
+Gamma body
+`
+
+func TestFlagDifference(t *testing.T) {
+	tests := []struct {
+		source, target []imap.Flag
+		want           string
+	}{
+		{[]imap.Flag{imap.FlagSeen}, []imap.Flag{`\seen`, `\Recent`}, ""},
+		{[]imap.Flag{imap.FlagSeen, imap.FlagFlagged}, []imap.Flag{imap.FlagSeen}, `target lacks \Flagged`},
+		{nil, []imap.Flag{"$Junk", imap.FlagSeen}, `target adds $Junk \Seen`},
+		{[]imap.Flag{imap.FlagAnswered}, []imap.Flag{imap.FlagDraft}, `target lacks \Answered; target adds \Draft`},
+	}
+	for _, tt := range tests {
+		if got := flagDifference(tt.source, tt.target); got != tt.want {
+			t.Errorf("flagDifference(%v, %v) = %q, want %q", tt.source, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestMatchMessages(t *testing.T) {
+	date := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	msg := func(uid imap.UID, id string, size int64, flags ...imap.Flag) comparedMessage {
+		return comparedMessage{UID: uid, MessageID: id, Size: size, Date: date, Flags: flags}
+	}
+	source := []comparedMessage{
+		msg(1, "a@x", 100, imap.FlagSeen),
+		msg(2, "a@x", 100), // duplicate on the source
+		msg(3, "b@x", 200),
+		msg(4, "c@x", 300),
+	}
+	target := []comparedMessage{
+		msg(10, "a@x", 100, imap.FlagSeen),
+		msg(11, "b@x", 200, imap.FlagSeen),
+		msg(12, "c@x", 301), // size differs: not the same message
+		msg(13, "d@x", 400),
+	}
+
+	missing, extra, mismatches := matchMessages(source, target)
+	if len(missing) != 2 || missing[0].UID != 2 || missing[1].UID != 4 {
+		t.Errorf("missing = %+v, want UIDs 2 and 4", missing)
+	}
+	if len(extra) != 2 || extra[0].UID != 12 || extra[1].UID != 13 {
+		t.Errorf("extra = %+v, want UIDs 12 and 13", extra)
+	}
+	if len(mismatches) != 1 || mismatches[0].Source.UID != 3 || mismatches[0].Detail != `target adds \Seen` {
+		t.Errorf("mismatches = %+v, want UID 3 with \\Seen added", mismatches)
+	}
+}
+
+func TestTargetConfigFromViper(t *testing.T) {
+	v := viper.New()
+	v.Set("host", "imap.example.com")
+	v.Set("port", 993)
+	v.Set("imaps", true)
+	v.Set("username", "alice@example.com")
+	v.Set("password", "source-secret")
+
+	path := filepath.Join(t.TempDir(), "target.yaml")
+	if err := os.WriteFile(path, []byte("username: bob@example.com\npassword: file-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("IMAPTARGETPASSWORD", "env-secret")
+
+	target, err := TargetConfigFromViper(v, path)
+	if err != nil {
+		t.Fatalf("TargetConfigFromViper() error = %v", err)
+	}
+	if target.Host != "imap.example.com" || target.Port != 993 || !target.IMAPS {
+		t.Errorf("target server = %s:%d imaps=%v, want settings inherited from the source", target.Host, target.Port, target.IMAPS)
+	}
+	if target.Username != "bob@example.com" || target.Password != "env-secret" {
+		t.Errorf("target account = %s / %s, want bob@example.com / env-secret", target.Username, target.Password)
+	}
+
+	if _, err := TargetConfigFromViper(v, filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("TargetConfigFromViper() with a missing file expected error")
+	}
+}
+
+func TestCompareMailboxes_CopyMissing(t *testing.T) {
+	source, sourceUser := newTestIMAPServer(t, testMessageBeta)
+	appendTestMessage(t, sourceUser, "INBOX", testMessageAlpha, imap.FlagSeen)
+	if err := sourceUser.Create("Archive", nil); err != nil {
+		t.Fatal(err)
+	}
+	appendTestMessage(t, sourceUser, "Archive", testMessageGamma, imap.FlagFlagged)
+
+	targetUser := imapmemserver.NewUser("tester", "secret")
+	if err := targetUser.Create("INBOX", nil); err != nil {
+		t.Fatal(err)
+	}
+	appendTestMessage(t, targetUser, "INBOX", testMessageAlpha)
+	appendTestMessage(t, targetUser, "INBOX", testMessageGamma)
+	target := startTestIMAPServer(t, targetUser, nil)
+
+	source.Action = ActionCompare
+	target.Action = ActionCompare
+
+	csvLog := &recordingLogger{}
+	if err := compareMailboxes(t.Context(), source, target, csvLog, nil); err == nil {
+		t.Fatal("compareMailboxes() expected error for missing messages")
+	}
+	results := make(map[string]int)
+	for _, row := range csvLog.rows {
+		results[csvLog.column(row, "Result")+" "+csvLog.column(row, "Folder")]++
+	}
+	for key, want := range map[string]int{
+		"MISSING INBOX":   1, // beta
+		"EXTRA INBOX":     1, // gamma
+		"FLAGS INBOX":     1, // alpha without \Seen
+		"MISSING Archive": 2, // the folder and gamma
+	} {
+		if results[key] != want {
+			t.Errorf("%s rows = %d, want %d (all: %v)", key, results[key], want, results)
+		}
+	}
+
+	source.CopyMissing = true
+	if err := compareMailboxes(t.Context(), source, target, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("compareMailboxes() with --copy-missing error = %v", err)
+	}
+
+	status, err := targetUser.Status("Archive", &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatalf("Archive was not created on the target: %v", err)
+	}
+	if *status.NumMessages != 1 {
+		t.Errorf("target Archive has %d messages, want 1", *status.NumMessages)
+	}
+
+	source.CopyMissing = false
+	csvLog = &recordingLogger{}
+	if err := compareMailboxes(t.Context(), source, target, csvLog, nil); err != nil {
+		t.Fatalf("compareMailboxes() after copy error = %v", err)
+	}
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Folder") == "Archive" && csvLog.column(row, "Result") == compareFlags {
+			t.Errorf("copied message lost its flags: %v", row)
+		}
+	}
+}
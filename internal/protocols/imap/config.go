@@ -25,8 +25,10 @@ type Config struct {
 	// Authentication
 	Username    string
 	Password    string
-	AccessToken string // OAuth2 access token for XOAUTH2 authentication
-	AuthMethod  string // PLAIN, LOGIN, XOAUTH2, or "auto"
+	AccessToken string // OAuth2 access token for XOAUTH2 or OAUTHBEARER authentication
+	AuthMethod  string // PLAIN, LOGIN, XOAUTH2, OAUTHBEARER, NTLM, GSSAPI, or "auto"
+	Realm       string // Kerberos realm for GSSAPI (auto-extracted from user@REALM if empty)
+	KDCAddress  string // KDC host:port override for GSSAPI (uses DNS SRV if empty)
 
 	// TLS configuration
 	IMAPS      bool   // Use IMAPS (implicit TLS on port 993)
@@ -40,10 +42,57 @@ type Config struct {
 	ConnectAddress string // Override address for TCP connection (IP or hostname)
 	IPv4Only       bool   // Force resolving --host/--address to an IPv4 (A record) address
 	IPv6Only       bool   // Force resolving --host/--address to an IPv6 (AAAA record) address
+	ProxyProtocol  string // Send a HAProxy PROXY header after connect: v1, v2 (empty = disabled)
+	ProxySource    string // Client address announced in the PROXY header (ip or ip:port; default: local address)
 	ProxyURL       string
 	MaxRetries     int
 	RetryDelay     time.Duration
 
+	// Folder statistics (listfolders)
+	SnapshotFile string // Write per-folder counters to this JSON file
+	CompareWith  string // Compare per-folder counters with this JSON snapshot
+
+	// Message retrieval (fetchmail)
+	Mailbox       string   // Mailbox to select (default INBOX)
+	SearchSince   string   // Only messages received since this date (YYYY-MM-DD) or duration ago (e.g. 24h, 7d)
+	SearchFrom    string   // FROM header substring
+	SearchSubject string   // SUBJECT header substring
+	SearchUnseen  bool     // Only messages without the \Seen flag
+	SearchHeaders []string // Additional "Name: value" header criteria
+	Limit         int      // Number of newest matches to fetch
+	SaveDir       string   // Directory to write full messages as .eml (empty = don't save)
+
+	// Append test (testappend)
+	AppendFile   string   // .eml file to upload (empty = generate a test message)
+	AppendFlags  []string // Flags to set on the appended message (e.g. \Seen, $Label1)
+	AppendDate   string   // Internal date for the appended message (RFC 3339 or YYYY-MM-DD)
+	AppendSizeKB int      // Approximate size of the generated message in KB
+	DeleteAfter  bool     // Delete (and UID EXPUNGE) the message after verification
+
+	// IDLE monitor (idle)
+	IdleDuration  time.Duration // How long to monitor (0 = until interrupted)
+	IdleRefresh   time.Duration // Re-issue IDLE after this long (must stay below the 29-minute server timeout)
+	ExpectSubject string        // Stop when a new message with this subject substring arrives and report its latency
+	IdleProbe     bool          // APPEND a probe message once IDLE is up and time it until it is pushed
+
+	// Mailbox report (mailboxinfo)
+	MailboxPattern string // LIST pattern selecting the mailboxes to inspect (default "*")
+
+	// Mailbox export (export)
+	ExportFormat string // eml, mbox or maildir
+	OutputDir    string // Directory receiving the export and its manifest
+	Recursive    bool   // Also export the folders below --mailbox
+
+	// Mailbox comparison (compare)
+	TargetConfigFile string // YAML config file with the target server settings
+	CopyMissing      bool   // APPEND messages missing on the target
+
+	// Test message cleanup (cleanup)
+	SubjectPrefix string // Only messages whose subject starts with this text
+	OlderThan     string // Only messages received before this date (YYYY-MM-DD) or duration ago (e.g. 24h, 7d)
+	DryRun        bool   // List the matches without deleting or moving them
+	MoveToTrash   bool   // MOVE matches to the Trash mailbox instead of deleting them
+
 	// Runtime configuration
 	VerboseMode  bool
 	LogLevel     string
@@ -54,9 +103,17 @@ type Config struct {
 
 // Action constants
 const (
-	ActionTestConnect = "testconnect"
-	ActionTestAuth    = "testauth"
-	ActionListFolders = "listfolders"
+	ActionTestConnect    = "testconnect"
+	ActionTestAuth       = "testauth"
+	ActionListFolders    = "listfolders"
+	ActionFetchMail      = "fetchmail"
+	ActionTestAppend     = "testappend"
+	ActionIdle           = "idle"
+	ActionMailboxInfo    = "mailboxinfo"
+	ActionExport         = "export"
+	ActionCompare        = "compare"
+	ActionTestExtensions = "testextensions"
+	ActionCleanup        = "cleanup"
 )
 
 // NewConfig creates a new Config with default values.
@@ -76,6 +133,19 @@ func NewConfig() *Config {
 		OutputFormat: "text",
 		LogFormat:    "csv",
 		RateLimit:    0,
+
+		Mailbox: "INBOX",
+		Limit:   10,
+
+		AppendSizeKB: 4,
+
+		IdleRefresh: 25 * time.Minute,
+
+		MailboxPattern: "*",
+
+		ExportFormat: exportFormatEML,
+
+		OlderThan: "24h",
 	}
 }
 
@@ -92,8 +162,10 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 	// Authentication
 	f.String("username", "", "Username for authentication (env: IMAPUSERNAME)")
 	f.String("password", "", "Password for authentication (env: IMAPPASSWORD)")
-	f.String("accesstoken", "", "OAuth2 access token for XOAUTH2 authentication (env: IMAPACCESSTOKEN)")
-	f.String("authmethod", "auto", "Authentication method: PLAIN, LOGIN, XOAUTH2, auto (env: IMAPAUTHMETHOD)")
+	f.String("accesstoken", "", "OAuth2 access token for XOAUTH2 or OAUTHBEARER authentication (env: IMAPACCESSTOKEN)")
+	f.String("authmethod", "auto", "Authentication method: PLAIN, LOGIN, XOAUTH2, OAUTHBEARER, NTLM, GSSAPI, auto (env: IMAPAUTHMETHOD)")
+	f.String("realm", "", "Kerberos realm for GSSAPI (auto-extracted from user@REALM if omitted) (env: IMAPREALM)")
+	f.String("kdc", "", "KDC address override for GSSAPI (host or host:port; uses DNS SRV if omitted) (env: IMAPKDC)")
 
 	// TLS
 	f.Bool("starttls", false, "Force STARTTLS usage (env: IMAPSTARTTLS)")
@@ -107,6 +179,8 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 	f.String("address", "", "Optional: connect to this IP/hostname instead of --host (e.g. to test a specific server behind a load balancer); --host is still used for SNI, certificate checks, and authentication (env: IMAPADDRESS)")
 	f.Bool("ipv4", false, "Force IPv4: resolve --host/--address to an A record and connect over IPv4 (env: IMAPIPV4)")
 	f.Bool("ipv6", false, "Force IPv6: resolve --host/--address to an AAAA record and connect over IPv6 (env: IMAPIPV6)")
+	f.String("proxy-protocol", "", "Send a HAProxy PROXY protocol header (v1 or v2) right after TCP connect, for listeners behind HAProxy (env: IMAPPROXYPROTOCOL)")
+	f.String("proxy-source", "", "Client address to announce in the PROXY header, as ip or ip:port (default: local connection address) (env: IMAPPROXYSOURCE)")
 	f.String("proxy", "", "HTTP/HTTPS proxy URL (env: IMAPPROXY)")
 	f.Int("maxretries", 3, "Maximum retry attempts (env: IMAPMAXRETRIES)")
 	f.Int("retrydelay", 2000, "Retry delay in milliseconds (env: IMAPRETRYDELAY)")
@@ -123,28 +197,61 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 // Must be called after RegisterPersistentFlags.
 func BindEnvs(v *viper.Viper) {
 	bindings := map[string]string{
-		"host":        "IMAPHOST",
-		"port":        "IMAPPORT",
-		"timeout":     "IMAPTIMEOUT",
-		"username":    "IMAPUSERNAME",
-		"password":    "IMAPPASSWORD",
-		"accesstoken": "IMAPACCESSTOKEN",
-		"authmethod":  "IMAPAUTHMETHOD",
-		"starttls":    "IMAPSTARTTLS",
-		"imaps":       "IMAPIMAPS",
-		"no-starttls": "IMAPNOSTARTTLS",
-		"no-imaps":    "IMAPNOIMAPS",
-		"skipverify":  "IMAPSKIPVERIFY",
-		"tlsversion":  "IMAPTLSVERSION",
-		"address":     "IMAPADDRESS",
-		"ipv4":        "IMAPIPV4",
-		"ipv6":        "IMAPIPV6",
-		"proxy":       "IMAPPROXY",
-		"maxretries":  "IMAPMAXRETRIES",
-		"retrydelay":  "IMAPRETRYDELAY",
-		"output":      "IMAPOUTPUT",
-		"logformat":   "IMAPLOGFORMAT",
-		"ratelimit":   "IMAPRATELIMIT",
+		"host":           "IMAPHOST",
+		"port":           "IMAPPORT",
+		"timeout":        "IMAPTIMEOUT",
+		"username":       "IMAPUSERNAME",
+		"password":       "IMAPPASSWORD",
+		"accesstoken":    "IMAPACCESSTOKEN",
+		"authmethod":     "IMAPAUTHMETHOD",
+		"realm":          "IMAPREALM",
+		"kdc":            "IMAPKDC",
+		"starttls":       "IMAPSTARTTLS",
+		"imaps":          "IMAPIMAPS",
+		"no-starttls":    "IMAPNOSTARTTLS",
+		"no-imaps":       "IMAPNOIMAPS",
+		"skipverify":     "IMAPSKIPVERIFY",
+		"tlsversion":     "IMAPTLSVERSION",
+		"address":        "IMAPADDRESS",
+		"ipv4":           "IMAPIPV4",
+		"ipv6":           "IMAPIPV6",
+		"proxy-protocol": "IMAPPROXYPROTOCOL",
+		"proxy-source":   "IMAPPROXYSOURCE",
+		"proxy":          "IMAPPROXY",
+		"maxretries":     "IMAPMAXRETRIES",
+		"retrydelay":     "IMAPRETRYDELAY",
+		"output":         "IMAPOUTPUT",
+		"logformat":      "IMAPLOGFORMAT",
+		"ratelimit":      "IMAPRATELIMIT",
+		"mailbox":        "IMAPMAILBOX",
+		"since":          "IMAPSINCE",
+		"from":           "IMAPFROM",
+		"subject":        "IMAPSUBJECT",
+		"unseen":         "IMAPUNSEEN",
+		"header":         "IMAPHEADER",
+		"limit":          "IMAPLIMIT",
+		"save-dir":       "IMAPSAVEDIR",
+		"eml":            "IMAPEML",
+		"flags":          "IMAPFLAGS",
+		"internal-date":  "IMAPINTERNALDATE",
+		"size-kb":        "IMAPSIZEKB",
+		"delete":         "IMAPDELETE",
+		"duration":       "IMAPDURATION",
+		"refresh":        "IMAPREFRESH",
+		"expect-subject": "IMAPEXPECTSUBJECT",
+		"probe":          "IMAPPROBE",
+		"pattern":        "IMAPPATTERN",
+		"snapshot":       "IMAPSNAPSHOT",
+		"compare-with":   "IMAPCOMPAREWITH",
+		"format":         "IMAPFORMAT",
+		"output-dir":     "IMAPOUTPUTDIR",
+		"recursive":      "IMAPRECURSIVE",
+		"target-config":  "IMAPTARGETCONFIG",
+		"copy-missing":   "IMAPCOPYMISSING",
+		"subject-prefix": "IMAPSUBJECTPREFIX",
+		"older-than":     "IMAPOLDERTHAN",
+		"dry-run":        "IMAPDRYRUN",
+		"move-to-trash":  "IMAPMOVETOTRASH",
 	}
 	for key, env := range bindings {
 		_ = v.BindEnv(key, env)
@@ -201,6 +308,41 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		logFormat = defaults.LogFormat
 	}
 
+	mailbox := v.GetString("mailbox")
+	if mailbox == "" {
+		mailbox = defaults.Mailbox
+	}
+
+	limit := v.GetInt("limit")
+	if limit == 0 {
+		limit = defaults.Limit
+	}
+
+	appendSizeKB := v.GetInt("size-kb")
+	if appendSizeKB == 0 {
+		appendSizeKB = defaults.AppendSizeKB
+	}
+
+	idleRefreshMin := v.GetInt("refresh")
+	if idleRefreshMin == 0 {
+		idleRefreshMin = int(defaults.IdleRefresh / time.Minute)
+	}
+
+	mailboxPattern := v.GetString("pattern")
+	if mailboxPattern == "" {
+		mailboxPattern = defaults.MailboxPattern
+	}
+
+	exportFormat := strings.ToLower(v.GetString("format"))
+	if exportFormat == "" {
+		exportFormat = defaults.ExportFormat
+	}
+
+	olderThan := v.GetString("older-than")
+	if olderThan == "" {
+		olderThan = defaults.OlderThan
+	}
+
 	return &Config{
 		Host:           v.GetString("host"),
 		Port:           port,
@@ -209,6 +351,8 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		Password:       v.GetString("password"),
 		AccessToken:    v.GetString("accesstoken"),
 		AuthMethod:     authMethod,
+		Realm:          strings.ToUpper(v.GetString("realm")),
+		KDCAddress:     v.GetString("kdc"),
 		IMAPS:          v.GetBool("imaps"),
 		StartTLS:       v.GetBool("starttls"),
 		NoStartTLS:     v.GetBool("no-starttls"),
@@ -218,6 +362,8 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		ConnectAddress: v.GetString("address"),
 		IPv4Only:       v.GetBool("ipv4"),
 		IPv6Only:       v.GetBool("ipv6"),
+		ProxyProtocol:  strings.ToLower(v.GetString("proxy-protocol")),
+		ProxySource:    v.GetString("proxy-source"),
 		ProxyURL:       v.GetString("proxy"),
 		MaxRetries:     maxRetries,
 		RetryDelay:     time.Duration(retryDelayMs) * time.Millisecond,
@@ -226,13 +372,84 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		OutputFormat:   outputFormat,
 		LogFormat:      logFormat,
 		RateLimit:      v.GetFloat64("ratelimit"),
+
+		Mailbox:       mailbox,
+		SearchSince:   v.GetString("since"),
+		SearchFrom:    v.GetString("from"),
+		SearchSubject: v.GetString("subject"),
+		SearchUnseen:  v.GetBool("unseen"),
+		SearchHeaders: stringList(v, "header"),
+		Limit:         limit,
+		SaveDir:       v.GetString("save-dir"),
+
+		AppendFile:   v.GetString("eml"),
+		AppendFlags:  splitCommaSeparated(v.GetString("flags")),
+		AppendDate:   v.GetString("internal-date"),
+		AppendSizeKB: appendSizeKB,
+		DeleteAfter:  v.GetBool("delete"),
+
+		IdleDuration:  time.Duration(v.GetInt("duration")) * time.Second,
+		IdleRefresh:   time.Duration(idleRefreshMin) * time.Minute,
+		ExpectSubject: v.GetString("expect-subject"),
+		IdleProbe:     v.GetBool("probe"),
+
+		MailboxPattern: mailboxPattern,
+
+		SnapshotFile: v.GetString("snapshot"),
+		CompareWith:  v.GetString("compare-with"),
+
+		ExportFormat: exportFormat,
+		OutputDir:    v.GetString("output-dir"),
+		Recursive:    v.GetBool("recursive"),
+
+		TargetConfigFile: v.GetString("target-config"),
+		CopyMissing:      v.GetBool("copy-missing"),
+
+		SubjectPrefix: v.GetString("subject-prefix"),
+		OlderThan:     olderThan,
+		DryRun:        v.GetBool("dry-run"),
+		MoveToTrash:   v.GetBool("move-to-trash"),
+	}
+}
+
+// TargetConfigFromViper builds the target server config for compare. It
+// starts from all settings in v (so a second account on the same server
+// only needs a username and password) and overlays the YAML file at path.
+// IMAPTARGETPASSWORD and IMAPTARGETACCESSTOKEN override the file, keeping
+// secrets out of it.
+func TargetConfigFromViper(v *viper.Viper, path string) (*Config, error) {
+	tv := viper.New()
+	if err := tv.MergeConfigMap(v.AllSettings()); err != nil {
+		return nil, fmt.Errorf("failed to copy source settings: %w", err)
+	}
+	tv.SetConfigFile(path)
+	tv.SetConfigType("yaml")
+	if err := tv.MergeInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read target config file %q: %w", path, err)
 	}
+	_ = tv.BindEnv("password", "IMAPTARGETPASSWORD")
+	_ = tv.BindEnv("accesstoken", "IMAPTARGETACCESSTOKEN")
+
+	return ConfigFromViper(tv), nil
+}
+
+// stringList reads a repeatable flag. A value coming from an environment
+// variable or config file as a single string is kept whole rather than split
+// on whitespace, since header criteria contain spaces.
+func stringList(v *viper.Viper, key string) []string {
+	if s, ok := v.Get(key).(string); ok {
+		if s == "" {
+			return nil
+		}
+		return []string{s}
+	}
+	return v.GetStringSlice(key)
 }
 
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
-	validActions := []string{ActionTestConnect, ActionTestAuth, ActionListFolders}
+	validActions := []string{ActionTestConnect, ActionTestAuth, ActionListFolders, ActionFetchMail, ActionTestAppend, ActionIdle, ActionMailboxInfo, ActionExport, ActionCompare, ActionTestExtensions, ActionCleanup}
 	valid := false
 	for _, a := range validActions {
 		if config.Action == a {
@@ -305,25 +522,136 @@ func validateConfiguration(config *Config) error {
 		}
 	}
 
+	// Validate PROXY protocol settings (if provided)
+	if err := network.ValidateProxyProtocol(config.ProxyProtocol, config.ProxySource); err != nil {
+		return err
+	}
+
+	// Validate auth method
+	switch strings.ToUpper(config.AuthMethod) {
+	case "AUTO", "PLAIN", "LOGIN", "XOAUTH2", "OAUTHBEARER", "NTLM", "GSSAPI":
+	default:
+		return fmt.Errorf("invalid --authmethod: %s (must be one of: PLAIN, LOGIN, XOAUTH2, OAUTHBEARER, NTLM, GSSAPI, auto)", config.AuthMethod)
+	}
+
 	// Action-specific validation
 	switch config.Action {
-	case ActionTestAuth, ActionListFolders:
+	case ActionTestAuth, ActionListFolders, ActionFetchMail, ActionTestAppend, ActionIdle, ActionMailboxInfo, ActionExport, ActionCompare, ActionTestExtensions, ActionCleanup:
 		if config.Username == "" {
 			return fmt.Errorf("%s requires --username", config.Action)
 		}
-		if strings.EqualFold(config.AuthMethod, "XOAUTH2") {
+		// Token-based mechanisms (XOAUTH2, OAUTHBEARER) require accesstoken instead of password
+		if strings.EqualFold(config.AuthMethod, "XOAUTH2") || strings.EqualFold(config.AuthMethod, "OAUTHBEARER") {
+			method := strings.ToUpper(config.AuthMethod)
 			if config.AccessToken == "" {
-				return fmt.Errorf("XOAUTH2 authentication requires --accesstoken")
+				return fmt.Errorf("%s authentication requires --accesstoken", method)
 			}
 			if config.Password != "" {
-				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored for XOAUTH2")
+				fmt.Printf("Warning: both --password and --accesstoken provided; --password will be ignored for %s\n", method)
+			}
+		} else if strings.EqualFold(config.AuthMethod, "NTLM") || strings.EqualFold(config.AuthMethod, "GSSAPI") {
+			if config.Password == "" {
+				return fmt.Errorf("%s authentication requires --password", strings.ToUpper(config.AuthMethod))
 			}
 		} else if config.AccessToken != "" {
 			if config.Password != "" {
-				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored (using XOAUTH2)")
+				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored (using token-based auth)")
 			}
 		} else if config.Password == "" {
-			return fmt.Errorf("%s requires --password (or --accesstoken for XOAUTH2)", config.Action)
+			return fmt.Errorf("%s requires --password (or --accesstoken for XOAUTH2/OAUTHBEARER)", config.Action)
+		}
+	}
+
+	if config.Action == ActionFetchMail {
+		if config.Mailbox == "" {
+			return fmt.Errorf("fetchmail requires --mailbox")
+		}
+		if config.Limit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		if config.SearchSince != "" {
+			if _, err := parseSince(config.SearchSince, time.Now()); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+		}
+		for _, h := range config.SearchHeaders {
+			if _, _, err := parseHeaderCriterion(h); err != nil {
+				return fmt.Errorf("invalid --header: %w", err)
+			}
+		}
+	}
+
+	if config.Action == ActionTestAppend {
+		if config.Mailbox == "" {
+			return fmt.Errorf("testappend requires --mailbox")
+		}
+		if config.AppendFile == "" && config.AppendSizeKB < 1 {
+			return fmt.Errorf("--size-kb must be at least 1")
+		}
+		if _, err := parseAppendFlags(config.AppendFlags); err != nil {
+			return fmt.Errorf("invalid --flags: %w", err)
+		}
+		if config.AppendDate != "" {
+			if _, err := parseInternalDate(config.AppendDate); err != nil {
+				return fmt.Errorf("invalid --internal-date: %w", err)
+			}
+		}
+	}
+
+	if config.Action == ActionIdle {
+		if config.Mailbox == "" {
+			return fmt.Errorf("idle requires --mailbox")
+		}
+		if config.IdleDuration < 0 {
+			return fmt.Errorf("--duration cannot be negative")
+		}
+		// RFC 2177: servers may drop clients idle for 30 minutes (at least 29 are guaranteed)
+		if config.IdleRefresh < time.Minute || config.IdleRefresh > 29*time.Minute {
+			return fmt.Errorf("--refresh must be between 1 and 29 minutes")
+		}
+		if config.IdleProbe && config.ExpectSubject != "" {
+			return fmt.Errorf("--probe and --expect-subject are mutually exclusive")
+		}
+	}
+
+	if config.Action == ActionExport {
+		if config.Mailbox == "" {
+			return fmt.Errorf("export requires --mailbox")
+		}
+		if config.OutputDir == "" {
+			return fmt.Errorf("export requires --output-dir")
+		}
+		switch config.ExportFormat {
+		case exportFormatEML, exportFormatMbox, exportFormatMaildir:
+		default:
+			return fmt.Errorf("invalid --format: %s (must be one of: eml, mbox, maildir)", config.ExportFormat)
+		}
+	}
+
+	if config.Action == ActionCompare {
+		if config.TargetConfigFile == "" {
+			return fmt.Errorf("compare requires --target-config")
+		}
+		if config.MailboxPattern == "" {
+			return fmt.Errorf("compare requires --pattern")
+		}
+	}
+
+	if config.Action == ActionTestExtensions && config.Mailbox == "" {
+		return fmt.Errorf("testextensions requires --mailbox")
+	}
+
+	if config.Action == ActionCleanup {
+		if config.Mailbox == "" {
+			return fmt.Errorf("cleanup requires --mailbox")
+		}
+		if _, err := parseSince(config.OlderThan, time.Now()); err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+		for _, h := range config.SearchHeaders {
+			if _, _, err := parseHeaderCriterion(h); err != nil {
+				return fmt.Errorf("invalid --header: %w", err)
+			}
 		}
 	}
 
@@ -6,6 +6,7 @@ package imap
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestValidateConfiguration_IMAPSAndSTARTTLS tests mutual exclusion of IMAPS and STARTTLS flags
@@ -106,3 +107,298 @@ func TestValidateConfiguration_NoTLSFlags(t *testing.T) {
 		})
 	}
 }
+
+// TestValidateConfiguration_AuthMethod tests per-mechanism credential requirements
+func TestValidateConfiguration_AuthMethod(t *testing.T) {
+	tests := []struct {
+		name        string
+		authMethod  string
+		password    string
+		accessToken string
+		errorMsg    string
+	}{
+		{name: "auto with password", authMethod: "auto", password: "secret"},
+		{name: "OAUTHBEARER with token", authMethod: "OAUTHBEARER", accessToken: "tok"},
+		{name: "OAUTHBEARER without token", authMethod: "oauthbearer", password: "secret", errorMsg: "OAUTHBEARER authentication requires --accesstoken"},
+		{name: "NTLM with password", authMethod: "NTLM", password: "secret"},
+		{name: "NTLM with token only", authMethod: "NTLM", accessToken: "tok", errorMsg: "NTLM authentication requires --password"},
+		{name: "GSSAPI without password", authMethod: "GSSAPI", errorMsg: "GSSAPI authentication requires --password"},
+		{name: "unknown method", authMethod: "CRAM-MD5", password: "secret", errorMsg: "invalid --authmethod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestAuth
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.AuthMethod = tt.authMethod
+			config.Password = tt.password
+			config.AccessToken = tt.accessToken
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_FetchMail tests fetchmail search and limit validation
+func TestValidateConfiguration_FetchMail(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "date since", modify: func(c *Config) { c.SearchSince = "2026-01-31" }},
+		{name: "duration since", modify: func(c *Config) { c.SearchSince = "7d" }},
+		{name: "header criterion", modify: func(c *Config) { c.SearchHeaders = []string{"X-GoMailTest-Probe: eicar"} }},
+		{name: "invalid since", modify: func(c *Config) { c.SearchSince = "last week" }, errorMsg: "invalid --since"},
+		{name: "invalid header", modify: func(c *Config) { c.SearchHeaders = []string{"X-GoMailTest-Probe"} }, errorMsg: "invalid --header"},
+		{name: "zero limit", modify: func(c *Config) { c.Limit = 0 }, errorMsg: "--limit must be at least 1"},
+		{name: "empty mailbox", modify: func(c *Config) { c.Mailbox = "" }, errorMsg: "fetchmail requires --mailbox"},
+		{name: "missing password", modify: func(c *Config) { c.Password = "" }, errorMsg: "fetchmail requires --password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionFetchMail
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_TestAppend tests testappend flag validation
+func TestValidateConfiguration_TestAppend(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "flags and date", modify: func(c *Config) {
+			c.AppendFlags = []string{`\Seen`, "$Label1"}
+			c.AppendDate = "2026-01-31"
+		}},
+		{name: "eml file ignores size", modify: func(c *Config) { c.AppendFile = "message.eml"; c.AppendSizeKB = 0 }},
+		{name: "invalid flag", modify: func(c *Config) { c.AppendFlags = []string{`\Recent`} }, errorMsg: "invalid --flags"},
+		{name: "invalid date", modify: func(c *Config) { c.AppendDate = "yesterday" }, errorMsg: "invalid --internal-date"},
+		{name: "zero size", modify: func(c *Config) { c.AppendSizeKB = 0 }, errorMsg: "--size-kb must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestAppend
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_Idle tests idle duration and refresh validation
+func TestValidateConfiguration_Idle(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "defaults", modify: func(c *Config) {}},
+		{name: "duration and expect subject", modify: func(c *Config) {
+			c.IdleDuration = 5 * time.Minute
+			c.ExpectSubject = "probe"
+		}},
+		{name: "refresh at limit", modify: func(c *Config) { c.IdleRefresh = 29 * time.Minute }},
+		{name: "refresh too long", modify: func(c *Config) { c.IdleRefresh = 30 * time.Minute }, errorMsg: "--refresh must be between 1 and 29 minutes"},
+		{name: "negative duration", modify: func(c *Config) { c.IdleDuration = -time.Second }, errorMsg: "--duration cannot be negative"},
+		{name: "probe", modify: func(c *Config) { c.IdleProbe = true }},
+		{name: "probe and expect subject", modify: func(c *Config) {
+			c.IdleProbe = true
+			c.ExpectSubject = "probe"
+		}, errorMsg: "--probe and --expect-subject are mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionIdle
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Export(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "defaults with output dir", modify: func(c *Config) {}},
+		{name: "mbox recursive", modify: func(c *Config) {
+			c.ExportFormat = "mbox"
+			c.Recursive = true
+		}},
+		{name: "missing output dir", modify: func(c *Config) { c.OutputDir = "" }, errorMsg: "export requires --output-dir"},
+		{name: "invalid format", modify: func(c *Config) { c.ExportFormat = "pst" }, errorMsg: "invalid --format"},
+		{name: "missing mailbox", modify: func(c *Config) { c.Mailbox = "" }, errorMsg: "export requires --mailbox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionExport
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			config.OutputDir = "export"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Compare(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "defaults with target config", modify: func(c *Config) {}},
+		{name: "copy missing", modify: func(c *Config) { c.CopyMissing = true }},
+		{name: "missing target config", modify: func(c *Config) { c.TargetConfigFile = "" }, errorMsg: "compare requires --target-config"},
+		{name: "missing pattern", modify: func(c *Config) { c.MailboxPattern = "" }, errorMsg: "compare requires --pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionCompare
+			config.Host = "imap.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			config.TargetConfigFile = "target.yaml"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_TestExtensions(t *testing.T) {
+	config := NewConfig()
+	config.Action = ActionTestExtensions
+	config.Host = "imap.example.com"
+	config.Username = "user@example.com"
+	config.Password = "secret"
+	if err := validateConfiguration(config); err != nil {
+		t.Errorf("validateConfiguration() unexpected error = %v", err)
+	}
+
+	config.Mailbox = ""
+	if err := validateConfiguration(config); err == nil || !strings.Contains(err.Error(), "testextensions requires --mailbox") {
+		t.Errorf("validateConfiguration() error = %v, want missing --mailbox", err)
+	}
+
+	config.Mailbox = "INBOX"
+	config.Username = ""
+	if err := validateConfiguration(config); err == nil || !strings.Contains(err.Error(), "requires --username") {
+		t.Errorf("validateConfiguration() error = %v, want missing --username", err)
+	}
+}
+
+func TestValidateConfiguration_Cleanup(t *testing.T) {
+	config := NewConfig()
+	config.Action = ActionCleanup
+	config.Host = "imap.example.com"
+	config.Username = "user@example.com"
+	config.Password = "secret"
+	if err := validateConfiguration(config); err != nil {
+		t.Errorf("validateConfiguration() unexpected error = %v", err)
+	}
+
+	config.OlderThan = "last week"
+	if err := validateConfiguration(config); err == nil || !strings.Contains(err.Error(), "invalid --older-than") {
+		t.Errorf("validateConfiguration() error = %v, want invalid --older-than", err)
+	}
+
+	config.OlderThan = "7d"
+	config.SearchHeaders = []string{"no colon"}
+	if err := validateConfiguration(config); err == nil || !strings.Contains(err.Error(), "invalid --header") {
+		t.Errorf("validateConfiguration() error = %v, want invalid --header", err)
+	}
+
+	config.SearchHeaders = nil
+	config.Mailbox = ""
+	if err := validateConfiguration(config); err == nil || !strings.Contains(err.Error(), "cleanup requires --mailbox") {
+		t.Errorf("validateConfiguration() error = %v, want missing --mailbox", err)
+	}
+}
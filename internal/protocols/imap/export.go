@@ -0,0 +1,272 @@
+package imap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// exportProgressEvery is how often (in messages) export progress is printed.
+const exportProgressEvery = 100
+
+// exportResult summarises the export of one folder.
+type exportResult struct {
+	UIDValidity uint32
+	Exported    int
+	Skipped     int // Exported by a previous run
+	Bytes       int64
+	Path        string
+}
+
+// exportMailboxes downloads --mailbox (and with --recursive its subfolders)
+// into --output-dir as EML files, mbox or Maildir, recording every message in
+// manifest.csv. A restarted export continues after the highest UID already
+// in the manifest. Messages are fetched one at a time with BODY.PEEK, so
+// --ratelimit applies per message and \Seen flags are left alone.
+func exportMailboxes(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Exporting %s from %s:%d to %s (%s)...\n", config.Mailbox, config.Host, config.Port, config.OutputDir, config.ExportFormat)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Mailbox", "UIDValidity", "Exported", "Skipped", "Bytes", "Format", "Path", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(mailbox string, result exportResult, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		uidValidity := ""
+		if result.UIDValidity != 0 {
+			uidValidity = fmt.Sprintf("%d", result.UIDValidity)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			mailbox, uidValidity, fmt.Sprintf("%d", result.Exported), fmt.Sprintf("%d", result.Skipped),
+			fmt.Sprintf("%d", result.Bytes), config.ExportFormat, result.Path, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	if err := os.MkdirAll(config.OutputDir, 0o755); err != nil {
+		err = fmt.Errorf("cannot create output directory: %w", err)
+		writeRow(config.Mailbox, exportResult{}, err)
+		return err
+	}
+	manifest, err := openExportManifest(config.OutputDir)
+	if err != nil {
+		writeRow(config.Mailbox, exportResult{}, err)
+		return err
+	}
+	defer func() {
+		if err := manifest.Close(); err != nil {
+			logger.LogError(slogLogger, "Failed to close manifest", "error", err)
+		}
+	}()
+
+	client, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		writeRow(config.Mailbox, exportResult{}, err)
+		return err
+	}
+	defer func() { _ = client.Logout() }()
+
+	folders, err := exportFolderList(ctx, client, config.Mailbox, config.Recursive)
+	if err != nil {
+		logger.LogError(slogLogger, "LIST command failed", "error", err)
+		writeRow(config.Mailbox, exportResult{}, err)
+		return err
+	}
+	fmt.Printf("✓ %d folder(s) to export\n", len(folders))
+
+	var totalExported, totalSkipped, failures int
+	var totalBytes int64
+	for _, folder := range folders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n%s\n", folder.Name)
+		result, err := exportFolder(ctx, client, config, manifest, folder)
+		writeRow(folder.Name, result, err)
+		totalExported += result.Exported
+		totalSkipped += result.Skipped
+		totalBytes += result.Bytes
+		if err != nil {
+			failures++
+			logger.LogError(slogLogger, "Folder export failed", "mailbox", folder.Name, "error", err)
+			fmt.Printf("  ✗ %v\n", err)
+			continue
+		}
+		fmt.Printf("  ✓ %d exported, %d already exported (UIDVALIDITY %d) → %s\n",
+			result.Exported, result.Skipped, result.UIDValidity, result.Path)
+	}
+
+	logger.LogInfo(slogLogger, "Export completed",
+		"host", config.Host,
+		"folders", len(folders),
+		"exported", totalExported,
+		"skipped", totalSkipped,
+		"bytes", totalBytes,
+		"failures", failures)
+
+	fmt.Printf("\nExported %d messages (%s), %d already present, manifest: %s\n",
+		totalExported, formatBytes(totalBytes), totalSkipped, filepath.Join(config.OutputDir, exportManifestName))
+	if failures > 0 {
+		return fmt.Errorf("%d of %d folders failed to export", failures, len(folders))
+	}
+	fmt.Println("✓ Export completed")
+	return nil
+}
+
+// exportFolderList returns the selectable folders to export: mailbox itself
+// and, when recursive, every folder below it.
+func exportFolderList(ctx context.Context, client *IMAPClient, mailbox string, recursive bool) ([]MailboxInfo, error) {
+	listed, err := client.ListMailboxNames(ctx, mailbox)
+	if err != nil {
+		return nil, err
+	}
+	if len(listed) == 0 {
+		return nil, fmt.Errorf("mailbox %q not found", mailbox)
+	}
+
+	folders := listed
+	if recursive && listed[0].Delimiter != "" {
+		children, err := client.ListMailboxNames(ctx, mailbox+listed[0].Delimiter+"*")
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, children...)
+	}
+
+	selectable := folders[:0]
+	for _, f := range folders {
+		if isSelectable(f.Attributes) {
+			selectable = append(selectable, f)
+		}
+	}
+	return selectable, nil
+}
+
+// exportFolder exports the messages of one folder that are not yet in the
+// manifest.
+func exportFolder(ctx context.Context, client *IMAPClient, config *Config, manifest *exportManifest, folder MailboxInfo) (exportResult, error) {
+	var result exportResult
+
+	data, err := client.SelectMailbox(ctx, folder.Name, true)
+	if err != nil {
+		return result, err
+	}
+	result.UIDValidity = data.UIDValidity
+
+	previous, resumed := manifest.state[folder.Name]
+	if resumed && previous.UIDValidity != data.UIDValidity {
+		return result, fmt.Errorf("UIDVALIDITY changed from %d to %d since the previous export; use a new --output-dir",
+			previous.UIDValidity, data.UIDValidity)
+	}
+	result.Skipped = previous.Count
+
+	folderPath := exportFolderPath(folder.Name, folder.Delimiter)
+	result.Path = folderPath
+	if config.ExportFormat == exportFormatMbox {
+		result.Path += ".mbox"
+	}
+
+	var uids []imap.UID
+	if data.NumMessages > 0 {
+		// "n:*" always matches the highest UID, even below n
+		criteria := &imap.SearchCriteria{UID: []imap.UIDSet{{{Start: previous.LastUID + 1, Stop: 0}}}}
+		found, err := client.SearchUIDs(ctx, criteria)
+		if err != nil {
+			return result, err
+		}
+		for _, uid := range found {
+			if uid > previous.LastUID {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	if resumed {
+		fmt.Printf("  Resuming after UID %d: %d new message(s)\n", previous.LastUID, len(uids))
+	} else {
+		fmt.Printf("  %d message(s)\n", len(uids))
+	}
+
+	writer, err := newExportWriter(config.ExportFormat, config.OutputDir, folderPath, data.UIDValidity, previous.MboxEnd)
+	if err != nil {
+		return result, fmt.Errorf("cannot open %s: %w", result.Path, err)
+	}
+
+	bodySection := &imap.FetchItemBodySection{Peek: true}
+	options := &imap.FetchOptions{
+		UID:          true,
+		Flags:        true,
+		InternalDate: true,
+		BodySection:  []*imap.FetchItemBodySection{bodySection},
+	}
+	for _, uid := range uids {
+		if err := ctx.Err(); err != nil {
+			_ = writer.Close()
+			return result, err
+		}
+
+		fetched, err := client.FetchUIDs(ctx, []imap.UID{uid}, options)
+		if err != nil {
+			_ = writer.Close()
+			return result, err
+		}
+		if len(fetched) == 0 {
+			// Expunged since the search
+			continue
+		}
+		msg := fetched[0]
+		body := msg.FindBodySection(bodySection)
+		if body == nil {
+			_ = writer.Close()
+			return result, fmt.Errorf("server returned no message body for UID %d", uid)
+		}
+
+		loc, err := writer.write(uid, msg.InternalDate, msg.Flags, body)
+		if err != nil {
+			_ = writer.Close()
+			return result, fmt.Errorf("cannot write UID %d: %w", uid, err)
+		}
+		sum := sha256.Sum256(body)
+		if err := manifest.add(exportEntry{
+			Folder:       folder.Name,
+			UIDValidity:  data.UIDValidity,
+			UID:          uid,
+			InternalDate: msg.InternalDate,
+			Flags:        msg.Flags,
+			Size:         len(body),
+			SHA256:       hex.EncodeToString(sum[:]),
+			File:         loc.File,
+			Offset:       loc.Offset,
+			Length:       loc.Length,
+		}); err != nil {
+			_ = writer.Close()
+			return result, fmt.Errorf("cannot update manifest: %w", err)
+		}
+
+		result.Exported++
+		result.Bytes += int64(len(body))
+		if result.Exported%exportProgressEvery == 0 {
+			fmt.Printf("  %d/%d messages (%s)\n", result.Exported, len(uids), formatBytes(result.Bytes))
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return result, fmt.Errorf("cannot close %s: %w", result.Path, err)
+	}
+	return result, nil
+}
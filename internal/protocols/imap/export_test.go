@@ -0,0 +1,212 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+func TestMboxRecord(t *testing.T) {
+	date := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	got := string(mboxRecord(date, []byte("Subject: x\r\n\r\nFrom here\r\n>From there\r\nend")))
+	want := "From MAILER-DAEMON Fri Jan  2 10:00:00 2026\n" +
+		"Subject: x\n\n>From here\n>>From there\nend\n\n"
+	if got != want {
+		t.Errorf("mboxRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestMaildirFlags(t *testing.T) {
+	got := maildirFlags([]imap.Flag{imap.FlagSeen, "$Label1", `\flagged`, imap.FlagAnswered})
+	if got != "FRS" {
+		t.Errorf("maildirFlags() = %q, want %q", got, "FRS")
+	}
+}
+
+func TestExportFolderPath(t *testing.T) {
+	tests := []struct {
+		name, delimiter, want string
+	}{
+		{"INBOX", "/", "INBOX"},
+		{"INBOX.Sent Items", ".", filepath.Join("INBOX", "Sent Items")},
+		{"Archive/2026/Q1", "/", filepath.Join("Archive", "2026", "Q1")},
+		{"a:b/..", "/", filepath.Join("a_b", "_..")},
+		{"Flat/Name", "", "Flat_Name"},
+	}
+	for _, tt := range tests {
+		if got := exportFolderPath(tt.name, tt.delimiter); got != tt.want {
+			t.Errorf("exportFolderPath(%q, %q) = %q, want %q", tt.name, tt.delimiter, got, tt.want)
+		}
+	}
+}
+
+// newExportTestServer serves INBOX with two messages and INBOX/Archive with one.
+func newExportTestServer(t *testing.T, format string) (*Config, *imapmemserver.User) {
+	t.Helper()
+	config, user := newTestIMAPServer(t, testMessageAlpha, testMessageBeta)
+	if err := user.Create("INBOX/Archive", nil); err != nil {
+		t.Fatalf("create INBOX/Archive: %v", err)
+	}
+	appendTestMessage(t, user, "INBOX/Archive", testMessageAlpha, imap.FlagSeen)
+
+	config.Action = ActionExport
+	config.ExportFormat = format
+	config.OutputDir = t.TempDir()
+	config.Recursive = true
+	return config, user
+}
+
+func appendTestMessage(t *testing.T, user *imapmemserver.User, mailbox, message string, flags ...imap.Flag) {
+	t.Helper()
+	msg := strings.ReplaceAll(message, "\n", "\r\n")
+	if _, err := user.Append(mailbox, bytes.NewReader([]byte(msg)), &imap.AppendOptions{Flags: flags}); err != nil {
+		t.Fatalf("append to %s: %v", mailbox, err)
+	}
+}
+
+// readManifest returns the manifest rows without the header.
+func readManifest(t *testing.T, dir string) [][]string {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, exportManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return records[1:]
+}
+
+func TestExport_EMLResume(t *testing.T) {
+	config, user := newExportTestServer(t, exportFormatEML)
+
+	if err := exportMailboxes(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("exportMailboxes() error = %v", err)
+	}
+	rows := readManifest(t, config.OutputDir)
+	if len(rows) != 3 {
+		t.Fatalf("manifest has %d rows, want 3", len(rows))
+	}
+	for _, row := range rows {
+		data, err := os.ReadFile(filepath.Join(config.OutputDir, filepath.FromSlash(row[7])))
+		if err != nil {
+			t.Fatalf("exported file: %v", err)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != row[6] {
+			t.Errorf("%s: SHA256 in manifest does not match the file", row[7])
+		}
+	}
+	if rows[2][0] != "INBOX/Archive" || rows[2][7] != "INBOX/Archive/1.eml" || rows[2][4] != `\Seen` {
+		t.Errorf("Archive manifest row = %v", rows[2])
+	}
+
+	// A new message since the first run: only it is exported
+	appendTestMessage(t, user, "INBOX", testMessageAlpha)
+	csvLog := &recordingLogger{}
+	if err := exportMailboxes(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("exportMailboxes() resume error = %v", err)
+	}
+	if rows := readManifest(t, config.OutputDir); len(rows) != 4 {
+		t.Errorf("manifest has %d rows after resume, want 4", len(rows))
+	}
+	inbox := csvLog.rows[0]
+	if csvLog.column(inbox, "Exported") != "1" || csvLog.column(inbox, "Skipped") != "2" {
+		t.Errorf("INBOX resume row = %v, want 1 exported and 2 skipped", inbox)
+	}
+}
+
+func TestExport_MboxTruncatesPartialMessage(t *testing.T) {
+	config, user := newExportTestServer(t, exportFormatMbox)
+	config.Recursive = false
+
+	if err := exportMailboxes(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("exportMailboxes() error = %v", err)
+	}
+
+	// Simulate a run interrupted between writing a message and recording it
+	mbox := filepath.Join(config.OutputDir, "INBOX.mbox")
+	f, err := os.OpenFile(mbox, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("From MAILER-DAEMON partial\nSubject: cut off")
+	_ = f.Close()
+
+	appendTestMessage(t, user, "INBOX", testMessageAlpha)
+	if err := exportMailboxes(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("exportMailboxes() resume error = %v", err)
+	}
+
+	data, err := os.ReadFile(mbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "partial") {
+		t.Error("partially written message was not removed on resume")
+	}
+	if n := strings.Count(string(data), "\nFrom MAILER-DAEMON ") + 1; n != 3 {
+		t.Errorf("mbox has %d messages, want 3", n)
+	}
+
+	rows := readManifest(t, config.OutputDir)
+	last := rows[len(rows)-1]
+	if last[8] == "-1" || last[9] == "-1" {
+		t.Fatalf("mbox manifest row has no offset: %v", last)
+	}
+	offset, _ := strconv.Atoi(last[8])
+	length, _ := strconv.Atoi(last[9])
+	if offset+length != len(data) || !strings.HasPrefix(string(data[offset:]), "From MAILER-DAEMON ") {
+		t.Errorf("offset %d and length %d do not locate the last message in a %d-byte mbox", offset, length, len(data))
+	}
+}
+
+func TestExport_Maildir(t *testing.T) {
+	config, _ := newExportTestServer(t, exportFormatMaildir)
+
+	if err := exportMailboxes(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("exportMailboxes() error = %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(config.OutputDir, "INBOX", "Archive", "cur", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Archive/cur has %v (%v), want 1 message", files, err)
+	}
+	if !strings.HasSuffix(files[0], ":2,S") {
+		t.Errorf("Maildir file %s lacks the S (seen) flag", filepath.Base(files[0]))
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(config.OutputDir, "INBOX", "tmp")); len(tmp) != 0 {
+		t.Errorf("INBOX/tmp still holds %d files", len(tmp))
+	}
+}
+
+func TestExport_UIDValidityChange(t *testing.T) {
+	config, _ := newExportTestServer(t, exportFormatEML)
+	config.Recursive = false
+
+	manifest := "Folder,UIDVALIDITY,UID,Internal_Date,Flags,Size,SHA256,File,Offset,Length\n" +
+		"INBOX,999,1,2026-01-02T10:00:00Z,,10,00,INBOX/1.eml,-1,-1\n"
+	if err := os.WriteFile(filepath.Join(config.OutputDir, exportManifestName), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := exportMailboxes(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil {
+		t.Fatal("exportMailboxes() expected error after UIDVALIDITY change")
+	}
+}
@@ -0,0 +1,365 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+// Export formats
+const (
+	exportFormatEML     = "eml"
+	exportFormatMbox    = "mbox"
+	exportFormatMaildir = "maildir"
+)
+
+// exportManifestName is the manifest file written at the top of an export directory.
+const exportManifestName = "manifest.csv"
+
+var exportManifestColumns = []string{"Folder", "UIDVALIDITY", "UID", "Internal_Date", "Flags", "Size", "SHA256", "File", "Offset", "Length"}
+
+// exportEntry is one exported message as recorded in the manifest.
+type exportEntry struct {
+	Folder       string
+	UIDValidity  uint32
+	UID          imap.UID
+	InternalDate time.Time
+	Flags        []imap.Flag
+	Size         int
+	SHA256       string
+	File         string // Path relative to the export directory
+	Offset       int64  // Start of the message in an mbox file (-1 for other formats)
+	Length       int64  // Length of the message in an mbox file (-1 for other formats)
+}
+
+// exportFolderState is what a previous run exported from a folder.
+type exportFolderState struct {
+	UIDValidity uint32
+	LastUID     imap.UID
+	Count       int
+	MboxEnd     int64 // End of the last message written to the folder's mbox file
+}
+
+// exportManifest appends entries to manifest.csv and knows, from the rows
+// already in it, where each folder's export stopped.
+type exportManifest struct {
+	file   *os.File
+	writer *csv.Writer
+	state  map[string]exportFolderState
+}
+
+// openExportManifest opens (or creates) the manifest in dir and loads the
+// resume state of every folder recorded in it.
+func openExportManifest(dir string) (*exportManifest, error) {
+	path := filepath.Join(dir, exportManifestName)
+	state := make(map[string]exportFolderState)
+
+	existing, err := os.Open(path)
+	switch {
+	case err == nil:
+		state, err = readExportManifest(existing)
+		_ = existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	m := &exportManifest{file: file, writer: csv.NewWriter(file), state: state}
+
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		if err := m.writer.Write(exportManifestColumns); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to write manifest header: %w", err)
+		}
+		m.writer.Flush()
+	}
+	return m, nil
+}
+
+// readExportManifest returns the resume state per folder from manifest rows.
+func readExportManifest(r io.Reader) (map[string]exportFolderState, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(exportManifestColumns)
+
+	state := make(map[string]exportFolderState)
+	header := true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return state, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			header = false
+			continue
+		}
+
+		uidValidity, err := strconv.ParseUint(record[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UIDVALIDITY %q", record[1])
+		}
+		uid, err := strconv.ParseUint(record[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UID %q", record[2])
+		}
+
+		s := state[record[0]]
+		if s.Count > 0 && s.UIDValidity != uint32(uidValidity) {
+			return nil, fmt.Errorf("folder %q has rows with different UIDVALIDITY values", record[0])
+		}
+		s.UIDValidity = uint32(uidValidity)
+		s.Count++
+		if imap.UID(uid) > s.LastUID {
+			s.LastUID = imap.UID(uid)
+		}
+		if offset, err := strconv.ParseInt(record[8], 10, 64); err == nil && offset >= 0 {
+			if length, err := strconv.ParseInt(record[9], 10, 64); err == nil && offset+length > s.MboxEnd {
+				s.MboxEnd = offset + length
+			}
+		}
+		state[record[0]] = s
+	}
+}
+
+// add appends an entry to the manifest and flushes it, so an interrupted
+// export resumes after the last recorded message.
+func (m *exportManifest) add(e exportEntry) error {
+	flags := make([]string, len(e.Flags))
+	for i, f := range e.Flags {
+		flags[i] = string(f)
+	}
+	if err := m.writer.Write([]string{
+		e.Folder,
+		strconv.FormatUint(uint64(e.UIDValidity), 10),
+		strconv.FormatUint(uint64(e.UID), 10),
+		e.InternalDate.UTC().Format(time.RFC3339),
+		strings.Join(flags, " "),
+		strconv.Itoa(e.Size),
+		e.SHA256,
+		filepath.ToSlash(e.File),
+		strconv.FormatInt(e.Offset, 10),
+		strconv.FormatInt(e.Length, 10),
+	}); err != nil {
+		return err
+	}
+	m.writer.Flush()
+	return m.writer.Error()
+}
+
+// Close flushes and closes the manifest.
+func (m *exportManifest) Close() error {
+	m.writer.Flush()
+	if err := m.file.Sync(); err != nil {
+		_ = m.file.Close()
+		return err
+	}
+	return m.file.Close()
+}
+
+// exportLocation is where a writer stored a message.
+type exportLocation struct {
+	File   string // Path relative to the export directory
+	Offset int64
+	Length int64
+}
+
+// exportWriter stores the messages of one folder in an export format.
+type exportWriter interface {
+	write(uid imap.UID, internalDate time.Time, flags []imap.Flag, message []byte) (exportLocation, error)
+	Close() error
+}
+
+// newExportWriter creates the writer for one folder. folderPath is the
+// folder's relative path inside root; mboxEnd is where a resumed mbox export
+// stopped (anything after it is a partially written message and is cut off).
+func newExportWriter(format, root, folderPath string, uidValidity uint32, mboxEnd int64) (exportWriter, error) {
+	switch format {
+	case exportFormatEML:
+		if err := os.MkdirAll(filepath.Join(root, folderPath), 0o755); err != nil {
+			return nil, err
+		}
+		return &emlWriter{root: root, dir: folderPath}, nil
+	case exportFormatMaildir:
+		for _, sub := range []string{"tmp", "new", "cur"} {
+			if err := os.MkdirAll(filepath.Join(root, folderPath, sub), 0o755); err != nil {
+				return nil, err
+			}
+		}
+		return &maildirWriter{root: root, dir: folderPath, uidValidity: uidValidity}, nil
+	case exportFormatMbox:
+		return newMboxWriter(root, folderPath+".mbox", mboxEnd)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// emlWriter writes each message unchanged to <folder>/<uid>.eml.
+type emlWriter struct {
+	root, dir string
+}
+
+func (w *emlWriter) write(uid imap.UID, _ time.Time, _ []imap.Flag, message []byte) (exportLocation, error) {
+	file := filepath.Join(w.dir, fmt.Sprintf("%d.eml", uid))
+	if err := os.WriteFile(filepath.Join(w.root, file), message, 0o644); err != nil {
+		return exportLocation{}, err
+	}
+	return exportLocation{File: file, Offset: -1, Length: -1}, nil
+}
+
+func (w *emlWriter) Close() error { return nil }
+
+// maildirWriter delivers each message unchanged into <folder>/cur via tmp,
+// with the IMAP system flags as Maildir info flags.
+type maildirWriter struct {
+	root, dir   string
+	uidValidity uint32
+}
+
+func (w *maildirWriter) write(uid imap.UID, internalDate time.Time, flags []imap.Flag, message []byte) (exportLocation, error) {
+	// Unique and stable, so a resumed export overwrites rather than duplicates
+	name := fmt.Sprintf("%d.U%dI%d.gomailtest", internalDate.Unix(), w.uidValidity, uid)
+	tmp := filepath.Join(w.root, w.dir, "tmp", name)
+	if err := os.WriteFile(tmp, message, 0o644); err != nil {
+		return exportLocation{}, err
+	}
+	file := filepath.Join(w.dir, "cur", name+":2,"+maildirFlags(flags))
+	if err := os.Rename(tmp, filepath.Join(w.root, file)); err != nil {
+		return exportLocation{}, err
+	}
+	return exportLocation{File: file, Offset: -1, Length: -1}, nil
+}
+
+func (w *maildirWriter) Close() error { return nil }
+
+// maildirFlags maps IMAP system flags to Maildir info flags, in ASCII order
+// as the Maildir convention requires.
+func maildirFlags(flags []imap.Flag) string {
+	letters := map[imap.Flag]byte{
+		imap.FlagDraft:    'D',
+		imap.FlagFlagged:  'F',
+		imap.FlagAnswered: 'R',
+		imap.FlagSeen:     'S',
+		imap.FlagDeleted:  'T',
+	}
+	var info []byte
+	for _, f := range flags {
+		for flag, letter := range letters {
+			if strings.EqualFold(string(f), string(flag)) {
+				info = append(info, letter)
+			}
+		}
+	}
+	sort.Slice(info, func(i, j int) bool { return info[i] < info[j] })
+	return string(info)
+}
+
+// mboxWriter appends messages to <folder>.mbox in mboxrd format.
+type mboxWriter struct {
+	file   *os.File
+	name   string
+	offset int64
+}
+
+func newMboxWriter(root, name string, resumeAt int64) (*mboxWriter, error) {
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Drop a message that was written but never recorded in the manifest
+	if err := file.Truncate(resumeAt); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(resumeAt, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &mboxWriter{file: file, name: name, offset: resumeAt}, nil
+}
+
+func (w *mboxWriter) write(_ imap.UID, internalDate time.Time, _ []imap.Flag, message []byte) (exportLocation, error) {
+	record := mboxRecord(internalDate, message)
+	if _, err := w.file.Write(record); err != nil {
+		return exportLocation{}, err
+	}
+	loc := exportLocation{File: w.name, Offset: w.offset, Length: int64(len(record))}
+	w.offset += int64(len(record))
+	return loc, nil
+}
+
+func (w *mboxWriter) Close() error {
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// mboxRecord formats a message as an mboxrd entry: a "From " separator line
+// with the internal date, the message with LF line endings and any
+// ">*From " line quoted with one more ">", and a trailing blank line.
+func mboxRecord(internalDate time.Time, message []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From MAILER-DAEMON %s\n", internalDate.UTC().Format("Mon Jan _2 15:04:05 2006"))
+
+	scanner := bufio.NewScanner(bytes.NewReader(message))
+	scanner.Buffer(make([]byte, 64*1024), len(message)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte("\r"))
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// exportFolderPath turns a mailbox name into a relative directory path,
+// one directory per hierarchy level. Characters that are not safe in file
+// names are replaced.
+func exportFolderPath(name, delimiter string) string {
+	segments := []string{name}
+	if delimiter != "" {
+		segments = strings.Split(name, delimiter)
+	}
+	for i, s := range segments {
+		s = strings.Map(func(r rune) rune {
+			switch {
+			case r < 0x20, strings.ContainsRune(`<>:"/\|?*`, r):
+				return '_'
+			default:
+				return r
+			}
+		}, s)
+		if s == "" || s == "." || s == ".." {
+			s = "_" + s
+		}
+		segments[i] = s
+	}
+	return filepath.Join(segments...)
+}
@@ -0,0 +1,476 @@
+package imap
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/common/version"
+)
+
+// Extension check results
+const (
+	extensionOK      = "OK"
+	extensionSkipped = "SKIPPED"
+	extensionFailed  = "FAILED"
+)
+
+// compressSampleMessages is how many messages the COMPRESS check fetches
+// (at most 64 KB of each) to measure the bytes saved.
+const compressSampleMessages = 10
+
+// idFieldOrder is the order in which the RFC 2971 fields are shown; any
+// other fields follow alphabetically.
+var idFieldOrder = []string{"name", "vendor", "version", "os", "os-version", "support-url", "address", "date", "command", "arguments", "environment"}
+
+// extensionCheck is the outcome of one extension check.
+type extensionCheck struct {
+	Name         string
+	Result       string // extensionOK, extensionSkipped or extensionFailed
+	Detail       string
+	Uncompressed int64 // COMPRESS sample size before compression
+	Compressed   int64 // COMPRESS sample size on the wire
+	Err          error
+}
+
+// testExtensions identifies the server with ID, enables CONDSTORE, QRESYNC
+// and UTF8=ACCEPT, and turns on COMPRESS=DEFLATE to measure the bytes saved
+// on a sample FETCH from --mailbox. Extensions that are not advertised are
+// skipped; an advertised extension that does not work fails the action.
+func testExtensions(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Testing IMAP extensions on %s:%d...\n", config.Host, config.Port)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Check", "Result", "Detail", "Uncompressed_Bytes", "Compressed_Bytes", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(check extensionCheck) {
+		status, errMsg := "SUCCESS", ""
+		if check.Result == extensionFailed {
+			status = "FAILURE"
+		}
+		if check.Err != nil {
+			errMsg = check.Err.Error()
+		}
+		uncompressed, compressed := "", ""
+		if check.Compressed > 0 {
+			uncompressed = strconv.FormatInt(check.Uncompressed, 10)
+			compressed = strconv.FormatInt(check.Compressed, 10)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			check.Name, check.Result, check.Detail, uncompressed, compressed, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	session, preauth, err := dialRawSession(ctx, config)
+	if err != nil {
+		logger.LogError(slogLogger, "Connection failed",
+			"error", err,
+			"host", config.Host,
+			"port", config.Port)
+		writeRow(extensionCheck{Name: "CONNECT", Result: extensionFailed, Err: err})
+		return fmt.Errorf("connection failed: %w", err)
+	}
+	defer session.Close()
+	fmt.Printf("✓ Connected to %s:%d\n", config.Host, config.Port)
+
+	if preauth {
+		fmt.Println("✓ Pre-authenticated by the server")
+	} else {
+		method := selectAuthMethod(config, session.caps, config.AccessToken != "")
+		if config.AccessToken != "" && method != "XOAUTH2" && method != "OAUTHBEARER" {
+			logger.LogWarn(slogLogger, "Access token provided but server supports neither XOAUTH2 nor OAUTHBEARER", "method", method)
+		}
+		fmt.Printf("Authenticating with method: %s\n", method)
+		if err := session.authenticate(ctx, method); err != nil {
+			logger.LogError(slogLogger, "Authentication failed",
+				"error", err,
+				"username", maskUsername(config.Username),
+				"method", method)
+			writeRow(extensionCheck{Name: "AUTH", Result: extensionFailed, Detail: method, Err: err})
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+		fmt.Println("✓ Authentication successful")
+
+		// Servers often advertise more extensions after authentication
+		if err := session.refreshCapabilities(ctx); err != nil {
+			writeRow(extensionCheck{Name: "CAPABILITY", Result: extensionFailed, Err: err})
+			return err
+		}
+	}
+
+	checks := []extensionCheck{checkID(ctx, session)}
+	enabled, enableChecks := checkEnable(ctx, session)
+	checks = append(checks, enableChecks...)
+
+	// Open the sample mailbox; with CONDSTORE enabled the response must
+	// carry HIGHESTMODSEQ or NOMODSEQ
+	messages, examineErr := examineForChecks(ctx, session, config.Mailbox, enabled["CONDSTORE"], checks)
+	checks = append(checks, checkCompress(ctx, session, config.Mailbox, messages, examineErr))
+
+	fmt.Println("\nExtensions:")
+	failures := 0
+	for _, check := range checks {
+		writeRow(check)
+		mark := "✓"
+		switch check.Result {
+		case extensionSkipped:
+			mark = "-"
+		case extensionFailed:
+			mark = "✗"
+			failures++
+			logger.LogWarn(slogLogger, "Extension check failed", "check", check.Name, "error", check.Err)
+		}
+		line := fmt.Sprintf("  %s %-20s %s", mark, check.Name, check.Detail)
+		if check.Err != nil {
+			line += fmt.Sprintf(" (%v)", check.Err)
+		}
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	logger.LogInfo(slogLogger, "Extension checks completed",
+		"host", config.Host,
+		"checks", len(checks),
+		"failures", failures)
+
+	if failures > 0 {
+		return fmt.Errorf("%d of %d extension checks failed", failures, len(checks))
+	}
+	fmt.Println("\n✓ All advertised extensions work")
+	return nil
+}
+
+// checkID sends the RFC 2971 ID command with our identity and reports the
+// server's.
+func checkID(ctx context.Context, session *rawSession) extensionCheck {
+	check := extensionCheck{Name: "ID"}
+	if !session.caps.SupportsID() {
+		check.Result, check.Detail = extensionSkipped, "not advertised"
+		return check
+	}
+
+	resp, err := session.command(ctx, `ID ("name" "gomailtest" "version" %s)`, quoteIMAPString(version.Get()))
+	if err == nil {
+		err = resp.err("ID")
+	}
+	if err != nil {
+		check.Result, check.Err = extensionFailed, err
+		return check
+	}
+
+	var fields map[string]string
+	for _, line := range resp.Untagged {
+		if name, rest, _ := strings.Cut(line, " "); strings.EqualFold(name, "ID") {
+			if fields, err = parseIDFields(rest); err != nil {
+				check.Result, check.Err = extensionFailed, err
+				return check
+			}
+		}
+	}
+	if fields == nil {
+		check.Result, check.Err = extensionFailed, fmt.Errorf("server sent no ID response")
+		return check
+	}
+
+	check.Result = extensionOK
+	check.Detail = formatIDFields(fields)
+	if check.Detail == "" {
+		check.Detail = "server does not identify itself (ID NIL)"
+	}
+
+	fmt.Println("\nServer identity (ID):")
+	if len(fields) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, key := range sortedIDKeys(fields) {
+		fmt.Printf("  %-12s %s\n", key+":", fields[key])
+	}
+	return check
+}
+
+// checkEnable sends a separate ENABLE for each of CONDSTORE, QRESYNC and
+// UTF8=ACCEPT, so one refusal does not hide the others, and returns the
+// extensions the server confirmed in an ENABLED response.
+func checkEnable(ctx context.Context, session *rawSession) (map[string]bool, []extensionCheck) {
+	caps := session.caps
+	hasEnable := caps.SupportsENABLE() || caps.SupportsIMAP4rev2()
+	enabled := make(map[string]bool)
+
+	var checks []extensionCheck
+	for _, ext := range []struct {
+		name       string
+		advertised bool
+	}{
+		{"CONDSTORE", caps.SupportsCONDSTORE()},
+		{"QRESYNC", caps.SupportsQRESYNC()},
+		{"UTF8=ACCEPT", caps.SupportsUTF8ACCEPT()},
+	} {
+		check := extensionCheck{Name: "ENABLE " + ext.name}
+		switch {
+		case !ext.advertised:
+			check.Result, check.Detail = extensionSkipped, "not advertised"
+		case !hasEnable:
+			check.Result, check.Detail = extensionSkipped, "advertised, but ENABLE is not"
+		default:
+			resp, err := session.command(ctx, "ENABLE %s", ext.name)
+			if err == nil {
+				err = resp.err("ENABLE")
+			}
+			switch {
+			case err != nil:
+				check.Result, check.Err = extensionFailed, err
+			case !enabledIn(resp, ext.name):
+				check.Result, check.Err = extensionFailed, fmt.Errorf("server accepted ENABLE but did not list %s as ENABLED", ext.name)
+			default:
+				check.Result, check.Detail = extensionOK, "enabled"
+				enabled[ext.name] = true
+			}
+		}
+		checks = append(checks, check)
+	}
+	return enabled, checks
+}
+
+// enabledIn reports whether an ENABLE response lists name as ENABLED.
+func enabledIn(resp *rawResponse, name string) bool {
+	for _, line := range resp.Untagged {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "ENABLED") {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if strings.EqualFold(f, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// examineForChecks opens mailbox read-only and returns its message count.
+// With CONDSTORE enabled, the ENABLE CONDSTORE check in checks is failed
+// when the response carries neither HIGHESTMODSEQ nor NOMODSEQ (RFC 7162
+// section 3.1.2.1).
+func examineForChecks(ctx context.Context, session *rawSession, mailbox string, condstore bool, checks []extensionCheck) (int, error) {
+	resp, err := session.command(ctx, "EXAMINE %s", quoteIMAPString(mailbox))
+	if err == nil {
+		err = resp.err("EXAMINE")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	messages, modSeq := 0, ""
+	for _, line := range resp.Untagged {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.EqualFold(fields[1], "EXISTS") {
+			messages, _ = strconv.Atoi(fields[0])
+		}
+		upper := strings.ToUpper(line)
+		if i := strings.Index(upper, "[HIGHESTMODSEQ "); i >= 0 {
+			modSeq, _, _ = strings.Cut(line[i+len("[HIGHESTMODSEQ "):], "]")
+			modSeq = "HIGHESTMODSEQ " + modSeq
+		} else if strings.Contains(upper, "[NOMODSEQ]") {
+			modSeq = "NOMODSEQ"
+		}
+	}
+
+	if condstore {
+		for i := range checks {
+			if checks[i].Name != "ENABLE CONDSTORE" {
+				continue
+			}
+			if modSeq == "" {
+				checks[i].Result = extensionFailed
+				checks[i].Err = fmt.Errorf("EXAMINE %s returned neither HIGHESTMODSEQ nor NOMODSEQ", mailbox)
+			} else {
+				checks[i].Detail = fmt.Sprintf("enabled; %s on %s", modSeq, mailbox)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// checkCompress measures COMPRESS=DEFLATE (RFC 4978): the sample command
+// (a FETCH of up to compressSampleMessages messages, or a LIST when the
+// mailbox is empty) is sent once before and once after compression is
+// turned on, and the decompressed responses must match the uncompressed ones.
+func checkCompress(ctx context.Context, session *rawSession, mailbox string, messages int, examineErr error) extensionCheck {
+	check := extensionCheck{Name: "COMPRESS=DEFLATE"}
+	if !session.caps.SupportsCOMPRESSDEFLATE() {
+		check.Result, check.Detail = extensionSkipped, "not advertised"
+		return check
+	}
+	if examineErr != nil {
+		check.Result, check.Err = extensionFailed, fmt.Errorf("cannot open sample mailbox: %w", examineErr)
+		return check
+	}
+
+	sample, what := `LIST "" "*"`, "LIST"
+	if messages > 0 {
+		n := min(messages, compressSampleMessages)
+		sample = fmt.Sprintf("FETCH 1:%d BODY.PEEK[]<0.65536>", n)
+		what = fmt.Sprintf("FETCH of %d message(s) from %s", n, mailbox)
+	}
+
+	// Returns the bytes received on the wire and a digest of the
+	// (decompressed) untagged responses
+	run := func() (int64, [32]byte, error) {
+		before := session.wire.read
+		resp, err := session.command(ctx, "%s", sample)
+		if err == nil {
+			err = resp.err(strings.Fields(sample)[0])
+		}
+		if err != nil {
+			return 0, [32]byte{}, err
+		}
+		return session.wire.read - before, sha256.Sum256([]byte(strings.Join(resp.Untagged, "\r\n"))), nil
+	}
+
+	plain, plainDigest, err := run()
+	if err != nil {
+		check.Result, check.Err = extensionFailed, err
+		return check
+	}
+	if err := session.startCompression(ctx); err != nil {
+		check.Result, check.Err = extensionFailed, err
+		return check
+	}
+	wire, compressedDigest, err := run()
+	if err != nil {
+		check.Result, check.Err = extensionFailed, fmt.Errorf("after COMPRESS: %w", err)
+		return check
+	}
+	if compressedDigest != plainDigest {
+		check.Result, check.Err = extensionFailed, fmt.Errorf("decompressed %s response differs from the uncompressed one", what)
+		return check
+	}
+
+	check.Result = extensionOK
+	check.Uncompressed, check.Compressed = plain, wire
+	saved := 0.0
+	if plain > 0 {
+		saved = float64(plain-wire) * 100 / float64(plain)
+	}
+	check.Detail = fmt.Sprintf("%s: %s uncompressed, %s on the wire (%.1f%% saved)",
+		what, formatBytes(plain), formatBytes(wire), saved)
+	return check
+}
+
+// parseIDFields parses the parameter list of an ID response, e.g.
+// `("name" "Dovecot" "version" NIL)`, into lower-case keys and values.
+// NIL values are dropped; the whole list may be NIL.
+func parseIDFields(list string) (map[string]string, error) {
+	list = strings.TrimSpace(list)
+	fields := make(map[string]string)
+	if strings.EqualFold(list, "NIL") {
+		return fields, nil
+	}
+	if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+		return nil, fmt.Errorf("malformed ID response: %s", list)
+	}
+
+	tokens, err := idTokens(list[1 : len(list)-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens)%2 != 0 {
+		return nil, fmt.Errorf("malformed ID response: odd number of fields")
+	}
+	for i := 0; i < len(tokens); i += 2 {
+		if tokens[i] == nil {
+			return nil, fmt.Errorf("malformed ID response: NIL field name")
+		}
+		if tokens[i+1] != nil {
+			fields[strings.ToLower(*tokens[i])] = *tokens[i+1]
+		}
+	}
+	return fields, nil
+}
+
+// idTokens splits the inside of an ID parameter list into strings (quoted
+// or literal) and NILs (nil entries).
+func idTokens(s string) ([]*string, error) {
+	var tokens []*string
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return tokens, nil
+		}
+		switch {
+		case s[0] == '"':
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("malformed ID response: unterminated string")
+			}
+			value := b.String()
+			tokens = append(tokens, &value)
+			s = s[i+1:]
+		case s[0] == '{':
+			header, rest, ok := strings.Cut(s, "\r\n")
+			size, isLiteral := literalSize(header)
+			if !ok || !isLiteral || int64(len(rest)) < size {
+				return nil, fmt.Errorf("malformed ID response: bad literal")
+			}
+			value := rest[:size]
+			tokens = append(tokens, &value)
+			s = rest[size:]
+		case len(s) >= 3 && strings.EqualFold(s[:3], "NIL"):
+			tokens = append(tokens, nil)
+			s = s[3:]
+		default:
+			return nil, fmt.Errorf("malformed ID response near %q", s)
+		}
+	}
+}
+
+// sortedIDKeys returns the ID field names in idFieldOrder, followed by any
+// others in alphabetical order.
+func sortedIDKeys(fields map[string]string) []string {
+	known := make(map[string]bool, len(idFieldOrder))
+	var keys []string
+	for _, key := range idFieldOrder {
+		known[key] = true
+		if _, ok := fields[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var others []string
+	for key := range fields {
+		if !known[key] {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	return append(keys, others...)
+}
+
+// formatIDFields renders the server's name, vendor and version, e.g.
+// "name=Dovecot; version=2.3.21".
+func formatIDFields(fields map[string]string) string {
+	var parts []string
+	for _, key := range []string{"name", "vendor", "version"} {
+		if value, ok := fields[key]; ok {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,246 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bufio"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseIDFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "NIL", list: "NIL", want: map[string]string{}},
+		{name: "quoted", list: `("name" "Dovecot" "Version" "2.3.21")`, want: map[string]string{"name": "Dovecot", "version": "2.3.21"}},
+		{name: "NIL value dropped", list: `("name" "Cyrus" "os" NIL)`, want: map[string]string{"name": "Cyrus"}},
+		{name: "escapes", list: `("vendor" "Acme \"Mail\" \\ Co")`, want: map[string]string{"vendor": `Acme "Mail" \ Co`}},
+		{name: "literal", list: "(\"vendor\" {7}\r\nExample \"name\" \"x\")", want: map[string]string{"vendor": "Example", "name": "x"}},
+		{name: "odd fields", list: `("name")`, wantErr: true},
+		{name: "unterminated", list: `("name" "x)`, wantErr: true},
+		{name: "not a list", list: `"name" "x"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIDFields(tt.list)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseIDFields(%q) expected error, got %v", tt.list, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseIDFields(%q) error = %v", tt.list, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseIDFields(%q) = %v, want %v", tt.list, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLiteralSize(t *testing.T) {
+	tests := []struct {
+		line string
+		size int64
+		ok   bool
+	}{
+		{"* 1 FETCH (BODY[] {342}", 342, true},
+		{"A1 LOGIN {5+}", 5, true},
+		{"* OK [HIGHESTMODSEQ 5] done", 0, false},
+		{"* OK {braces}", 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := literalSize(tt.line)
+		if size != tt.size || ok != tt.ok {
+			t.Errorf("literalSize(%q) = %d, %v; want %d, %v", tt.line, size, ok, tt.size, tt.ok)
+		}
+	}
+}
+
+func TestTestExtensions_MemServer(t *testing.T) {
+	config, _ := newTestIMAPServer(t, testMessageAlpha)
+	config.Action = ActionTestExtensions
+
+	csvLog := &recordingLogger{}
+	if err := testExtensions(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("testExtensions() error = %v", err)
+	}
+
+	results := make(map[string]string)
+	for _, row := range csvLog.rows {
+		results[csvLog.column(row, "Check")] = csvLog.column(row, "Result")
+	}
+	want := map[string]string{
+		"ID":                 extensionSkipped,
+		"ENABLE CONDSTORE":   extensionSkipped,
+		"ENABLE QRESYNC":     extensionSkipped,
+		"ENABLE UTF8=ACCEPT": extensionOK,
+		"COMPRESS=DEFLATE":   extensionSkipped,
+	}
+	for check, result := range want {
+		if results[check] != result {
+			t.Errorf("%s = %q, want %q (all: %v)", check, results[check], result, results)
+		}
+	}
+}
+
+func TestTestExtensions_Compress(t *testing.T) {
+	config := startFakeExtensionServer(t)
+	config.Action = ActionTestExtensions
+
+	csvLog := &recordingLogger{}
+	if err := testExtensions(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("testExtensions() error = %v", err)
+	}
+
+	rows := make(map[string][]string)
+	for _, row := range csvLog.rows {
+		rows[csvLog.column(row, "Check")] = row
+	}
+
+	id := rows["ID"]
+	if csvLog.column(id, "Result") != extensionOK || csvLog.column(id, "Detail") != "name=FakeIMAP; vendor=Example; version=1.0" {
+		t.Errorf("ID row = %v", id)
+	}
+	condstore := rows["ENABLE CONDSTORE"]
+	if csvLog.column(condstore, "Result") != extensionOK || !strings.Contains(csvLog.column(condstore, "Detail"), "HIGHESTMODSEQ 42") {
+		t.Errorf("ENABLE CONDSTORE row = %v", condstore)
+	}
+	if result := csvLog.column(rows["ENABLE QRESYNC"], "Result"); result != extensionSkipped {
+		t.Errorf("ENABLE QRESYNC = %q, want %q", result, extensionSkipped)
+	}
+
+	compress := rows["COMPRESS=DEFLATE"]
+	if csvLog.column(compress, "Result") != extensionOK {
+		t.Fatalf("COMPRESS row = %v", compress)
+	}
+	uncompressed, _ := strconv.ParseInt(csvLog.column(compress, "Uncompressed_Bytes"), 10, 64)
+	compressed, _ := strconv.ParseInt(csvLog.column(compress, "Compressed_Bytes"), 10, 64)
+	if compressed <= 0 || compressed >= uncompressed/2 {
+		t.Errorf("compressed %d bytes of %d, want a large saving on repetitive messages", compressed, uncompressed)
+	}
+}
+
+func TestTestExtensions_EnableRefused(t *testing.T) {
+	config := startFakeExtensionServer(t, "ENABLE CONDSTORE")
+	config.Action = ActionTestExtensions
+
+	csvLog := &recordingLogger{}
+	err := testExtensions(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 5 extension checks failed") {
+		t.Fatalf("testExtensions() error = %v, want one failed check", err)
+	}
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Check") == "ENABLE CONDSTORE" && csvLog.column(row, "Status") != "FAILURE" {
+			t.Errorf("ENABLE CONDSTORE row = %v, want FAILURE", row)
+		}
+	}
+}
+
+// startFakeExtensionServer serves a scripted IMAP server advertising ID,
+// ENABLE, CONDSTORE and COMPRESS=DEFLATE. Commands listed in refuse are
+// answered with NO.
+func startFakeExtensionServer(t *testing.T, refuse ...string) *Config {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeExtensionConn(conn, refuse)
+		}
+	}()
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	config.Username = "tester"
+	config.Password = "secret"
+	return config
+}
+
+func serveFakeExtensionConn(conn net.Conn, refuse []string) {
+	defer conn.Close()
+
+	var r *bufio.Reader = bufio.NewReader(conn)
+	var w io.Writer = conn
+	var flush func() error
+	send := func(lines ...string) {
+		for _, line := range lines {
+			_, _ = io.WriteString(w, line+"\r\n")
+		}
+		if flush != nil {
+			_ = flush()
+		}
+	}
+
+	body := strings.Repeat("The quick brown fox jumps over the lazy dog.\r\n", 200)
+	send("* OK fake server ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		name, _, _ := strings.Cut(command, " ")
+
+		refused := false
+		for _, cmd := range refuse {
+			if strings.EqualFold(command, cmd) {
+				refused = true
+			}
+		}
+		if refused {
+			send(tag + " NO refused")
+			continue
+		}
+
+		switch strings.ToUpper(name) {
+		case "CAPABILITY":
+			send("* CAPABILITY IMAP4rev1 ID ENABLE CONDSTORE COMPRESS=DEFLATE AUTH=PLAIN SASL-IR", tag+" OK done")
+		case "AUTHENTICATE":
+			send(tag + " OK authenticated")
+		case "ID":
+			send("* ID (\"name\" \"FakeIMAP\" \"vendor\" {7}\r\nExample \"version\" \"1.0\" \"os\" NIL)", tag+" OK done")
+		case "ENABLE":
+			send("* ENABLED CONDSTORE", tag+" OK done")
+		case "EXAMINE":
+			send("* 2 EXISTS", "* OK [UIDVALIDITY 1] ok", "* OK [HIGHESTMODSEQ 42] ok", tag+" OK [READ-ONLY] done")
+		case "FETCH":
+			for i := 1; i <= 2; i++ {
+				send(fmt.Sprintf("* %d FETCH (BODY[]<0> {%d}\r\n%s)", i, len(body), body))
+			}
+			send(tag + " OK done")
+		case "COMPRESS":
+			send(tag + " OK DEFLATE active")
+			r = bufio.NewReader(flate.NewReader(r))
+			compressor, _ := flate.NewWriter(conn, flate.DefaultCompression)
+			w, flush = compressor, compressor.Flush
+		case "LOGOUT":
+			send("* BYE bye", tag+" OK done")
+			return
+		default:
+			send(tag + " BAD unknown command")
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// fetchMail searches the selected mailbox and shows the newest matches:
+// envelope, flags, size and MIME structure. With --save-dir the full
+// RFC 822 messages are written as .eml files. The mailbox is opened with
+// EXAMINE and bodies are fetched with BODY.PEEK, so \Seen flags are left alone.
+func fetchMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Fetching messages from %s on %s:%d...\n", config.Mailbox, config.Host, config.Port)
+
+	// CSV columns for fetchmail
+	columns := []string{"Action", "Status", "Server", "Port", "Mailbox", "UID", "Date", "From", "Subject", "Message_ID", "Flags", "Size", "Structure", "Saved_File", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeFailure := func(err error) {
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+			config.Mailbox, "", "", "", "", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	criteria, err := buildSearchCriteria(config, time.Now())
+	if err != nil {
+		writeFailure(err)
+		return err
+	}
+
+	if config.SaveDir != "" {
+		if err := os.MkdirAll(config.SaveDir, 0o755); err != nil {
+			writeFailure(err)
+			return fmt.Errorf("cannot create save directory: %w", err)
+		}
+	}
+
+	client, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		writeFailure(err)
+		return err
+	}
+	defer func() { _ = client.Logout() }()
+
+	selectData, err := client.SelectMailbox(ctx, config.Mailbox, true)
+	if err != nil {
+		logger.LogError(slogLogger, "EXAMINE failed", "error", err, "mailbox", config.Mailbox)
+		writeFailure(err)
+		return err
+	}
+	fmt.Printf("✓ Opened %s (%d messages, UIDVALIDITY %d)\n", config.Mailbox, selectData.NumMessages, selectData.UIDValidity)
+
+	uids, err := client.SearchUIDs(ctx, criteria)
+	if err != nil {
+		logger.LogError(slogLogger, "SEARCH failed", "error", err)
+		writeFailure(err)
+		return err
+	}
+
+	fmt.Printf("✓ Search matched %d message(s)\n", len(uids))
+	if len(uids) == 0 {
+		logger.LogInfo(slogLogger, "Fetch mail completed", "mailbox", config.Mailbox, "matched", 0)
+		fmt.Println("\n✓ Fetch mail completed")
+		return nil
+	}
+
+	// Newest matches have the highest UIDs
+	if len(uids) > config.Limit {
+		uids = uids[len(uids)-config.Limit:]
+	}
+
+	options := &imap.FetchOptions{
+		UID:           true,
+		Envelope:      true,
+		Flags:         true,
+		InternalDate:  true,
+		RFC822Size:    true,
+		BodyStructure: &imap.FetchItemBodyStructure{Extended: true},
+	}
+	if config.SaveDir != "" {
+		options.BodySection = []*imap.FetchItemBodySection{{Peek: true}}
+	}
+
+	messages, err := client.FetchUIDs(ctx, uids, options)
+	if err != nil {
+		logger.LogError(slogLogger, "FETCH failed", "error", err)
+		writeFailure(err)
+		return err
+	}
+
+	// Show newest first
+	sortMessagesByUIDDesc(messages)
+
+	fmt.Printf("\nShowing %d newest message(s):\n", len(messages))
+	for _, msg := range messages {
+		env := msg.Envelope
+		if env == nil {
+			env = &imap.Envelope{}
+		}
+
+		date := env.Date
+		if date.IsZero() {
+			date = msg.InternalDate
+		}
+
+		flags := formatFlags(msg.Flags)
+		structure := formatBodyStructure(msg.BodyStructure)
+
+		fmt.Printf("\n  UID:        %d\n", msg.UID)
+		fmt.Printf("  Date:       %s\n", formatDate(date))
+		fmt.Printf("  From:       %s\n", formatAddresses(env.From))
+		fmt.Printf("  To:         %s\n", formatAddresses(env.To))
+		if len(env.Cc) > 0 {
+			fmt.Printf("  Cc:         %s\n", formatAddresses(env.Cc))
+		}
+		fmt.Printf("  Subject:    %s\n", env.Subject)
+		fmt.Printf("  Message-ID: %s\n", env.MessageID)
+		fmt.Printf("  Flags:      %s\n", flags)
+		fmt.Printf("  Size:       %d bytes\n", msg.RFC822Size)
+		if len(structure) > 0 {
+			fmt.Println("  Structure:")
+			for _, line := range structure {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+
+		status := "SUCCESS"
+		savedFile := ""
+		errMsg := ""
+		if config.SaveDir != "" {
+			path, err := saveMessage(config.SaveDir, config.Mailbox, msg)
+			if err != nil {
+				logger.LogError(slogLogger, "Failed to save message", "error", err, "uid", msg.UID)
+				fmt.Printf("  ✗ Save failed: %v\n", err)
+				status = "FAILURE"
+				errMsg = err.Error()
+			} else {
+				fmt.Printf("  ✓ Saved to %s\n", path)
+				savedFile = path
+			}
+		}
+
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			config.Mailbox, fmt.Sprintf("%d", msg.UID), formatDate(date), formatAddresses(env.From),
+			env.Subject, env.MessageID, flags, fmt.Sprintf("%d", msg.RFC822Size),
+			strings.Join(structure, "; "), savedFile, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	logger.LogInfo(slogLogger, "Fetch mail completed",
+		"mailbox", config.Mailbox,
+		"matched", len(uids),
+		"fetched", len(messages))
+
+	fmt.Println("\n✓ Fetch mail completed")
+	return nil
+}
+
+// buildSearchCriteria turns the search flags into IMAP SEARCH criteria.
+// With no flags set every message matches.
+func buildSearchCriteria(config *Config, now time.Time) (*imap.SearchCriteria, error) {
+	criteria := &imap.SearchCriteria{}
+
+	if config.SearchSince != "" {
+		since, err := parseSince(config.SearchSince, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since: %w", err)
+		}
+		criteria.Since = since
+	}
+	if config.SearchFrom != "" {
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: "From", Value: config.SearchFrom})
+	}
+	if config.SearchSubject != "" {
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: "Subject", Value: config.SearchSubject})
+	}
+	for _, h := range config.SearchHeaders {
+		name, value, err := parseHeaderCriterion(h)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --header: %w", err)
+		}
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: name, Value: value})
+	}
+	if config.SearchUnseen {
+		criteria.NotFlag = append(criteria.NotFlag, imap.FlagSeen)
+	}
+
+	return criteria, nil
+}
+
+// parseSince accepts a date (YYYY-MM-DD) or a duration before now, either a
+// Go duration (36h) or a number of days (7d). IMAP SINCE only compares dates.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD) or duration (e.g. 24h, 7d)", value)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD) or duration (e.g. 24h, 7d)", value)
+	}
+	return now.Add(-d), nil
+}
+
+// parseHeaderCriterion splits a "Name: value" header criterion. An empty
+// value matches every message that has the header.
+func parseHeaderCriterion(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("%q must be in \"Name: value\" format", s)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// sortMessagesByUIDDesc orders fetched messages newest (highest UID) first.
+func sortMessagesByUIDDesc(messages []*imapclient.FetchMessageBuffer) {
+	sort.Slice(messages, func(i, j int) bool { return messages[i].UID > messages[j].UID })
+}
+
+// formatDate formats a message date for display, or "" when unknown.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC1123Z)
+}
+
+// formatAddresses renders envelope addresses as "Name <addr>", comma separated.
+func formatAddresses(addrs []imap.Address) string {
+	var parts []string
+	for _, a := range addrs {
+		addr := a.Addr()
+		if addr == "" {
+			continue // group markers
+		}
+		if a.Name != "" {
+			parts = append(parts, fmt.Sprintf("%s <%s>", a.Name, addr))
+		} else {
+			parts = append(parts, addr)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatFlags renders message flags space separated, or "(none)".
+func formatFlags(flags []imap.Flag) string {
+	if len(flags) == 0 {
+		return "(none)"
+	}
+	parts := make([]string, len(flags))
+	for i, f := range flags {
+		parts[i] = string(f)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatBodyStructure renders a BODYSTRUCTURE as one line per part, indented
+// by depth and prefixed with the IMAP part path, e.g.
+//
+//	multipart/mixed
+//	  1 text/plain charset=utf-8 7bit 120 bytes
+//	  2 application/pdf "report.pdf" base64 5230 bytes attachment
+func formatBodyStructure(bs imap.BodyStructure) []string {
+	if bs == nil {
+		return nil
+	}
+
+	var lines []string
+	bs.Walk(func(path []int, part imap.BodyStructure) bool {
+		indent := strings.Repeat("  ", len(path))
+		var num string
+		if len(path) > 0 {
+			parts := make([]string, len(path))
+			for i, n := range path {
+				parts[i] = strconv.Itoa(n)
+			}
+			num = strings.Join(parts, ".") + " "
+		}
+
+		line := part.MediaType()
+		if single, ok := part.(*imap.BodyStructureSinglePart); ok {
+			if _, isMultipartRoot := bs.(*imap.BodyStructureMultiPart); !isMultipartRoot {
+				// A single-part message: show it without a part number
+				indent, num = "", ""
+			}
+			if charset := single.Params["charset"]; charset != "" {
+				line += " charset=" + strings.ToLower(charset)
+			}
+			if filename := single.Filename(); filename != "" {
+				line += fmt.Sprintf(" %q", filename)
+			}
+			if single.Encoding != "" {
+				line += " " + strings.ToLower(single.Encoding)
+			}
+			line += fmt.Sprintf(" %d bytes", single.Size)
+		}
+		if disp := part.Disposition(); disp != nil && disp.Value != "" {
+			line += " " + strings.ToLower(disp.Value)
+		}
+
+		lines = append(lines, indent+num+line)
+		return true
+	})
+	return lines
+}
+
+// saveMessage writes the fetched BODY[] of msg to dir as
+// <mailbox>_<uid>.eml and returns the file path.
+func saveMessage(dir, mailbox string, msg *imapclient.FetchMessageBuffer) (string, error) {
+	var body []byte
+	for _, section := range msg.BodySection {
+		if section.Section != nil && section.Section.Specifier == imap.PartSpecifierNone && len(section.Section.Part) == 0 {
+			body = section.Bytes
+			break
+		}
+	}
+	if body == nil {
+		return "", fmt.Errorf("server returned no message body for UID %d", msg.UID)
+	}
+
+	path := filepath.Join(dir, emlFileName(mailbox, msg.UID))
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// emlFileName builds a file name for a saved message. Characters that are
+// not safe in file names (such as hierarchy separators) are replaced.
+func emlFileName(mailbox string, uid imap.UID) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, mailbox)
+	return fmt.Sprintf("%s_%d.eml", safe, uid)
+}
@@ -0,0 +1,300 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+// recordingLogger is an in-memory logger.Logger that keeps the CSV rows.
+type recordingLogger struct {
+	header []string
+	rows   [][]string
+}
+
+func (l *recordingLogger) WriteHeader(columns []string) error { l.header = columns; return nil }
+func (l *recordingLogger) WriteRow(row []string) error        { l.rows = append(l.rows, row); return nil }
+func (l *recordingLogger) Close() error                       { return nil }
+func (l *recordingLogger) ShouldWriteHeader() (bool, error)   { return l.header == nil, nil }
+
+// column returns the value of the named column in row.
+func (l *recordingLogger) column(row []string, name string) string {
+	for i, c := range l.header {
+		if c == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// newTestIMAPServer starts an in-memory IMAP server with user "tester"
+// (password "secret") and an INBOX holding the given messages. It returns a
+// config pointing at the server and the user, for inspecting the mailboxes.
+func newTestIMAPServer(t *testing.T, messages ...string) (*Config, *imapmemserver.User) {
+	t.Helper()
+
+	user := imapmemserver.NewUser("tester", "secret")
+	if err := user.Create("INBOX", nil); err != nil {
+		t.Fatalf("create INBOX: %v", err)
+	}
+	for _, msg := range messages {
+		msg = strings.ReplaceAll(msg, "\n", "\r\n")
+		if _, err := user.Append("INBOX", bytes.NewReader([]byte(msg)), &imap.AppendOptions{}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	return startTestIMAPServer(t, user, nil), user
+}
+
+// startTestIMAPServer serves user from an in-memory IMAP server on a local
+// port. onConn, if set, is called for each accepted connection.
+func startTestIMAPServer(t *testing.T, user *imapmemserver.User, onConn func(*imapserver.Conn)) *Config {
+	t.Helper()
+
+	mem := imapmemserver.New()
+	mem.AddUser(user)
+
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(conn *imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			if onConn != nil {
+				onConn(conn)
+			}
+			return mem.NewSession(), nil, nil
+		},
+		Caps:         imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapIMAP4rev2: {}},
+		InsecureAuth: true,
+		Logger:       log.New(io.Discard, "", 0),
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	config.Username = "tester"
+	config.Password = "secret"
+	config.AuthMethod = "LOGIN"
+	return config
+}
+
+const testMessageAlpha = `From: Alice <alice@example.com>
+To: bob@example.com
+Subject: Alpha report
+Message-ID: <alpha@example.com>
+Date: Mon, 02 Jan 2026 10:00:00 +0000
+X-GoMailTest-Probe: baseline
+
+Alpha body
+`
+
+const testMessageBeta = `From: Carol <carol@example.com>
+To: bob@example.com
+Subject: Beta notes
+Message-ID: <beta@example.com>
+Date: Tue, 03 Jan 2026 10:00:00 +0000
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=utf-8
+
+Beta body
+--b1
+Content-Type: application/pdf; name="notes.pdf"
+Content-Disposition: attachment; filename="notes.pdf"
+Content-Transfer-Encoding: base64
+
+JVBERi0xLjQK
+--b1--
+`
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2026-01-15", want: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "36h", want: now.Add(-36 * time.Hour)},
+		{value: "yesterday", wantErr: true},
+		{value: "-5d", wantErr: true},
+		{value: "xd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSince(tt.value, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSince(%q) expected error, got %v", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSince(%q) unexpected error = %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSince(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHeaderCriterion(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{input: "X-Mailer: gomailtest", wantName: "X-Mailer", wantValue: "gomailtest"},
+		{input: "List-Id:", wantName: "List-Id", wantValue: ""},
+		{input: "Received: from a: b", wantName: "Received", wantValue: "from a: b"},
+		{input: "no colon", wantErr: true},
+		{input: ": value", wantErr: true},
+		{input: "Bad Name: value", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			name, value, err := parseHeaderCriterion(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseHeaderCriterion(%q) expected error", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseHeaderCriterion(%q) unexpected error = %v", tt.input, err)
+			}
+			if name != tt.wantName || value != tt.wantValue {
+				t.Errorf("parseHeaderCriterion(%q) = (%q, %q), want (%q, %q)", tt.input, name, value, tt.wantName, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestBuildSearchCriteria(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	config := NewConfig()
+	config.SearchSince = "2d"
+	config.SearchFrom = "alice"
+	config.SearchSubject = "report"
+	config.SearchUnseen = true
+	config.SearchHeaders = []string{"X-GoMailTest-Probe: eicar"}
+
+	criteria, err := buildSearchCriteria(config, now)
+	if err != nil {
+		t.Fatalf("buildSearchCriteria() error = %v", err)
+	}
+	if !criteria.Since.Equal(now.AddDate(0, 0, -2)) {
+		t.Errorf("Since = %v, want %v", criteria.Since, now.AddDate(0, 0, -2))
+	}
+	wantHeaders := []imap.SearchCriteriaHeaderField{
+		{Key: "From", Value: "alice"},
+		{Key: "Subject", Value: "report"},
+		{Key: "X-GoMailTest-Probe", Value: "eicar"},
+	}
+	if len(criteria.Header) != len(wantHeaders) {
+		t.Fatalf("Header = %v, want %v", criteria.Header, wantHeaders)
+	}
+	for i, h := range wantHeaders {
+		if criteria.Header[i] != h {
+			t.Errorf("Header[%d] = %v, want %v", i, criteria.Header[i], h)
+		}
+	}
+	if len(criteria.NotFlag) != 1 || criteria.NotFlag[0] != imap.FlagSeen {
+		t.Errorf("NotFlag = %v, want [\\Seen]", criteria.NotFlag)
+	}
+}
+
+func TestFormatBodyStructure(t *testing.T) {
+	bs := &imap.BodyStructureMultiPart{
+		Subtype: "mixed",
+		Children: []imap.BodyStructure{
+			&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain", Params: map[string]string{"charset": "UTF-8"}, Encoding: "7BIT", Size: 120},
+			&imap.BodyStructureSinglePart{
+				Type: "application", Subtype: "pdf", Encoding: "BASE64", Size: 5230,
+				Extended: &imap.BodyStructureSinglePartExt{Disposition: &imap.BodyStructureDisposition{
+					Value: "attachment", Params: map[string]string{"filename": "report.pdf"},
+				}},
+			},
+		},
+	}
+
+	want := []string{
+		"multipart/mixed",
+		"  1 text/plain charset=utf-8 7bit 120 bytes",
+		`  2 application/pdf "report.pdf" base64 5230 bytes attachment`,
+	}
+	got := formatBodyStructure(bs)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("formatBodyStructure() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	single := &imap.BodyStructureSinglePart{Type: "text", Subtype: "plain", Size: 10}
+	if got := formatBodyStructure(single); len(got) != 1 || got[0] != "text/plain 10 bytes" {
+		t.Errorf("formatBodyStructure(single) = %v", got)
+	}
+}
+
+func TestEmlFileName(t *testing.T) {
+	if got := emlFileName("INBOX/Sub Folder", 42); got != "INBOX_Sub_Folder_42.eml" {
+		t.Errorf("emlFileName() = %q", got)
+	}
+}
+
+func TestFetchMail_SearchAndSave(t *testing.T) {
+	config, _ := newTestIMAPServer(t, testMessageAlpha, testMessageBeta)
+	config.Action = ActionFetchMail
+	config.SearchFrom = "carol"
+	config.SaveDir = t.TempDir()
+
+	csvLog := &recordingLogger{}
+	if err := fetchMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("fetchMail() error = %v", err)
+	}
+
+	if len(csvLog.rows) != 1 {
+		t.Fatalf("got %d CSV rows, want 1", len(csvLog.rows))
+	}
+	row := csvLog.rows[0]
+	if got := csvLog.column(row, "Message_ID"); got != "beta@example.com" {
+		t.Errorf("Message_ID = %q, want beta@example.com", got)
+	}
+	if got := csvLog.column(row, "Structure"); !strings.Contains(got, `application/pdf "notes.pdf"`) {
+		t.Errorf("Structure = %q, want the PDF attachment listed", got)
+	}
+
+	saved, err := os.ReadFile(filepath.Join(config.SaveDir, "INBOX_2.eml"))
+	if err != nil {
+		t.Fatalf("saved message not found: %v", err)
+	}
+	if !bytes.Contains(saved, []byte("Message-ID: <beta@example.com>")) {
+		t.Errorf("saved message is not the Beta message:\n%s", saved)
+	}
+	if _, err := os.Stat(filepath.Join(config.SaveDir, "INBOX_1.eml")); err == nil {
+		t.Error("Alpha message saved although it does not match --from carol")
+	}
+}
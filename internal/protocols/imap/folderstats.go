@@ -0,0 +1,225 @@
+package imap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Folder comparison states reported by listfolders --compare-with.
+const (
+	compareMatch   = "MATCH"
+	compareChanged = "CHANGED"
+	compareNew     = "NEW"
+	compareMissing = "MISSING"
+)
+
+// folderSnapshot is the JSON document written by listfolders --snapshot and
+// read back by --compare-with.
+type folderSnapshot struct {
+	Server   string        `json:"server"`
+	Port     int           `json:"port"`
+	Username string        `json:"username"`
+	Taken    time.Time     `json:"taken"`
+	Folders  []folderStats `json:"folders"`
+	Totals   folderTotals  `json:"totals"`
+}
+
+// folderStats is the snapshot form of a MailboxInfo.
+type folderStats struct {
+	Name        string   `json:"name"`
+	Delimiter   string   `json:"delimiter,omitempty"`
+	Attributes  []string `json:"attributes,omitempty"`
+	Selectable  bool     `json:"selectable"`
+	Messages    uint32   `json:"messages"`
+	Unseen      uint32   `json:"unseen"`
+	UIDNext     uint32   `json:"uidnext,omitempty"`
+	UIDValidity uint32   `json:"uidvalidity,omitempty"`
+	Size        *int64   `json:"size,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// folderTotals sums the counters of all selectable folders whose STATUS
+// succeeded. Size is nil when the server reported no folder sizes.
+type folderTotals struct {
+	Folders  int    `json:"folders"`
+	Messages uint64 `json:"messages"`
+	Unseen   uint64 `json:"unseen"`
+	Size     *int64 `json:"size,omitempty"`
+}
+
+// folderDelta is the comparison of one folder against a snapshot.
+type folderDelta struct {
+	Name               string
+	State              string // compareMatch, compareChanged, compareNew or compareMissing
+	Messages           int64
+	Unseen             int64
+	Size               *int64 // nil unless both sides have a size
+	UIDValidityChanged bool
+}
+
+// newFolderStats converts listed mailboxes to their snapshot form.
+func newFolderStats(mailboxes []MailboxInfo) []folderStats {
+	folders := make([]folderStats, 0, len(mailboxes))
+	for _, mb := range mailboxes {
+		folders = append(folders, folderStats{
+			Name:        mb.Name,
+			Delimiter:   mb.Delimiter,
+			Attributes:  mb.Attributes,
+			Selectable:  isSelectable(mb.Attributes),
+			Messages:    mb.Messages,
+			Unseen:      mb.Unseen,
+			UIDNext:     mb.UIDNext,
+			UIDValidity: mb.UIDValidity,
+			Size:        mb.Size,
+			Error:       mb.StatusError,
+		})
+	}
+	return folders
+}
+
+// sumFolders totals the counters of the folders that have STATUS data.
+func sumFolders(folders []folderStats) folderTotals {
+	var totals folderTotals
+	for _, f := range folders {
+		if !f.Selectable || f.Error != "" {
+			continue
+		}
+		totals.Folders++
+		totals.Messages += uint64(f.Messages)
+		totals.Unseen += uint64(f.Unseen)
+		if f.Size != nil {
+			if totals.Size == nil {
+				totals.Size = new(int64)
+			}
+			*totals.Size += *f.Size
+		}
+	}
+	return totals
+}
+
+// writeFolderSnapshot writes a snapshot to path as indented JSON.
+func writeFolderSnapshot(path string, snapshot *folderSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// loadFolderSnapshot reads a snapshot written by writeFolderSnapshot.
+func loadFolderSnapshot(path string) (*folderSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snapshot folderSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// folderKey returns the name used to match folders between servers: the
+// hierarchy delimiter is normalised to "/" and INBOX to upper case, so
+// "INBOX.Sent" on one server matches "inbox/Sent" on another.
+func folderKey(name, delimiter string) string {
+	if delimiter != "" && delimiter != "/" {
+		name = strings.ReplaceAll(name, delimiter, "/")
+	}
+	if head, rest, found := strings.Cut(name, "/"); strings.EqualFold(head, "INBOX") {
+		name = "INBOX"
+		if found {
+			name += "/" + rest
+		}
+	}
+	return name
+}
+
+// compareFolders compares the selectable current folders with a snapshot.
+// Folders are reported in current order, followed by the snapshot folders
+// that no longer exist. Sizes and UIDVALIDITY legitimately differ between
+// servers, so they only mark a folder changed when sameServer is set.
+func compareFolders(previous []folderStats, current []folderStats, sameServer bool) []folderDelta {
+	old := make(map[string]folderStats, len(previous))
+	for _, f := range previous {
+		if f.Selectable {
+			old[folderKey(f.Name, f.Delimiter)] = f
+		}
+	}
+
+	var deltas []folderDelta
+	seen := make(map[string]bool, len(current))
+	for _, f := range current {
+		if !f.Selectable {
+			continue
+		}
+		key := folderKey(f.Name, f.Delimiter)
+		seen[key] = true
+
+		p, ok := old[key]
+		if !ok {
+			deltas = append(deltas, folderDelta{
+				Name:     f.Name,
+				State:    compareNew,
+				Messages: int64(f.Messages),
+				Unseen:   int64(f.Unseen),
+				Size:     f.Size,
+			})
+			continue
+		}
+
+		d := folderDelta{
+			Name:     f.Name,
+			Messages: int64(f.Messages) - int64(p.Messages),
+			Unseen:   int64(f.Unseen) - int64(p.Unseen),
+		}
+		if f.Size != nil && p.Size != nil {
+			size := *f.Size - *p.Size
+			d.Size = &size
+		}
+		d.UIDValidityChanged = sameServer && p.UIDValidity != 0 && f.UIDValidity != 0 && p.UIDValidity != f.UIDValidity
+
+		d.State = compareMatch
+		sizeChanged := sameServer && d.Size != nil && *d.Size != 0
+		if d.Messages != 0 || d.Unseen != 0 || sizeChanged || d.UIDValidityChanged {
+			d.State = compareChanged
+		}
+		deltas = append(deltas, d)
+	}
+
+	var missing []folderDelta
+	for key, p := range old {
+		if seen[key] {
+			continue
+		}
+		d := folderDelta{
+			Name:     p.Name,
+			State:    compareMissing,
+			Messages: -int64(p.Messages),
+			Unseen:   -int64(p.Unseen),
+		}
+		if p.Size != nil {
+			size := -*p.Size
+			d.Size = &size
+		}
+		missing = append(missing, d)
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i].Name < missing[j].Name })
+
+	return append(deltas, missing...)
+}
+
+// formatSigned renders a delta with an explicit sign.
+func formatSigned(n int64) string {
+	if n > 0 {
+		return fmt.Sprintf("+%d", n)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,153 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestFolderKey(t *testing.T) {
+	tests := []struct {
+		name, delimiter, want string
+	}{
+		{"INBOX.Sent", ".", "INBOX/Sent"},
+		{"inbox/Sent", "/", "INBOX/Sent"},
+		{"Inbox", "/", "INBOX"},
+		{"Archive", "", "Archive"},
+		{"Inboxes.2026", ".", "Inboxes/2026"},
+	}
+	for _, tt := range tests {
+		if got := folderKey(tt.name, tt.delimiter); got != tt.want {
+			t.Errorf("folderKey(%q, %q) = %q, want %q", tt.name, tt.delimiter, got, tt.want)
+		}
+	}
+}
+
+func TestSumFolders(t *testing.T) {
+	size := func(n int64) *int64 { return &n }
+	totals := sumFolders([]folderStats{
+		{Name: "INBOX", Selectable: true, Messages: 10, Unseen: 2, Size: size(1000)},
+		{Name: "Sent", Selectable: true, Messages: 5, Size: size(500)},
+		{Name: "Shared", Selectable: false},
+		{Name: "Broken", Selectable: true, Messages: 99, Error: "NO access denied"},
+	})
+	if totals.Folders != 2 || totals.Messages != 15 || totals.Unseen != 2 || totals.Size == nil || *totals.Size != 1500 {
+		t.Errorf("sumFolders() = %+v", totals)
+	}
+
+	if totals := sumFolders([]folderStats{{Name: "INBOX", Selectable: true, Messages: 1}}); totals.Size != nil {
+		t.Errorf("sumFolders() without sizes has Size %d, want nil", *totals.Size)
+	}
+}
+
+func TestCompareFolders(t *testing.T) {
+	size := func(n int64) *int64 { return &n }
+	previous := []folderStats{
+		{Name: "INBOX", Delimiter: ".", Selectable: true, Messages: 10, UIDValidity: 1, Size: size(1000)},
+		{Name: "INBOX.Sent", Delimiter: ".", Selectable: true, Messages: 5, UIDValidity: 1, Size: size(500)},
+		{Name: "Old", Delimiter: ".", Selectable: true, Messages: 3},
+	}
+	current := []folderStats{
+		{Name: "INBOX", Delimiter: "/", Selectable: true, Messages: 10, UIDValidity: 7, Size: size(1200)},
+		{Name: "INBOX/Sent", Delimiter: "/", Selectable: true, Messages: 4, UIDValidity: 7, Size: size(400)},
+		{Name: "New", Delimiter: "/", Selectable: true, Messages: 2},
+		{Name: "Public", Delimiter: "/", Selectable: false},
+	}
+
+	got := make(map[string]folderDelta)
+	for _, d := range compareFolders(previous, current, false) {
+		got[d.Name] = d
+	}
+	if len(got) != 4 {
+		t.Fatalf("compareFolders() returned %d folders, want 4: %v", len(got), got)
+	}
+	if d := got["INBOX"]; d.State != compareMatch || d.Size == nil || *d.Size != 200 || d.UIDValidityChanged {
+		t.Errorf("INBOX across servers = %+v, want MATCH with size +200", d)
+	}
+	if d := got["INBOX/Sent"]; d.State != compareChanged || d.Messages != -1 {
+		t.Errorf("INBOX/Sent = %+v, want CHANGED with messages -1", d)
+	}
+	if d := got["New"]; d.State != compareNew || d.Messages != 2 {
+		t.Errorf("New = %+v, want NEW with messages +2", d)
+	}
+	if d := got["Old"]; d.State != compareMissing || d.Messages != -3 {
+		t.Errorf("Old = %+v, want MISSING with messages -3", d)
+	}
+
+	for _, d := range compareFolders(previous, current, true) {
+		if d.Name == "INBOX" && (d.State != compareChanged || !d.UIDValidityChanged) {
+			t.Errorf("INBOX on the same server = %+v, want CHANGED with UIDVALIDITY change", d)
+		}
+	}
+}
+
+func TestListFolders_SnapshotAndCompare(t *testing.T) {
+	config, user := newTestIMAPServer(t, testMessageAlpha, testMessageBeta)
+	config.Action = ActionListFolders
+	if err := user.Create("Archive", nil); err != nil {
+		t.Fatalf("create Archive: %v", err)
+	}
+	config.SnapshotFile = filepath.Join(t.TempDir(), "folders.json")
+
+	csvLog := &recordingLogger{}
+	if err := listFolders(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("listFolders() error = %v", err)
+	}
+
+	rows := make(map[string][]string)
+	for _, row := range csvLog.rows {
+		rows[csvLog.column(row, "Folder_Name")] = row
+	}
+	inbox := rows["INBOX"]
+	if inbox == nil || csvLog.column(inbox, "Total_Messages") != "2" || csvLog.column(inbox, "UIDNext") != "3" {
+		t.Errorf("INBOX row = %v, want 2 messages and UIDNEXT 3", inbox)
+	}
+	if csvLog.column(inbox, "Size_Bytes") == "" {
+		t.Error("INBOX row has no Size_Bytes from an IMAP4rev2 server")
+	}
+	if total := rows[totalFolderName]; total == nil || csvLog.column(total, "Total_Messages") != "2" {
+		t.Errorf("total row = %v, want 2 messages", total)
+	}
+
+	snapshot, err := loadFolderSnapshot(config.SnapshotFile)
+	if err != nil {
+		t.Fatalf("loadFolderSnapshot() error = %v", err)
+	}
+	if snapshot.Totals.Folders != 2 || snapshot.Totals.Messages != 2 {
+		t.Errorf("snapshot totals = %+v, want 2 folders and 2 messages", snapshot.Totals)
+	}
+
+	// One more message in Archive since the snapshot
+	msg := strings.ReplaceAll(testMessageAlpha, "\n", "\r\n")
+	if _, err := user.Append("Archive", bytes.NewReader([]byte(msg)), &imap.AppendOptions{}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	config.CompareWith = config.SnapshotFile
+	config.SnapshotFile = ""
+
+	csvLog = &recordingLogger{}
+	if err := listFolders(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("listFolders() with --compare-with error = %v", err)
+	}
+	for _, row := range csvLog.rows {
+		name := csvLog.column(row, "Folder_Name")
+		compare, delta := csvLog.column(row, "Compare"), csvLog.column(row, "Messages_Delta")
+		switch name {
+		case "INBOX":
+			if compare != compareMatch || delta != "0" {
+				t.Errorf("INBOX Compare = %q, Messages_Delta = %q, want MATCH, 0", compare, delta)
+			}
+		case "Archive", totalFolderName:
+			if compare != compareChanged || delta != "+1" {
+				t.Errorf("%s Compare = %q, Messages_Delta = %q, want CHANGED, +1", name, compare, delta)
+			}
+		}
+	}
+}
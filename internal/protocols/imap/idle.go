@@ -0,0 +1,505 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// idleEvent is an unsolicited server update received while monitoring.
+type idleEvent struct {
+	kind  string // EXISTS, EXPUNGE or FETCH
+	num   uint32 // message count for EXISTS, sequence number otherwise
+	flags []imap.Flag
+	at    time.Time
+}
+
+// errConnectionDropped reports that the server closed the connection while idling.
+var errConnectionDropped = errors.New("connection dropped")
+
+// idleMonitor holds the state of an idle run across reconnects.
+type idleMonitor struct {
+	config     *Config
+	csvLogger  logger.Logger
+	slogLogger *slog.Logger
+
+	events   chan idleEvent
+	deadline <-chan time.Time
+	exists   uint32 // message count of the selected mailbox
+
+	notifications int
+	reissues      int
+	reconnects    int
+	matched       bool
+
+	// --probe: a second session APPENDs the probe, since the IDLE session
+	// cannot send commands while idling
+	probe        *IMAPClient
+	probeSubject string
+	probeSentAt  time.Time
+	probeDone    chan error // outcome of the APPEND
+	probePending bool
+	probeErr     error
+}
+
+// monitorIdle keeps an IDLE session open on a mailbox and prints
+// EXISTS/EXPUNGE/FETCH notifications as they arrive. IDLE is re-issued every
+// --refresh minutes, ahead of the 29-minute inactivity timeout, and the
+// session is re-established when the connection drops (up to --maxretries
+// consecutive failures). With --probe it APPENDs a probe message once IDLE
+// is up and reports the time from the APPEND to the notification; with
+// --expect-subject it stops at the first new message whose subject matches.
+func monitorIdle(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Monitoring %s on %s:%d with IDLE...\n", config.Mailbox, config.Host, config.Port)
+
+	// CSV columns for idle
+	columns := []string{"Action", "Status", "Server", "Port", "Mailbox", "Event", "Detail", "Subject", "Probe_To_Push_Ms", "Date_To_Push_Ms", "Delivery_To_Push_Ms", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	m := &idleMonitor{
+		config:     config,
+		csvLogger:  csvLogger,
+		slogLogger: slogLogger,
+		events:     make(chan idleEvent, 256),
+	}
+	if config.IdleProbe {
+		if err := m.openProbe(ctx); err != nil {
+			m.writeRow("FAILURE", "ERROR", "", "", "", "", "", err)
+			return err
+		}
+		defer func() { _ = m.probe.Logout() }()
+	}
+	if config.IdleDuration > 0 {
+		timer := time.NewTimer(config.IdleDuration)
+		defer timer.Stop()
+		m.deadline = timer.C
+	}
+
+	failures := 0
+	for {
+		established, err := m.runSession(ctx)
+		if err == nil {
+			break
+		}
+		if !established && m.reconnects == 0 && failures == 0 {
+			// The first session never came up: nothing to reconnect to
+			m.writeRow("FAILURE", "ERROR", "", "", "", "", "", err)
+			return err
+		}
+		if established {
+			failures = 0
+		}
+		failures++
+		if failures > config.MaxRetries {
+			err = fmt.Errorf("giving up after %d failed reconnect attempt(s): %w", failures-1, err)
+			logger.LogError(slogLogger, "IDLE monitor failed", "error", err)
+			m.writeRow("FAILURE", "ERROR", "", "", "", "", "", err)
+			return err
+		}
+
+		m.reconnects++
+		logger.LogWarn(slogLogger, "IDLE session lost, reconnecting", "error", err, "attempt", failures)
+		fmt.Printf("⚠ %v — reconnecting in %s (attempt %d/%d)\n", err, config.RetryDelay, failures, config.MaxRetries)
+		m.writeRow("SUCCESS", "RECONNECT", err.Error(), "", "", "", "", nil)
+
+		select {
+		case <-ctx.Done():
+			return m.finish(ctx)
+		case <-m.deadline:
+			return m.finish(ctx)
+		case <-time.After(config.RetryDelay):
+		}
+	}
+
+	return m.finish(ctx)
+}
+
+// runSession connects, selects the mailbox and idles until the monitor is
+// done (nil error) or the session fails. established reports whether the
+// session got as far as IDLE.
+func (m *idleMonitor) runSession(ctx context.Context) (established bool, err error) {
+	config := m.config
+
+	client := NewIMAPClient(config)
+	client.SetUnilateralDataHandler(&imapclient.UnilateralDataHandler{
+		Mailbox: func(data *imapclient.UnilateralDataMailbox) {
+			if data.NumMessages != nil {
+				m.push(idleEvent{kind: "EXISTS", num: *data.NumMessages, at: time.Now()})
+			}
+		},
+		Expunge: func(seqNum uint32) {
+			m.push(idleEvent{kind: "EXPUNGE", num: seqNum, at: time.Now()})
+		},
+		Fetch: func(msg *imapclient.FetchMessageData) {
+			buf, err := msg.Collect()
+			if err != nil {
+				return
+			}
+			m.push(idleEvent{kind: "FETCH", num: buf.SeqNum, flags: buf.Flags, at: time.Now()})
+		},
+	})
+
+	if err := startSession(ctx, client, config, m.slogLogger); err != nil {
+		return false, err
+	}
+	defer func() { _ = client.Logout() }()
+
+	if caps := client.RefreshCapabilities(); caps == nil || !(caps.SupportsIDLE() || caps.SupportsIMAP4rev2()) {
+		return false, fmt.Errorf("server does not advertise IDLE")
+	}
+
+	selectData, err := client.SelectMailbox(ctx, config.Mailbox, true)
+	if err != nil {
+		return false, err
+	}
+	m.exists = selectData.NumMessages
+	m.drainEvents() // updates from before SELECT are not news
+	fmt.Printf("✓ Opened %s (%d messages)\n", config.Mailbox, m.exists)
+
+	for {
+		idleCmd, err := client.Idle()
+		if err != nil {
+			return established, err
+		}
+		if !established {
+			fmt.Printf("✓ IDLE started (re-issued every %s)", config.IdleRefresh)
+			if subject := m.expectSubject(); subject != "" {
+				fmt.Printf(", waiting for subject %q", subject)
+			}
+			fmt.Println()
+			if m.reconnects == 0 {
+				fmt.Println("  Press Ctrl+C to stop.")
+			}
+			if m.probe != nil && m.probeSentAt.IsZero() {
+				m.sendProbe(ctx)
+			}
+		}
+		established = true
+
+		idleDone := make(chan error, 1)
+		go func() { idleDone <- idleCmd.Wait() }()
+
+		refresh := time.NewTimer(config.IdleRefresh)
+		stop, fetchFrom, fetchTo := false, uint32(0), uint32(0)
+		var notifiedAt time.Time
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				stop = true
+				break wait
+			case <-m.deadline:
+				stop = true
+				break wait
+			case <-refresh.C:
+				m.reissues++
+				logger.LogDebug(m.slogLogger, "Re-issuing IDLE", "after", config.IdleRefresh)
+				fmt.Printf("  [%s] ↻ Re-issuing IDLE\n", time.Now().Format("15:04:05"))
+				break wait
+			case err := <-idleDone:
+				refresh.Stop()
+				if err == nil {
+					return established, errConnectionDropped
+				}
+				return established, fmt.Errorf("%w: %v", errConnectionDropped, err)
+			case err := <-m.probeDone:
+				m.probePending = false
+				if err != nil {
+					m.probeErr = fmt.Errorf("probe APPEND failed: %w", err)
+					stop = true
+					break wait
+				}
+				fmt.Printf("  [%s] Probe APPEND accepted after %d ms\n", time.Now().Format("15:04:05"), time.Since(m.probeSentAt).Milliseconds())
+			case ev := <-m.events:
+				if from, to := m.handleEvent(ev); to >= from && to > 0 {
+					fetchFrom, fetchTo, notifiedAt = from, to, ev.at
+					break wait
+				}
+			}
+		}
+		refresh.Stop()
+
+		if err := idleCmd.Close(); err != nil {
+			return established, fmt.Errorf("%w: %v", errConnectionDropped, err)
+		}
+		if err := <-idleDone; err != nil {
+			return established, fmt.Errorf("%w: %v", errConnectionDropped, err)
+		}
+		if stop {
+			return established, nil
+		}
+
+		if fetchTo > 0 {
+			if err := m.showNewMessages(ctx, client, fetchFrom, fetchTo, notifiedAt); err != nil {
+				return established, err
+			}
+			if m.matched {
+				return established, nil
+			}
+		}
+	}
+}
+
+// push queues an event without blocking the client's reader goroutine.
+func (m *idleMonitor) push(ev idleEvent) {
+	select {
+	case m.events <- ev:
+	default:
+		logger.LogWarn(m.slogLogger, "IDLE event queue full, dropping event", "event", ev.kind)
+	}
+}
+
+// drainEvents discards queued events.
+func (m *idleMonitor) drainEvents() {
+	for {
+		select {
+		case <-m.events:
+		default:
+			return
+		}
+	}
+}
+
+// handleEvent prints and logs one notification. For an EXISTS that adds
+// messages it returns the sequence range of the new messages.
+func (m *idleMonitor) handleEvent(ev idleEvent) (from, to uint32) {
+	m.notifications++
+	stamp := ev.at.Format("15:04:05")
+
+	var detail string
+	switch ev.kind {
+	case "EXISTS":
+		delta := int64(ev.num) - int64(m.exists)
+		detail = fmt.Sprintf("%d messages (%+d)", ev.num, delta)
+		if delta > 0 {
+			from, to = m.exists+1, ev.num
+		}
+		m.exists = ev.num
+	case "EXPUNGE":
+		detail = fmt.Sprintf("message %d removed", ev.num)
+		if m.exists > 0 {
+			m.exists--
+		}
+	case "FETCH":
+		detail = fmt.Sprintf("message %d flags: %s", ev.num, formatFlags(ev.flags))
+	}
+
+	fmt.Printf("  [%s] %-7s %s\n", stamp, ev.kind, detail)
+	logger.LogInfo(m.slogLogger, "IDLE notification", "event", ev.kind, "detail", detail)
+	m.writeRow("SUCCESS", ev.kind, detail, "", "", "", "", nil)
+	return from, to
+}
+
+// showNewMessages fetches the envelopes of newly arrived messages, announced
+// at notifiedAt, and checks them against the expected subject.
+func (m *idleMonitor) showNewMessages(ctx context.Context, client *IMAPClient, from, to uint32, notifiedAt time.Time) error {
+	messages, err := client.FetchSeqRange(ctx, from, to, &imap.FetchOptions{
+		UID:          true,
+		Envelope:     true,
+		InternalDate: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		env := msg.Envelope
+		if env == nil {
+			env = &imap.Envelope{}
+		}
+		fmt.Printf("           new: UID %d from %s: %s\n", msg.UID, formatAddresses(env.From), env.Subject)
+
+		expected := m.expectSubject()
+		if expected == "" || !strings.Contains(strings.ToLower(env.Subject), strings.ToLower(expected)) {
+			continue
+		}
+
+		var probeLatency string
+		if m.probe != nil {
+			probeLatency = latencyMs(m.probeSentAt, notifiedAt)
+		}
+		dateLatency := latencyMs(env.Date, notifiedAt)
+		deliveryLatency := latencyMs(msg.InternalDate, notifiedAt)
+		fmt.Printf("\n✓ Expected message arrived: %s\n", env.Subject)
+		if probeLatency != "" {
+			fmt.Printf("  Probe → push:    %s ms (from the APPEND)\n", probeLatency)
+		}
+		if dateLatency != "" {
+			fmt.Printf("  Date → push:     %s ms (from the Date header; 1 s resolution, sender's clock)\n", dateLatency)
+		}
+		if deliveryLatency != "" {
+			fmt.Printf("  Delivery → push: %s ms (from INTERNALDATE)\n", deliveryLatency)
+		}
+		logger.LogInfo(m.slogLogger, "Expected message arrived",
+			"subject", env.Subject,
+			"probe_to_push_ms", probeLatency,
+			"date_to_push_ms", dateLatency,
+			"delivery_to_push_ms", deliveryLatency)
+		m.writeRow("SUCCESS", "MATCH", fmt.Sprintf("UID %d", msg.UID), env.Subject, probeLatency, dateLatency, deliveryLatency, nil)
+		m.matched = true
+		if m.probe != nil {
+			m.deleteProbe(ctx, msg.UID)
+		}
+		return nil
+	}
+	return nil
+}
+
+// latencyMs returns the milliseconds from t to notifiedAt, or "" when t is
+// unknown. Header dates have one-second resolution and depend on the
+// sender's clock, so small negative values are reported as 0.
+func latencyMs(t, notifiedAt time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d", max(notifiedAt.Sub(t).Milliseconds(), 0))
+}
+
+// expectSubject returns the subject the monitor waits for: the probe's with
+// --probe, otherwise --expect-subject.
+func (m *idleMonitor) expectSubject() string {
+	if m.probe != nil {
+		return m.probeSubject
+	}
+	return m.config.ExpectSubject
+}
+
+// openProbe opens the session that APPENDs the --probe message and picks
+// the probe's unique subject.
+func (m *idleMonitor) openProbe(ctx context.Context) error {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	client, err := openSession(ctx, m.config, m.slogLogger)
+	if err != nil {
+		return fmt.Errorf("probe session: %w", err)
+	}
+	m.probe = client
+	m.probeSubject = "gomailtest IDLE probe " + hex.EncodeToString(random)
+	m.probeDone = make(chan error, 1)
+	return nil
+}
+
+// sendProbe records the send time and APPENDs the probe message in the
+// background; the outcome arrives on probeDone.
+func (m *idleMonitor) sendProbe(ctx context.Context) {
+	message := buildProbeMessage(m.config, m.probeSubject, time.Now())
+	m.probePending = true
+	m.probeSentAt = time.Now()
+	go func() {
+		_, err := m.probe.AppendMessage(ctx, m.config.Mailbox, message, nil)
+		m.probeDone <- err
+	}()
+}
+
+// deleteProbe removes the probe message with the probe session once it has
+// been seen. Without UIDPLUS it is only flagged \Deleted, since a plain
+// EXPUNGE would also remove other deleted messages.
+func (m *idleMonitor) deleteProbe(ctx context.Context, uid imap.UID) {
+	if m.probePending {
+		<-m.probeDone
+		m.probePending = false
+	}
+
+	err := func() error {
+		if _, err := m.probe.SelectMailbox(ctx, m.config.Mailbox, false); err != nil {
+			return err
+		}
+		if err := m.probe.AddFlags(ctx, []imap.UID{uid}, imap.FlagDeleted); err != nil {
+			return err
+		}
+		if caps := m.probe.RefreshCapabilities(); caps == nil || !(caps.SupportsUIDPLUS() || caps.SupportsIMAP4rev2()) {
+			fmt.Printf("⚠ Probe UID %d flagged \\Deleted but not expunged: UID EXPUNGE needs UIDPLUS\n", uid)
+			return nil
+		}
+		if err := m.probe.ExpungeUIDs(ctx, []imap.UID{uid}); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Probe UID %d deleted\n", uid)
+		return nil
+	}()
+	if err != nil {
+		logger.LogWarn(m.slogLogger, "Failed to delete the IDLE probe", "error", err, "uid", uid)
+		fmt.Printf("⚠ Probe UID %d not deleted: %v\n", uid, err)
+	}
+}
+
+// buildProbeMessage generates the --probe message. It carries the header
+// imap cleanup looks for, in case it is left behind.
+func buildProbeMessage(config *Config, subject string, now time.Time) []byte {
+	address := probeAddress(config)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: <%s>\r\n", address)
+	fmt.Fprintf(&buf, "To: <%s>\r\n", address)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "%s: idle\r\n", appendProbeHeader)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString("IDLE push latency probe from gomailtest imap idle; deleted once it is seen.\r\n")
+	return buf.Bytes()
+}
+
+// finish reports the outcome once monitoring ends.
+func (m *idleMonitor) finish(ctx context.Context) error {
+	summary := fmt.Sprintf("%d notification(s), %d IDLE re-issue(s), %d reconnect(s)", m.notifications, m.reissues, m.reconnects)
+
+	if m.probeErr != nil {
+		logger.LogError(m.slogLogger, "IDLE monitor failed", "error", m.probeErr)
+		m.writeRow("FAILURE", "ERROR", summary, m.probeSubject, "", "", "", m.probeErr)
+		return m.probeErr
+	}
+	if subject := m.expectSubject(); subject != "" && !m.matched {
+		reason := "monitoring stopped"
+		if ctx.Err() == nil {
+			reason = fmt.Sprintf("no message within %s", m.config.IdleDuration)
+		}
+		err := fmt.Errorf("expected subject %q not seen: %s", subject, reason)
+		logger.LogError(m.slogLogger, "IDLE monitor failed", "error", err)
+		m.writeRow("FAILURE", "TIMEOUT", summary, "", "", "", "", err)
+		return err
+	}
+
+	logger.LogInfo(m.slogLogger, "IDLE monitor completed",
+		"mailbox", m.config.Mailbox,
+		"notifications", m.notifications,
+		"reissues", m.reissues,
+		"reconnects", m.reconnects)
+	m.writeRow("SUCCESS", "SUMMARY", summary, "", "", "", "", nil)
+
+	fmt.Printf("\n✓ IDLE monitor completed: %s\n", summary)
+	return nil
+}
+
+// writeRow writes one CSV row for the idle action.
+func (m *idleMonitor) writeRow(status, event, detail, subject, probeLatency, dateLatency, deliveryLatency string, err error) {
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	if logErr := m.csvLogger.WriteRow([]string{
+		m.config.Action, status, m.config.Host, fmt.Sprintf("%d", m.config.Port),
+		m.config.Mailbox, event, detail, subject, probeLatency, dateLatency, deliveryLatency, errMsg,
+	}); logErr != nil {
+		logger.LogError(m.slogLogger, "Failed to write CSV row", "error", logErr)
+	}
+}
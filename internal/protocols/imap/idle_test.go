@@ -0,0 +1,183 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+func TestLatencyMs(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 5, 0, time.UTC)
+	if got := latencyMs(now.Add(-1500*time.Millisecond), now); got != "1500" {
+		t.Errorf("latencyMs() = %q, want 1500", got)
+	}
+	if got := latencyMs(now.Add(time.Second), now); got != "0" {
+		t.Errorf("latencyMs(future) = %q, want 0", got)
+	}
+	if got := latencyMs(time.Time{}, now); got != "" {
+		t.Errorf("latencyMs(zero) = %q, want empty", got)
+	}
+}
+
+func TestIdleMonitor_HandleEvent(t *testing.T) {
+	config := NewConfig()
+	config.Action = ActionIdle
+	m := &idleMonitor{config: config, csvLogger: &recordingLogger{}, exists: 5}
+
+	if from, to := m.handleEvent(idleEvent{kind: "EXISTS", num: 7}); from != 6 || to != 7 {
+		t.Errorf("EXISTS 7 after 5: range = %d:%d, want 6:7", from, to)
+	}
+	if _, to := m.handleEvent(idleEvent{kind: "EXPUNGE", num: 2}); to != 0 {
+		t.Error("EXPUNGE should not return a fetch range")
+	}
+	if m.exists != 6 {
+		t.Errorf("exists after EXPUNGE = %d, want 6", m.exists)
+	}
+	if _, to := m.handleEvent(idleEvent{kind: "EXISTS", num: 6}); to != 0 {
+		t.Error("EXISTS without new messages should not return a fetch range")
+	}
+	if m.notifications != 3 {
+		t.Errorf("notifications = %d, want 3", m.notifications)
+	}
+}
+
+func TestMonitorIdle_ExpectSubject(t *testing.T) {
+	config, user := newTestIMAPServer(t, testMessageAlpha)
+	config.Action = ActionIdle
+	config.ExpectSubject = "latency probe"
+	config.IdleDuration = 10 * time.Second
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		msg := strings.ReplaceAll("From: <a@example.com>\nSubject: IDLE latency probe\nDate: "+
+			time.Now().Format(time.RFC1123Z)+"\n\nbody\n", "\n", "\r\n")
+		_, _ = user.Append("INBOX", bytes.NewReader([]byte(msg)), &imap.AppendOptions{})
+	}()
+
+	csvLog := &recordingLogger{}
+	start := time.Now()
+	if err := monitorIdle(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("monitorIdle() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("monitorIdle() took %s, want it to stop at the matching message", elapsed)
+	}
+
+	var match []string
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Event") == "MATCH" {
+			match = row
+		}
+	}
+	if match == nil {
+		t.Fatalf("no MATCH row in %v", csvLog.rows)
+	}
+	if got := csvLog.column(match, "Subject"); got != "IDLE latency probe" {
+		t.Errorf("Subject = %q", got)
+	}
+	if csvLog.column(match, "Delivery_To_Push_Ms") == "" {
+		t.Error("Delivery_To_Push_Ms is empty")
+	}
+}
+
+func TestMonitorIdle_Probe(t *testing.T) {
+	config, user := newTestIMAPServer(t, testMessageAlpha)
+	config.Action = ActionIdle
+	config.IdleProbe = true
+	config.IdleDuration = 10 * time.Second
+
+	csvLog := &recordingLogger{}
+	if err := monitorIdle(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("monitorIdle() error = %v", err)
+	}
+
+	var match []string
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Event") == "MATCH" {
+			match = row
+		}
+	}
+	if match == nil {
+		t.Fatalf("no MATCH row in %v", csvLog.rows)
+	}
+	if got := csvLog.column(match, "Subject"); !strings.HasPrefix(got, "gomailtest IDLE probe ") {
+		t.Errorf("Subject = %q, want the probe's", got)
+	}
+	if csvLog.column(match, "Probe_To_Push_Ms") == "" {
+		t.Error("Probe_To_Push_Ms is empty")
+	}
+	if got := mailboxCount(t, user, "INBOX"); got != 1 {
+		t.Errorf("INBOX has %d messages after the run, want the probe deleted", got)
+	}
+}
+
+func TestMonitorIdle_RefreshAndTimeout(t *testing.T) {
+	config, _ := newTestIMAPServer(t)
+	config.Action = ActionIdle
+	config.IdleRefresh = 100 * time.Millisecond
+	config.IdleDuration = 450 * time.Millisecond
+	config.ExpectSubject = "never arrives"
+
+	csvLog := &recordingLogger{}
+	err := monitorIdle(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "not seen") {
+		t.Fatalf("monitorIdle() error = %v, want expected subject not seen", err)
+	}
+
+	last := csvLog.rows[len(csvLog.rows)-1]
+	if got := csvLog.column(last, "Event"); got != "TIMEOUT" {
+		t.Errorf("last Event = %q, want TIMEOUT", got)
+	}
+	if detail := csvLog.column(last, "Detail"); strings.HasPrefix(detail, "0 notification(s), 0 IDLE re-issue(s)") {
+		t.Errorf("IDLE was never re-issued: %s", detail)
+	}
+}
+
+func TestMonitorIdle_ReconnectsAfterDrop(t *testing.T) {
+	user := imapmemserver.NewUser("tester", "secret")
+	if err := user.Create("INBOX", nil); err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan *imapserver.Conn, 4)
+	config := startTestIMAPServer(t, user, func(conn *imapserver.Conn) { conns <- conn })
+	config.Action = ActionIdle
+	config.IdleDuration = 3 * time.Second
+	config.RetryDelay = 50 * time.Millisecond
+	config.MaxRetries = 2
+
+	go func() {
+		// Drop the first session once it is idling
+		first := <-conns
+		time.Sleep(300 * time.Millisecond)
+		_ = first.NetConn().Close()
+	}()
+
+	csvLog := &recordingLogger{}
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	if err := monitorIdle(ctx, config, csvLog, nil); err != nil {
+		t.Fatalf("monitorIdle() error = %v", err)
+	}
+
+	reconnects := 0
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Event") == "RECONNECT" {
+			reconnects++
+		}
+	}
+	if reconnects != 1 {
+		t.Errorf("got %d RECONNECT rows, want 1: %v", reconnects, csvLog.rows)
+	}
+	if len(conns) != 1 {
+		t.Errorf("server saw %d further connection(s), want 1", len(conns))
+	}
+}
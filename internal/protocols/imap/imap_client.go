@@ -5,11 +5,11 @@ import (
 	"crypto/tls"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
-	"github.com/emersion/go-sasl"
 
 	"github.com/ziembor/gomailtesttool/internal/common/network"
 	"github.com/ziembor/gomailtesttool/internal/common/ratelimit"
@@ -25,14 +25,21 @@ type IMAPClient struct {
 	caps     *imapprotocol.Capabilities
 	limiter  *ratelimit.Limiter
 	tlsState *tls.ConnectionState
+
+	unilateral *imapclient.UnilateralDataHandler // optional handler for untagged server updates
 }
 
 // MailboxInfo holds information about a mailbox.
 type MailboxInfo struct {
-	Name       string
-	Attributes []string
-	Messages   uint32
-	Unseen     uint32
+	Name        string
+	Delimiter   string // Hierarchy delimiter ("" for a flat hierarchy)
+	Attributes  []string
+	Messages    uint32
+	Unseen      uint32
+	UIDNext     uint32
+	UIDValidity uint32
+	Size        *int64 // Total size in bytes; nil unless the server supports STATUS=SIZE
+	StatusError string // Why STATUS failed ("" on success)
 }
 
 // NewIMAPClient creates a new IMAP client.
@@ -58,30 +65,23 @@ func (c *IMAPClient) Connect(ctx context.Context) error {
 		}
 	}
 
-	// Determine connection address (override or default to host)
-	connectHost := c.host
-	if c.config.ConnectAddress != "" {
-		connectHost = c.config.ConnectAddress
-	}
-
-	// Resolve to a specific address family if --ipv4/--ipv6 was requested
-	connectHost, err := network.ResolveForDial(ctx, connectHost, c.config.IPv4Only, c.config.IPv6Only)
+	address, err := dialAddress(ctx, c.config)
 	if err != nil {
 		return err
 	}
-	address := net.JoinHostPort(connectHost, fmt.Sprintf("%d", c.port))
 
 	options := &imapclient.Options{
-		TLSConfig: &tls.Config{
-			ServerName:         c.host,
-			InsecureSkipVerify: c.config.SkipVerify,
-			MinVersion:         parseTLSVersion(c.config.TLSVersion),
-		},
+		TLSConfig:             newTLSConfig(c.config),
+		UnilateralDataHandler: c.unilateral,
 	}
 
 	var client *imapclient.Client
 
-	if c.config.IMAPS {
+	if c.config.ProxyProtocol != "" {
+		// PROXY protocol: dial ourselves so the header can be written
+		// before the greeting and any TLS handshake
+		client, err = c.dialWithProxyHeader(ctx, address, options)
+	} else if c.config.IMAPS {
 		// Implicit TLS (IMAPS)
 		client, err = imapclient.DialTLS(address, options)
 		if err == nil {
@@ -112,6 +112,75 @@ func (c *IMAPClient) Connect(ctx context.Context) error {
 	return nil
 }
 
+// dialAddress returns the host:port to dial: --address, or --host when it
+// is not set, resolved to an address family if --ipv4/--ipv6 was requested.
+func dialAddress(ctx context.Context, config *Config) (string, error) {
+	connectHost := config.Host
+	if config.ConnectAddress != "" {
+		connectHost = config.ConnectAddress
+	}
+
+	connectHost, err := network.ResolveForDial(ctx, connectHost, config.IPv4Only, config.IPv6Only)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(connectHost, fmt.Sprintf("%d", config.Port)), nil
+}
+
+// newTLSConfig returns the TLS settings for a connection to --host.
+func newTLSConfig(config *Config) *tls.Config {
+	return &tls.Config{
+		ServerName:         config.Host,
+		InsecureSkipVerify: config.SkipVerify,
+		MinVersion:         parseTLSVersion(config.TLSVersion),
+	}
+}
+
+// dialWithProxyHeader dials address, sends a HAProxy PROXY header and then
+// sets up the IMAP client in the configured TLS mode (implicit TLS, STARTTLS
+// or plain), mirroring imapclient's Dial* helpers.
+func (c *IMAPClient) dialWithProxyHeader(ctx context.Context, address string, options *imapclient.Options) (*imapclient.Client, error) {
+	dialer := &net.Dialer{Timeout: c.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := network.WriteProxyHeader(conn, c.config.ProxyProtocol, c.config.ProxySource); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	switch {
+	case c.config.IMAPS:
+		tlsConfig := options.TLSConfig.Clone()
+		tlsConfig.NextProtos = []string{"imap"}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.tlsState = &tls.ConnectionState{} // Mark as TLS connection
+		return imapclient.New(tlsConn, options), nil
+	case c.config.StartTLS:
+		client, err := imapclient.NewStartTLS(conn, options)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.tlsState = &tls.ConnectionState{} // Mark as TLS connection
+		return client, nil
+	default:
+		return imapclient.New(conn, options), nil
+	}
+}
+
+// SetUnilateralDataHandler registers a handler for unsolicited EXISTS,
+// EXPUNGE and FETCH responses (e.g. during IDLE). It must be called before Connect.
+func (c *IMAPClient) SetUnilateralDataHandler(handler *imapclient.UnilateralDataHandler) {
+	c.unilateral = handler
+}
+
 // GetGreeting returns the server greeting (capabilities from greeting).
 func (c *IMAPClient) GetGreeting() string {
 	if c.caps != nil {
@@ -138,6 +207,27 @@ func (c *IMAPClient) StartTLS(tlsConfig *tls.Config) error {
 	return fmt.Errorf("STARTTLS must be enabled before Connect() by setting StartTLS=true in config")
 }
 
+// SelectAuthMethod returns the mechanism Auth will use: the configured
+// --authmethod, or for "auto" the best one advertised by the server.
+func (c *IMAPClient) SelectAuthMethod(hasAccessToken bool) string {
+	return selectAuthMethod(c.config, c.caps, hasAccessToken)
+}
+
+// selectAuthMethod returns the configured --authmethod, or for "auto" the
+// best mechanism in caps.
+func selectAuthMethod(config *Config, caps *imapprotocol.Capabilities, hasAccessToken bool) string {
+	method := strings.ToUpper(config.AuthMethod)
+	if method != "AUTO" {
+		return method
+	}
+	if caps != nil {
+		if best := caps.SelectBestAuthMechanism(hasAccessToken); best != "" {
+			return best
+		}
+	}
+	return "LOGIN" // Fallback
+}
+
 // Auth authenticates with the server using the specified method.
 func (c *IMAPClient) Auth(ctx context.Context, username, password, accessToken string) error {
 	if c.limiter != nil {
@@ -146,38 +236,21 @@ func (c *IMAPClient) Auth(ctx context.Context, username, password, accessToken s
 		}
 	}
 
-	method := c.config.AuthMethod
-
-	// Auto-select auth method
-	if strings.EqualFold(method, "auto") {
-		if accessToken != "" && c.caps != nil && c.caps.SupportsXOAUTH2() {
-			method = "XOAUTH2"
-		} else if c.caps != nil && c.caps.SupportsPlain() {
-			method = "PLAIN"
-		} else if c.caps != nil && c.caps.SupportsLogin() {
-			method = "LOGIN"
-		} else {
-			method = "LOGIN" // Fallback
-		}
-	}
-
-	switch strings.ToUpper(method) {
-	case "XOAUTH2":
-		return c.authXOAUTH2(username, accessToken)
-	case "PLAIN":
-		return c.authPlain(username, password)
-	case "LOGIN":
+	method := c.SelectAuthMethod(accessToken != "")
+	if method == "LOGIN" {
 		return c.authLogin(username, password)
-	default:
-		return fmt.Errorf("unsupported auth method: %s", method)
 	}
-}
 
-// authPlain performs PLAIN authentication.
-func (c *IMAPClient) authPlain(username, password string) error {
-	saslClient := sasl.NewPlainClient("", username, password)
+	saslClient, done, err := newSASLClient(c.config, method, username, password, accessToken)
+	if err != nil {
+		return err
+	}
+	defer done()
 	if err := c.client.Authenticate(saslClient); err != nil {
-		return fmt.Errorf("PLAIN authentication failed: %w", err)
+		if bearer, ok := saslClient.(*oauthbearerClient); ok && bearer.serverError != nil {
+			return fmt.Errorf("OAUTHBEARER authentication failed (status %s): %w", bearer.serverError.Status, err)
+		}
+		return fmt.Errorf("%s authentication failed: %w", method, err)
 	}
 	return nil
 }
@@ -190,19 +263,9 @@ func (c *IMAPClient) authLogin(username, password string) error {
 	return nil
 }
 
-// authXOAUTH2 performs XOAUTH2 authentication.
-func (c *IMAPClient) authXOAUTH2(username, accessToken string) error {
-	saslClient := sasl.NewOAuthBearerClient(&sasl.OAuthBearerOptions{
-		Username: username,
-		Token:    accessToken,
-	})
-	if err := c.client.Authenticate(saslClient); err != nil {
-		return fmt.Errorf("XOAUTH2 authentication failed: %w", err)
-	}
-	return nil
-}
-
-// ListMailboxes lists all mailboxes.
+// ListMailboxes lists all mailboxes and runs STATUS on each selectable one
+// for its message, unseen, UIDNEXT, UIDVALIDITY and (with STATUS=SIZE or
+// IMAP4rev2) size counters.
 func (c *IMAPClient) ListMailboxes(ctx context.Context) ([]MailboxInfo, error) {
 	if c.limiter != nil {
 		if err := c.limiter.Wait(ctx); err != nil {
@@ -217,26 +280,51 @@ func (c *IMAPClient) ListMailboxes(ctx context.Context) ([]MailboxInfo, error) {
 		return nil, fmt.Errorf("LIST failed: %w", err)
 	}
 
+	caps := c.RefreshCapabilities()
+	options := &imap.StatusOptions{
+		NumMessages: true,
+		NumUnseen:   true,
+		UIDNext:     true,
+		UIDValidity: true,
+		Size:        caps.SupportsSTATUSSIZE() || caps.SupportsIMAP4rev2(),
+	}
+
 	var result []MailboxInfo
 	for _, mb := range mailboxes {
 		info := MailboxInfo{
 			Name:       mb.Mailbox,
 			Attributes: convertMailboxAttrs(mb.Attrs),
 		}
+		if mb.Delim != 0 {
+			info.Delimiter = string(mb.Delim)
+		}
 
-		// Try to get STATUS for message counts (optional)
-		// Some servers may not allow STATUS on all mailboxes
-		statusCmd := c.client.Status(mb.Mailbox, &imap.StatusOptions{
-			NumMessages: true,
-			NumUnseen:   true,
-		})
-		if status, err := statusCmd.Wait(); err == nil {
+		if !isSelectable(info.Attributes) {
+			result = append(result, info)
+			continue
+		}
+
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return nil, fmt.Errorf("rate limit wait: %w", err)
+			}
+		}
+
+		// Some servers refuse STATUS on individual mailboxes; record why
+		// and keep going.
+		status, err := c.client.Status(mb.Mailbox, options).Wait()
+		if err != nil {
+			info.StatusError = err.Error()
+		} else {
 			if status.NumMessages != nil {
 				info.Messages = *status.NumMessages
 			}
 			if status.NumUnseen != nil {
 				info.Unseen = *status.NumUnseen
 			}
+			info.UIDNext = uint32(status.UIDNext)
+			info.UIDValidity = status.UIDValidity
+			info.Size = status.Size
 		}
 
 		result = append(result, info)
@@ -245,6 +333,285 @@ func (c *IMAPClient) ListMailboxes(ctx context.Context) ([]MailboxInfo, error) {
 	return result, nil
 }
 
+// ListMailboxNames lists the mailboxes matching pattern without the
+// per-mailbox STATUS round trip done by ListMailboxes.
+func (c *IMAPClient) ListMailboxNames(ctx context.Context, pattern string) ([]MailboxInfo, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	mailboxes, err := c.client.List("", pattern, nil).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("LIST failed: %w", err)
+	}
+
+	result := make([]MailboxInfo, 0, len(mailboxes))
+	for _, mb := range mailboxes {
+		info := MailboxInfo{
+			Name:       mb.Mailbox,
+			Attributes: convertMailboxAttrs(mb.Attrs),
+		}
+		if mb.Delim != 0 {
+			info.Delimiter = string(mb.Delim)
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// CreateMailbox creates a mailbox with CREATE.
+func (c *IMAPClient) CreateMailbox(ctx context.Context, mailbox string) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if err := c.client.Create(mailbox, nil).Wait(); err != nil {
+		return fmt.Errorf("CREATE %s failed: %w", mailbox, err)
+	}
+	return nil
+}
+
+// Namespace runs NAMESPACE (RFC 2342) and returns the personal,
+// other users' and shared namespaces.
+func (c *IMAPClient) Namespace(ctx context.Context) (*imap.NamespaceData, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.Namespace().Wait()
+	if err != nil {
+		return nil, fmt.Errorf("NAMESPACE failed: %w", err)
+	}
+	return data, nil
+}
+
+// GetQuotaRoot runs GETQUOTAROOT (RFC 9208) and returns the usage and
+// limits of every quota root the mailbox belongs to.
+func (c *IMAPClient) GetQuotaRoot(ctx context.Context, mailbox string) ([]imapclient.QuotaData, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.GetQuotaRoot(mailbox).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("GETQUOTAROOT %s failed: %w", mailbox, err)
+	}
+	return data, nil
+}
+
+// MyRights runs MYRIGHTS (RFC 4314) and returns the rights the
+// authenticated user has on the mailbox.
+func (c *IMAPClient) MyRights(ctx context.Context, mailbox string) (imap.RightSet, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.MyRights(mailbox).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("MYRIGHTS %s failed: %w", mailbox, err)
+	}
+	return data.Rights, nil
+}
+
+// GetACL runs GETACL (RFC 4314) and returns the full access control list
+// of the mailbox. Servers only allow this with the "a" (administer) right.
+func (c *IMAPClient) GetACL(ctx context.Context, mailbox string) (map[imap.RightsIdentifier]imap.RightSet, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.GetACL(mailbox).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("GETACL %s failed: %w", mailbox, err)
+	}
+	return data.Rights, nil
+}
+
+// SelectMailbox opens a mailbox with SELECT, or EXAMINE when readOnly is set.
+func (c *IMAPClient) SelectMailbox(ctx context.Context, mailbox string, readOnly bool) (*imap.SelectData, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.Select(mailbox, &imap.SelectOptions{ReadOnly: readOnly}).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("SELECT %s failed: %w", mailbox, err)
+	}
+	return data, nil
+}
+
+// SearchUIDs runs UID SEARCH in the selected mailbox and returns the
+// matching UIDs in ascending order.
+func (c *IMAPClient) SearchUIDs(ctx context.Context, criteria *imap.SearchCriteria) ([]imap.UID, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	data, err := c.client.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		return nil, fmt.Errorf("UID SEARCH failed: %w", err)
+	}
+	uids := data.AllUIDs()
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+// FetchUIDs runs UID FETCH for the given UIDs in the selected mailbox.
+func (c *IMAPClient) FetchUIDs(ctx context.Context, uids []imap.UID, options *imap.FetchOptions) ([]*imapclient.FetchMessageBuffer, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	messages, err := c.client.Fetch(imap.UIDSetNum(uids...), options).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("UID FETCH failed: %w", err)
+	}
+	return messages, nil
+}
+
+// FetchSeqRange runs FETCH for the messages with sequence numbers from..to
+// in the selected mailbox.
+func (c *IMAPClient) FetchSeqRange(ctx context.Context, from, to uint32, options *imap.FetchOptions) ([]*imapclient.FetchMessageBuffer, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	var seqSet imap.SeqSet
+	seqSet.AddRange(from, to)
+	messages, err := c.client.Fetch(seqSet, options).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("FETCH failed: %w", err)
+	}
+	return messages, nil
+}
+
+// Idle starts an IDLE command. The caller must Close it before sending
+// any other command.
+func (c *IMAPClient) Idle() (*imapclient.IdleCommand, error) {
+	cmd, err := c.client.Idle()
+	if err != nil {
+		return nil, fmt.Errorf("IDLE failed: %w", err)
+	}
+	return cmd, nil
+}
+
+// RefreshCapabilities re-reads the server capabilities. Servers often
+// advertise more extensions after authentication than in the greeting.
+func (c *IMAPClient) RefreshCapabilities() *imapprotocol.Capabilities {
+	if caps := c.client.Caps(); caps != nil {
+		c.caps = convertCaps(caps)
+	}
+	return c.caps
+}
+
+// AppendMessage uploads a message to mailbox with APPEND. With UIDPLUS
+// (or IMAP4rev2) the returned data holds the APPENDUID UID and UIDVALIDITY.
+func (c *IMAPClient) AppendMessage(ctx context.Context, mailbox string, message []byte, options *imap.AppendOptions) (*imap.AppendData, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	cmd := c.client.Append(mailbox, int64(len(message)), options)
+	if _, err := cmd.Write(message); err != nil {
+		_ = cmd.Close()
+		return nil, fmt.Errorf("APPEND write failed: %w", err)
+	}
+	if err := cmd.Close(); err != nil {
+		return nil, fmt.Errorf("APPEND failed: %w", err)
+	}
+	data, err := cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("APPEND failed: %w", err)
+	}
+	return data, nil
+}
+
+// AddFlags adds flags to the given UIDs in the selected mailbox (UID STORE +FLAGS.SILENT).
+func (c *IMAPClient) AddFlags(ctx context.Context, uids []imap.UID, flags ...imap.Flag) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	store := &imap.StoreFlags{Op: imap.StoreFlagsAdd, Silent: true, Flags: flags}
+	if err := c.client.Store(imap.UIDSetNum(uids...), store, nil).Close(); err != nil {
+		return fmt.Errorf("UID STORE failed: %w", err)
+	}
+	return nil
+}
+
+// ExpungeUIDs permanently removes the given \Deleted messages with
+// UID EXPUNGE (requires UIDPLUS or IMAP4rev2), leaving other \Deleted
+// messages in the mailbox untouched.
+func (c *IMAPClient) ExpungeUIDs(ctx context.Context, uids []imap.UID) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if err := c.client.UIDExpunge(imap.UIDSetNum(uids...)).Close(); err != nil {
+		return fmt.Errorf("UID EXPUNGE failed: %w", err)
+	}
+	return nil
+}
+
+// Expunge permanently removes every \Deleted message in the selected
+// mailbox. Prefer ExpungeUIDs, which leaves other \Deleted messages alone.
+func (c *IMAPClient) Expunge(ctx context.Context) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if err := c.client.Expunge().Close(); err != nil {
+		return fmt.Errorf("EXPUNGE failed: %w", err)
+	}
+	return nil
+}
+
+// MoveUIDs moves the given UIDs from the selected mailbox to mailbox with
+// UID MOVE (requires MOVE or IMAP4rev2).
+func (c *IMAPClient) MoveUIDs(ctx context.Context, uids []imap.UID, mailbox string) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if _, err := c.client.Move(imap.UIDSetNum(uids...), mailbox).Wait(); err != nil {
+		return fmt.Errorf("UID MOVE failed: %w", err)
+	}
+	return nil
+}
+
 // Logout sends the LOGOUT command and closes the connection.
 func (c *IMAPClient) Logout() error {
 	if c.client != nil {
@@ -5,22 +5,44 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
 
-// listFolders lists all mailbox folders.
+// totalFolderName is the Folder_Name of the CSV row holding the totals.
+const totalFolderName = "(total)"
+
+// listFolders lists all mailbox folders with their STATUS counters and
+// totals, optionally saving them as a snapshot or comparing them with one.
 func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
 	fmt.Printf("Listing folders on %s:%d...\n", config.Host, config.Port)
 
 	// CSV columns for listfolders
-	columns := []string{"Action", "Status", "Server", "Port", "Folder_Name", "Attributes", "Total_Messages", "Unseen", "Error"}
+	columns := []string{"Action", "Status", "Server", "Port", "Folder_Name", "Attributes", "Total_Messages", "Unseen",
+		"UIDNext", "UIDValidity", "Size_Bytes", "Compare", "Messages_Delta", "Unseen_Delta", "Size_Delta", "Error"}
 	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
 		if err := csvLogger.WriteHeader(columns); err != nil {
 			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 		}
 	}
 
+	// Read the snapshot before connecting so a bad path fails fast
+	var previous *folderSnapshot
+	if config.CompareWith != "" {
+		var err error
+		if previous, err = loadFolderSnapshot(config.CompareWith); err != nil {
+			logger.LogError(slogLogger, "Failed to load snapshot", "error", err, "file", config.CompareWith)
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				"", "", "", "", "", "", "", "", "", "", "", err.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+	}
+
 	client := NewIMAPClient(config)
 
 	// Connect to server
@@ -32,7 +54,7 @@ func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, s
 
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"", "", "", "", err.Error(),
+			"", "", "", "", "", "", "", "", "", "", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -42,34 +64,16 @@ func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, s
 
 	fmt.Printf("✓ Connected to %s:%d\n", config.Host, config.Port)
 
-	// Get capabilities
-	caps := client.GetCapabilities()
-
 	// Determine auth method
-	authMethod := config.AuthMethod
-	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				authMethod = "PLAIN"
-			}
-		} else if caps != nil && caps.SupportsPlain() {
-			authMethod = "PLAIN"
-		} else {
-			authMethod = "LOGIN"
-		}
+	authMethod := client.SelectAuthMethod(config.AccessToken != "")
+	if config.AccessToken != "" && authMethod != "XOAUTH2" && authMethod != "OAUTHBEARER" {
+		logger.LogWarn(slogLogger, "Access token provided but server supports neither XOAUTH2 nor OAUTHBEARER", "method", authMethod)
 	}
 
 	fmt.Printf("Authenticating with method: %s\n", authMethod)
 
 	// Authenticate
-	var authErr error
-	if config.AccessToken != "" && strings.EqualFold(authMethod, "XOAUTH2") {
-		authErr = client.Auth(ctx, config.Username, "", config.AccessToken)
-	} else {
-		authErr = client.Auth(ctx, config.Username, config.Password, "")
-	}
+	authErr := client.Auth(ctx, config.Username, config.Password, config.AccessToken)
 
 	if authErr != nil {
 		logger.LogError(slogLogger, "Authentication failed",
@@ -78,7 +82,7 @@ func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, s
 
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"", "", "", "", fmt.Sprintf("Auth failed: %v", authErr),
+			"", "", "", "", "", "", "", "", "", "", "", fmt.Sprintf("Auth failed: %v", authErr),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -94,34 +98,164 @@ func listFolders(ctx context.Context, config *Config, csvLogger logger.Logger, s
 
 		if logErr := csvLogger.WriteRow([]string{
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			"", "", "", "", fmt.Sprintf("LIST failed: %v", err),
+			"", "", "", "", "", "", "", "", "", "", "", fmt.Sprintf("LIST failed: %v", err),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
 		return fmt.Errorf("LIST failed: %w", err)
 	}
 
+	folders := newFolderStats(mailboxes)
+	totals := sumFolders(folders)
+
+	var deltas map[string]folderDelta
+	var comparison []folderDelta
+	if previous != nil {
+		comparison = compareFolders(previous.Folders, folders, strings.EqualFold(previous.Server, config.Host) && previous.Port == config.Port)
+		deltas = make(map[string]folderDelta, len(comparison))
+		for _, d := range comparison {
+			if d.State != compareMissing {
+				deltas[d.Name] = d
+			}
+		}
+	}
+
 	fmt.Printf("\nFound %d mailboxes:\n", len(mailboxes))
-	fmt.Println("  Name                              Messages  Unseen  Attributes")
-	fmt.Println("  ----                              --------  ------  ----------")
+	fmt.Println("  Name                              Messages  Unseen   UIDNEXT  UIDVALIDITY        Size  Attributes")
+	fmt.Println("  ----                              --------  ------   -------  -----------        ----  ----------")
+
+	for _, f := range folders {
+		attrs := strings.Join(f.Attributes, ", ")
+		status := "SUCCESS"
+		var messages, unseen, uidNext, uidValidity, size string
+		switch {
+		case !f.Selectable:
+			fmt.Printf("  %-34s %8s  %6s  %8s  %11s  %10s  %s\n", f.Name, "-", "-", "-", "-", "-", attrs)
+		case f.Error != "":
+			status = "FAILURE"
+			fmt.Printf("  %-34s %8s  %6s  %8s  %11s  %10s  %s\n", f.Name, "-", "-", "-", "-", "-", attrs)
+			fmt.Printf("    ⚠ STATUS failed: %s\n", f.Error)
+		default:
+			messages = fmt.Sprintf("%d", f.Messages)
+			unseen = fmt.Sprintf("%d", f.Unseen)
+			uidNext = fmt.Sprintf("%d", f.UIDNext)
+			uidValidity = fmt.Sprintf("%d", f.UIDValidity)
+			sizeText := "-"
+			if f.Size != nil {
+				size = fmt.Sprintf("%d", *f.Size)
+				sizeText = formatBytes(*f.Size)
+			}
+			fmt.Printf("  %-34s %8d  %6d  %8d  %11d  %10s  %s\n", f.Name, f.Messages, f.Unseen, f.UIDNext, f.UIDValidity, sizeText, attrs)
+		}
 
-	for _, mb := range mailboxes {
-		attrs := strings.Join(mb.Attributes, ", ")
-		fmt.Printf("  %-34s %8d  %6d  %s\n", mb.Name, mb.Messages, mb.Unseen, attrs)
+		compare, messagesDelta, unseenDelta, sizeDelta := deltaColumns(deltas, f.Name)
 
 		// Log each mailbox to CSV
 		if logErr := csvLogger.WriteRow([]string{
-			config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
-			mb.Name, attrs, fmt.Sprintf("%d", mb.Messages), fmt.Sprintf("%d", mb.Unseen), "",
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			f.Name, attrs, messages, unseen, uidNext, uidValidity, size,
+			compare, messagesDelta, unseenDelta, sizeDelta, f.Error,
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
 	}
 
+	totalSize, totalSizeText := "", "-"
+	if totals.Size != nil {
+		totalSize = fmt.Sprintf("%d", *totals.Size)
+		totalSizeText = formatBytes(*totals.Size)
+	}
+	fmt.Println("  ----                              --------  ------" + strings.Repeat(" ", 31) + "----")
+	fmt.Printf("  %-34s %8d  %6d  %8s  %11s  %10s\n", fmt.Sprintf("Total (%d folders)", totals.Folders), totals.Messages, totals.Unseen, "", "", totalSizeText)
+
+	var totalCompare, totalMessagesDelta, totalUnseenDelta, totalSizeDelta string
+	if previous != nil {
+		totalCompare, totalMessagesDelta, totalUnseenDelta, totalSizeDelta = printComparison(previous, totals, comparison)
+	}
+
+	if logErr := csvLogger.WriteRow([]string{
+		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
+		totalFolderName, fmt.Sprintf("%d folders", totals.Folders),
+		fmt.Sprintf("%d", totals.Messages), fmt.Sprintf("%d", totals.Unseen), "", "", totalSize,
+		totalCompare, totalMessagesDelta, totalUnseenDelta, totalSizeDelta, "",
+	}); logErr != nil {
+		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+	}
+
+	if config.SnapshotFile != "" {
+		snapshot := &folderSnapshot{
+			Server:   config.Host,
+			Port:     config.Port,
+			Username: config.Username,
+			Taken:    time.Now().UTC(),
+			Folders:  folders,
+			Totals:   totals,
+		}
+		if err := writeFolderSnapshot(config.SnapshotFile, snapshot); err != nil {
+			logger.LogError(slogLogger, "Failed to write snapshot", "error", err, "file", config.SnapshotFile)
+			return err
+		}
+		fmt.Printf("\n✓ Snapshot saved to %s\n", config.SnapshotFile)
+	}
+
 	logger.LogInfo(slogLogger, "List folders completed",
 		"host", config.Host,
-		"mailbox_count", len(mailboxes))
+		"mailbox_count", len(mailboxes),
+		"messages", totals.Messages)
 
 	fmt.Println("\n✓ List folders completed")
 	return nil
 }
+
+// deltaColumns returns the CSV comparison columns for a folder, all empty
+// when no comparison was requested or the folder is not selectable.
+func deltaColumns(deltas map[string]folderDelta, name string) (compare, messages, unseen, size string) {
+	d, ok := deltas[name]
+	if !ok {
+		return "", "", "", ""
+	}
+	if d.Size != nil {
+		size = formatSigned(*d.Size)
+	}
+	return d.State, formatSigned(d.Messages), formatSigned(d.Unseen), size
+}
+
+// printComparison prints the folders that differ from the snapshot and the
+// change in totals, and returns the comparison columns for the totals row.
+func printComparison(previous *folderSnapshot, totals folderTotals, comparison []folderDelta) (compare, messages, unseen, size string) {
+	fmt.Printf("\nComparison with snapshot of %s:%d taken %s:\n",
+		previous.Server, previous.Port, previous.Taken.Local().Format("2006-01-02 15:04:05"))
+
+	counts := make(map[string]int)
+	for _, d := range comparison {
+		counts[d.State]++
+		if d.State == compareMatch {
+			continue
+		}
+		line := fmt.Sprintf("  %-8s %-34s messages %s, unseen %s", d.State, d.Name, formatSigned(d.Messages), formatSigned(d.Unseen))
+		if d.Size != nil {
+			line += fmt.Sprintf(", size %s", formatSigned(*d.Size))
+		}
+		if d.UIDValidityChanged {
+			line += " (UIDVALIDITY changed)"
+		}
+		fmt.Println(line)
+	}
+
+	messages = formatSigned(int64(totals.Messages) - int64(previous.Totals.Messages))
+	unseen = formatSigned(int64(totals.Unseen) - int64(previous.Totals.Unseen))
+	if totals.Size != nil && previous.Totals.Size != nil {
+		size = formatSigned(*totals.Size - *previous.Totals.Size)
+	}
+
+	changed := counts[compareChanged] + counts[compareNew] + counts[compareMissing]
+	fmt.Printf("  %d matching, %d changed, %d new, %d missing folders; total messages %d -> %d (%s)\n",
+		counts[compareMatch], counts[compareChanged], counts[compareNew], counts[compareMissing],
+		previous.Totals.Messages, totals.Messages, messages)
+	if changed == 0 {
+		fmt.Println("✓ All folders match the snapshot")
+		return compareMatch, messages, unseen, size
+	}
+	fmt.Println("⚠ Folders differ from the snapshot")
+	return compareChanged, messages, unseen, size
+}
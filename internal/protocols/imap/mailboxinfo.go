@@ -0,0 +1,340 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// quotaWarnPercent is the usage level at which a quota resource is flagged.
+const quotaWarnPercent = 90
+
+// rightNames describes the RFC 4314 rights, including the obsolete RFC 2086
+// "c" and "d" rights still reported by older servers.
+var rightNames = map[imap.Right]string{
+	'l': "lookup",
+	'r': "read",
+	's': "seen",
+	'w': "write",
+	'i': "insert",
+	'p': "post",
+	'k': "create-mailbox",
+	'x': "delete-mailbox",
+	't': "delete-messages",
+	'e': "expunge",
+	'c': "create (obsolete)",
+	'd': "delete (obsolete)",
+	'a': "administer",
+}
+
+// mailboxInfo reports namespaces and, for each mailbox, its quota roots
+// and access rights.
+func mailboxInfo(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Inspecting mailboxes on %s:%d...\n", config.Host, config.Port)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Type", "Name", "Detail", "Quota_Root",
+		"Storage_Used_KB", "Storage_Limit_KB", "Messages_Used", "Messages_Limit", "My_Rights", "ACL", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(status string, fields ...string) {
+		row := append([]string{config.Action, status, config.Host, fmt.Sprintf("%d", config.Port)}, fields...)
+		if logErr := csvLogger.WriteRow(row); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	writeFailure := func(err error) {
+		writeRow("FAILURE", "", "", "", "", "", "", "", "", "", "", err.Error())
+	}
+
+	client, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		writeFailure(err)
+		return err
+	}
+	defer func() { _ = client.Logout() }()
+
+	caps := client.RefreshCapabilities()
+	hasNamespace := caps.SupportsNAMESPACE() || caps.SupportsIMAP4rev2()
+	hasQuota := caps.SupportsQUOTA()
+	hasACL := caps.SupportsACL()
+	fmt.Printf("\nExtensions: NAMESPACE %s, QUOTA %s, ACL %s\n",
+		supportMark(hasNamespace), supportMark(hasQuota), supportMark(hasACL))
+
+	if hasNamespace {
+		namespaces, err := client.Namespace(ctx)
+		if err != nil {
+			logger.LogWarn(slogLogger, "NAMESPACE failed", "error", err)
+			fmt.Printf("⚠ %v\n", err)
+			writeRow("FAILURE", "NAMESPACE", "", "", "", "", "", "", "", "", "", err.Error())
+		} else {
+			fmt.Println("\nNamespaces:")
+			for _, ns := range []struct {
+				kind        string
+				descriptors []imap.NamespaceDescriptor
+			}{
+				{"personal", namespaces.Personal},
+				{"other users", namespaces.Other},
+				{"shared", namespaces.Shared},
+			} {
+				fmt.Printf("  %-12s %s\n", ns.kind+":", formatNamespaces(ns.descriptors))
+				for _, d := range ns.descriptors {
+					writeRow("SUCCESS", "NAMESPACE", d.Prefix,
+						fmt.Sprintf("%s; delimiter %s", ns.kind, formatDelimiter(d.Delim)),
+						"", "", "", "", "", "", "", "")
+				}
+			}
+		}
+	}
+
+	fmt.Printf("\nListing mailboxes matching %q...\n", config.MailboxPattern)
+	mailboxes, err := client.ListMailboxNames(ctx, config.MailboxPattern)
+	if err != nil {
+		logger.LogError(slogLogger, "LIST command failed", "error", err)
+		writeFailure(err)
+		return err
+	}
+	fmt.Printf("Found %d mailboxes\n", len(mailboxes))
+
+	failures := 0
+	for _, mb := range mailboxes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		attrs := strings.Join(mb.Attributes, ", ")
+		fmt.Printf("\n  %s", mb.Name)
+		if attrs != "" {
+			fmt.Printf("  [%s]", attrs)
+		}
+		fmt.Println()
+
+		if !isSelectable(mb.Attributes) {
+			fmt.Println("    (not selectable, skipped)")
+			writeRow("SUCCESS", "MAILBOX", mb.Name, attrs, "", "", "", "", "", "", "", "")
+			continue
+		}
+
+		var (
+			quotaRoots, storageUsed, storageLimit, messagesUsed, messagesLimit []string
+			myRights, acl                                                      string
+			errs                                                               []string
+		)
+
+		if hasQuota {
+			quotas, err := client.GetQuotaRoot(ctx, mb.Name)
+			if err != nil {
+				logger.LogWarn(slogLogger, "GETQUOTAROOT failed", "mailbox", mb.Name, "error", err)
+				fmt.Printf("    ✗ %v\n", err)
+				errs = append(errs, err.Error())
+			} else if len(quotas) == 0 {
+				fmt.Println("    Quota: none")
+			}
+			for _, q := range quotas {
+				fmt.Printf("    Quota root %q: %s\n", q.Root, formatQuotaResources(q.Resources))
+				quotaRoots = append(quotaRoots, q.Root)
+				storageUsed = append(storageUsed, quotaValue(q.Resources, imap.QuotaResourceStorage, false))
+				storageLimit = append(storageLimit, quotaValue(q.Resources, imap.QuotaResourceStorage, true))
+				messagesUsed = append(messagesUsed, quotaValue(q.Resources, imap.QuotaResourceMessage, false))
+				messagesLimit = append(messagesLimit, quotaValue(q.Resources, imap.QuotaResourceMessage, true))
+			}
+		}
+
+		if hasACL {
+			rights, err := client.MyRights(ctx, mb.Name)
+			if err != nil {
+				logger.LogWarn(slogLogger, "MYRIGHTS failed", "mailbox", mb.Name, "error", err)
+				fmt.Printf("    ✗ %v\n", err)
+				errs = append(errs, err.Error())
+			} else {
+				myRights = rights.String()
+				fmt.Printf("    My rights: %s (%s)\n", myRights, describeRights(rights))
+				if !hasRight(rights, imap.RightRead) {
+					fmt.Println("    ⚠ Missing \"r\" right: SELECT and FETCH will be refused")
+				}
+
+				// GETACL is refused without the administer right, so only
+				// ask when the server would answer.
+				if hasRight(rights, imap.RightAdminister) {
+					entries, err := client.GetACL(ctx, mb.Name)
+					if err != nil {
+						logger.LogWarn(slogLogger, "GETACL failed", "mailbox", mb.Name, "error", err)
+						fmt.Printf("    ✗ %v\n", err)
+						errs = append(errs, err.Error())
+					} else {
+						acl = formatACL(entries)
+						fmt.Printf("    ACL: %s\n", acl)
+					}
+				} else {
+					fmt.Println("    ACL: not readable (requires the \"a\" right)")
+				}
+			}
+		}
+
+		status := "SUCCESS"
+		if len(errs) > 0 {
+			status = "FAILURE"
+			failures++
+		}
+		writeRow(status, "MAILBOX", mb.Name, attrs,
+			strings.Join(quotaRoots, "; "),
+			strings.Join(storageUsed, "; "), strings.Join(storageLimit, "; "),
+			strings.Join(messagesUsed, "; "), strings.Join(messagesLimit, "; "),
+			myRights, acl, strings.Join(errs, "; "))
+	}
+
+	if !hasQuota && !hasACL {
+		fmt.Println("\n⚠ Server supports neither QUOTA nor ACL; only namespaces and mailbox names were reported")
+	}
+
+	logger.LogInfo(slogLogger, "Mailbox info completed",
+		"host", config.Host,
+		"mailbox_count", len(mailboxes),
+		"failures", failures)
+
+	if failures > 0 {
+		fmt.Printf("\n⚠ Mailbox info completed with errors on %d of %d mailboxes\n", failures, len(mailboxes))
+		return nil
+	}
+	fmt.Println("\n✓ Mailbox info completed")
+	return nil
+}
+
+// supportMark renders an extension as supported or not.
+func supportMark(supported bool) string {
+	if supported {
+		return "✓"
+	}
+	return "✗"
+}
+
+// isSelectable reports whether a mailbox with the given LIST attributes
+// can hold messages.
+func isSelectable(attrs []string) bool {
+	for _, a := range attrs {
+		if strings.EqualFold(a, string(imap.MailboxAttrNoSelect)) || strings.EqualFold(a, string(imap.MailboxAttrNonExistent)) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatDelimiter renders a hierarchy delimiter, which is zero for flat
+// namespaces.
+func formatDelimiter(delim rune) string {
+	if delim == 0 {
+		return "NIL"
+	}
+	return fmt.Sprintf("%q", delim)
+}
+
+// formatNamespaces renders the descriptors of one namespace class.
+func formatNamespaces(descriptors []imap.NamespaceDescriptor) string {
+	if len(descriptors) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(descriptors))
+	for _, d := range descriptors {
+		parts = append(parts, fmt.Sprintf("%q (delimiter %s)", d.Prefix, formatDelimiter(d.Delim)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatQuotaResources renders quota usage, e.g.
+// "STORAGE 512/1024 KB (50.0%), MESSAGE 10/1000 (1.0%)". Resources at or
+// above quotaWarnPercent are marked with ⚠.
+func formatQuotaResources(resources map[imap.QuotaResourceType]imapclient.QuotaResourceData) string {
+	if len(resources) == 0 {
+		return "no limits"
+	}
+
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		r := resources[imap.QuotaResourceType(name)]
+		unit := ""
+		if imap.QuotaResourceType(name) == imap.QuotaResourceStorage {
+			unit = " KB"
+		}
+		part := fmt.Sprintf("%s %d/%d%s", name, r.Usage, r.Limit, unit)
+		if r.Limit > 0 {
+			percent := float64(r.Usage) * 100 / float64(r.Limit)
+			part += fmt.Sprintf(" (%.1f%%)", percent)
+			if percent >= quotaWarnPercent {
+				part += " ⚠"
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quotaValue returns the usage or limit of a resource, or "" when the
+// quota root does not limit it.
+func quotaValue(resources map[imap.QuotaResourceType]imapclient.QuotaResourceData, resource imap.QuotaResourceType, limit bool) string {
+	r, ok := resources[resource]
+	if !ok {
+		return ""
+	}
+	if limit {
+		return fmt.Sprintf("%d", r.Limit)
+	}
+	return fmt.Sprintf("%d", r.Usage)
+}
+
+// hasRight reports whether rights contains right.
+func hasRight(rights imap.RightSet, right imap.Right) bool {
+	for _, r := range rights {
+		if r == right {
+			return true
+		}
+	}
+	return false
+}
+
+// describeRights spells out a rights string, e.g. "lr" as "lookup, read".
+// Rights unknown to RFC 4314 are shown as-is.
+func describeRights(rights imap.RightSet) string {
+	if len(rights) == 0 {
+		return "no rights"
+	}
+	names := make([]string, 0, len(rights))
+	for _, r := range rights {
+		if name, ok := rightNames[r]; ok {
+			names = append(names, name)
+		} else {
+			names = append(names, string(r))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// formatACL renders an access control list as "identifier=rights" pairs
+// sorted by identifier.
+func formatACL(entries map[imap.RightsIdentifier]imap.RightSet) string {
+	identifiers := make([]string, 0, len(entries))
+	for id := range entries {
+		identifiers = append(identifiers, string(id))
+	}
+	sort.Strings(identifiers)
+
+	parts := make([]string, 0, len(identifiers))
+	for _, id := range identifiers {
+		parts = append(parts, fmt.Sprintf("%s=%s", id, entries[imap.RightsIdentifier(id)].String()))
+	}
+	return strings.Join(parts, ", ")
+}
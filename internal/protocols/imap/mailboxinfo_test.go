@@ -0,0 +1,102 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+)
+
+func TestDescribeRights(t *testing.T) {
+	tests := []struct {
+		rights string
+		want   string
+	}{
+		{"lr", "lookup, read"},
+		{"lrswipkxtea", "lookup, read, seen, write, insert, post, create-mailbox, delete-mailbox, delete-messages, expunge, administer"},
+		{"cd", "create (obsolete), delete (obsolete)"},
+		{"l9", "lookup, 9"},
+		{"", "no rights"},
+	}
+	for _, tt := range tests {
+		if got := describeRights(imap.RightSet(tt.rights)); got != tt.want {
+			t.Errorf("describeRights(%q) = %q, want %q", tt.rights, got, tt.want)
+		}
+	}
+}
+
+func TestFormatQuotaResources(t *testing.T) {
+	got := formatQuotaResources(map[imap.QuotaResourceType]imapclient.QuotaResourceData{
+		imap.QuotaResourceStorage: {Usage: 950, Limit: 1000},
+		imap.QuotaResourceMessage: {Usage: 10, Limit: 1000},
+	})
+	want := "MESSAGE 10/1000 (1.0%), STORAGE 950/1000 KB (95.0%) ⚠"
+	if got != want {
+		t.Errorf("formatQuotaResources() = %q, want %q", got, want)
+	}
+
+	if got := formatQuotaResources(nil); got != "no limits" {
+		t.Errorf("formatQuotaResources(nil) = %q, want %q", got, "no limits")
+	}
+}
+
+func TestFormatACL(t *testing.T) {
+	got := formatACL(map[imap.RightsIdentifier]imap.RightSet{
+		"user@example.com":          imap.RightSet("lrswipkxtea"),
+		imap.RightsIdentifierAnyone: imap.RightSet("lr"),
+		"-guest":                    imap.RightSet("w"),
+	})
+	want := "-guest=w, anyone=lr, user@example.com=lrswipkxtea"
+	if got != want {
+		t.Errorf("formatACL() = %q, want %q", got, want)
+	}
+}
+
+func TestIsSelectable(t *testing.T) {
+	if !isSelectable([]string{`\HasNoChildren`, `\Sent`}) {
+		t.Error(`isSelectable(\HasNoChildren \Sent) = false, want true`)
+	}
+	if isSelectable([]string{`\NoSelect`}) {
+		t.Error(`isSelectable(\NoSelect) = true, want false`)
+	}
+}
+
+func TestMailboxInfo_NamespacesWithoutQuotaOrACL(t *testing.T) {
+	config, user := newTestIMAPServer(t)
+	config.Action = ActionMailboxInfo
+	if err := user.Create("Archive", nil); err != nil {
+		t.Fatalf("create Archive: %v", err)
+	}
+
+	csvLog := &recordingLogger{}
+	if err := mailboxInfo(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("mailboxInfo() error = %v", err)
+	}
+
+	var namespaces, mailboxes []string
+	for _, row := range csvLog.rows {
+		if got := csvLog.column(row, "Status"); got != "SUCCESS" {
+			t.Errorf("row %v has Status %q, want SUCCESS", row, got)
+		}
+		switch csvLog.column(row, "Type") {
+		case "NAMESPACE":
+			namespaces = append(namespaces, csvLog.column(row, "Detail"))
+		case "MAILBOX":
+			mailboxes = append(mailboxes, csvLog.column(row, "Name"))
+			if rights := csvLog.column(row, "My_Rights"); rights != "" {
+				t.Errorf("My_Rights = %q without ACL support", rights)
+			}
+		}
+	}
+
+	if len(namespaces) == 0 || !strings.HasPrefix(namespaces[0], "personal;") {
+		t.Errorf("namespace rows = %v, want a personal namespace", namespaces)
+	}
+	if strings.Join(mailboxes, ",") != "Archive,INBOX" && strings.Join(mailboxes, ",") != "INBOX,Archive" {
+		t.Errorf("mailbox rows = %v, want INBOX and Archive", mailboxes)
+	}
+}
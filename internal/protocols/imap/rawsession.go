@@ -0,0 +1,413 @@
+package imap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/network"
+	"github.com/ziembor/gomailtesttool/internal/common/ratelimit"
+	imapprotocol "github.com/ziembor/gomailtesttool/internal/imap/protocol"
+)
+
+// maxRawLiteral caps the size of a single literal read by rawSession.
+const maxRawLiteral = 64 << 20
+
+// rawResponse is the outcome of one command sent on a rawSession.
+type rawResponse struct {
+	Untagged []string // Untagged responses without "* " and CRLF, literals inlined
+	Status   string   // OK, NO or BAD
+	Text     string   // Text of the tagged status response
+}
+
+// err returns the tagged status as an error unless it is OK.
+func (r *rawResponse) err(command string) error {
+	if r.Status == "OK" {
+		return nil
+	}
+	return fmt.Errorf("%s failed: %s %s", command, r.Status, r.Text)
+}
+
+// countingConn counts the bytes read from and written to a connection.
+type countingConn struct {
+	net.Conn
+	read, written int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read += int64(n)
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// rawSession is a minimal IMAP session for the checks go-imap cannot do:
+// it refuses to ENABLE extensions that change the response syntax
+// (CONDSTORE, QRESYNC) and does not implement COMPRESS. Responses are read
+// whole, literals included, and only parsed as far as a check needs.
+// Bytes are counted on the wire and, once COMPRESS is active, after
+// decompression. It is not safe for concurrent use.
+type rawSession struct {
+	config  *Config
+	limiter *ratelimit.Limiter
+	wire    *countingConn   // Application bytes on the connection (inside TLS)
+	data    *countingReader // Bytes received after decompression
+	r       *bufio.Reader
+	w       io.Writer
+	flush   func() error // Completes a write once COMPRESS is active
+	caps    *imapprotocol.Capabilities
+	tag     int
+	stop    func() bool
+}
+
+// dialRawSession connects the same way IMAPClient.Connect does (--address,
+// --ipv4/--ipv6, PROXY header, IMAPS or STARTTLS), reads the greeting and
+// the capabilities. preauth reports a PREAUTH greeting.
+func dialRawSession(ctx context.Context, config *Config) (session *rawSession, preauth bool, err error) {
+	address, err := dialAddress(ctx, config)
+	if err != nil {
+		return nil, false, err
+	}
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if config.ProxyProtocol != "" {
+		if _, err := network.WriteProxyHeader(conn, config.ProxyProtocol, config.ProxySource); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+	}
+	if config.IMAPS {
+		tlsConfig := newTLSConfig(config)
+		tlsConfig.NextProtos = []string{"imap"}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, false, err
+		}
+		conn = tlsConn
+	}
+
+	s := newRawSession(config, conn)
+	s.stop = context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+
+	s.setDeadline()
+	greeting, err := s.readResponse()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read greeting: %w", err)
+	}
+	switch {
+	case strings.HasPrefix(strings.ToUpper(greeting), "* BYE"):
+		return nil, false, fmt.Errorf("server refused the connection: %s", greeting)
+	case strings.HasPrefix(strings.ToUpper(greeting), "* PREAUTH"):
+		preauth = true
+	}
+
+	if config.StartTLS {
+		if preauth {
+			return nil, false, fmt.Errorf("server sent PREAUTH on unencrypted connection")
+		}
+		if err := s.startTLS(ctx); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := s.refreshCapabilities(ctx); err != nil {
+		return nil, false, err
+	}
+	return s, preauth, nil
+}
+
+func newRawSession(config *Config, conn net.Conn) *rawSession {
+	s := &rawSession{config: config}
+	if config.RateLimit > 0 {
+		s.limiter = ratelimit.New(config.RateLimit)
+	}
+	s.wire = &countingConn{Conn: conn}
+	s.data = &countingReader{r: s.wire}
+	s.r = bufio.NewReader(s.data)
+	s.w = s.wire
+	return s
+}
+
+// startTLS upgrades the connection with STARTTLS.
+func (s *rawSession) startTLS(ctx context.Context) error {
+	resp, err := s.command(ctx, "STARTTLS")
+	if err != nil {
+		return err
+	}
+	if err := resp.err("STARTTLS"); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(s.wire.Conn, newTLSConfig(s.config))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	s.wire.Conn = tlsConn
+	s.r.Reset(s.data)
+	return nil
+}
+
+// refreshCapabilities asks for the capabilities, which change after
+// STARTTLS and authentication.
+func (s *rawSession) refreshCapabilities(ctx context.Context) error {
+	resp, err := s.command(ctx, "CAPABILITY")
+	if err != nil {
+		return err
+	}
+	if err := resp.err("CAPABILITY"); err != nil {
+		return err
+	}
+	for _, line := range resp.Untagged {
+		if name, rest, _ := strings.Cut(line, " "); strings.EqualFold(name, "CAPABILITY") {
+			s.caps = imapprotocol.NewCapabilities(strings.Fields(rest))
+		}
+	}
+	if s.caps == nil {
+		return fmt.Errorf("server sent no CAPABILITY response")
+	}
+	return nil
+}
+
+// authenticate logs in with method, as returned by selectAuthMethod.
+func (s *rawSession) authenticate(ctx context.Context, method string) error {
+	config := s.config
+	if method == "LOGIN" {
+		resp, err := s.command(ctx, "LOGIN %s %s", quoteIMAPString(config.Username), quoteIMAPString(config.Password))
+		if err != nil {
+			return err
+		}
+		return resp.err("LOGIN")
+	}
+
+	client, done, err := newSASLClient(config, method, config.Username, config.Password, config.AccessToken)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	mech, ir, err := client.Start()
+	if err != nil {
+		return err
+	}
+	command := "AUTHENTICATE " + mech
+	if ir != nil && s.caps.SupportsSASLIR() {
+		encoded := base64.StdEncoding.EncodeToString(ir)
+		if encoded == "" {
+			encoded = "="
+		}
+		command += " " + encoded
+		ir = nil
+	}
+
+	first := true
+	resp, err := s.exchange(ctx, command, func(text string) (string, error) {
+		if first && ir != nil {
+			first = false
+			return base64.StdEncoding.EncodeToString(ir), nil
+		}
+		first = false
+		challenge, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid SASL challenge: %w", err)
+		}
+		response, err := client.Next(challenge)
+		if err != nil {
+			return "*", err // Cancel the exchange
+		}
+		return base64.StdEncoding.EncodeToString(response), nil
+	})
+	if err != nil {
+		return err
+	}
+	return resp.err(method + " authentication")
+}
+
+// startCompression sends COMPRESS DEFLATE and switches both directions to
+// raw DEFLATE (RFC 4978).
+func (s *rawSession) startCompression(ctx context.Context) error {
+	resp, err := s.command(ctx, "COMPRESS DEFLATE")
+	if err != nil {
+		return err
+	}
+	if err := resp.err("COMPRESS"); err != nil {
+		return err
+	}
+
+	// Anything already buffered is compressed data that followed the OK
+	buffered, _ := s.r.Peek(s.r.Buffered())
+	s.data.r = flate.NewReader(io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), s.wire))
+	s.r = bufio.NewReader(s.data)
+
+	compressor, err := flate.NewWriter(s.wire, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	s.w = compressor
+	s.flush = compressor.Flush
+	return nil
+}
+
+// command sends a command built from format and args and reads the
+// responses up to the tagged status.
+func (s *rawSession) command(ctx context.Context, format string, args ...any) (*rawResponse, error) {
+	return s.exchange(ctx, fmt.Sprintf(format, args...), nil)
+}
+
+// exchange sends command and reads the responses up to the tagged status,
+// answering each continuation request with the line returned by
+// onContinue. An error from onContinue is reported after the tagged status.
+func (s *rawSession) exchange(ctx context.Context, command string, onContinue func(text string) (string, error)) (*rawResponse, error) {
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	s.tag++
+	tag := fmt.Sprintf("G%d", s.tag)
+	name, _, _ := strings.Cut(command, " ")
+	s.setDeadline()
+	if err := s.writeLine(tag + " " + command); err != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+
+	resp := &rawResponse{}
+	var continueErr error
+	for {
+		line, err := s.readResponse()
+		if err != nil {
+			return nil, fmt.Errorf("%s failed: %w", name, err)
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			if onContinue == nil {
+				return nil, fmt.Errorf("%s failed: unexpected continuation request", name)
+			}
+			reply, err := onContinue(strings.TrimSpace(strings.TrimPrefix(line, "+")))
+			if err != nil && continueErr == nil {
+				continueErr = err
+			}
+			if err := s.writeLine(reply); err != nil {
+				return nil, fmt.Errorf("%s failed: %w", name, err)
+			}
+		case strings.HasPrefix(line, tag+" "):
+			status, text, _ := strings.Cut(strings.TrimPrefix(line, tag+" "), " ")
+			resp.Status, resp.Text = strings.ToUpper(status), text
+			if continueErr != nil {
+				return resp, continueErr
+			}
+			return resp, nil
+		default:
+			resp.Untagged = append(resp.Untagged, strings.TrimPrefix(line, "* "))
+		}
+	}
+}
+
+// writeLine sends one line, flushing the compressor when it is active.
+func (s *rawSession) writeLine(line string) error {
+	if _, err := io.WriteString(s.w, line+"\r\n"); err != nil {
+		return err
+	}
+	if s.flush != nil {
+		return s.flush()
+	}
+	return nil
+}
+
+// readResponse reads one response line, including the literals it
+// announces, without the final CRLF.
+func (s *rawSession) readResponse() (string, error) {
+	var b strings.Builder
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		b.WriteString(line)
+
+		size, ok := literalSize(line)
+		if !ok {
+			return b.String(), nil
+		}
+		if size > maxRawLiteral {
+			return "", fmt.Errorf("literal of %d bytes exceeds the %d byte limit", size, maxRawLiteral)
+		}
+		b.WriteString("\r\n")
+		if _, err := io.CopyN(&b, s.r, size); err != nil {
+			return "", err
+		}
+	}
+}
+
+// setDeadline applies --timeout to the next command.
+func (s *rawSession) setDeadline() {
+	if s.config.Timeout > 0 {
+		_ = s.wire.SetDeadline(time.Now().Add(s.config.Timeout))
+	}
+}
+
+// Close logs out and closes the connection.
+func (s *rawSession) Close() {
+	_, _ = s.command(context.Background(), "LOGOUT")
+	s.stop()
+	_ = s.wire.Close()
+}
+
+// literalSize returns the size announced by a line ending in a literal
+// ("{123}" or the LITERAL+ form "{123+}").
+func literalSize(line string) (int64, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(strings.TrimSuffix(line[open+1:len(line)-1], "+"), 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// quoteIMAPString returns s as an IMAP quoted string.
+func quoteIMAPString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
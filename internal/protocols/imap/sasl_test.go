@@ -0,0 +1,85 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"testing"
+
+	commonsasl "github.com/ziembor/gomailtesttool/internal/common/sasl"
+)
+
+func TestXOAUTH2Client(t *testing.T) {
+	c := &xoauth2Client{username: "user@example.com", accessToken: "tok"}
+	mech, ir, err := c.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if mech != "XOAUTH2" {
+		t.Errorf("mechanism = %q, want XOAUTH2", mech)
+	}
+	if want := "user=user@example.com\x01auth=Bearer tok\x01\x01"; string(ir) != want {
+		t.Errorf("initial response = %q, want %q", ir, want)
+	}
+
+	resp, err := c.Next([]byte(`{"status":"401"}`))
+	if err != nil || len(resp) != 0 {
+		t.Errorf("Next() = %q, %v; want empty response", resp, err)
+	}
+}
+
+func TestOAuthBearerClient(t *testing.T) {
+	c := &oauthbearerClient{username: "user@example.com", accessToken: "tok", host: "imap.example.com", port: 993}
+	mech, ir, err := c.Start()
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if mech != "OAUTHBEARER" {
+		t.Errorf("mechanism = %q, want OAUTHBEARER", mech)
+	}
+	want := "n,a=user@example.com,\x01host=imap.example.com\x01port=993\x01auth=Bearer tok\x01\x01"
+	if string(ir) != want {
+		t.Errorf("initial response = %q, want %q", ir, want)
+	}
+
+	// RFC 7628 §3.2.3: an error challenge is answered with a single kvsep
+	resp, err := c.Next([]byte(`{"status":"invalid_token","scope":"mail"}`))
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if string(resp) != "\x01" {
+		t.Errorf("Next() = %q, want \\x01", resp)
+	}
+	if c.serverError == nil || c.serverError.Status != "invalid_token" {
+		t.Errorf("serverError = %+v, want status invalid_token", c.serverError)
+	}
+}
+
+func TestNewSASLClient(t *testing.T) {
+	config := &Config{Host: "imap.example.com", Port: 993, Realm: "EXAMPLE.COM"}
+
+	for _, method := range []string{"PLAIN", "XOAUTH2", "OAUTHBEARER", "NTLM"} {
+		client, done, err := newSASLClient(config, method, "user@example.com", "secret", "tok")
+		if err != nil {
+			t.Fatalf("newSASLClient(%s) error = %v", method, err)
+		}
+		mech, _, err := client.Start()
+		done()
+		if err != nil || mech != method {
+			t.Errorf("newSASLClient(%s).Start() = %q, %v", method, mech, err)
+		}
+	}
+
+	client, done, err := newSASLClient(config, "GSSAPI", "user", "secret", "")
+	if err != nil {
+		t.Fatalf("newSASLClient(GSSAPI) error = %v", err)
+	}
+	if gssapi, ok := client.(*commonsasl.GSSAPIClient); !ok || gssapi.Service != "imap" || gssapi.Target != "imap.example.com" || gssapi.Realm != "EXAMPLE.COM" {
+		t.Errorf("newSASLClient(GSSAPI) = %#v, want a client for the server's realm", client)
+	}
+	done()
+
+	if _, _, err := newSASLClient(config, "CRAM-MD5", "user", "secret", ""); err == nil {
+		t.Error("newSASLClient(CRAM-MD5) accepted an unsupported method")
+	}
+}
@@ -0,0 +1,54 @@
+package imap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// openSession connects and authenticates the same way listfolders does,
+// printing the usual progress lines. It is used by actions that work with
+// mailbox contents, where CSV reporting of failures is done by the caller.
+// The returned client must be logged out.
+func openSession(ctx context.Context, config *Config, slogLogger *slog.Logger) (*IMAPClient, error) {
+	client := NewIMAPClient(config)
+	if err := startSession(ctx, client, config, slogLogger); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// startSession connects and authenticates an already configured client,
+// e.g. one with a handler for unsolicited server updates.
+func startSession(ctx context.Context, client *IMAPClient, config *Config, slogLogger *slog.Logger) error {
+	if err := client.Connect(ctx); err != nil {
+		logger.LogError(slogLogger, "Connection failed",
+			"error", err,
+			"host", config.Host,
+			"port", config.Port)
+		return fmt.Errorf("connection failed: %w", err)
+	}
+
+	fmt.Printf("✓ Connected to %s:%d\n", config.Host, config.Port)
+
+	authMethod := client.SelectAuthMethod(config.AccessToken != "")
+	if config.AccessToken != "" && authMethod != "XOAUTH2" && authMethod != "OAUTHBEARER" {
+		logger.LogWarn(slogLogger, "Access token provided but server supports neither XOAUTH2 nor OAUTHBEARER", "method", authMethod)
+	}
+
+	fmt.Printf("Authenticating with method: %s\n", authMethod)
+
+	if err := client.Auth(ctx, config.Username, config.Password, config.AccessToken); err != nil {
+		logger.LogError(slogLogger, "Authentication failed",
+			"error", err,
+			"username", maskUsername(config.Username),
+			"method", authMethod)
+		_ = client.Logout()
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	fmt.Println("✓ Authentication successful")
+
+	return nil
+}
@@ -0,0 +1,374 @@
+package imap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// appendProbeHeader marks messages uploaded by testappend, the same header
+// smtp testfilter uses, so leftovers can be found and cleaned up later.
+const appendProbeHeader = "X-GoMailTest-Probe"
+
+// testAppend uploads a message with APPEND, reads it back by UID and
+// compares it byte for byte. The UID comes from the APPENDUID response code
+// when the server supports UIDPLUS; otherwise the message is found again by
+// searching for its Message-ID. With --delete the message is then flagged
+// \Deleted and removed with UID EXPUNGE.
+func testAppend(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Testing APPEND to %s on %s:%d...\n", config.Mailbox, config.Host, config.Port)
+
+	// CSV columns for testappend
+	columns := []string{"Action", "Status", "Server", "Port", "Mailbox", "Message_Bytes", "UID", "UIDPLUS", "Append_Ms", "Fetched_Bytes", "Match", "Deleted", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	var (
+		messageBytes, fetchedBytes int
+		uid                        imap.UID
+		uidplus                    bool
+		appendMs                   int64
+		match, deleted             string
+	)
+	writeRow := func(status string, err error) {
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		uidStr := ""
+		if uid != 0 {
+			uidStr = fmt.Sprintf("%d", uid)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			config.Mailbox, fmt.Sprintf("%d", messageBytes), uidStr, fmt.Sprintf("%t", uidplus),
+			fmt.Sprintf("%d", appendMs), fmt.Sprintf("%d", fetchedBytes), match, deleted, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	flags, err := parseAppendFlags(config.AppendFlags)
+	if err != nil {
+		writeRow("FAILURE", err)
+		return err
+	}
+	var internalDate time.Time
+	if config.AppendDate != "" {
+		if internalDate, err = parseInternalDate(config.AppendDate); err != nil {
+			writeRow("FAILURE", err)
+			return err
+		}
+	}
+
+	message, err := loadAppendMessage(config)
+	if err != nil {
+		writeRow("FAILURE", err)
+		return err
+	}
+	messageBytes = len(message)
+	messageID := messageIDOf(message)
+
+	client, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		writeRow("FAILURE", err)
+		return err
+	}
+	defer func() { _ = client.Logout() }()
+
+	if caps := client.RefreshCapabilities(); caps != nil {
+		uidplus = caps.SupportsUIDPLUS() || caps.SupportsIMAP4rev2()
+	}
+	if uidplus {
+		fmt.Println("✓ Server supports UIDPLUS (APPENDUID)")
+	} else {
+		fmt.Println("⚠ Server does not advertise UIDPLUS; the message will be located by Message-ID")
+	}
+
+	fmt.Printf("\nAppending %d bytes to %s...\n", messageBytes, config.Mailbox)
+	start := time.Now()
+	appendData, err := client.AppendMessage(ctx, config.Mailbox, message, &imap.AppendOptions{Flags: flags, Time: internalDate})
+	appendMs = time.Since(start).Milliseconds()
+	if err != nil {
+		logger.LogError(slogLogger, "APPEND failed", "error", err, "mailbox", config.Mailbox, "bytes", messageBytes)
+		writeRow("FAILURE", err)
+		return err
+	}
+	fmt.Printf("✓ APPEND accepted in %d ms\n", appendMs)
+
+	if _, err := client.SelectMailbox(ctx, config.Mailbox, false); err != nil {
+		logger.LogError(slogLogger, "SELECT failed", "error", err, "mailbox", config.Mailbox)
+		writeRow("FAILURE", err)
+		return err
+	}
+
+	if appendData != nil && appendData.UID != 0 {
+		uid = appendData.UID
+		fmt.Printf("✓ APPENDUID: UID %d (UIDVALIDITY %d)\n", uid, appendData.UIDValidity)
+	} else {
+		if messageID == "" {
+			err := fmt.Errorf("server returned no APPENDUID and the message has no Message-ID to search for")
+			writeRow("FAILURE", err)
+			return err
+		}
+		uids, err := client.SearchUIDs(ctx, &imap.SearchCriteria{
+			Header: []imap.SearchCriteriaHeaderField{{Key: "Message-ID", Value: messageID}},
+		})
+		if err != nil {
+			writeRow("FAILURE", err)
+			return err
+		}
+		if len(uids) == 0 {
+			err := fmt.Errorf("appended message %s not found in %s", messageID, config.Mailbox)
+			writeRow("FAILURE", err)
+			return err
+		}
+		uid = uids[len(uids)-1]
+		fmt.Printf("✓ Found appended message by Message-ID: UID %d\n", uid)
+	}
+
+	fetched, err := client.FetchUIDs(ctx, []imap.UID{uid}, &imap.FetchOptions{
+		UID:          true,
+		Flags:        true,
+		InternalDate: true,
+		RFC822Size:   true,
+		BodySection:  []*imap.FetchItemBodySection{{Peek: true}},
+	})
+	if err == nil && len(fetched) == 0 {
+		err = fmt.Errorf("UID %d not returned by FETCH", uid)
+	}
+	if err != nil {
+		logger.LogError(slogLogger, "FETCH failed", "error", err, "uid", uid)
+		writeRow("FAILURE", err)
+		return err
+	}
+
+	msg := fetched[0]
+	var body []byte
+	for _, section := range msg.BodySection {
+		body = section.Bytes
+	}
+	fetchedBytes = len(body)
+
+	var problems []string
+	if offset := firstDifference(message, body); offset >= 0 {
+		match = "false"
+		problems = append(problems, fmt.Sprintf("content differs at byte %d (sent %d bytes, read back %d)", offset, messageBytes, fetchedBytes))
+		fmt.Printf("✗ Content differs at byte %d (sent %d bytes, read back %d)\n", offset, messageBytes, fetchedBytes)
+	} else {
+		match = "true"
+		fmt.Printf("✓ Read back %d bytes: identical to the uploaded message\n", fetchedBytes)
+	}
+	if msg.RFC822Size != 0 && msg.RFC822Size != int64(messageBytes) {
+		fmt.Printf("⚠ RFC822.SIZE is %d, uploaded %d bytes\n", msg.RFC822Size, messageBytes)
+	}
+
+	if missing := missingFlags(flags, msg.Flags); len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf("flags not stored: %s", formatFlags(missing)))
+		fmt.Printf("✗ Flags not stored: %s (have %s)\n", formatFlags(missing), formatFlags(msg.Flags))
+	} else if len(flags) > 0 {
+		fmt.Printf("✓ Flags stored: %s\n", formatFlags(msg.Flags))
+	}
+
+	if !internalDate.IsZero() {
+		if !msg.InternalDate.Truncate(time.Second).Equal(internalDate.Truncate(time.Second)) {
+			problems = append(problems, fmt.Sprintf("internal date is %s, want %s", formatDate(msg.InternalDate), formatDate(internalDate)))
+			fmt.Printf("✗ Internal date is %s, want %s\n", formatDate(msg.InternalDate), formatDate(internalDate))
+		} else {
+			fmt.Printf("✓ Internal date stored: %s\n", formatDate(msg.InternalDate))
+		}
+	}
+
+	if config.DeleteAfter {
+		deleted = "false"
+		if err := client.AddFlags(ctx, []imap.UID{uid}, imap.FlagDeleted); err != nil {
+			logger.LogError(slogLogger, "Failed to flag message as deleted", "error", err, "uid", uid)
+			problems = append(problems, err.Error())
+			fmt.Printf("✗ %v\n", err)
+		} else if !uidplus {
+			// A plain EXPUNGE would also remove other \Deleted messages
+			fmt.Printf("⚠ Message UID %d flagged \\Deleted but not expunged: UID EXPUNGE needs UIDPLUS\n", uid)
+		} else if err := client.ExpungeUIDs(ctx, []imap.UID{uid}); err != nil {
+			logger.LogError(slogLogger, "UID EXPUNGE failed", "error", err, "uid", uid)
+			problems = append(problems, err.Error())
+			fmt.Printf("✗ %v\n", err)
+		} else {
+			deleted = "true"
+			fmt.Printf("✓ Message UID %d deleted and expunged\n", uid)
+		}
+	}
+
+	if len(problems) > 0 {
+		err := fmt.Errorf("append verification failed: %s", strings.Join(problems, "; "))
+		logger.LogError(slogLogger, "Append test failed", "error", err, "uid", uid)
+		writeRow("FAILURE", err)
+		return err
+	}
+
+	logger.LogInfo(slogLogger, "Append test completed",
+		"mailbox", config.Mailbox,
+		"uid", uid,
+		"bytes", messageBytes,
+		"append_ms", appendMs)
+	writeRow("SUCCESS", nil)
+
+	fmt.Println("\n✓ Append test completed")
+	return nil
+}
+
+// loadAppendMessage reads --eml or generates a test message. Line endings
+// are normalized to CRLF as IMAP requires, so the comparison after reading
+// the message back is against exactly what was uploaded.
+func loadAppendMessage(config *Config) ([]byte, error) {
+	if config.AppendFile == "" {
+		return buildAppendMessage(config, time.Now())
+	}
+	data, err := os.ReadFile(config.AppendFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read --eml file: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("--eml file %s is empty", config.AppendFile)
+	}
+	return normalizeCRLF(data), nil
+}
+
+// buildAppendMessage generates a plain-text test message of about
+// --size-kb KB, padded with base64 lines so the size is predictable.
+func buildAppendMessage(config *Config, now time.Time) ([]byte, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(random)
+
+	address := probeAddress(config)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: <%s>\r\n", address)
+	fmt.Fprintf(&buf, "To: <%s>\r\n", address)
+	fmt.Fprintf(&buf, "Subject: gomailtest IMAP append test %s\r\n", now.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s.append@gomailtest>\r\n", id)
+	fmt.Fprintf(&buf, "%s: testappend\r\n", appendProbeHeader)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString("This message was uploaded by gomailtest imap testappend.\r\n\r\n")
+
+	target := config.AppendSizeKB * 1024
+	line := make([]byte, 57) // 76 base64 characters per line
+	for buf.Len()+78 <= target {
+		if _, err := rand.Read(line); err != nil {
+			return nil, err
+		}
+		buf.WriteString(base64.StdEncoding.EncodeToString(line))
+		buf.WriteString("\r\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// probeAddress returns the From and To address of generated messages: the
+// username when it is an email address.
+func probeAddress(config *Config) string {
+	if strings.Contains(config.Username, "@") {
+		return config.Username
+	}
+	return "gomailtest@localhost"
+}
+
+// normalizeCRLF converts bare LF (and stray CR) line endings to CRLF.
+func normalizeCRLF(data []byte) []byte {
+	s := strings.ReplaceAll(string(data), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+// messageIDOf returns the Message-ID of a message without angle brackets,
+// or "" when it has none.
+func messageIDOf(message []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(strings.TrimSpace(msg.Header.Get("Message-ID")), "<>")
+}
+
+// parseAppendFlags validates --flags entries. System flags must be one of
+// the RFC 9051 flags that clients may set; keywords must be atoms.
+func parseAppendFlags(values []string) ([]imap.Flag, error) {
+	var flags []imap.Flag
+	for _, v := range values {
+		if strings.HasPrefix(v, "\\") {
+			switch strings.ToLower(v) {
+			case `\seen`, `\answered`, `\flagged`, `\deleted`, `\draft`:
+			default:
+				return nil, fmt.Errorf("%s is not a settable system flag (use \\Seen, \\Answered, \\Flagged, \\Deleted or \\Draft)", v)
+			}
+		} else if strings.ContainsAny(v, " ()[]{%*\"\\") {
+			return nil, fmt.Errorf("%q is not a valid keyword", v)
+		}
+		flags = append(flags, imap.Flag(v))
+	}
+	return flags, nil
+}
+
+// parseInternalDate accepts RFC 3339 (2026-01-31T10:00:00+01:00) or a date (2026-01-31).
+func parseInternalDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not RFC 3339 (2026-01-31T10:00:00Z) or a date (2026-01-31)", value)
+}
+
+// firstDifference returns the offset of the first differing byte of a and b,
+// or -1 when they are identical.
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	if len(a) != len(b) {
+		return n
+	}
+	return -1
+}
+
+// missingFlags returns the wanted flags not present in have (case-insensitive).
+func missingFlags(want, have []imap.Flag) []imap.Flag {
+	var missing []imap.Flag
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(string(w), string(h)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, w)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,143 @@
+//go:build !integration
+// +build !integration
+
+package imap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestParseAppendFlags(t *testing.T) {
+	flags, err := parseAppendFlags([]string{`\Seen`, `\flagged`, "$Label1"})
+	if err != nil {
+		t.Fatalf("parseAppendFlags() error = %v", err)
+	}
+	if len(flags) != 3 || flags[0] != imap.FlagSeen || flags[2] != "$Label1" {
+		t.Errorf("parseAppendFlags() = %v", flags)
+	}
+
+	for _, bad := range []string{`\Recent`, `\Bogus`, "bad keyword", "(x)"} {
+		if _, err := parseAppendFlags([]string{bad}); err == nil {
+			t.Errorf("parseAppendFlags(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseInternalDate(t *testing.T) {
+	got, err := parseInternalDate("2026-01-31T10:00:00+01:00")
+	if err != nil || !got.Equal(time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseInternalDate(RFC 3339) = %v, %v", got, err)
+	}
+	got, err = parseInternalDate("2026-01-31")
+	if err != nil || !got.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseInternalDate(date) = %v, %v", got, err)
+	}
+	if _, err := parseInternalDate("31/01/2026"); err == nil {
+		t.Error("parseInternalDate(31/01/2026) expected error")
+	}
+}
+
+func TestFirstDifference(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"abc", "abc", -1},
+		{"abc", "abd", 2},
+		{"abc", "ab", 2},
+		{"", "x", 0},
+	}
+	for _, tt := range tests {
+		if got := firstDifference([]byte(tt.a), []byte(tt.b)); got != tt.want {
+			t.Errorf("firstDifference(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	got := string(normalizeCRLF([]byte("a\nb\r\nc\rd")))
+	if got != "a\r\nb\r\nc\r\nd" {
+		t.Errorf("normalizeCRLF() = %q", got)
+	}
+}
+
+func TestBuildAppendMessage(t *testing.T) {
+	config := NewConfig()
+	config.Username = "user@example.com"
+	config.AppendSizeKB = 32
+
+	message, err := buildAppendMessage(config, time.Now())
+	if err != nil {
+		t.Fatalf("buildAppendMessage() error = %v", err)
+	}
+	if len(message) > 32*1024 || len(message) < 31*1024 {
+		t.Errorf("message size = %d, want about %d", len(message), 32*1024)
+	}
+	if messageIDOf(message) == "" {
+		t.Error("generated message has no Message-ID")
+	}
+	if !strings.Contains(string(message), appendProbeHeader+": testappend\r\n") {
+		t.Errorf("generated message has no %s header", appendProbeHeader)
+	}
+	if strings.Contains(strings.ReplaceAll(string(message), "\r\n", ""), "\n") {
+		t.Error("generated message contains bare LF")
+	}
+}
+
+func TestTestAppend_RoundTripAndDelete(t *testing.T) {
+	config, user := newTestIMAPServer(t)
+	config.Action = ActionTestAppend
+	config.AppendFlags = []string{`\Flagged`, "$Label1"}
+	config.AppendDate = "2026-01-31T10:00:00Z"
+	config.DeleteAfter = true
+
+	csvLog := &recordingLogger{}
+	if err := testAppend(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("testAppend() error = %v", err)
+	}
+
+	if len(csvLog.rows) != 1 {
+		t.Fatalf("got %d CSV rows, want 1", len(csvLog.rows))
+	}
+	row := csvLog.rows[0]
+	for column, want := range map[string]string{"Status": "SUCCESS", "Match": "true", "Deleted": "true", "UIDPLUS": "true"} {
+		if got := csvLog.column(row, column); got != want {
+			t.Errorf("%s = %q, want %q", column, got, want)
+		}
+	}
+
+	status, err := user.Status("INBOX", &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatalf("STATUS: %v", err)
+	}
+	if *status.NumMessages != 0 {
+		t.Errorf("INBOX has %d messages after --delete, want 0", *status.NumMessages)
+	}
+}
+
+func TestTestAppend_EMLFile(t *testing.T) {
+	config, user := newTestIMAPServer(t)
+	config.Action = ActionTestAppend
+	config.AppendFile = filepath.Join(t.TempDir(), "message.eml")
+	if err := os.WriteFile(config.AppendFile, []byte(testMessageBeta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testAppend(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("testAppend() error = %v", err)
+	}
+
+	status, err := user.Status("INBOX", &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatalf("STATUS: %v", err)
+	}
+	if *status.NumMessages != 1 {
+		t.Errorf("INBOX has %d messages, want 1 (kept without --delete)", *status.NumMessages)
+	}
+}
@@ -57,31 +57,15 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	}
 
 	// Determine auth method
-	authMethod := config.AuthMethod
-	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				logger.LogWarn(slogLogger, "Access token provided but XOAUTH2 not supported by server")
-				authMethod = "PLAIN"
-			}
-		} else if caps != nil && caps.SupportsPlain() {
-			authMethod = "PLAIN"
-		} else {
-			authMethod = "LOGIN"
-		}
+	authMethod := client.SelectAuthMethod(config.AccessToken != "")
+	if config.AccessToken != "" && authMethod != "XOAUTH2" && authMethod != "OAUTHBEARER" {
+		logger.LogWarn(slogLogger, "Access token provided but server supports neither XOAUTH2 nor OAUTHBEARER", "method", authMethod)
 	}
 
 	fmt.Printf("Authenticating with method: %s\n", authMethod)
 
 	// Authenticate
-	var authErr error
-	if config.AccessToken != "" && strings.EqualFold(authMethod, "XOAUTH2") {
-		authErr = client.Auth(ctx, config.Username, "", config.AccessToken)
-	} else {
-		authErr = client.Auth(ctx, config.Username, config.Password, "")
-	}
+	authErr := client.Auth(ctx, config.Username, config.Password, config.AccessToken)
 
 	if authErr != nil {
 		logger.LogError(slogLogger, "Authentication failed",
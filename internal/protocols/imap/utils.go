@@ -1,5 +1,10 @@
 package imap
 
+import (
+	"fmt"
+	"strings"
+)
+
 // maskUsername masks a username for safe logging.
 // Shows first 2 and last 2 characters with **** in between.
 func maskUsername(username string) string {
@@ -8,3 +13,29 @@ func maskUsername(username string) string {
 	}
 	return username[:2] + "****" + username[len(username)-2:]
 }
+
+// splitCommaSeparated splits a comma-separated list, trimming whitespace and
+// dropping empty entries.
+func splitCommaSeparated(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// formatBytes formats a byte count using binary units (KB, MB, GB).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,329 @@
+package jmap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// --vacation values
+const (
+	vacationEnable  = "enable"
+	vacationDisable = "disable"
+)
+
+// Sections of the accountinfo report, one CSV row per item
+const (
+	sectionAccount  = "ACCOUNT"
+	sectionIdentity = "IDENTITY"
+	sectionVacation = "VACATION"
+	sectionQuota    = "QUOTA"
+	sectionSieve    = "SIEVE"
+)
+
+// accountInfo reports what an account is provisioned with: its sending
+// identities (Identity/get), automatic reply (VacationResponse/get), quotas
+// (Quota/get, RFC 9425) and Sieve support (RFC 9661). With --vacation the
+// automatic reply is enabled or disabled first. Optional capabilities the
+// server does not advertise are reported as warnings, not failures.
+func accountInfo(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Getting account information from %s...\n\n", config.Host)
+
+	// CSV columns for accountinfo
+	columns := []string{"Action", "Status", "Server", "Account_Id", "Section", "Item_Id", "Name", "Detail", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	var accountId protocol.Id
+	writeRow := func(section string, itemId protocol.Id, name, detail string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, string(accountId), section, string(itemId), name, detail, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	failures := 0
+	fail := func(section string, err error) {
+		failures++
+		writeRow(section, "", "", "", err)
+		fmt.Printf("  ✗ %v\n", err)
+		logger.LogError(slogLogger, "Account check failed", "section", section, "error", err)
+	}
+
+	client := NewJMAPClient(config)
+	session, err := client.Discover(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		writeRow(sectionAccount, "", "", "", err)
+		return fmt.Errorf("JMAP discovery failed: %w", err)
+	}
+
+	var ok bool
+	accountId, ok = session.GetPrimaryMailAccountId()
+	if !ok {
+		err := fmt.Errorf("no primary mail account found")
+		writeRow(sectionAccount, "", "", "", err)
+		return err
+	}
+	account := session.Accounts[accountId]
+	fmt.Printf("✓ Account: %s (%s)\n", account.Name, accountId)
+	fmt.Printf("  Username:  %s\n", session.Username)
+	fmt.Printf("  Personal:  %t, read-only: %t\n", account.IsPersonal, account.IsReadOnly)
+	writeRow(sectionAccount, accountId, account.Name,
+		fmt.Sprintf("personal=%t readOnly=%t", account.IsPersonal, account.IsReadOnly), nil)
+
+	// Identities
+	fmt.Println("\nIdentities:")
+	identities, err := client.GetIdentities(ctx)
+	if err != nil {
+		fail(sectionIdentity, fmt.Errorf("Identity/get failed: %w", err))
+	} else {
+		if len(identities) == 0 {
+			fmt.Println("  ⚠ No identities; this account cannot submit email")
+		}
+		for _, identity := range identities {
+			detail := identityDetail(identity)
+			fmt.Printf("  ✓ %s <%s> (%s)\n", identity.Name, identity.Email, identity.Id)
+			if detail != "" {
+				fmt.Printf("      %s\n", detail)
+			}
+			writeRow(sectionIdentity, identity.Id, identity.Name, identity.Email+" "+detail, nil)
+		}
+	}
+
+	// Vacation response
+	fmt.Println("\nVacation response:")
+	vacationStatus := "not supported"
+	if !session.HasCapability(protocol.VacationResponseCapability) {
+		if config.Vacation != "" {
+			fail(sectionVacation, fmt.Errorf("cannot %s the vacation response: the server does not advertise %s",
+				config.Vacation, protocol.VacationResponseCapability))
+		} else {
+			fmt.Printf("  ⚠ Not supported (no %s capability)\n", protocol.VacationResponseCapability)
+			writeRow(sectionVacation, "", "", vacationStatus, nil)
+		}
+	} else {
+		setFailed := false
+		if config.Vacation != "" {
+			update := protocol.VacationResponseUpdate{IsEnabled: config.Vacation == vacationEnable}
+			if config.VacationSubject != "" {
+				update.Subject = &config.VacationSubject
+			}
+			if config.VacationBody != "" {
+				update.TextBody = &config.VacationBody
+			}
+			if err := client.SetVacationResponse(ctx, update); err != nil {
+				setFailed = true
+				fail(sectionVacation, fmt.Errorf("VacationResponse/set failed: %w", err))
+			} else {
+				fmt.Printf("  ✓ Vacation response %sd\n", config.Vacation)
+			}
+		}
+
+		vacation, err := client.GetVacationResponse(ctx)
+		switch {
+		case err != nil:
+			vacationStatus = "unknown"
+			fail(sectionVacation, fmt.Errorf("VacationResponse/get failed: %w", err))
+		case config.Vacation != "" && !setFailed && vacation.IsEnabled != (config.Vacation == vacationEnable):
+			vacationStatus = vacationState(vacation)
+			fail(sectionVacation, fmt.Errorf("vacation response is still %s after VacationResponse/set", vacationStatus))
+		default:
+			vacationStatus = vacationState(vacation)
+			detail := vacationDetail(vacation)
+			fmt.Printf("  ✓ %s\n", strings.ToUpper(vacationStatus[:1])+vacationStatus[1:])
+			if detail != "" {
+				fmt.Printf("      %s\n", detail)
+			}
+			subject := ""
+			if vacation.Subject != nil {
+				subject = *vacation.Subject
+			}
+			writeRow(sectionVacation, vacation.Id, subject, strings.TrimSpace(vacationStatus+" "+detail), nil)
+		}
+	}
+
+	// Quotas
+	fmt.Println("\nQuotas:")
+	quotaStatus := "not supported"
+	if !session.HasCapability(protocol.QuotaCapability) {
+		fmt.Printf("  ⚠ Not supported (no %s capability)\n", protocol.QuotaCapability)
+		writeRow(sectionQuota, "", "", quotaStatus, nil)
+	} else if quotas, err := client.GetQuotas(ctx); err != nil {
+		quotaStatus = "unknown"
+		fail(sectionQuota, fmt.Errorf("Quota/get failed: %w", err))
+	} else {
+		warnings := 0
+		for _, quota := range quotas {
+			mark := "✓"
+			if quotaExceeded(quota) {
+				mark = "⚠"
+				warnings++
+			}
+			fmt.Printf("  %s %s: %s\n", mark, quota.Name, formatQuotaUsage(quota))
+			detail := fmt.Sprintf("scope=%s types=%s", quota.Scope, strings.Join(quota.Types, ","))
+			fmt.Printf("      %s\n", detail)
+			writeRow(sectionQuota, quota.Id, quota.Name, formatQuotaUsage(quota)+" "+detail, nil)
+		}
+		if len(quotas) == 0 {
+			fmt.Println("  No quotas set")
+		}
+		quotaStatus = fmt.Sprintf("%d, %d at or over a limit", len(quotas), warnings)
+	}
+
+	// Sieve, advertised per account (RFC 9661)
+	fmt.Println("\nSieve:")
+	sieveStatus := "not supported"
+	sieveAccount, _ := session.GetPrimaryAccountId(protocol.SieveCapability)
+	if !session.HasCapability(protocol.SieveCapability) {
+		fmt.Printf("  ⚠ Not supported (no %s capability)\n", protocol.SieveCapability)
+		writeRow(sectionSieve, "", "", sieveStatus, nil)
+	} else if sieve, err := session.GetSieveCapability(sieveAccount); err != nil {
+		sieveStatus = "not enabled for the account"
+		fmt.Printf("  ⚠ Advertised by the server, but not for account %s: %v\n", sieveAccount, err)
+		writeRow(sectionSieve, sieveAccount, "", sieveStatus, nil)
+	} else {
+		sieveStatus = "supported"
+		detail := sieveDetail(sieve)
+		fmt.Printf("  ✓ %s\n", orNone(sieve.Implementation))
+		fmt.Printf("      %s\n", detail)
+		writeRow(sectionSieve, sieveAccount, sieve.Implementation, detail, nil)
+	}
+
+	fmt.Println("\nSummary:")
+	fmt.Printf("  Identities:        %d\n", len(identities))
+	fmt.Printf("  Vacation response: %s\n", vacationStatus)
+	fmt.Printf("  Quotas:            %s\n", quotaStatus)
+	fmt.Printf("  Sieve:             %s\n", sieveStatus)
+
+	logger.LogInfo(slogLogger, "Account info completed",
+		"host", config.Host,
+		"account", accountId,
+		"identities", len(identities),
+		"vacation", vacationStatus,
+		"quotas", quotaStatus,
+		"sieve", sieveStatus,
+		"failures", failures)
+
+	if failures > 0 {
+		return fmt.Errorf("%d account check(s) failed", failures)
+	}
+	return nil
+}
+
+// identityDetail describes the optional properties of an identity.
+func identityDetail(identity protocol.Identity) string {
+	var parts []string
+	if len(identity.ReplyTo) > 0 {
+		parts = append(parts, fmt.Sprintf("replyTo=%q", formatAddresses(identity.ReplyTo)))
+	}
+	if len(identity.Bcc) > 0 {
+		parts = append(parts, fmt.Sprintf("bcc=%q", formatAddresses(identity.Bcc)))
+	}
+	var signatures []string
+	if identity.TextSignature != "" {
+		signatures = append(signatures, "text")
+	}
+	if identity.HTMLSignature != "" {
+		signatures = append(signatures, "html")
+	}
+	if len(signatures) > 0 {
+		parts = append(parts, "signature="+strings.Join(signatures, ","))
+	}
+	parts = append(parts, fmt.Sprintf("mayDelete=%t", identity.MayDelete))
+	return strings.Join(parts, " ")
+}
+
+// vacationState is "enabled" or "disabled".
+func vacationState(vacation *protocol.VacationResponse) string {
+	if vacation.IsEnabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// vacationDetail describes the dates and content of a vacation response.
+func vacationDetail(vacation *protocol.VacationResponse) string {
+	var parts []string
+	if vacation.FromDate != nil {
+		parts = append(parts, "from="+*vacation.FromDate)
+	}
+	if vacation.ToDate != nil {
+		parts = append(parts, "to="+*vacation.ToDate)
+	}
+	if vacation.Subject != nil {
+		parts = append(parts, fmt.Sprintf("subject=%q", *vacation.Subject))
+	}
+	if vacation.TextBody != nil {
+		parts = append(parts, fmt.Sprintf("textBody=%d chars", len(*vacation.TextBody)))
+	}
+	if vacation.HTMLBody != nil {
+		parts = append(parts, fmt.Sprintf("htmlBody=%d chars", len(*vacation.HTMLBody)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quotaExceeded reports whether a quota's usage has reached its warn, soft
+// or hard limit.
+func quotaExceeded(quota protocol.Quota) bool {
+	if quota.HardLimit > 0 && quota.Used >= quota.HardLimit {
+		return true
+	}
+	for _, limit := range []*uint64{quota.WarnLimit, quota.SoftLimit} {
+		if limit != nil && quota.Used >= *limit {
+			return true
+		}
+	}
+	return false
+}
+
+// formatQuotaUsage formats a quota's usage against its hard limit, in bytes
+// for octets quotas and as a count otherwise.
+func formatQuotaUsage(quota protocol.Quota) string {
+	format := func(n uint64) string { return fmt.Sprint(n) }
+	if quota.ResourceType == "octets" {
+		format = func(n uint64) string { return formatBytes(int64(n)) }
+	}
+	usage := fmt.Sprintf("%s of %s", format(quota.Used), format(quota.HardLimit))
+	if quota.HardLimit > 0 {
+		usage += fmt.Sprintf(" (%.1f%%)", float64(quota.Used)*100/float64(quota.HardLimit))
+	}
+	if quota.ResourceType != "octets" {
+		usage += " " + quota.ResourceType
+	}
+	return usage
+}
+
+// sieveDetail describes the limits and extensions of a Sieve capability.
+func sieveDetail(sieve *protocol.SieveCapabilityInfo) string {
+	limit := func(n *int64) string {
+		if n == nil {
+			return "unlimited"
+		}
+		return fmt.Sprint(*n)
+	}
+	return fmt.Sprintf("maxNumberScripts=%s maxSizeScript=%s maxNumberRedirects=%s extensions=%s",
+		limit(sieve.MaxNumberScripts), limit(sieve.MaxSizeScript), limit(sieve.MaxNumberRedirects),
+		orNone(strings.Join(sieve.SieveExtensions, ",")))
+}
+
+// orNone returns s, or "(none)" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package jmap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// serveAccountInfo advertises the vacation response, quota and Sieve
+// capabilities and answers Identity/get, VacationResponse/get and /set and
+// Quota/get. The vacation response starts disabled; updates are applied to
+// it.
+func serveAccountInfo(server *fakeJMAPServer) {
+	server.capabilities = map[string]interface{}{
+		protocol.VacationResponseCapability: map[string]interface{}{},
+		protocol.QuotaCapability:            map[string]interface{}{},
+		protocol.SieveCapability:            map[string]interface{}{},
+	}
+	server.accountCapabilities = map[string]interface{}{
+		protocol.SieveCapability: map[string]interface{}{
+			"implementation": "Fake Sieve 1.0", "maxSizeScriptName": 512, "maxSizeScript": 65536,
+			"maxNumberScripts": 5, "maxNumberRedirects": nil, "sieveExtensions": []string{"fileinto", "vacation"},
+		},
+	}
+
+	vacation := map[string]interface{}{"id": "singleton", "isEnabled": false}
+	server.handle(protocol.MethodIdentityGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodIdentityGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "I1", "name": "Tester", "email": "tester@example.com", "textSignature": "-- Tester", "mayDelete": false},
+				{"id": "I2", "name": "Support", "email": "support@example.com", "replyTo": []map[string]string{{"email": "help@example.com"}}, "mayDelete": true},
+			},
+		}
+	})
+	server.handle(protocol.MethodVacationResponseGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodVacationResponseGet, map[string]interface{}{"accountId": "A1", "state": "v1", "list": []interface{}{vacation}}
+	})
+	server.handle(protocol.MethodVacationResponseSet, func(args json.RawMessage) (string, interface{}) {
+		var request struct {
+			Update map[string]map[string]interface{} `json:"update"`
+		}
+		_ = json.Unmarshal(args, &request)
+		for property, value := range request.Update["singleton"] {
+			vacation[property] = value
+		}
+		return protocol.MethodVacationResponseSet, map[string]interface{}{"accountId": "A1", "updated": map[string]interface{}{"singleton": nil}}
+	})
+	server.handle(protocol.MethodQuotaGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodQuotaGet, map[string]interface{}{
+			"accountId": "A1", "state": "q1",
+			"list": []map[string]interface{}{
+				{"id": "Q1", "resourceType": "octets", "used": 536870912, "hardLimit": 1073741824, "scope": "account", "name": "Mail storage", "types": []string{"Mail"}},
+				{"id": "Q2", "resourceType": "count", "used": 950, "hardLimit": 1000, "warnLimit": 900, "scope": "account", "name": "Messages", "types": []string{"Mail"}},
+			},
+		}
+	})
+}
+
+func TestAccountInfo(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveAccountInfo(server)
+	config.Action = ActionAccountInfo
+	config.Vacation = vacationEnable
+	config.VacationSubject = "Out of office"
+
+	csvLog := &recordingLogger{}
+	if err := accountInfo(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("accountInfo() error = %v", err)
+	}
+
+	sections := make(map[string][][]string)
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Status") != "SUCCESS" {
+			t.Errorf("row %v, want SUCCESS", row)
+		}
+		section := csvLog.column(row, "Section")
+		sections[section] = append(sections[section], row)
+	}
+	if len(sections[sectionIdentity]) != 2 {
+		t.Errorf("identity rows = %v, want 2", sections[sectionIdentity])
+	} else if detail := csvLog.column(sections[sectionIdentity][1], "Detail"); !strings.Contains(detail, `replyTo="help@example.com"`) {
+		t.Errorf("identity detail = %q, want the replyTo address", detail)
+	}
+	if rows := sections[sectionVacation]; len(rows) != 1 || csvLog.column(rows[0], "Name") != "Out of office" ||
+		!strings.HasPrefix(csvLog.column(rows[0], "Detail"), "enabled") {
+		t.Errorf("vacation rows = %v, want the enabled response read back", rows)
+	}
+	if rows := sections[sectionQuota]; len(rows) != 2 || !strings.Contains(csvLog.column(rows[0], "Detail"), "512.0 MB of 1.0 GB (50.0%)") ||
+		!strings.Contains(csvLog.column(rows[1], "Detail"), "950 of 1000 (95.0%) count") {
+		t.Errorf("quota rows = %v, want storage at 50%% and messages at 95%%", rows)
+	}
+	if rows := sections[sectionSieve]; len(rows) != 1 || csvLog.column(rows[0], "Name") != "Fake Sieve 1.0" ||
+		!strings.Contains(csvLog.column(rows[0], "Detail"), "maxNumberRedirects=unlimited extensions=fileinto,vacation") {
+		t.Errorf("sieve rows = %v", rows)
+	}
+}
+
+func TestAccountInfo_CapabilitiesMissing(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	server.handle(protocol.MethodIdentityGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodIdentityGet, map[string]interface{}{"accountId": "A1", "list": []interface{}{}}
+	})
+	config.Action = ActionAccountInfo
+
+	// Missing optional capabilities are only reported
+	csvLog := &recordingLogger{}
+	if err := accountInfo(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("accountInfo() error = %v", err)
+	}
+	var unsupported []string
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Detail") == "not supported" {
+			unsupported = append(unsupported, csvLog.column(row, "Section"))
+		}
+	}
+	if got := strings.Join(unsupported, ","); got != "VACATION,QUOTA,SIEVE" {
+		t.Errorf("unsupported sections = %s, want VACATION,QUOTA,SIEVE", got)
+	}
+
+	// Changing the vacation response without the capability fails
+	config.Vacation = vacationDisable
+	err := accountInfo(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 account check(s) failed") {
+		t.Errorf("accountInfo() error = %v, want the vacation check failed", err)
+	}
+}
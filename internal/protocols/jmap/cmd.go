@@ -2,14 +2,16 @@ package jmap
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/ziembor/gomailtesttool/internal/common/bootstrap"
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
 
-// NewCmd returns the "jmap" cobra.Command with all 3 action subcommands.
+// NewCmd returns the "jmap" cobra.Command with all 9 action subcommands.
 // Each subcommand shares persistent flags (server, auth, TLS, output).
 func NewCmd() *cobra.Command {
 	v := viper.New()
@@ -17,7 +19,8 @@ func NewCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "jmap",
 		Short: "JMAP server connectivity and authentication testing",
-		Long: `Test JMAP server connectivity, authentication, and mailbox listing.
+		Long: `Test JMAP server connectivity, authentication, mailbox listing, message retrieval,
+sending, push notifications, delta sync, import, and account provisioning.
 
 Uses HTTPS with Bearer or Basic authentication. Supports connect-address override
 for load balancer testing and JMAP session discovery per RFC 8620.
@@ -32,6 +35,12 @@ Environment variables use the JMAP prefix (e.g. JMAPHOST, JMAPPORT, JMAPUSERNAME
 		newTestConnectCmd(v),
 		newTestAuthCmd(v),
 		newGetMailboxesCmd(v),
+		newGetMailCmd(v),
+		newSendMailCmd(v),
+		newWatchCmd(v),
+		newSyncCmd(v),
+		newImportCmd(v),
+		newAccountInfoCmd(v),
 	)
 
 	return cmd
@@ -171,3 +180,373 @@ using the Mailbox/get JMAP method. Shows mailbox name, role, and message counts.
 		},
 	}
 }
+
+func newGetMailCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "getmail",
+		Short: "Query and fetch JMAP emails",
+		Long: `Authenticate to the JMAP server and search emails with Email/query, filtered by
+mailbox, sender, subject, date and keyword, then fetch them with Email/get in the same request
+by back-referencing the query's ids. Prints the emails, exports them to JSON with --export, and
+downloads the raw messages as .eml files with --download.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionGetMail
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionGetMail, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := getMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("mailbox", "", "Only emails in this mailbox, by id, role (e.g. inbox) or name (env: JMAPMAILBOX)")
+	f.String("from", "", "Only emails whose From contains this text (env: JMAPFROM)")
+	f.String("subject", "", "Only emails whose Subject contains this text (env: JMAPSUBJECT)")
+	f.String("after", "", "Only emails received after this date: YYYY-MM-DD or RFC 3339 (env: JMAPAFTER)")
+	f.String("haskeyword", "", "Only emails with this keyword, e.g. $seen or $flagged (env: JMAPHASKEYWORD)")
+	f.Int("limit", 10, "Maximum number of emails to fetch (env: JMAPLIMIT)")
+	f.String("sort", "receivedAt", "Sort by: receivedAt, sentAt, size, from, to, subject (env: JMAPSORT)")
+	f.Bool("ascending", false, "Sort ascending (oldest or smallest first) (env: JMAPASCENDING)")
+	f.StringSlice("properties", nil, "Email properties to fetch (comma-separated; default: a summary set) (env: JMAPPROPERTIES)")
+	f.String("export", "", "Write the fetched emails to this JSON file (env: JMAPEXPORT)")
+	f.Bool("download", false, "Download each email's raw message as <id>.eml (env: JMAPDOWNLOAD)")
+	f.String("output-dir", ".", "Directory for the downloaded .eml files (env: JMAPOUTPUTDIR)")
+
+	return cmd
+}
+
+func newSendMailCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sendmail",
+		Short: "Send a test email through JMAP submission",
+		Long: `Authenticate to the JMAP server, create the email in the Drafts mailbox with Email/set and
+send it with EmailSubmission/set in the same request, using the Identity/get identity that
+matches --from. Attachments are uploaded as blobs first. On success the server moves the email
+to Sent; the submission's undoStatus and per-recipient deliveryStatus are reported, polling for
+up to --wait while delivery is still queued.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			bindSendMailFlags(v, cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionSendMail
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionSendMail, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := SendMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("from", "", "Sender address; selects the matching identity (default: the first identity) (env: JMAPIDENTITY)")
+	f.String("to", "", "Comma-separated recipient email addresses (env: JMAPTO)")
+	f.String("cc", "", "Comma-separated CC recipient email addresses (env: JMAPCC)")
+	f.String("bcc", "", "Comma-separated BCC recipient email addresses; included in the submission envelope only, never in message headers (env: JMAPBCC)")
+	f.String("subject", "JMAP Test", "Email subject (env: JMAPEMAILSUBJECT)")
+	f.String("body", "This is a test message from jmaptool", "Email body text (env: JMAPBODY)")
+	f.String("bodyhtml", "", "HTML body content; combine with --body for multipart/alternative (env: JMAPBODYHTML)")
+	f.String("attachments", "", "Comma-separated file paths to attach, uploaded as blobs (env: JMAPATTACHMENTS)")
+	f.Duration("wait", 0, "Poll the delivery status for up to this long while it is queued, e.g. 30s (env: JMAPWAIT)")
+
+	return cmd
+}
+
+// bindSendMailFlags binds the sendmail flags to v. --from and --subject are
+// bound to the identity and email-subject keys, since the from and subject
+// keys (and JMAPFROM and JMAPSUBJECT) are getmail's search filters.
+func bindSendMailFlags(v *viper.Viper, flags *pflag.FlagSet) {
+	_ = v.BindPFlags(flags)
+	_ = v.BindPFlag("identity", flags.Lookup("from"))
+	_ = v.BindPFlag("email-subject", flags.Lookup("subject"))
+}
+
+func newWatchCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch push notifications and resolve the changes",
+		Long: `Authenticate to the JMAP server and subscribe to push notifications over EventSource, or
+over a WebSocket when the server advertises urn:ietf:params:jmap:websocket with push support.
+Each StateChange is printed and resolved with Mailbox/changes and Email/changes into new,
+updated and destroyed items. The push channel is reopened if it drops.
+
+With --probes, probe emails are created in the inbox every --probe-interval and the time
+until each is pushed is reported as push latency; probes are deleted when the watch ends.
+Runs until interrupted, for --duration, or (with probes and no duration) until every probe
+has been resolved.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionWatch
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionWatch, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := watch(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("transport", "auto", "Push transport: auto, eventsource or websocket (env: JMAPTRANSPORT)")
+	f.Duration("duration", 0, "Stop watching after this long, e.g. 5m (default: until interrupted) (env: JMAPDURATION)")
+	f.Duration("ping", 30*time.Second, "EventSource ping interval; a stream silent for twice this long is reopened (0 disables) (env: JMAPPING)")
+	f.Int("probes", 0, "Number of probe emails to create in the inbox to measure push latency (env: JMAPPROBES)")
+	f.Duration("probe-interval", 10*time.Second, "Interval between probe emails (env: JMAPPROBEINTERVAL)")
+	f.Duration("probe-timeout", 30*time.Second, "How long to wait for a probe's push before counting it lost (env: JMAPPROBETIMEOUT)")
+
+	return cmd
+}
+
+func newSyncCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Report what changed since the last run with Mailbox/changes and Email/changes",
+		Long: `Authenticate to the JMAP server and bring the Mailbox and Email state strings kept in
+--statefile up to date with /changes, paging with --max-changes, printing the ids created,
+updated and destroyed since the last run. The first run of an account lists every id. When
+the server answers cannotCalculateChanges, sync falls back to a full resync and reports the
+difference.
+
+Each page of changes is checked against the tracked ids, and with --verify the result is
+compared with a full listing; inconsistencies in the server's change log fail the action.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionSync
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionSync, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := syncMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("statefile", "", "State file of the tracked states and ids (default: ./.jmapsync-state.json) (env: JMAPSTATEFILE)")
+	f.Int("max-changes", 100, "maxChanges of each /changes call; more changes are fetched in pages (env: JMAPMAXCHANGES)")
+	f.Bool("verify", false, "Compare the tracked ids with a full listing after applying the changes (env: JMAPVERIFY)")
+
+	return cmd
+}
+
+func newImportCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import .eml files with Email/import and verify them by checksum",
+		Long: `Authenticate to the JMAP server, upload each .eml file to the session's upload URL and
+import it into --mailbox with Email/import, setting --keywords and receivedAt. Each imported
+email's blob is then downloaded through the download URL and its SHA-256 compared with the
+file's.
+
+Files over the server's maxSizeUpload are reported as limit violations without uploading,
+and no more than maxConcurrentUpload uploads run at once. Uploads the server rejects with a
+limit error are reported as limit violations too.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionImport
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionImport, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := importMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("files", "", "Comma-separated .eml files, or directories of .eml files, to import (env: JMAPFILES)")
+	f.String("mailbox", "inbox", "Mailbox to import into, by id, role or name (env: JMAPMAILBOX)")
+	f.String("keywords", "", "Comma-separated keywords to set on the imported emails, e.g. $seen,$flagged (env: JMAPKEYWORDS)")
+	f.String("received-at", receivedAtHeader, "receivedAt of the imported emails: header (the Date header), server (the import time), or YYYY-MM-DD / RFC 3339 (env: JMAPRECEIVEDAT)")
+	f.Int("concurrency", 0, "Parallel uploads, capped at the server's maxConcurrentUpload (default: maxConcurrentUpload) (env: JMAPCONCURRENCY)")
+
+	return cmd
+}
+
+func newAccountInfoCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "accountinfo",
+		Short: "Report identities, vacation response, quotas and Sieve support of the account",
+		Long: `Authenticate to the JMAP server and report how the primary account is provisioned:
+its sending identities (Identity/get), vacation response (VacationResponse/get), quotas
+(Quota/get, RFC 9425) and the Sieve capability (RFC 9661) when the server advertises it.
+
+With --vacation enable or --vacation disable the vacation response is updated with
+VacationResponse/set first and then read back. Capabilities the server does not advertise
+are reported as warnings rather than failures.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionAccountInfo
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("jmaptool", ActionAccountInfo, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "JMAP Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := accountInfo(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("vacation", "", "Update the vacation response first: enable or disable (env: JMAPVACATION)")
+	f.String("vacation-subject", "", "Subject of the automatic reply, with --vacation enable (env: JMAPVACATIONSUBJECT)")
+	f.String("vacation-body", "", "Text body of the automatic reply, with --vacation enable (env: JMAPVACATIONBODY)")
+
+	return cmd
+}
@@ -2,7 +2,9 @@ package jmap
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,6 +30,55 @@ type Config struct {
 	AccessToken string // OAuth2 bearer token
 	AuthMethod  string // auto, basic, bearer
 
+	// Getmail options
+	Mailbox    string   // Mailbox to search, by id, role or name (empty = all mailboxes)
+	From       string   // Only emails whose From contains this text
+	Subject    string   // Only emails whose Subject contains this text
+	After      string   // Only emails received after this date (YYYY-MM-DD or RFC 3339)
+	HasKeyword string   // Only emails with this keyword, e.g. $seen or $flagged
+	Limit      int      // Maximum number of emails to fetch
+	Sort       string   // Email/query sort property
+	Ascending  bool     // Sort ascending (oldest or smallest first)
+	Properties []string // Email properties to fetch (empty = the default set)
+	Export     string   // Write the fetched emails to this JSON file
+	Download   bool     // Download each email's raw message through the download URL
+	OutputDir  string   // Directory for downloaded .eml files
+
+	// Sendmail options
+	Identity     string        // Sender address; selects the matching identity (empty = the first identity)
+	EmailSubject string        // Subject of the sent email
+	To           []string      // Recipient addresses
+	Cc           []string      // Cc recipient addresses
+	Bcc          []string      // Bcc recipient addresses (envelope only)
+	Body         string        // Text body
+	BodyHTML     string        // HTML body
+	Attachments  []string      // Files to attach, uploaded as blobs
+	Wait         time.Duration // How long to poll EmailSubmission/get for the delivery status
+
+	// Watch options
+	Transport     string        // Push transport: auto, eventsource, websocket
+	Duration      time.Duration // How long to watch (0 = until interrupted, or until the probes finish)
+	Ping          time.Duration // EventSource ping interval; a silent stream is reconnected after twice this
+	Probes        int           // Number of probe emails to create for push latency measurement
+	ProbeInterval time.Duration // Time between probes
+	ProbeTimeout  time.Duration // How long to wait for a probe's push before counting it as lost
+
+	// Sync options
+	StateFile  string // State file of the tracked states and ids (default: ./.jmapsync-state.json)
+	MaxChanges int    // maxChanges of each /changes call
+	Verify     bool   // Compare the tracked ids with a full listing after applying the changes
+
+	// Import options
+	Files       []string // .eml files, or directories of them, to import
+	Keywords    []string // Keywords to set on the imported emails, e.g. $seen
+	ReceivedAt  string   // receivedAt of the imported emails: header, server, or a date
+	Concurrency int      // Parallel uploads (0 = the server's maxConcurrentUpload)
+
+	// Accountinfo options
+	Vacation        string // Set the VacationResponse first: enable or disable (empty = only report it)
+	VacationSubject string // Subject of the automatic reply when enabling
+	VacationBody    string // Text body of the automatic reply when enabling
+
 	// TLS configuration
 	SkipVerify bool
 
@@ -42,15 +93,32 @@ const (
 	ActionTestConnect  = "testconnect"
 	ActionTestAuth     = "testauth"
 	ActionGetMailboxes = "getmailboxes"
+	ActionGetMail      = "getmail"
+	ActionSendMail     = "sendmail"
+	ActionWatch        = "watch"
+	ActionSync         = "sync"
+	ActionImport       = "import"
+	ActionAccountInfo  = "accountinfo"
 )
 
 // NewConfig creates a new Config with default values.
 func NewConfig() *Config {
 	return &Config{
-		Port:       443,
-		AuthMethod: "auto",
-		LogLevel:   "info",
-		LogFormat:  "csv",
+		Port:          443,
+		AuthMethod:    "auto",
+		Limit:         10,
+		Sort:          "receivedAt",
+		OutputDir:     ".",
+		EmailSubject:  "JMAP Test",
+		Body:          "This is a test message from jmaptool",
+		Transport:     transportAuto,
+		Ping:          30 * time.Second,
+		ProbeInterval: 10 * time.Second,
+		ProbeTimeout:  30 * time.Second,
+		MaxChanges:    100,
+		ReceivedAt:    receivedAtHeader,
+		LogLevel:      "info",
+		LogFormat:     "csv",
 	}
 }
 
@@ -85,19 +153,56 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 // Must be called after RegisterPersistentFlags.
 func BindEnvs(v *viper.Viper) {
 	bindings := map[string]string{
-		"host":        "JMAPHOST",
-		"port":        "JMAPPORT",
-		"address":     "JMAPADDRESS",
-		"ipv4":        "JMAPIPV4",
-		"ipv6":        "JMAPIPV6",
-		"username":    "JMAPUSERNAME",
-		"password":    "JMAPPASSWORD",
-		"accesstoken": "JMAPACCESSTOKEN",
-		"authmethod":  "JMAPAUTHMETHOD",
-		"skipverify":  "JMAPSKIPVERIFY",
-		"verbose":     "JMAPVERBOSE",
-		"loglevel":    "JMAPLOGLEVEL",
-		"logformat":   "JMAPLOGFORMAT",
+		"host":             "JMAPHOST",
+		"port":             "JMAPPORT",
+		"address":          "JMAPADDRESS",
+		"ipv4":             "JMAPIPV4",
+		"ipv6":             "JMAPIPV6",
+		"username":         "JMAPUSERNAME",
+		"password":         "JMAPPASSWORD",
+		"accesstoken":      "JMAPACCESSTOKEN",
+		"authmethod":       "JMAPAUTHMETHOD",
+		"skipverify":       "JMAPSKIPVERIFY",
+		"verbose":          "JMAPVERBOSE",
+		"loglevel":         "JMAPLOGLEVEL",
+		"logformat":        "JMAPLOGFORMAT",
+		"mailbox":          "JMAPMAILBOX",
+		"from":             "JMAPFROM",
+		"subject":          "JMAPSUBJECT",
+		"identity":         "JMAPIDENTITY",
+		"email-subject":    "JMAPEMAILSUBJECT",
+		"after":            "JMAPAFTER",
+		"haskeyword":       "JMAPHASKEYWORD",
+		"limit":            "JMAPLIMIT",
+		"sort":             "JMAPSORT",
+		"ascending":        "JMAPASCENDING",
+		"properties":       "JMAPPROPERTIES",
+		"export":           "JMAPEXPORT",
+		"download":         "JMAPDOWNLOAD",
+		"output-dir":       "JMAPOUTPUTDIR",
+		"to":               "JMAPTO",
+		"cc":               "JMAPCC",
+		"bcc":              "JMAPBCC",
+		"body":             "JMAPBODY",
+		"bodyhtml":         "JMAPBODYHTML",
+		"attachments":      "JMAPATTACHMENTS",
+		"wait":             "JMAPWAIT",
+		"transport":        "JMAPTRANSPORT",
+		"duration":         "JMAPDURATION",
+		"ping":             "JMAPPING",
+		"probes":           "JMAPPROBES",
+		"probe-interval":   "JMAPPROBEINTERVAL",
+		"probe-timeout":    "JMAPPROBETIMEOUT",
+		"statefile":        "JMAPSTATEFILE",
+		"max-changes":      "JMAPMAXCHANGES",
+		"verify":           "JMAPVERIFY",
+		"files":            "JMAPFILES",
+		"keywords":         "JMAPKEYWORDS",
+		"received-at":      "JMAPRECEIVEDAT",
+		"concurrency":      "JMAPCONCURRENCY",
+		"vacation":         "JMAPVACATION",
+		"vacation-subject": "JMAPVACATIONSUBJECT",
+		"vacation-body":    "JMAPVACATIONBODY",
 	}
 	for key, env := range bindings {
 		_ = v.BindEnv(key, env)
@@ -119,6 +224,62 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		authMethod = defaults.AuthMethod
 	}
 
+	limit := v.GetInt("limit")
+	if limit <= 0 {
+		limit = defaults.Limit
+	}
+
+	sort := v.GetString("sort")
+	if sort == "" {
+		sort = defaults.Sort
+	}
+
+	outputDir := v.GetString("output-dir")
+	if outputDir == "" {
+		outputDir = defaults.OutputDir
+	}
+
+	emailSubject := v.GetString("email-subject")
+	if emailSubject == "" {
+		emailSubject = defaults.EmailSubject
+	}
+
+	body := v.GetString("body")
+	if body == "" {
+		body = defaults.Body
+	}
+
+	transport := strings.ToLower(v.GetString("transport"))
+	if transport == "" {
+		transport = defaults.Transport
+	}
+
+	// --ping 0 disables pings, so only an unset ping takes the default
+	ping := defaults.Ping
+	if v.IsSet("ping") {
+		ping = v.GetDuration("ping")
+	}
+
+	probeInterval := v.GetDuration("probe-interval")
+	if probeInterval <= 0 {
+		probeInterval = defaults.ProbeInterval
+	}
+
+	probeTimeout := v.GetDuration("probe-timeout")
+	if probeTimeout <= 0 {
+		probeTimeout = defaults.ProbeTimeout
+	}
+
+	receivedAt := v.GetString("received-at")
+	if receivedAt == "" {
+		receivedAt = defaults.ReceivedAt
+	}
+
+	maxChanges := v.GetInt("max-changes")
+	if maxChanges <= 0 {
+		maxChanges = defaults.MaxChanges
+	}
+
 	logLevel := strings.ToLower(v.GetString("loglevel"))
 	if logLevel == "" {
 		logLevel = defaults.LogLevel
@@ -130,26 +291,85 @@ func ConfigFromViper(v *viper.Viper) *Config {
 	}
 
 	return &Config{
-		Host:           v.GetString("host"),
-		Port:           port,
-		ConnectAddress: v.GetString("address"),
-		IPv4Only:       v.GetBool("ipv4"),
-		IPv6Only:       v.GetBool("ipv6"),
-		Username:       v.GetString("username"),
-		Password:       v.GetString("password"),
-		AccessToken:    v.GetString("accesstoken"),
-		AuthMethod:     authMethod,
-		SkipVerify:     v.GetBool("skipverify"),
-		VerboseMode:    v.GetBool("verbose"),
-		LogLevel:       logLevel,
-		LogFormat:      logFormat,
+		Host:            v.GetString("host"),
+		Port:            port,
+		ConnectAddress:  v.GetString("address"),
+		IPv4Only:        v.GetBool("ipv4"),
+		IPv6Only:        v.GetBool("ipv6"),
+		Username:        v.GetString("username"),
+		Password:        v.GetString("password"),
+		AccessToken:     v.GetString("accesstoken"),
+		AuthMethod:      authMethod,
+		Mailbox:         v.GetString("mailbox"),
+		From:            v.GetString("from"),
+		Subject:         v.GetString("subject"),
+		After:           v.GetString("after"),
+		HasKeyword:      v.GetString("haskeyword"),
+		Limit:           limit,
+		Sort:            sort,
+		Ascending:       v.GetBool("ascending"),
+		Properties:      stringList(v, "properties"),
+		Export:          v.GetString("export"),
+		Download:        v.GetBool("download"),
+		OutputDir:       outputDir,
+		Identity:        v.GetString("identity"),
+		EmailSubject:    emailSubject,
+		To:              splitCommaSeparated(v.GetString("to")),
+		Cc:              splitCommaSeparated(v.GetString("cc")),
+		Bcc:             splitCommaSeparated(v.GetString("bcc")),
+		Body:            body,
+		BodyHTML:        v.GetString("bodyhtml"),
+		Attachments:     splitCommaSeparated(v.GetString("attachments")),
+		Wait:            v.GetDuration("wait"),
+		Transport:       transport,
+		Duration:        v.GetDuration("duration"),
+		Ping:            ping,
+		Probes:          v.GetInt("probes"),
+		ProbeInterval:   probeInterval,
+		ProbeTimeout:    probeTimeout,
+		StateFile:       v.GetString("statefile"),
+		MaxChanges:      maxChanges,
+		Verify:          v.GetBool("verify"),
+		Files:           splitCommaSeparated(v.GetString("files")),
+		Keywords:        splitCommaSeparated(v.GetString("keywords")),
+		ReceivedAt:      receivedAt,
+		Concurrency:     v.GetInt("concurrency"),
+		Vacation:        strings.ToLower(v.GetString("vacation")),
+		VacationSubject: v.GetString("vacation-subject"),
+		VacationBody:    v.GetString("vacation-body"),
+		SkipVerify:      v.GetBool("skipverify"),
+		VerboseMode:     v.GetBool("verbose"),
+		LogLevel:        logLevel,
+		LogFormat:       logFormat,
 	}
 }
 
+// stringList reads a list flag. A value coming from an environment variable
+// or config file may list several items, separated by commas or spaces.
+func stringList(v *viper.Viper, key string) []string {
+	var list []string
+	for _, item := range v.GetStringSlice(key) {
+		list = append(list, strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' })...)
+	}
+	return list
+}
+
+// splitCommaSeparated splits a comma-separated string into a trimmed,
+// non-empty list of values. Returns nil if the input is empty.
+func splitCommaSeparated(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if trimmed := strings.TrimSpace(item); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
-	validActions := []string{ActionTestConnect, ActionTestAuth, ActionGetMailboxes}
+	validActions := []string{ActionTestConnect, ActionTestAuth, ActionGetMailboxes, ActionGetMail, ActionSendMail, ActionWatch, ActionSync, ActionImport, ActionAccountInfo}
 	valid := false
 	for _, a := range validActions {
 		if config.Action == a {
@@ -196,12 +416,124 @@ func validateConfiguration(config *Config) error {
 
 	// Action-specific credential validation
 	switch config.Action {
-	case ActionTestAuth, ActionGetMailboxes:
+	case ActionTestAuth, ActionGetMailboxes, ActionGetMail, ActionSendMail, ActionWatch, ActionSync, ActionImport, ActionAccountInfo:
 		if config.AccessToken == "" && config.Password == "" {
 			return fmt.Errorf("%s requires either --password or --accesstoken", config.Action)
 		}
 	}
 
+	if config.Action == ActionGetMail {
+		if config.Limit <= 0 {
+			return fmt.Errorf("--limit must be positive")
+		}
+		if !validEmailSorts[config.Sort] {
+			return fmt.Errorf("invalid --sort: %s (valid: receivedAt, sentAt, size, from, to, subject)", config.Sort)
+		}
+		if config.After != "" {
+			if _, err := parseAfter(config.After); err != nil {
+				return err
+			}
+		}
+		if config.Download && config.OutputDir == "" {
+			return fmt.Errorf("--download requires --output-dir")
+		}
+	}
+
+	if config.Action == ActionSendMail {
+		if config.Identity != "" {
+			if err := validation.ValidateEmail(config.Identity); err != nil {
+				return fmt.Errorf("invalid sender email: %w", err)
+			}
+		}
+		if len(config.To) == 0 {
+			return fmt.Errorf("sendmail requires --to")
+		}
+		if err := validation.ValidateEmails(config.To, "recipient"); err != nil {
+			return err
+		}
+		if err := validation.ValidateEmails(config.Cc, "cc"); err != nil {
+			return err
+		}
+		if err := validation.ValidateEmails(config.Bcc, "bcc"); err != nil {
+			return err
+		}
+		if config.EmailSubject == "" {
+			return fmt.Errorf("sendmail requires --subject")
+		}
+		for i, path := range config.Attachments {
+			if err := validation.ValidateFilePath(path, fmt.Sprintf("Attachment file #%d", i+1)); err != nil {
+				return err
+			}
+		}
+		if config.Wait < 0 {
+			return fmt.Errorf("invalid --wait: %s (must not be negative)", config.Wait)
+		}
+	}
+
+	if config.Action == ActionWatch {
+		switch config.Transport {
+		case transportAuto, transportEventSource, transportWebSocket:
+		default:
+			return fmt.Errorf("invalid --transport: %s (valid: auto, eventsource, websocket)", config.Transport)
+		}
+		if config.Duration < 0 {
+			return fmt.Errorf("invalid --duration: %s (must not be negative)", config.Duration)
+		}
+		if config.Ping < 0 {
+			return fmt.Errorf("invalid --ping: %s (must not be negative)", config.Ping)
+		}
+		if config.Probes < 0 {
+			return fmt.Errorf("invalid --probes: %d (must not be negative)", config.Probes)
+		}
+		if config.ProbeInterval <= 0 || config.ProbeTimeout <= 0 {
+			return fmt.Errorf("--probe-interval and --probe-timeout must be positive")
+		}
+	}
+
+	if config.Action == ActionSync {
+		if config.MaxChanges <= 0 {
+			return fmt.Errorf("--max-changes must be positive")
+		}
+	}
+
+	if config.Action == ActionImport {
+		if len(config.Files) == 0 {
+			return fmt.Errorf("import requires --files")
+		}
+		for _, path := range config.Files {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("cannot import %s: %w", path, err)
+			}
+		}
+		if config.Mailbox == "" {
+			return fmt.Errorf("import requires --mailbox")
+		}
+		for _, keyword := range config.Keywords {
+			if !validKeyword(keyword) {
+				return fmt.Errorf("invalid keyword: %q", keyword)
+			}
+		}
+		if config.ReceivedAt != receivedAtHeader && config.ReceivedAt != receivedAtServer {
+			if _, err := parseAfter(config.ReceivedAt); err != nil {
+				return fmt.Errorf("invalid --received-at: %s (use header, server, YYYY-MM-DD or RFC 3339)", config.ReceivedAt)
+			}
+		}
+		if config.Concurrency < 0 {
+			return fmt.Errorf("invalid --concurrency: %d (must not be negative)", config.Concurrency)
+		}
+	}
+
+	if config.Action == ActionAccountInfo {
+		switch config.Vacation {
+		case "", vacationEnable, vacationDisable:
+		default:
+			return fmt.Errorf("invalid --vacation: %s (valid: enable, disable)", config.Vacation)
+		}
+		if config.Vacation != vacationEnable && (config.VacationSubject != "" || config.VacationBody != "") {
+			return fmt.Errorf("--vacation-subject and --vacation-body require --vacation enable")
+		}
+	}
+
 	// Validate log level
 	config.LogLevel = strings.ToLower(config.LogLevel)
 	validLogLevels := map[string]bool{
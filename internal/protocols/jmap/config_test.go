@@ -1,7 +1,12 @@
 package jmap
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 // newTestConfig returns a valid Config for testing with all required defaults set.
@@ -11,6 +16,9 @@ func newTestConfig() *Config {
 		Host:       "jmap.example.com",
 		Port:       443,
 		AuthMethod: "auto",
+		Limit:      10,
+		Sort:       "receivedAt",
+		OutputDir:  ".",
 		LogLevel:   "info",
 		LogFormat:  "csv",
 	}
@@ -33,6 +41,42 @@ func TestNewConfig(t *testing.T) {
 	}
 }
 
+func TestConfigFromViper_SendMailKeys(t *testing.T) {
+	t.Setenv("JMAPFROM", "alice")
+	t.Setenv("JMAPSUBJECT", "Invoice")
+	t.Setenv("JMAPIDENTITY", "sender@example.com")
+	v := viper.New()
+	BindEnvs(v)
+
+	// The getmail filters do not leak into the email sendmail sends
+	config := ConfigFromViper(v)
+	if config.From != "alice" || config.Subject != "Invoice" {
+		t.Errorf("filters = %q, %q, want alice, Invoice", config.From, config.Subject)
+	}
+	if config.Identity != "sender@example.com" || config.EmailSubject != "JMAP Test" {
+		t.Errorf("identity = %q, subject = %q, want sender@example.com and the default subject", config.Identity, config.EmailSubject)
+	}
+
+	t.Setenv("JMAPEMAILSUBJECT", "Report")
+	if config := ConfigFromViper(v); config.EmailSubject != "Report" || config.Subject != "Invoice" {
+		t.Errorf("subjects = %q, %q, want Report, Invoice", config.EmailSubject, config.Subject)
+	}
+}
+
+func TestSendMailCmd_FromSubjectFlags(t *testing.T) {
+	v := viper.New()
+	cmd := newSendMailCmd(v)
+	if err := cmd.ParseFlags([]string{"--from", "alias@example.com", "--subject", "Report"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	bindSendMailFlags(v, cmd.Flags())
+
+	config := ConfigFromViper(v)
+	if config.Identity != "alias@example.com" || config.EmailSubject != "Report" {
+		t.Errorf("identity = %q, subject = %q, want alias@example.com, Report", config.Identity, config.EmailSubject)
+	}
+}
+
 func TestValidateConfiguration_Action(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -42,6 +86,7 @@ func TestValidateConfiguration_Action(t *testing.T) {
 		{"valid testconnect", ActionTestConnect, false},
 		{"valid testauth", ActionTestAuth, false},
 		{"valid getmailboxes", ActionGetMailboxes, false},
+		{"valid getmail", ActionGetMail, false},
 		{"invalid action", "invalid", true},
 		{"empty action", "", true},
 	}
@@ -50,7 +95,7 @@ func TestValidateConfiguration_Action(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			config := newTestConfig()
 			config.Action = tt.action
-			if tt.action == ActionTestAuth || tt.action == ActionGetMailboxes {
+			if tt.action == ActionTestAuth || tt.action == ActionGetMailboxes || tt.action == ActionGetMail {
 				config.AccessToken = "test-token"
 			}
 			err := validateConfiguration(config)
@@ -147,6 +192,8 @@ func TestValidateConfiguration_Credentials(t *testing.T) {
 		{"testauth no creds", ActionTestAuth, "", "", true},
 		{"getmailboxes with token", ActionGetMailboxes, "", "token", false},
 		{"getmailboxes no creds", ActionGetMailboxes, "", "", true},
+		{"getmail with token", ActionGetMail, "", "token", false},
+		{"getmail no creds", ActionGetMail, "", "", true},
 	}
 
 	for _, tt := range tests {
@@ -163,6 +210,186 @@ func TestValidateConfiguration_Credentials(t *testing.T) {
 	}
 }
 
+func TestValidateConfiguration_GetMail(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"after date", func(c *Config) { c.After = "2026-01-15" }, false},
+		{"after RFC 3339", func(c *Config) { c.After = "2026-01-15T08:00:00+01:00" }, false},
+		{"after invalid", func(c *Config) { c.After = "15/01/2026" }, true},
+		{"sort by size", func(c *Config) { c.Sort = "size" }, false},
+		{"sort invalid", func(c *Config) { c.Sort = "color" }, true},
+		{"zero limit", func(c *Config) { c.Limit = 0 }, true},
+		{"download without output dir", func(c *Config) { c.Download = true; c.OutputDir = "" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionGetMail
+			config.AccessToken = "test-token"
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_SendMail(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"minimal", func(c *Config) {}, false},
+		{"with identity, cc and bcc", func(c *Config) {
+			c.Identity = "sender@example.com"
+			c.Cc = []string{"cc@example.com"}
+			c.Bcc = []string{"bcc@example.com"}
+		}, false},
+		{"no creds", func(c *Config) { c.AccessToken = "" }, true},
+		{"no to", func(c *Config) { c.To = nil }, true},
+		{"invalid to", func(c *Config) { c.To = []string{"not-an-address"} }, true},
+		{"invalid cc", func(c *Config) { c.Cc = []string{"not-an-address"} }, true},
+		{"invalid bcc", func(c *Config) { c.Bcc = []string{"not-an-address"} }, true},
+		{"invalid identity", func(c *Config) { c.Identity = "not-an-address" }, true},
+		{"no subject", func(c *Config) { c.EmailSubject = "" }, true},
+		{"missing attachment", func(c *Config) { c.Attachments = []string{"/nonexistent/file.pdf"} }, true},
+		{"negative wait", func(c *Config) { c.Wait = -time.Second }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionSendMail
+			config.AccessToken = "test-token"
+			config.To = []string{"rcpt@example.com"}
+			config.EmailSubject = "JMAP Test"
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Watch(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"websocket with probes", func(c *Config) {
+			c.Transport = transportWebSocket
+			c.Probes = 3
+			c.Duration = time.Minute
+		}, false},
+		{"pings disabled", func(c *Config) { c.Ping = 0 }, false},
+		{"no creds", func(c *Config) { c.AccessToken = "" }, true},
+		{"invalid transport", func(c *Config) { c.Transport = "longpoll" }, true},
+		{"negative duration", func(c *Config) { c.Duration = -time.Second }, true},
+		{"negative ping", func(c *Config) { c.Ping = -time.Second }, true},
+		{"negative probes", func(c *Config) { c.Probes = -1 }, true},
+		{"zero probe interval", func(c *Config) { c.ProbeInterval = 0 }, true},
+		{"zero probe timeout", func(c *Config) { c.ProbeTimeout = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionWatch
+			config.AccessToken = "test-token"
+			config.Transport = transportAuto
+			config.Ping = 30 * time.Second
+			config.ProbeInterval = 10 * time.Second
+			config.ProbeTimeout = 30 * time.Second
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Sync(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"state file and verify", func(c *Config) { c.StateFile = "/tmp/jmap.json"; c.Verify = true }, false},
+		{"no creds", func(c *Config) { c.AccessToken = "" }, true},
+		{"zero max changes", func(c *Config) { c.MaxChanges = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionSync
+			config.AccessToken = "test-token"
+			config.MaxChanges = 100
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Import(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "message.eml")
+	if err := os.WriteFile(file, []byte("Subject: Test\r\n\r\nBody\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"directory, keywords and date", func(c *Config) {
+			c.Files = []string{filepath.Dir(file)}
+			c.Keywords = []string{"$seen", "$flagged", "project-x"}
+			c.ReceivedAt = "2026-01-15T08:00:00Z"
+		}, false},
+		{"server receivedAt", func(c *Config) { c.ReceivedAt = receivedAtServer }, false},
+		{"no creds", func(c *Config) { c.AccessToken = "" }, true},
+		{"no files", func(c *Config) { c.Files = nil }, true},
+		{"missing file", func(c *Config) { c.Files = []string{"/nonexistent/message.eml"} }, true},
+		{"no mailbox", func(c *Config) { c.Mailbox = "" }, true},
+		{"invalid keyword", func(c *Config) { c.Keywords = []string{"bad keyword"} }, true},
+		{"invalid receivedAt", func(c *Config) { c.ReceivedAt = "yesterday" }, true},
+		{"negative concurrency", func(c *Config) { c.Concurrency = -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionImport
+			config.AccessToken = "test-token"
+			config.Files = []string{file}
+			config.Mailbox = "inbox"
+			config.ReceivedAt = receivedAtHeader
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateConfiguration_LogLevel(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -212,3 +439,36 @@ func TestValidateConfiguration_LogFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfiguration_AccountInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"enable with subject and body", func(c *Config) {
+			c.Vacation = vacationEnable
+			c.VacationSubject = "Out of office"
+			c.VacationBody = "Back on Monday"
+		}, false},
+		{"disable", func(c *Config) { c.Vacation = vacationDisable }, false},
+		{"no creds", func(c *Config) { c.AccessToken = "" }, true},
+		{"invalid vacation", func(c *Config) { c.Vacation = "on" }, true},
+		{"subject without enable", func(c *Config) { c.VacationSubject = "Out of office" }, true},
+		{"body with disable", func(c *Config) { c.Vacation = vacationDisable; c.VacationBody = "Back soon" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Action = ActionAccountInfo
+			config.AccessToken = "test-token"
+			tt.modify(config)
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
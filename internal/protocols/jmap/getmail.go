@@ -0,0 +1,326 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// validEmailSorts are the Email/query sort properties every server supports
+// (RFC 8621 Section 4.4.2).
+var validEmailSorts = map[string]bool{
+	"receivedAt": true,
+	"sentAt":     true,
+	"size":       true,
+	"from":       true,
+	"to":         true,
+	"subject":    true,
+}
+
+// defaultEmailProperties are fetched with Email/get when --properties is
+// not set.
+var defaultEmailProperties = []string{
+	"id", "blobId", "threadId", "mailboxIds", "keywords", "size",
+	"receivedAt", "messageId", "from", "to", "subject", "preview", "hasAttachment",
+}
+
+// getMail queries emails with Email/query and fetches them with Email/get
+// in the same request, then prints them, exports them to JSON and
+// downloads their raw messages as requested.
+func getMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Getting mail from %s...\n", config.Host)
+
+	// CSV columns for getmail
+	columns := []string{"Action", "Status", "Server", "Email_Id", "Received_At", "From", "Subject", "Size", "File", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(email protocol.Email, file string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		size := ""
+		if email.Id != "" {
+			size = fmt.Sprintf("%d", email.Size)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host,
+			string(email.Id), email.ReceivedAt, formatAddresses(email.From), email.Subject, size,
+			file, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	client := NewJMAPClient(config)
+
+	session, err := client.Discover(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		writeRow(protocol.Email{}, "", err)
+		return fmt.Errorf("JMAP discovery failed: %w", err)
+	}
+
+	fmt.Println("✓ Session discovered")
+	fmt.Printf("  API URL: %s\n", session.APIURL)
+
+	filter := protocol.EmailFilterCondition{
+		From:       config.From,
+		Subject:    config.Subject,
+		HasKeyword: config.HasKeyword,
+	}
+	if config.After != "" {
+		after, _ := parseAfter(config.After)
+		filter.After = after.UTC().Format(time.RFC3339)
+	}
+	if config.Mailbox != "" {
+		mailboxes, err := client.GetMailboxes(ctx)
+		if err != nil {
+			logger.LogError(slogLogger, "Failed to get mailboxes", "error", err, "host", config.Host)
+			writeRow(protocol.Email{}, "", err)
+			return fmt.Errorf("failed to get mailboxes: %w", err)
+		}
+		mailbox, err := resolveMailbox(mailboxes, config.Mailbox)
+		if err != nil {
+			writeRow(protocol.Email{}, "", err)
+			return err
+		}
+		filter.InMailbox = mailbox.Id
+		fmt.Printf("✓ Mailbox: %s (%s)\n", mailbox.Name, mailbox.Id)
+	}
+
+	if mailCap, err := session.GetMailCapability(); err == nil && len(mailCap.EmailQuerySortOptions) > 0 && !containsString(mailCap.EmailQuerySortOptions, config.Sort) {
+		fmt.Printf("⚠ Server does not list %s in emailQuerySortOptions; the query may fail\n", config.Sort)
+	}
+
+	sort := []protocol.SortOrder{{Property: config.Sort, IsAscending: config.Ascending}}
+	query, getResp, err := client.QueryEmails(ctx, filter, sort, uint32(config.Limit), emailProperties(config))
+	if err != nil {
+		logger.LogError(slogLogger, "Email query failed", "error", err, "host", config.Host)
+		writeRow(protocol.Email{}, "", err)
+		return fmt.Errorf("email query failed: %w", err)
+	}
+
+	emails, err := protocol.ParseEmailGetResponse(getResp)
+	if err != nil {
+		writeRow(protocol.Email{}, "", err)
+		return fmt.Errorf("failed to parse Email/get response: %w", err)
+	}
+
+	fmt.Printf("\nFound %d email(s), showing %d:\n", query.Total, len(emails.List))
+	if len(emails.List) > 0 {
+		fmt.Println("  Received              From                           Subject                                   Size")
+		fmt.Println("  --------              ----                           -------                                   ----")
+	}
+	for _, email := range emails.List {
+		fmt.Printf("  %-21s %-30s %-40s %6d\n",
+			formatReceivedAt(email.ReceivedAt), truncate(formatAddresses(email.From), 30), truncate(email.Subject, 40), email.Size)
+	}
+	if len(emails.NotFound) > 0 {
+		fmt.Printf("⚠ %d email(s) were removed between Email/query and Email/get\n", len(emails.NotFound))
+	}
+
+	if config.Export != "" {
+		if err := exportEmails(config.Export, getResp); err != nil {
+			writeRow(protocol.Email{}, "", err)
+			return fmt.Errorf("export failed: %w", err)
+		}
+		fmt.Printf("✓ Exported %d email(s) to %s\n", len(emails.List), config.Export)
+	}
+
+	failed := 0
+	if config.Download && len(emails.List) > 0 {
+		if err := os.MkdirAll(config.OutputDir, 0o755); err != nil {
+			writeRow(protocol.Email{}, "", err)
+			return fmt.Errorf("cannot create output directory: %w", err)
+		}
+		fmt.Printf("\nDownloading %d message(s) to %s:\n", len(emails.List), config.OutputDir)
+	}
+	for _, email := range emails.List {
+		if !config.Download {
+			writeRow(email, "", nil)
+			continue
+		}
+		path, n, err := downloadEmail(ctx, client, config.OutputDir, email)
+		if err != nil {
+			failed++
+			logger.LogError(slogLogger, "Download failed", "error", err, "email", email.Id)
+			fmt.Printf("  ✗ %s: %v\n", email.Id, err)
+			writeRow(email, "", err)
+			continue
+		}
+		fmt.Printf("  ✓ %s → %s (%d bytes)\n", email.Id, path, n)
+		writeRow(email, path, nil)
+	}
+
+	logger.LogInfo(slogLogger, "Get mail completed",
+		"host", config.Host,
+		"total", query.Total,
+		"fetched", len(emails.List),
+		"download_failures", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d message(s) could not be downloaded", failed, len(emails.List))
+	}
+
+	fmt.Println("\n✓ Get mail completed")
+	return nil
+}
+
+// parseAfter parses --after as an RFC 3339 time or a YYYY-MM-DD date (UTC
+// midnight).
+func parseAfter(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --after: %s (use YYYY-MM-DD or RFC 3339, e.g. 2026-01-02T15:04:05Z)", value)
+}
+
+// emailProperties returns the Email/get properties: --properties plus the
+// ones getmail itself needs.
+func emailProperties(config *Config) []string {
+	if len(config.Properties) == 0 {
+		return defaultEmailProperties
+	}
+	properties := append([]string(nil), config.Properties...)
+	required := []string{"id", "receivedAt", "from", "subject", "size"}
+	if config.Download {
+		required = append(required, "blobId")
+	}
+	for _, name := range required {
+		if !containsString(properties, name) {
+			properties = append(properties, name)
+		}
+	}
+	return properties
+}
+
+// resolveMailbox finds a mailbox by id, role (e.g. inbox) or name.
+func resolveMailbox(mailboxes []protocol.Mailbox, name string) (*protocol.Mailbox, error) {
+	for i, mb := range mailboxes {
+		if string(mb.Id) == name {
+			return &mailboxes[i], nil
+		}
+	}
+	for i, mb := range mailboxes {
+		if mb.Role != nil && strings.EqualFold(*mb.Role, name) {
+			return &mailboxes[i], nil
+		}
+	}
+	for i, mb := range mailboxes {
+		if strings.EqualFold(mb.Name, name) {
+			return &mailboxes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("mailbox not found: %s", name)
+}
+
+// exportEmails writes the Email/get list to path as a JSON array, with the
+// properties as the server returned them.
+func exportEmails(path string, getResp *protocol.MethodResponse) error {
+	var raw struct {
+		List []json.RawMessage `json:"list"`
+	}
+	if err := json.Unmarshal(getResp.Arguments, &raw); err != nil {
+		return err
+	}
+	if raw.List == nil {
+		raw.List = []json.RawMessage{}
+	}
+	data, err := json.MarshalIndent(raw.List, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// downloadEmail saves the raw message of email as <id>.eml in dir, through
+// a temporary file so an interrupted download leaves no partial message.
+func downloadEmail(ctx context.Context, client *JMAPClient, dir string, email protocol.Email) (string, int64, error) {
+	if email.BlobId == "" {
+		return "", 0, fmt.Errorf("email has no blobId")
+	}
+	if strings.ContainsAny(string(email.Id), `/\.`) || email.Id == "" {
+		return "", 0, fmt.Errorf("unsafe email id for a file name: %q", email.Id)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".getmail-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := client.DownloadBlob(ctx, email.BlobId, string(email.Id)+".eml", "message/rfc822", tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if email.Size > 0 && n != int64(email.Size) {
+		return "", 0, fmt.Errorf("downloaded %d bytes, Email/get reported %d", n, email.Size)
+	}
+
+	path := filepath.Join(dir, string(email.Id)+".eml")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+	return path, n, nil
+}
+
+// formatAddresses formats addresses as a comma-separated list.
+func formatAddresses(addresses []protocol.EmailAddress) string {
+	parts := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		if a.Name != "" {
+			parts = append(parts, fmt.Sprintf("%s <%s>", a.Name, a.Email))
+		} else {
+			parts = append(parts, a.Email)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatReceivedAt shortens a UTCDate for the table.
+func formatReceivedAt(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// truncate shortens s to at most n runes for table output.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,461 @@
+package jmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+	"golang.org/x/net/websocket"
+)
+
+// recordingLogger is a logger.Logger that keeps the rows in memory.
+type recordingLogger struct {
+	header []string
+	rows   [][]string
+}
+
+func (l *recordingLogger) WriteHeader(columns []string) error {
+	l.header = columns
+	return nil
+}
+
+func (l *recordingLogger) WriteRow(row []string) error {
+	l.rows = append(l.rows, row)
+	return nil
+}
+
+func (l *recordingLogger) Close() error { return nil }
+
+func (l *recordingLogger) ShouldWriteHeader() (bool, error) { return l.header == nil, nil }
+
+// column returns the value of the named column in row.
+func (l *recordingLogger) column(row []string, name string) string {
+	for i, c := range l.header {
+		if c == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// jmapMethod answers one method call with a response name and arguments.
+// Arguments of type []jmapResponse answer with several responses, as
+// EmailSubmission/set does with onSuccessUpdateEmail.
+type jmapMethod func(args json.RawMessage) (string, interface{})
+
+// jmapResponse is one of several responses to a method call.
+type jmapResponse struct {
+	name string
+	args interface{}
+}
+
+// fakeJMAPServer is an HTTPS JMAP server with account "A1". Method calls
+// are answered by the handlers in methods; blobs are served from blobs.
+// StateChange payloads sent to push are delivered over the EventSource
+// stream, or over the WebSocket when webSocket is set.
+type fakeJMAPServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	methods   map[string]jmapMethod
+	blobs     map[string]string
+	requests  []protocol.Request
+	push      chan string
+	webSocket bool
+	enabled   []string // WebSocketPushEnable messages received
+	wsOrigin  string   // Origin of the last WebSocket handshake
+	wsTLS     uint16   // TLS version of the last WebSocket handshake
+
+	// uploadLimit rejects larger uploads with a limit error, as a server
+	// enforcing a lower limit than it advertises does
+	uploadLimit int
+
+	// capabilities and accountCapabilities are advertised besides the
+	// defaults, in the session and for account A1
+	capabilities        map[string]interface{}
+	accountCapabilities map[string]interface{}
+}
+
+// startFakeJMAPServer starts a fake server and returns a config that
+// authenticates to it with a bearer token.
+func startFakeJMAPServer(t *testing.T) (*Config, *fakeJMAPServer) {
+	t.Helper()
+
+	server := &fakeJMAPServer{
+		methods: make(map[string]jmapMethod),
+		blobs:   make(map[string]string),
+		push:    make(chan string, 16),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/jmap", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		base := "https://" + r.Host
+		capabilities := map[string]interface{}{
+			protocol.CoreCapability:       map[string]interface{}{"maxSizeUpload": 1000000, "maxConcurrentUpload": 2},
+			protocol.MailCapability:       map[string]interface{}{"emailQuerySortOptions": []string{"receivedAt", "size"}},
+			protocol.SubmissionCapability: map[string]interface{}{"maxDelayedSend": 0},
+		}
+		server.mu.Lock()
+		if server.webSocket {
+			capabilities[protocol.WebSocketCapability] = map[string]interface{}{"url": "wss://" + r.Host + "/ws/", "supportsPush": true}
+		}
+		for uri, capability := range server.capabilities {
+			capabilities[uri] = capability
+		}
+		account := map[string]interface{}{"name": "tester@example.com", "isPersonal": true, "accountCapabilities": server.accountCapabilities}
+		server.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"capabilities":    capabilities,
+			"accounts":        map[string]interface{}{"A1": account},
+			"primaryAccounts": map[string]string{protocol.MailCapability: "A1"},
+			"username":        "tester@example.com",
+			"apiUrl":          base + "/api/",
+			"downloadUrl":     base + "/download/{accountId}/{blobId}/{name}?type={type}",
+			"uploadUrl":       base + "/upload/{accountId}/",
+			"eventSourceUrl":  base + "/events/",
+			"state":           "s1",
+		})
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		var raw struct {
+			Using       []string            `json:"using"`
+			MethodCalls [][]json.RawMessage `json:"methodCalls"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		request := protocol.Request{Using: raw.Using}
+		var responses []interface{}
+		for _, call := range raw.MethodCalls {
+			var name, callId string
+			_ = json.Unmarshal(call[0], &name)
+			_ = json.Unmarshal(call[2], &callId)
+			request.MethodCalls = append(request.MethodCalls, protocol.MethodCall{Name: name, Arguments: call[1], CallId: callId})
+
+			server.mu.Lock()
+			method, ok := server.methods[name]
+			server.mu.Unlock()
+			if !ok {
+				responses = append(responses, []interface{}{"error", map[string]string{"type": "unknownMethod"}, callId})
+				continue
+			}
+			respName, args := method(call[1])
+			if multi, ok := args.([]jmapResponse); ok {
+				for _, resp := range multi {
+					responses = append(responses, []interface{}{resp.name, resp.args, callId})
+				}
+				continue
+			}
+			responses = append(responses, []interface{}{respName, args, callId})
+		}
+
+		server.mu.Lock()
+		server.requests = append(server.requests, request)
+		server.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"methodResponses": responses, "sessionState": "s1"})
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/download/"), "/")
+		if len(parts) != 3 || parts[0] != "A1" {
+			http.NotFound(w, r)
+			return
+		}
+		server.mu.Lock()
+		blob, ok := server.blobs[parts[1]]
+		server.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		_, _ = w.Write([]byte(blob))
+	})
+
+	mux.HandleFunc("POST /upload/A1/", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		server.mu.Lock()
+		if server.uploadLimit > 0 && len(data) > server.uploadLimit {
+			server.mu.Unlock()
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"type": protocol.ErrorLimit, "limit": "maxSizeUpload", "status": 400, "detail": "upload too large",
+			})
+			return
+		}
+		blobId := fmt.Sprintf("U%d", len(server.blobs)+1)
+		server.blobs[blobId] = string(data)
+		server.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"accountId": "A1", "blobId": blobId, "type": r.Header.Get("Content-Type"), "size": len(data),
+		})
+	})
+
+	mux.HandleFunc("GET /events/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		flusher.Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case data := <-server.push:
+				fmt.Fprintf(w, "event: state\ndata: %s\n\n", data)
+				flusher.Flush()
+			}
+		}
+	})
+	mux.Handle("/ws/", websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer test-token" {
+				return fmt.Errorf("unauthorized")
+			}
+			config.Protocol = []string{"jmap"}
+			server.mu.Lock()
+			server.wsOrigin = r.Header.Get("Origin")
+			server.wsTLS = r.TLS.Version
+			server.mu.Unlock()
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			var enable string
+			if err := websocket.Message.Receive(ws, &enable); err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.enabled = append(server.enabled, enable)
+			server.mu.Unlock()
+
+			// The client sends nothing more; a failed read means it has gone
+			closed := make(chan struct{})
+			go func() {
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+				close(closed)
+			}()
+			for {
+				select {
+				case <-closed:
+					return
+				case data := <-server.push:
+					if err := websocket.Message.Send(ws, data); err != nil {
+						return
+					}
+				}
+			}
+		},
+	})
+
+	server.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = server.Listener.Addr().(*net.TCPAddr).Port
+	config.SkipVerify = true
+	config.AccessToken = "test-token"
+	return config, server
+}
+
+// handle sets the handler of a JMAP method.
+func (s *fakeJMAPServer) handle(name string, method jmapMethod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[name] = method
+}
+
+// lastRequest returns the last API request the server received.
+func (s *fakeJMAPServer) lastRequest(t *testing.T) protocol.Request {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("no API request received")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+const testMessage = "From: Alice <alice@example.com>\r\nSubject: Hello\r\n\r\nHi there\r\n"
+
+// serveTestEmails answers Mailbox/get, Email/query and Email/get with an
+// inbox holding two emails.
+func serveTestEmails(server *fakeJMAPServer) {
+	server.blobs["B1"] = testMessage
+	server.blobs["B2"] = testMessage + "more\r\n"
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "MB-inbox", "name": "Inbox", "role": "inbox"},
+				{"id": "MB-archive", "name": "Archive", "role": nil},
+			},
+		}
+	})
+	server.handle(protocol.MethodEmailQuery, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailQuery, map[string]interface{}{"accountId": "A1", "ids": []string{"E1", "E2"}, "total": 7}
+	})
+	server.handle(protocol.MethodEmailGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "E1", "blobId": "B1", "size": len(testMessage), "subject": "Hello", "receivedAt": "2026-02-01T10:00:00Z",
+					"from": []map[string]string{{"name": "Alice", "email": "alice@example.com"}}, "x-custom": "kept"},
+				{"id": "E2", "blobId": "B2", "size": len(testMessage) + 6, "subject": "Again", "receivedAt": "2026-02-02T10:00:00Z"},
+			},
+			"notFound": []string{},
+		}
+	})
+}
+
+func TestGetMail_QueryAndDownload(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveTestEmails(server)
+	config.Action = ActionGetMail
+	config.Mailbox = "INBOX"
+	config.From = "alice"
+	config.After = "2026-01-15"
+	config.HasKeyword = "$flagged"
+	config.Export = filepath.Join(t.TempDir(), "emails.json")
+	config.Download = true
+	config.OutputDir = t.TempDir()
+
+	csvLog := &recordingLogger{}
+	if err := getMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("getMail() error = %v", err)
+	}
+
+	request := server.lastRequest(t)
+	if len(request.MethodCalls) != 2 {
+		t.Fatalf("method calls = %d, want Email/query and Email/get in one request", len(request.MethodCalls))
+	}
+	var query struct {
+		Filter map[string]string    `json:"filter"`
+		Sort   []protocol.SortOrder `json:"sort"`
+		Limit  int                  `json:"limit"`
+	}
+	_ = json.Unmarshal(request.MethodCalls[0].Arguments.(json.RawMessage), &query)
+	wantFilter := map[string]string{"inMailbox": "MB-inbox", "from": "alice", "after": "2026-01-15T00:00:00Z", "hasKeyword": "$flagged"}
+	for key, want := range wantFilter {
+		if query.Filter[key] != want {
+			t.Errorf("filter[%s] = %q, want %q", key, query.Filter[key], want)
+		}
+	}
+	if len(query.Sort) != 1 || query.Sort[0].Property != "receivedAt" || query.Sort[0].IsAscending || query.Limit != 10 {
+		t.Errorf("sort = %+v, limit = %d; want receivedAt descending, 10", query.Sort, query.Limit)
+	}
+	if args := string(request.MethodCalls[1].Arguments.(json.RawMessage)); !strings.Contains(args, `"#ids":{"resultOf":"0","name":"Email/query","path":"/ids"}`) {
+		t.Errorf("Email/get arguments = %s, want an #ids back-reference", args)
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, "E1.eml"))
+	if err != nil || string(data) != testMessage {
+		t.Errorf("E1.eml = %q, %v; want the raw message", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "E2.eml")); err != nil {
+		t.Errorf("E2.eml not downloaded: %v", err)
+	}
+
+	exported, _ := os.ReadFile(config.Export)
+	var list []map[string]interface{}
+	if err := json.Unmarshal(exported, &list); err != nil || len(list) != 2 || list[0]["x-custom"] != "kept" {
+		t.Errorf("export = %s, want both emails with all returned properties", exported)
+	}
+
+	if len(csvLog.rows) != 2 || csvLog.column(csvLog.rows[0], "From") != "Alice <alice@example.com>" ||
+		csvLog.column(csvLog.rows[0], "File") != filepath.Join(config.OutputDir, "E1.eml") {
+		t.Errorf("rows = %v, want one row per email with its file", csvLog.rows)
+	}
+}
+
+func TestGetMail_SizeMismatch(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveTestEmails(server)
+	server.blobs["B2"] = "truncated"
+	config.Action = ActionGetMail
+	config.Download = true
+	config.OutputDir = t.TempDir()
+
+	err := getMail(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 message(s) could not be downloaded") {
+		t.Errorf("getMail() error = %v, want one failed download", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "E2.eml")); !os.IsNotExist(err) {
+		t.Errorf("E2.eml was kept after a short download")
+	}
+}
+
+func TestGetMail_MethodError(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveTestEmails(server)
+	server.handle(protocol.MethodEmailQuery, func(json.RawMessage) (string, interface{}) {
+		return "error", map[string]string{"type": "unsupportedSort", "description": "cannot sort by size"}
+	})
+	config.Action = ActionGetMail
+
+	err := getMail(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Email/query error: unsupportedSort (cannot sort by size)") {
+		t.Errorf("getMail() error = %v, want the JMAP method error", err)
+	}
+}
+
+func TestResolveMailbox(t *testing.T) {
+	inbox := "inbox"
+	mailboxes := []protocol.Mailbox{
+		{Id: "M1", Name: "INBOX", Role: &inbox},
+		{Id: "M2", Name: "Archive"},
+		{Id: "inbox", Name: "Confusing"},
+	}
+
+	tests := []struct {
+		name    string
+		want    protocol.Id
+		wantErr bool
+	}{
+		{"inbox", "inbox", false},
+		{"M1", "M1", false},
+		{"Inbox", "M1", false},
+		{"archive", "M2", false},
+		{"Sent", "", true},
+	}
+	for _, tt := range tests {
+		mb, err := resolveMailbox(mailboxes, tt.name)
+		if (err != nil) != tt.wantErr || (err == nil && mb.Id != tt.want) {
+			t.Errorf("resolveMailbox(%q) = %v, %v; want %s", tt.name, mb, err, tt.want)
+		}
+	}
+}
+
+func TestEmailProperties(t *testing.T) {
+	config := NewConfig()
+	if got := emailProperties(config); len(got) != len(defaultEmailProperties) {
+		t.Errorf("emailProperties() = %v, want the default set", got)
+	}
+
+	config.Properties = []string{"subject", "headers"}
+	config.Download = true
+	got := strings.Join(emailProperties(config), ",")
+	if want := "subject,headers,id,receivedAt,from,size,blobId"; got != want {
+		t.Errorf("emailProperties() = %s, want %s", got, want)
+	}
+}
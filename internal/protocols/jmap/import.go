@@ -0,0 +1,368 @@
+package jmap
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// --received-at values besides an explicit date
+const (
+	receivedAtHeader = "header" // the message's Date header
+	receivedAtServer = "server" // left to the server, which uses the import time
+)
+
+// Import results reported per file
+const (
+	importImported = "IMPORTED" // imported, and the downloaded blob matches the file
+	importExists   = "EXISTS"   // the server already has this message (alreadyExists)
+	importLimit    = "LIMIT"    // the file breaks a server limit
+	importMismatch = "MISMATCH" // imported, but the downloaded blob differs from the file
+	importFailed   = "FAILED"
+)
+
+// importJob is one file to import.
+type importJob struct {
+	index int
+	path  string
+	size  int64
+}
+
+// importResult is the outcome of one importJob.
+type importResult struct {
+	importJob
+	result     string
+	sha256     string
+	blobId     protocol.Id
+	emailId    protocol.Id
+	receivedAt string
+	err        error
+}
+
+// importMail uploads .eml files as blobs and imports them into a mailbox
+// with Email/import, then downloads each imported email's blob through the
+// download URL and compares its SHA-256 with the file's. Files over the
+// server's maxSizeUpload are reported without uploading, and no more than
+// maxConcurrentUpload uploads run at once.
+func importMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Importing into %s on %s...\n\n", config.Mailbox, config.Host)
+
+	// CSV columns for import
+	columns := []string{"Action", "Status", "Server", "File", "Size", "SHA256", "Mailbox", "Blob_Id", "Email_Id", "Received_At", "Result", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	var mailboxId protocol.Id
+	writeRow := func(r *importResult) {
+		status, errMsg := "SUCCESS", ""
+		if r.err != nil {
+			status, errMsg = "FAILURE", r.err.Error()
+		}
+		size := ""
+		if r.path != "" {
+			size = fmt.Sprint(r.size)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, r.path, size, r.sha256, string(mailboxId),
+			string(r.blobId), string(r.emailId), r.receivedAt, r.result, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	fail := func(err error) error {
+		writeRow(&importResult{result: importFailed, err: err})
+		return err
+	}
+
+	jobs, err := importFiles(config.Files)
+	if err != nil {
+		return fail(err)
+	}
+	if len(jobs) == 0 {
+		return fail(fmt.Errorf("no .eml files found in %s", strings.Join(config.Files, ", ")))
+	}
+
+	client := NewJMAPClient(config)
+	session, err := client.Discover(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		return fail(fmt.Errorf("JMAP discovery failed: %w", err))
+	}
+
+	var maxSize int64
+	var maxConcurrent int
+	if core, err := session.GetCoreCapability(); err == nil {
+		maxSize, maxConcurrent = core.MaxSizeUpload, core.MaxConcurrentUpload
+	}
+	fmt.Printf("✓ Server limits: maxSizeUpload %s, maxConcurrentUpload %s\n", formatLimit(maxSize), formatLimit(int64(maxConcurrent)))
+
+	workers := config.Concurrency
+	switch {
+	case workers == 0 && maxConcurrent > 0:
+		workers = maxConcurrent
+	case workers == 0:
+		workers = 1
+	case maxConcurrent > 0 && workers > maxConcurrent:
+		fmt.Printf("⚠ --concurrency %d exceeds the server's maxConcurrentUpload of %d; using %d\n", workers, maxConcurrent, maxConcurrent)
+		logger.LogWarn(slogLogger, "Concurrency over maxConcurrentUpload", "concurrency", workers, "maxConcurrentUpload", maxConcurrent)
+		workers = maxConcurrent
+	}
+
+	mailboxes, err := client.GetMailboxes(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get mailboxes: %w", err))
+	}
+	mailbox, err := resolveMailbox(mailboxes, config.Mailbox)
+	if err != nil {
+		return fail(err)
+	}
+	mailboxId = mailbox.Id
+	fmt.Printf("✓ Mailbox: %s (%s)\n", mailbox.Name, mailbox.Id)
+	fmt.Printf("  Importing %d file(s), %d upload(s) at a time\n\n", len(jobs), workers)
+
+	keywords := make(map[string]bool, len(config.Keywords))
+	for _, keyword := range config.Keywords {
+		keywords[strings.ToLower(keyword)] = true
+	}
+
+	// Oversized files are reported without uploading them
+	results := make([]*importResult, len(jobs))
+	var pending []importJob
+	for _, job := range jobs {
+		if maxSize > 0 && job.size > maxSize {
+			results[job.index] = &importResult{
+				importJob: job,
+				result:    importLimit,
+				err:       fmt.Errorf("file is %d bytes, over the server's maxSizeUpload of %d", job.size, maxSize),
+			}
+			continue
+		}
+		pending = append(pending, job)
+	}
+
+	queue := make(chan importJob)
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(pending)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				results[job.index] = importFile(ctx, client, job, mailboxId, keywords, config.ReceivedAt)
+			}
+		}()
+	}
+	for _, job := range pending {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	counts := make(map[string]int)
+	var importedBytes int64
+	for _, r := range results {
+		counts[r.result]++
+		writeRow(r)
+		name := filepath.Base(r.path)
+		switch r.result {
+		case importImported:
+			importedBytes += r.size
+			fmt.Printf("✓ %s → %s (%d bytes, SHA-256 verified)\n", name, r.emailId, r.size)
+		case importExists:
+			fmt.Printf("⚠ %s: already exists as %s\n", name, r.emailId)
+		case importLimit:
+			fmt.Printf("✗ %s: limit violation: %v\n", name, r.err)
+		default:
+			fmt.Printf("✗ %s: %v\n", name, r.err)
+		}
+		if r.err != nil {
+			logger.LogWarn(slogLogger, "Import failed", "file", r.path, "result", r.result, "error", r.err)
+		}
+	}
+
+	fmt.Println("\nSummary:")
+	fmt.Printf("  Imported and verified: %d (%d bytes)\n", counts[importImported], importedBytes)
+	fmt.Printf("  Already existed:       %d\n", counts[importExists])
+	fmt.Printf("  Limit violations:      %d\n", counts[importLimit])
+	fmt.Printf("  Checksum mismatches:   %d\n", counts[importMismatch])
+	fmt.Printf("  Failed:                %d\n", counts[importFailed])
+
+	logger.LogInfo(slogLogger, "Import completed",
+		"host", config.Host,
+		"mailbox", mailboxId,
+		"files", len(jobs),
+		"imported", counts[importImported],
+		"exists", counts[importExists],
+		"limit", counts[importLimit],
+		"mismatch", counts[importMismatch],
+		"failed", counts[importFailed])
+
+	if bad := counts[importLimit] + counts[importMismatch] + counts[importFailed]; bad > 0 {
+		return fmt.Errorf("%d of %d file(s) could not be imported and verified", bad, len(jobs))
+	}
+	return nil
+}
+
+// importFile uploads one file, imports it and verifies the round trip.
+func importFile(ctx context.Context, client *JMAPClient, job importJob, mailboxId protocol.Id, keywords map[string]bool, receivedAt string) *importResult {
+	r := &importResult{importJob: job, result: importFailed}
+
+	data, err := os.ReadFile(job.path)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	sum := sha256.Sum256(data)
+	r.sha256 = hex.EncodeToString(sum[:])
+
+	switch receivedAt {
+	case receivedAtHeader:
+		r.receivedAt = messageDate(data)
+	case receivedAtServer:
+	default:
+		t, _ := parseAfter(receivedAt)
+		r.receivedAt = t.UTC().Format(time.RFC3339)
+	}
+
+	upload, err := client.UploadBlob(ctx, bytes.NewReader(data), "message/rfc822")
+	if err != nil {
+		var limitErr *limitError
+		if errors.As(err, &limitErr) {
+			r.result = importLimit
+		}
+		r.err = err
+		return r
+	}
+	r.blobId = upload.BlobId
+	if upload.Size != int64(len(data)) {
+		r.err = fmt.Errorf("uploaded %d bytes, server stored %d", len(data), upload.Size)
+		return r
+	}
+
+	email, setErr, err := client.ImportEmail(ctx, protocol.EmailImport{
+		BlobId:     upload.BlobId,
+		MailboxIds: map[protocol.Id]bool{mailboxId: true},
+		Keywords:   keywords,
+		ReceivedAt: r.receivedAt,
+	})
+	if err != nil {
+		r.err = fmt.Errorf("Email/import failed: %w", err)
+		return r
+	}
+	if setErr != nil {
+		if setErr.Type == "alreadyExists" {
+			r.result = importExists
+			r.emailId = setErr.ExistingId
+			return r
+		}
+		r.err = fmt.Errorf("not imported: %s", formatSetError(*setErr))
+		return r
+	}
+	r.emailId = email.Id
+
+	// The imported email's blob is the raw message as the server stores it
+	blobId := email.BlobId
+	if blobId == "" {
+		blobId = upload.BlobId
+	}
+	hash := sha256.New()
+	if _, err := client.DownloadBlob(ctx, blobId, filepath.Base(job.path), "message/rfc822", hash); err != nil {
+		r.err = fmt.Errorf("download of blob %s failed: %w", blobId, err)
+		return r
+	}
+	if downloaded := hex.EncodeToString(hash.Sum(nil)); downloaded != r.sha256 {
+		r.result = importMismatch
+		r.err = fmt.Errorf("blob %s has SHA-256 %s, the file has %s", blobId, downloaded, r.sha256)
+		return r
+	}
+
+	r.result = importImported
+	return r
+}
+
+// importFiles lists the files to import: each path that is a file, and the
+// .eml files in each path that is a directory, sorted by name.
+func importFiles(paths []string) ([]importJob, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.eml"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	jobs := make([]importJob, 0, len(files))
+	for i, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import %s: %w", path, err)
+		}
+		jobs = append(jobs, importJob{index: i, path: path, size: info.Size()})
+	}
+	return jobs, nil
+}
+
+// messageDate returns the Date header of a message as a UTCDate, or "" if
+// it has none that parses, so that the server uses the import time.
+func messageDate(data []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	date, err := msg.Header.Date()
+	if err != nil {
+		return ""
+	}
+	return date.UTC().Format(time.RFC3339)
+}
+
+// validKeyword reports whether keyword is a valid email keyword: 1-255
+// printable ASCII characters other than ( ) { ] % * " \ (RFC 8621 Section
+// 4.1.1).
+func validKeyword(keyword string) bool {
+	if keyword == "" || len(keyword) > 255 {
+		return false
+	}
+	for _, r := range keyword {
+		if r < 0x21 || r > 0x7e || strings.ContainsRune(`(){]%*"\`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLimit formats a server limit, which is 0 when not advertised.
+func formatLimit(limit int64) string {
+	if limit <= 0 {
+		return "not advertised"
+	}
+	return fmt.Sprint(limit)
+}
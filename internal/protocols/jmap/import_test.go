@@ -0,0 +1,193 @@
+package jmap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// serveImport answers Mailbox/get and Email/import. An imported email gets
+// the uploaded blob as its blob, or tamperedBlob when set; a message with
+// the subject "Duplicate" already exists as E-old.
+func serveImport(server *fakeJMAPServer, tamperedBlob string) {
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "MB-inbox", "name": "Inbox", "role": "inbox"},
+				{"id": "MB-archive", "name": "Archive", "role": "archive"},
+			},
+		}
+	})
+	server.handle(protocol.MethodEmailImport, func(args json.RawMessage) (string, interface{}) {
+		var request struct {
+			Emails map[string]protocol.EmailImport `json:"emails"`
+		}
+		_ = json.Unmarshal(args, &request)
+		email := request.Emails["import"]
+
+		server.mu.Lock()
+		blob := server.blobs[string(email.BlobId)]
+		server.mu.Unlock()
+		if strings.Contains(blob, "Subject: Duplicate") {
+			return protocol.MethodEmailImport, map[string]interface{}{
+				"accountId":  "A1",
+				"notCreated": map[string]interface{}{"import": map[string]string{"type": "alreadyExists", "existingId": "E-old"}},
+			}
+		}
+		blobId := string(email.BlobId)
+		if tamperedBlob != "" {
+			blobId = tamperedBlob
+		}
+		return protocol.MethodEmailImport, map[string]interface{}{
+			"accountId": "A1",
+			"created": map[string]interface{}{"import": map[string]interface{}{
+				"id": "E-" + string(email.BlobId), "blobId": blobId, "threadId": "T1", "size": len(blob),
+			}},
+		}
+	})
+}
+
+// importCalls returns the Email/import arguments the server received.
+func importCalls(t *testing.T, server *fakeJMAPServer) []protocol.EmailImport {
+	t.Helper()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	var imports []protocol.EmailImport
+	for _, request := range server.requests {
+		for _, call := range request.MethodCalls {
+			if call.Name != protocol.MethodEmailImport {
+				continue
+			}
+			var args struct {
+				Emails map[string]protocol.EmailImport `json:"emails"`
+			}
+			if err := json.Unmarshal(call.Arguments.(json.RawMessage), &args); err != nil {
+				t.Fatal(err)
+			}
+			imports = append(imports, args.Emails["import"])
+		}
+	}
+	return imports
+}
+
+func writeEML(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportMail(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveImport(server, "")
+
+	dir := t.TempDir()
+	writeEML(t, dir, "a.eml", "Date: Thu, 15 Jan 2026 09:30:00 +0100\r\nSubject: First\r\n\r\nBody one\r\n")
+	writeEML(t, dir, "b.eml", "Subject: Second\r\n\r\nBody two\r\n")
+	writeEML(t, dir, "notes.txt", "not a message")
+	config.Action = ActionImport
+	config.Files = []string{dir}
+	config.Mailbox = "archive"
+	config.Keywords = []string{"$seen"}
+	config.ReceivedAt = receivedAtHeader
+	config.Concurrency = 8
+
+	csvLog := &recordingLogger{}
+	if err := importMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("importMail() error = %v", err)
+	}
+
+	if len(csvLog.rows) != 2 {
+		t.Fatalf("rows = %v, want one per .eml file", csvLog.rows)
+	}
+	for i, want := range []struct{ file, receivedAt string }{
+		{"a.eml", "2026-01-15T08:30:00Z"},
+		{"b.eml", ""},
+	} {
+		row := csvLog.rows[i]
+		if filepath.Base(csvLog.column(row, "File")) != want.file || csvLog.column(row, "Result") != importImported ||
+			csvLog.column(row, "Received_At") != want.receivedAt || csvLog.column(row, "Mailbox") != "MB-archive" {
+			t.Errorf("row %d = %v, want %s imported with receivedAt %q", i, row, want.file, want.receivedAt)
+		}
+	}
+
+	imports := importCalls(t, server)
+	if len(imports) != 2 {
+		t.Fatalf("Email/import calls = %d, want 2", len(imports))
+	}
+	for _, imported := range imports {
+		if !imported.MailboxIds["MB-archive"] || !imported.Keywords["$seen"] {
+			t.Errorf("import = %+v, want the archive mailbox and $seen", imported)
+		}
+	}
+}
+
+func TestImportMail_ChecksumMismatch(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveImport(server, "X1")
+	server.blobs["X1"] = "Subject: Tampered\r\n\r\n"
+
+	config.Action = ActionImport
+	config.Files = []string{writeEML(t, t.TempDir(), "a.eml", "Subject: First\r\n\r\nBody\r\n")}
+	config.Mailbox = "inbox"
+	config.ReceivedAt = receivedAtServer
+
+	csvLog := &recordingLogger{}
+	err := importMail(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 file(s) could not be imported and verified") {
+		t.Errorf("importMail() error = %v, want a failed verification", err)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Result") != importMismatch {
+		t.Errorf("rows = %v, want one MISMATCH", csvLog.rows)
+	}
+}
+
+func TestImportMail_LimitsAndDuplicates(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveImport(server, "")
+	server.uploadLimit = 200
+
+	dir := t.TempDir()
+	oversized := writeEML(t, dir, "big.eml", "Subject: Big\r\n\r\n"+strings.Repeat("x", 1000001))
+	rejected := writeEML(t, dir, "medium.eml", "Subject: Medium\r\n\r\n"+strings.Repeat("y", 300))
+	duplicate := writeEML(t, dir, "dup.eml", "Subject: Duplicate\r\n\r\nBody\r\n")
+	config.Action = ActionImport
+	config.Files = []string{oversized, rejected, duplicate}
+	config.Mailbox = "inbox"
+	config.ReceivedAt = "2026-02-01"
+
+	csvLog := &recordingLogger{}
+	err := importMail(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 file(s)") {
+		t.Errorf("importMail() error = %v, want 2 limit violations", err)
+	}
+
+	var results []string
+	for _, row := range csvLog.rows {
+		results = append(results, csvLog.column(row, "Result"))
+	}
+	if got := strings.Join(results, ","); got != "LIMIT,LIMIT,EXISTS" {
+		t.Errorf("results = %s, want LIMIT,LIMIT,EXISTS", got)
+	}
+	if msg := csvLog.column(csvLog.rows[0], "Error"); !strings.Contains(msg, "over the server's maxSizeUpload of 1000000") {
+		t.Errorf("oversized error = %q", msg)
+	}
+	if msg := csvLog.column(csvLog.rows[1], "Error"); !strings.Contains(msg, "server limit maxSizeUpload exceeded (status 400)") {
+		t.Errorf("rejected upload error = %q", msg)
+	}
+	if id := csvLog.column(csvLog.rows[2], "Email_Id"); id != "E-old" {
+		t.Errorf("duplicate email id = %q, want the existing E-old", id)
+	}
+
+	imports := importCalls(t, server)
+	if len(imports) != 1 || imports[0].ReceivedAt != "2026-02-01T00:00:00Z" {
+		t.Errorf("imports = %+v, want only the duplicate, received 2026-02-01", imports)
+	}
+}
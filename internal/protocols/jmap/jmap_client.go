@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -21,37 +22,25 @@ import (
 type JMAPClient struct {
 	config     *Config
 	httpClient *http.Client
+	tlsConfig  *tls.Config // TLS settings shared by the API and push connections
 	session    *protocol.Session
 }
 
 // NewJMAPClient creates a new JMAP client.
 func NewJMAPClient(config *Config) *JMAPClient {
+	tlsConfig := &tls.Config{
+		ServerName:         config.Host, // Use original host for SNI
+		InsecureSkipVerify: config.SkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			ServerName:         config.Host, // Use original host for SNI
-			InsecureSkipVerify: config.SkipVerify,
-		},
+		TLSClientConfig: tlsConfig,
 	}
 
 	// Override the dial address if --address was given and/or resolve to a
 	// specific address family if --ipv4/--ipv6 was requested.
 	if config.ConnectAddress != "" || config.IPv4Only || config.IPv6Only {
-		dialer := &net.Dialer{}
-		transport.DialContext = func(ctx context.Context, dialNetwork, addr string) (net.Conn, error) {
-			host, port, err := net.SplitHostPort(addr)
-			if err != nil {
-				// If no port in address, use original
-				return dialer.DialContext(ctx, dialNetwork, addr)
-			}
-			if config.ConnectAddress != "" {
-				host = config.ConnectAddress
-			}
-			host, err = network.ResolveForDial(ctx, host, config.IPv4Only, config.IPv6Only)
-			if err != nil {
-				return nil, err
-			}
-			return dialer.DialContext(ctx, dialNetwork, net.JoinHostPort(host, port))
-		}
+		transport.DialContext = dialContext(config)
 	}
 
 	return &JMAPClient{
@@ -60,6 +49,28 @@ func NewJMAPClient(config *Config) *JMAPClient {
 			Transport: transport,
 			Timeout:   30 * time.Second,
 		},
+		tlsConfig: tlsConfig,
+	}
+}
+
+// dialContext returns a dial function that connects to --address instead of
+// the requested host when set, resolved per --ipv4/--ipv6.
+func dialContext(config *Config) func(ctx context.Context, dialNetwork, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	return func(ctx context.Context, dialNetwork, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			// If no port in address, use original
+			return dialer.DialContext(ctx, dialNetwork, addr)
+		}
+		if config.ConnectAddress != "" {
+			host = config.ConnectAddress
+		}
+		host, err = network.ResolveForDial(ctx, host, config.IPv4Only, config.IPv6Only)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.DialContext(ctx, dialNetwork, net.JoinHostPort(host, port))
 	}
 }
 
@@ -215,6 +226,546 @@ func (c *JMAPClient) GetMailboxes(ctx context.Context) ([]protocol.Mailbox, erro
 	return mailboxResponse.List, nil
 }
 
+// QueryEmails runs Email/query with filter and sort and fetches the
+// matching emails with Email/get in the same request, by back-reference to
+// the query's ids. The Email/get response is returned unparsed so callers
+// keep properties that protocol.Email does not model.
+func (c *JMAPClient) QueryEmails(ctx context.Context, filter interface{}, sort []protocol.SortOrder, limit uint32, properties []string) (*protocol.QueryEmailsResponse, *protocol.MethodResponse, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request := protocol.NewEmailQueryGetRequest(accountId, filter, sort, limit, properties)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var query protocol.QueryEmailsResponse
+	if err := results.Get(request.Call(0), &query); err != nil {
+		return nil, nil, err
+	}
+	getResp, err := results.Find(request.Call(1))
+	if err != nil {
+		return nil, nil, err
+	}
+	return &query, getResp, nil
+}
+
+// DownloadBlob fetches a blob through the session's download URL and
+// writes it to w.
+func (c *JMAPClient) DownloadBlob(ctx context.Context, blobId protocol.Id, name, contentType string, w io.Writer) (int64, error) {
+	if c.session == nil {
+		return 0, fmt.Errorf("no session available")
+	}
+
+	accountId, ok := c.session.GetPrimaryMailAccountId()
+	if !ok {
+		return 0, fmt.Errorf("no primary mail account found")
+	}
+
+	url, err := c.session.BlobDownloadURL(accountId, blobId, name, contentType)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	c.addAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return io.Copy(w, resp.Body)
+}
+
+// GetIdentities fetches the sending identities with Identity/get.
+func (c *JMAPClient) GetIdentities(ctx context.Context) ([]protocol.Identity, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewIdentityGetRequest(accountId)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result protocol.GetIdentitiesResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return nil, err
+	}
+	return result.List, nil
+}
+
+// UploadBlob uploads data to the session's upload URL and returns the
+// new blob.
+func (c *JMAPClient) UploadBlob(ctx context.Context, data io.Reader, contentType string) (*protocol.UploadResponse, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.session.UploadURL == "" {
+		return nil, fmt.Errorf("session has no uploadUrl")
+	}
+	url := strings.ReplaceAll(c.session.UploadURL, "{accountId}", string(accountId))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	c.addAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		if problem, ok := protocol.ParseProblemDetails(body); ok && problem.Type == protocol.ErrorLimit {
+			return nil, &limitError{status: resp.StatusCode, limit: problem.Limit, detail: problem.Detail}
+		}
+		if resp.StatusCode == http.StatusRequestEntityTooLarge {
+			return nil, &limitError{status: resp.StatusCode, detail: strings.TrimSpace(string(body))}
+		}
+		return nil, fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var upload protocol.UploadResponse
+	if err := json.Unmarshal(body, &upload); err != nil {
+		return nil, fmt.Errorf("failed to parse upload response: %w", err)
+	}
+	return &upload, nil
+}
+
+// limitError is an upload the server rejected for exceeding one of its
+// limits, such as maxSizeUpload or maxConcurrentUpload.
+type limitError struct {
+	status int
+	limit  string // the limit's name; empty for a bare 413 response
+	detail string
+}
+
+func (e *limitError) Error() string {
+	msg := fmt.Sprintf("server limit %s exceeded (status %d)", e.limit, e.status)
+	if e.limit == "" {
+		msg = fmt.Sprintf("upload rejected as too large (status %d)", e.status)
+	}
+	if e.detail != "" {
+		msg += ": " + e.detail
+	}
+	return msg
+}
+
+// ImportEmail imports an uploaded message with Email/import and returns
+// the new email. An alreadyExists error names the existing email in its
+// ExistingId.
+func (c *JMAPClient) ImportEmail(ctx context.Context, email protocol.EmailImport) (*protocol.Email, *protocol.SetError, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request := protocol.NewEmailImportRequest(accountId, map[string]protocol.EmailImport{"import": email})
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	var result protocol.EmailImportResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return nil, nil, err
+	}
+	if setErr, ok := result.NotCreated["import"]; ok {
+		return nil, &setErr, nil
+	}
+	created, ok := result.Created["import"]
+	if !ok || created.Id == "" {
+		return nil, nil, fmt.Errorf("Email/import did not return the imported email")
+	}
+	return &created, nil, nil
+}
+
+// SubmitResult is the outcome of SubmitEmail.
+type SubmitResult struct {
+	EmailId    protocol.Id
+	Submission protocol.EmailSubmission
+
+	// Moved reports that onSuccessUpdateEmail moved the email out of
+	// Drafts; UpdateError says why it did not.
+	Moved       bool
+	UpdateError error
+}
+
+// SubmitEmail creates email in the drafts mailbox and submits it with
+// identityId in one request; see protocol.NewEmailSubmitRequest.
+func (c *JMAPClient) SubmitEmail(ctx context.Context, email protocol.EmailCreate, identityId protocol.Id, envelope *protocol.Envelope, draftsId, sentId protocol.Id) (*SubmitResult, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewEmailSubmitRequest(accountId, email, identityId, envelope, draftsId, sentId)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	set, submit := request.Call(0), request.Call(1)
+
+	var emailSet protocol.SetResponse
+	if err := results.Get(set, &emailSet); err != nil {
+		return nil, err
+	}
+	if setErr, ok := emailSet.NotCreated["draft"]; ok {
+		return nil, fmt.Errorf("email not created: %s", formatSetError(setErr))
+	}
+	var created protocol.Email
+	if err := json.Unmarshal(emailSet.Created["draft"], &created); err != nil || created.Id == "" {
+		return nil, fmt.Errorf("Email/set did not return the created email")
+	}
+	result := &SubmitResult{EmailId: created.Id}
+
+	var submissionSet protocol.SetResponse
+	if err := results.Get(submit, &submissionSet); err != nil {
+		return result, err
+	}
+	if setErr, ok := submissionSet.NotCreated["send"]; ok {
+		return result, fmt.Errorf("submission failed: %s", formatSetError(setErr))
+	}
+	if err := json.Unmarshal(submissionSet.Created["send"], &result.Submission); err != nil || result.Submission.Id == "" {
+		return result, fmt.Errorf("EmailSubmission/set did not return the created submission")
+	}
+
+	// onSuccessUpdateEmail answers with an implicit Email/set under the
+	// submission's call id
+	var update protocol.SetResponse
+	if err := results.Get(protocol.Call{Name: protocol.MethodEmailSet, CallId: submit.CallId}, &update); err == nil {
+		if setErr, ok := update.NotUpdated[created.Id]; ok {
+			result.UpdateError = fmt.Errorf("%s", formatSetError(setErr))
+		} else if _, ok := update.Updated[created.Id]; ok {
+			result.Moved = true
+		}
+	}
+	return result, nil
+}
+
+// CreateEmail creates email with Email/set and returns its id.
+func (c *JMAPClient) CreateEmail(ctx context.Context, email protocol.EmailCreate) (protocol.Id, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	b := protocol.NewBuilder(protocol.MailCapability)
+	call := b.Add(protocol.MethodEmailSet, protocol.SetRequest{AccountId: accountId, Create: map[string]interface{}{"new": email}})
+	set, err := c.setEmails(ctx, b, call)
+	if err != nil {
+		return "", err
+	}
+	if setErr, ok := set.NotCreated["new"]; ok {
+		return "", fmt.Errorf("email not created: %s", formatSetError(setErr))
+	}
+	var created protocol.Email
+	if err := json.Unmarshal(set.Created["new"], &created); err != nil || created.Id == "" {
+		return "", fmt.Errorf("Email/set did not return the created email")
+	}
+	return created.Id, nil
+}
+
+// DestroyEmails destroys emails with Email/set.
+func (c *JMAPClient) DestroyEmails(ctx context.Context, ids []protocol.Id) error {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return err
+	}
+
+	b := protocol.NewBuilder(protocol.MailCapability)
+	call := b.Add(protocol.MethodEmailSet, protocol.SetRequest{AccountId: accountId, Destroy: ids})
+	set, err := c.setEmails(ctx, b, call)
+	if err != nil {
+		return err
+	}
+	for id, setErr := range set.NotDestroyed {
+		return fmt.Errorf("email %s not destroyed: %s", id, formatSetError(setErr))
+	}
+	return nil
+}
+
+// setEmails sends the request built by b and returns the result of its
+// Email/set call.
+func (c *JMAPClient) setEmails(ctx context.Context, b *protocol.Builder, call protocol.Call) (*protocol.SetResponse, error) {
+	results, err := c.Do(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	var set protocol.SetResponse
+	if err := results.Get(call, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// GetEmailSubmission fetches a submission with EmailSubmission/get.
+func (c *JMAPClient) GetEmailSubmission(ctx context.Context, id protocol.Id) (*protocol.EmailSubmission, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewEmailSubmissionGetRequest(accountId, []protocol.Id{id})
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var result protocol.GetEmailSubmissionsResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return nil, err
+	}
+	if len(result.List) == 0 {
+		return nil, fmt.Errorf("submission %s not found", id)
+	}
+	return &result.List[0], nil
+}
+
+// Changes fetches one page of the ids that changed in dataType since
+// sinceState. A server that cannot calculate them answers with a
+// cannotCalculateChanges method error.
+func (c *JMAPClient) Changes(ctx context.Context, dataType, sinceState string, maxChanges uint32) (*protocol.ChangesResponse, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewChangesRequest(dataType, accountId, sinceState, maxChanges)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var changes protocol.ChangesResponse
+	if err := results.Get(request.Call(0), &changes); err != nil {
+		return nil, err
+	}
+	return &changes, nil
+}
+
+// ListIds returns the current state of dataType ("Mailbox" or "Email") and
+// the ids of all its objects. Emails are listed with Email/query in pages
+// of up to pageSize; the state is taken before the first page, so changes made
+// while listing are reported by a later /changes call.
+func (c *JMAPClient) ListIds(ctx context.Context, dataType string, pageSize uint32) (string, []protocol.Id, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch dataType {
+	case "Mailbox":
+		request := protocol.NewMailboxGetWithPropertiesRequest(accountId, []string{"id"})
+		results, err := c.call(ctx, request)
+		if err != nil {
+			return "", nil, err
+		}
+		var mailboxes protocol.GetMailboxesResponse
+		if err := results.Get(request.Call(0), &mailboxes); err != nil {
+			return "", nil, err
+		}
+		ids := make([]protocol.Id, 0, len(mailboxes.List))
+		for _, mailbox := range mailboxes.List {
+			ids = append(ids, mailbox.Id)
+		}
+		return mailboxes.State, ids, nil
+
+	case "Email":
+		var (
+			state  string
+			ids    []protocol.Id
+			anchor *protocol.Id
+		)
+		for {
+			request := protocol.NewEmailIdsRequest(accountId, anchor, pageSize)
+			results, err := c.call(ctx, request)
+			if err != nil {
+				return "", nil, err
+			}
+			if state == "" {
+				var emails protocol.GetEmailsResponse
+				if err := results.Get(request.Call(0), &emails); err != nil {
+					return "", nil, err
+				}
+				state = emails.State
+			}
+			var query protocol.QueryEmailsResponse
+			if err := results.Get(request.Call(1), &query); err != nil {
+				return "", nil, err
+			}
+			ids = append(ids, query.Ids...)
+			// The server may cap the page below pageSize, so only the total
+			// (or an empty page) marks the end
+			if len(query.Ids) == 0 || (query.Total > 0 && query.Position+uint32(len(query.Ids)) >= query.Total) {
+				return state, ids, nil
+			}
+			last := query.Ids[len(query.Ids)-1]
+			anchor = &last
+		}
+	}
+	return "", nil, fmt.Errorf("cannot list %s ids", dataType)
+}
+
+// GetVacationResponse fetches the account's VacationResponse.
+func (c *JMAPClient) GetVacationResponse(ctx context.Context) (*protocol.VacationResponse, error) {
+	accountId, err := c.accountFor(ctx, protocol.VacationResponseCapability)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewVacationResponseGetRequest(accountId)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var result protocol.GetVacationResponseResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return nil, err
+	}
+	if len(result.List) == 0 {
+		return nil, fmt.Errorf("no VacationResponse returned")
+	}
+	return &result.List[0], nil
+}
+
+// SetVacationResponse updates the account's VacationResponse.
+func (c *JMAPClient) SetVacationResponse(ctx context.Context, update protocol.VacationResponseUpdate) error {
+	accountId, err := c.accountFor(ctx, protocol.VacationResponseCapability)
+	if err != nil {
+		return err
+	}
+
+	request := protocol.NewVacationResponseSetRequest(accountId, update)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return err
+	}
+	var result protocol.SetResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return err
+	}
+	if setErr, ok := result.NotUpdated["singleton"]; ok {
+		return fmt.Errorf("VacationResponse not updated: %s", formatSetError(setErr))
+	}
+	if _, ok := result.Updated["singleton"]; !ok {
+		return fmt.Errorf("VacationResponse/set did not report the update")
+	}
+	return nil
+}
+
+// GetQuotas fetches all quotas of the account (RFC 9425).
+func (c *JMAPClient) GetQuotas(ctx context.Context) ([]protocol.Quota, error) {
+	accountId, err := c.accountFor(ctx, protocol.QuotaCapability)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewQuotaGetRequest(accountId)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var result protocol.GetQuotasResponse
+	if err := results.Get(request.Call(0), &result); err != nil {
+		return nil, err
+	}
+	return result.List, nil
+}
+
+// accountFor returns the primary account for a capability, discovering the
+// session first if needed.
+func (c *JMAPClient) accountFor(ctx context.Context, capability string) (protocol.Id, error) {
+	if c.session == nil {
+		if _, err := c.Discover(ctx); err != nil {
+			return "", fmt.Errorf("failed to discover session: %w", err)
+		}
+	}
+	accountId, ok := c.session.GetPrimaryAccountId(capability)
+	if !ok {
+		return "", fmt.Errorf("no primary account found for %s", capability)
+	}
+	return accountId, nil
+}
+
+// mailAccount returns the primary mail account, discovering the session
+// first if needed.
+func (c *JMAPClient) mailAccount(ctx context.Context) (protocol.Id, error) {
+	if c.session == nil {
+		if _, err := c.Discover(ctx); err != nil {
+			return "", fmt.Errorf("failed to discover session: %w", err)
+		}
+	}
+	accountId, ok := c.session.GetPrimaryMailAccountId()
+	if !ok {
+		return "", fmt.Errorf("no primary mail account found")
+	}
+	return accountId, nil
+}
+
+// formatSetError formats a /set error for display.
+func formatSetError(setErr protocol.SetError) string {
+	msg := setErr.Type
+	if setErr.Description != "" {
+		msg += " (" + setErr.Description + ")"
+	}
+	if len(setErr.Properties) > 0 {
+		msg += " properties: " + strings.Join(setErr.Properties, ", ")
+	}
+	return msg
+}
+
+// call sends request and returns its results. A method error is returned
+// by Results.Get for the call that failed, so the results of the other
+// calls stay usable.
+func (c *JMAPClient) call(ctx context.Context, request *protocol.Request) (*protocol.Results, error) {
+	response, err := c.makeAPIRequest(ctx, *request)
+	if err != nil {
+		return nil, err
+	}
+	return request.Results(response), nil
+}
+
+// Do sends the request built by b and returns its results, discovering the
+// session first if needed.
+func (c *JMAPClient) Do(ctx context.Context, b *protocol.Builder) (*protocol.Results, error) {
+	if c.session == nil {
+		if _, err := c.Discover(ctx); err != nil {
+			return nil, fmt.Errorf("failed to discover session: %w", err)
+		}
+	}
+	return c.call(ctx, b.Request())
+}
+
+// isMethodError reports whether err is a method error of the given type,
+// such as cannotCalculateChanges.
+func isMethodError(err error, errorType string) bool {
+	var methodErr *protocol.Error
+	return errors.As(err, &methodErr) && methodErr.Type == errorType
+}
+
 // makeAPIRequest sends a JMAP request to the API endpoint.
 func (c *JMAPClient) makeAPIRequest(ctx context.Context, request protocol.Request) (*protocol.Response, error) {
 	if c.session == nil {
@@ -0,0 +1,49 @@
+package jmap
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+func TestJMAPClient_Do(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxGet, map[string]interface{}{
+			"accountId": "A1", "state": "m1", "list": []map[string]string{{"id": "MB1", "name": "Inbox", "role": "inbox"}},
+		}
+	})
+	server.handle(protocol.MethodEmailQuery, func(json.RawMessage) (string, interface{}) {
+		return "error", map[string]string{"type": "anchorNotFound"}
+	})
+
+	// The Email/query error fails only its call, not the Mailbox/get beside it
+	b := protocol.NewBuilder(protocol.MailCapability)
+	mailboxes := b.Add(protocol.MethodMailboxGet, protocol.GetRequest{AccountId: "A1"})
+	query := b.AddWithRefs(protocol.MethodEmailQuery, protocol.QueryRequest{AccountId: "A1"},
+		protocol.Refs{"anchor": mailboxes.Ref("/list/0/id")})
+
+	client := NewJMAPClient(config)
+	results, err := client.Do(t.Context(), b)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	var got protocol.GetMailboxesResponse
+	if err := results.Get(mailboxes, &got); err != nil || len(got.List) != 1 || got.List[0].Id != "MB1" {
+		t.Errorf("Mailbox/get result = %+v, %v", got, err)
+	}
+	err = results.Get(query, &protocol.QueryEmailsResponse{})
+	var methodErr *protocol.Error
+	if !errors.As(err, &methodErr) || methodErr.Method != protocol.MethodEmailQuery || !isMethodError(err, "anchorNotFound") {
+		t.Errorf("Email/query error = %v, want anchorNotFound", err)
+	}
+
+	var args map[string]json.RawMessage
+	_ = json.Unmarshal(server.lastRequest(t).MethodCalls[1].Arguments.(json.RawMessage), &args)
+	if _, ok := args["#anchor"]; !ok {
+		t.Errorf("Email/query arguments = %v, want #anchor", args)
+	}
+}
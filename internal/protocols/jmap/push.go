@@ -0,0 +1,288 @@
+package jmap
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+	"golang.org/x/net/websocket"
+)
+
+// Push transports
+const (
+	transportAuto        = "auto"
+	transportEventSource = "eventsource"
+	transportWebSocket   = "websocket"
+)
+
+// pushStream is an open push channel. Next blocks until the server sends
+// something: a StateChange, or nil for a keep-alive such as an EventSource
+// ping.
+type pushStream interface {
+	Next() (*protocol.StateChange, error)
+	Close() error
+}
+
+// pushTransport picks the push transport for --transport: auto prefers a
+// WebSocket that supports push (RFC 8887) and falls back to EventSource.
+func pushTransport(session *protocol.Session, transport string) (string, error) {
+	ws, wsErr := session.GetWebSocketCapability()
+	switch transport {
+	case transportWebSocket:
+		if wsErr != nil {
+			return "", wsErr
+		}
+		if !ws.SupportsPush {
+			return "", fmt.Errorf("websocket capability does not support push")
+		}
+		return transportWebSocket, nil
+	case transportEventSource:
+		if session.EventSourceURL == "" {
+			return "", fmt.Errorf("session has no eventSourceUrl")
+		}
+		return transportEventSource, nil
+	}
+
+	if wsErr == nil && ws.SupportsPush {
+		return transportWebSocket, nil
+	}
+	if session.EventSourceURL != "" {
+		return transportEventSource, nil
+	}
+	return "", fmt.Errorf("server offers no push channel (no eventSourceUrl and no websocket push)")
+}
+
+// OpenPush opens a push channel for all data types over transport.
+func (c *JMAPClient) OpenPush(ctx context.Context, transport string, ping time.Duration) (pushStream, error) {
+	if c.session == nil {
+		return nil, fmt.Errorf("no session available")
+	}
+	if transport == transportWebSocket {
+		return c.openWebSocket(ctx)
+	}
+	return c.openEventSource(ctx, ping)
+}
+
+// eventSourceStream reads StateChange events from an EventSource
+// connection (RFC 8620 Section 7.3).
+type eventSourceStream struct {
+	body   interface{ Close() error }
+	events *protocol.EventReader
+}
+
+func (c *JMAPClient) openEventSource(ctx context.Context, ping time.Duration) (*eventSourceStream, error) {
+	url, err := c.session.EventSourceURLFor("*", "no", int(ping/time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	c.addAuth(req)
+
+	// The stream stays open indefinitely, so the client timeout must not apply
+	client := &http.Client{Transport: c.httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("EventSource connection failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("EventSource connection failed with status %d", resp.StatusCode)
+	}
+	return &eventSourceStream{body: resp.Body, events: protocol.NewEventReader(resp.Body)}, nil
+}
+
+func (s *eventSourceStream) Next() (*protocol.StateChange, error) {
+	for {
+		event, err := s.events.Next()
+		if err != nil {
+			return nil, err
+		}
+		switch event.Type {
+		case "state":
+			return protocol.ParseStateChange([]byte(event.Data))
+		case "ping":
+			return nil, nil
+		}
+	}
+}
+
+func (s *eventSourceStream) Close() error { return s.body.Close() }
+
+// webSocketStream reads StateChange pushes from a JMAP WebSocket (RFC 8887).
+type webSocketStream struct {
+	conn *websocket.Conn
+}
+
+func (c *JMAPClient) openWebSocket(ctx context.Context) (*webSocketStream, error) {
+	capability, err := c.session.GetWebSocketCapability()
+	if err != nil {
+		return nil, err
+	}
+	location, err := url.Parse(capability.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket url: %w", err)
+	}
+
+	wsConfig, err := websocket.NewConfig(capability.URL, webSocketOrigin(location))
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket url: %w", err)
+	}
+	wsConfig.Protocol = []string{"jmap"}
+	authReq, _ := http.NewRequest("GET", capability.URL, nil)
+	c.addAuth(authReq)
+	wsConfig.Header = authReq.Header
+
+	port := location.Port()
+	if port == "" {
+		port = "443"
+		if location.Scheme == "ws" {
+			port = "80"
+		}
+	}
+	conn, err := dialContext(c.config)(ctx, "tcp", net.JoinHostPort(location.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("websocket connection failed: %w", err)
+	}
+	if location.Scheme == "wss" {
+		// The push URL may name another host than the API
+		tlsConfig := c.tlsConfig.Clone()
+		tlsConfig.ServerName = location.Hostname()
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("websocket TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	ws, err := websocket.NewClient(wsConfig, conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// A null dataTypes enables push for all types
+	if err := websocket.JSON.Send(ws, protocol.NewWebSocketPushEnable(nil)); err != nil {
+		ws.Close()
+		return nil, fmt.Errorf("failed to enable push: %w", err)
+	}
+	return &webSocketStream{conn: ws}, nil
+}
+
+// webSocketOrigin returns the Origin sent in the WebSocket handshake: the
+// push host with the http scheme matching ws, or https matching wss.
+func webSocketOrigin(location *url.URL) string {
+	if location.Scheme == "ws" {
+		return "http://" + location.Host
+	}
+	return "https://" + location.Host
+}
+
+func (s *webSocketStream) Next() (*protocol.StateChange, error) {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.conn, &data); err != nil {
+			return nil, err
+		}
+		msg, err := protocol.ParseWebSocketMessage(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid websocket message: %w", err)
+		}
+		switch msg.Type {
+		case "StateChange":
+			return protocol.ParseStateChange(msg.Raw)
+		case "RequestError":
+			var problem struct {
+				Type   string `json:"type"`
+				Detail string `json:"detail"`
+			}
+			_ = json.Unmarshal(msg.Raw, &problem)
+			return nil, fmt.Errorf("websocket request error: %s %s", problem.Type, problem.Detail)
+		}
+	}
+}
+
+func (s *webSocketStream) Close() error { return s.conn.Close() }
+
+// GetStates returns the current state string of each data type.
+func (c *JMAPClient) GetStates(ctx context.Context, types ...string) (map[string]string, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewStateRequest(accountId, types...)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	states := make(map[string]string, len(types))
+	for i, dataType := range types {
+		var result struct {
+			State string `json:"state"`
+		}
+		if err := results.Get(request.Call(i), &result); err != nil {
+			return nil, err
+		}
+		states[dataType] = result.State
+	}
+	return states, nil
+}
+
+// ChangeSet is one page of changes to a data type, with the created and
+// updated objects as the server returned them.
+type ChangeSet struct {
+	*protocol.ChangesResponse
+	CreatedObjects []json.RawMessage
+	UpdatedObjects []json.RawMessage
+}
+
+// GetChanges fetches the changes to dataType since sinceState together with
+// the created and updated objects. A server that cannot calculate them
+// answers with a cannotCalculateChanges method error.
+func (c *JMAPClient) GetChanges(ctx context.Context, dataType, sinceState string, maxChanges uint32, properties []string) (*ChangeSet, error) {
+	accountId, err := c.mailAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request := protocol.NewChangesGetRequest(dataType, accountId, sinceState, maxChanges, properties)
+	results, err := c.call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	var changes protocol.ChangesResponse
+	if err := results.Get(request.Call(0), &changes); err != nil {
+		return nil, err
+	}
+
+	set := &ChangeSet{ChangesResponse: &changes}
+	for i, list := range []*[]json.RawMessage{&set.CreatedObjects, &set.UpdatedObjects} {
+		var result struct {
+			List []json.RawMessage `json:"list"`
+		}
+		if err := results.Get(request.Call(i+1), &result); err != nil {
+			return nil, err
+		}
+		*list = result.List
+	}
+	return set, nil
+}
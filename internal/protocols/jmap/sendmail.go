@@ -0,0 +1,376 @@
+package jmap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/email"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// submissionPollInterval is how often --wait polls EmailSubmission/get.
+var submissionPollInterval = 2 * time.Second
+
+// SendMail composes an email in the Drafts mailbox with Email/set and sends
+// it with EmailSubmission/set in the same request, using the identity that
+// matches --from. On success the server moves the email to Sent. It then
+// reports the submission's undoStatus and per-recipient deliveryStatus.
+func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Sending test email via %s...\n\n", config.Host)
+
+	// CSV columns for sendmail
+	columns := []string{"Action", "Status", "Server", "From", "To", "Subject", "Email_Id", "Submission_Id", "Undo_Status", "Delivery_Status", "Error"}
+	if csvLogger != nil {
+		if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+			if err := csvLogger.WriteHeader(columns); err != nil {
+				logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+			}
+		}
+	}
+
+	var (
+		fromEmail  string
+		result     *SubmitResult
+		submission *protocol.EmailSubmission
+	)
+	writeRow := func(err error) {
+		if csvLogger == nil {
+			return
+		}
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		var emailId, submissionId, undoStatus, delivery string
+		if result != nil {
+			emailId = string(result.EmailId)
+		}
+		if submission != nil {
+			submissionId = string(submission.Id)
+			undoStatus = submission.UndoStatus
+			delivery = formatDeliveryStatus(submission.DeliveryStatus)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host,
+			fromEmail, strings.Join(config.To, ";"), config.EmailSubject,
+			emailId, submissionId, undoStatus, delivery, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	client := NewJMAPClient(config)
+
+	session, err := client.Discover(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		writeRow(err)
+		return fmt.Errorf("JMAP discovery failed: %w", err)
+	}
+	fmt.Println("✓ Session discovered")
+
+	if !session.HasSubmissionCapability() {
+		err := fmt.Errorf("server does not advertise %s", protocol.SubmissionCapability)
+		writeRow(err)
+		return err
+	}
+
+	identities, err := client.GetIdentities(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "Failed to get identities", "error", err, "host", config.Host)
+		writeRow(err)
+		return fmt.Errorf("failed to get identities: %w", err)
+	}
+	identity, err := selectIdentity(identities, config.Identity)
+	if err != nil {
+		writeRow(err)
+		return err
+	}
+	fromEmail = identity.Email
+	if strings.HasPrefix(fromEmail, "*@") {
+		fromEmail = config.Identity
+	}
+	fmt.Printf("✓ Identity: %s (%s)\n", formatAddresses([]protocol.EmailAddress{{Name: identity.Name, Email: fromEmail}}), identity.Id)
+
+	mailboxes, err := client.GetMailboxes(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "Failed to get mailboxes", "error", err, "host", config.Host)
+		writeRow(err)
+		return fmt.Errorf("failed to get mailboxes: %w", err)
+	}
+	drafts := findMailboxByRole(mailboxes, "drafts")
+	if drafts == nil {
+		err := fmt.Errorf("no mailbox with the drafts role")
+		writeRow(err)
+		return err
+	}
+	var sentId protocol.Id
+	if sent := findMailboxByRole(mailboxes, "sent"); sent != nil {
+		sentId = sent.Id
+	} else {
+		fmt.Println("⚠ No mailbox with the sent role; the email will stay in Drafts")
+	}
+
+	attachments, err := uploadAttachments(ctx, client, session, config.Attachments, slogLogger)
+	if err != nil {
+		writeRow(err)
+		return err
+	}
+
+	draft := composeEmail(config, identity.Name, fromEmail, attachments)
+	envelope := &protocol.Envelope{MailFrom: protocol.Address{Email: fromEmail}}
+	for _, list := range [][]string{config.To, config.Cc, config.Bcc} {
+		for _, addr := range list {
+			envelope.RcptTo = append(envelope.RcptTo, protocol.Address{Email: addr})
+		}
+	}
+
+	logger.LogDebug(slogLogger, "Submitting email", "from", fromEmail, "to", config.To, "cc", config.Cc, "bcc", config.Bcc)
+	result, err = client.SubmitEmail(ctx, draft, identity.Id, envelope, drafts.Id, sentId)
+	if err != nil {
+		logger.LogError(slogLogger, "Email submission failed", "error", err, "host", config.Host)
+		writeRow(err)
+		return fmt.Errorf("email submission failed: %w", err)
+	}
+	fmt.Printf("✓ Email created in %s: %s\n", drafts.Name, result.EmailId)
+	fmt.Printf("✓ Submitted: %s\n", result.Submission.Id)
+	switch {
+	case result.UpdateError != nil:
+		fmt.Printf("⚠ Email was sent but not moved out of Drafts: %v\n", result.UpdateError)
+	case result.Moved && sentId != "":
+		fmt.Println("✓ Moved to Sent")
+	}
+
+	submission, err = waitForDelivery(ctx, client, &result.Submission, config.Wait)
+	if err != nil {
+		fmt.Printf("⚠ Could not fetch the submission status: %v\n", err)
+		logger.LogWarn(slogLogger, "EmailSubmission/get failed", "error", err)
+	}
+
+	fmt.Printf("\n  Undo status: %s\n", submission.UndoStatus)
+	failed := printDeliveryStatus(submission.DeliveryStatus)
+
+	logger.LogInfo(slogLogger, "Send mail completed",
+		"host", config.Host,
+		"email_id", result.EmailId,
+		"submission_id", submission.Id,
+		"undo_status", submission.UndoStatus)
+
+	if submission.UndoStatus == "canceled" {
+		err := fmt.Errorf("submission was canceled")
+		writeRow(err)
+		return err
+	}
+	if failed > 0 {
+		err := fmt.Errorf("delivery failed for %d recipient(s)", failed)
+		writeRow(err)
+		return err
+	}
+
+	writeRow(nil)
+	fmt.Println("\n✓ Email sent successfully")
+	return nil
+}
+
+// selectIdentity picks the identity for from: an exact address match, then
+// a wildcard identity (*@domain) for from's domain. An empty from selects
+// the first identity that is not a wildcard.
+func selectIdentity(identities []protocol.Identity, from string) (*protocol.Identity, error) {
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("account has no sending identities")
+	}
+
+	if from == "" {
+		for i, identity := range identities {
+			if !strings.HasPrefix(identity.Email, "*@") {
+				return &identities[i], nil
+			}
+		}
+		return nil, fmt.Errorf("all identities are wildcards (%s); set --from", identities[0].Email)
+	}
+
+	for i, identity := range identities {
+		if strings.EqualFold(identity.Email, from) {
+			return &identities[i], nil
+		}
+	}
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		for i, identity := range identities {
+			if strings.EqualFold(identity.Email, "*"+from[at:]) {
+				return &identities[i], nil
+			}
+		}
+	}
+
+	available := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		available = append(available, identity.Email)
+	}
+	return nil, fmt.Errorf("no identity matches %s (available: %s)", from, strings.Join(available, ", "))
+}
+
+// findMailboxByRole returns the mailbox with role, or nil.
+func findMailboxByRole(mailboxes []protocol.Mailbox, role string) *protocol.Mailbox {
+	for i, mb := range mailboxes {
+		if mb.Role != nil && *mb.Role == role {
+			return &mailboxes[i]
+		}
+	}
+	return nil
+}
+
+// uploadAttachments uploads each file as a blob and returns the attachment
+// body parts referring to them.
+func uploadAttachments(ctx context.Context, client *JMAPClient, session *protocol.Session, paths []string, slogLogger *slog.Logger) ([]protocol.EmailBodyPart, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	files, err := email.LoadAttachments(paths, func(path string, err error) {
+		fmt.Printf("⚠ Skipping attachment %s: %v\n", path, err)
+		logger.LogWarn(slogLogger, "Skipping attachment", "path", path, "error", err)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attachments: %w", err)
+	}
+
+	var maxSize int64
+	if core, err := session.GetCoreCapability(); err == nil {
+		maxSize = core.MaxSizeUpload
+	}
+
+	parts := make([]protocol.EmailBodyPart, 0, len(files))
+	for _, file := range files {
+		if maxSize > 0 && int64(len(file.Data)) > maxSize {
+			return nil, fmt.Errorf("attachment %s is %d bytes, over the server's maxSizeUpload of %d", file.Name, len(file.Data), maxSize)
+		}
+		upload, err := client.UploadBlob(ctx, bytes.NewReader(file.Data), file.ContentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload %s: %w", file.Name, err)
+		}
+		fmt.Printf("✓ Uploaded %s (%d bytes, blob %s)\n", file.Name, upload.Size, upload.BlobId)
+		parts = append(parts, protocol.EmailBodyPart{
+			BlobId:      upload.BlobId,
+			Type:        file.ContentType,
+			Name:        file.Name,
+			Disposition: "attachment",
+		})
+	}
+	return parts, nil
+}
+
+// composeEmail builds the Email/set object. Bcc recipients go only in the
+// submission envelope, never in the headers.
+func composeEmail(config *Config, fromName, fromEmail string, attachments []protocol.EmailBodyPart) protocol.EmailCreate {
+	draft := protocol.EmailCreate{
+		From:        []protocol.EmailAddress{{Name: fromName, Email: fromEmail}},
+		To:          emailAddresses(config.To),
+		Cc:          emailAddresses(config.Cc),
+		Subject:     config.EmailSubject,
+		Attachments: attachments,
+		BodyValues:  map[string]protocol.EmailBodyValue{},
+	}
+	if config.Body != "" || config.BodyHTML == "" {
+		draft.TextBody = []protocol.EmailBodyPart{{PartId: "text", Type: "text/plain"}}
+		draft.BodyValues["text"] = protocol.EmailBodyValue{Value: config.Body}
+	}
+	if config.BodyHTML != "" {
+		draft.HTMLBody = []protocol.EmailBodyPart{{PartId: "html", Type: "text/html"}}
+		draft.BodyValues["html"] = protocol.EmailBodyValue{Value: config.BodyHTML}
+	}
+	return draft
+}
+
+func emailAddresses(list []string) []protocol.EmailAddress {
+	var addresses []protocol.EmailAddress
+	for _, addr := range list {
+		addresses = append(addresses, protocol.EmailAddress{Email: addr})
+	}
+	return addresses
+}
+
+// waitForDelivery fetches the submission with EmailSubmission/get and, for
+// up to wait, polls it until no recipient is still queued. On error it
+// returns the submission as EmailSubmission/set created it.
+func waitForDelivery(ctx context.Context, client *JMAPClient, created *protocol.EmailSubmission, wait time.Duration) (*protocol.EmailSubmission, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		submission, err := client.GetEmailSubmission(ctx, created.Id)
+		if err != nil {
+			return created, err
+		}
+		if !hasQueued(submission.DeliveryStatus) || !time.Now().Before(deadline) {
+			return submission, nil
+		}
+		select {
+		case <-ctx.Done():
+			return submission, nil
+		case <-time.After(submissionPollInterval):
+		}
+	}
+}
+
+func hasQueued(status map[string]protocol.DeliveryStatus) bool {
+	for _, s := range status {
+		if s.Delivered == "queued" {
+			return true
+		}
+	}
+	return false
+}
+
+// printDeliveryStatus prints each recipient's delivery status and returns
+// how many were not delivered.
+func printDeliveryStatus(status map[string]protocol.DeliveryStatus) int {
+	if len(status) == 0 {
+		fmt.Println("  Delivery status: not reported by the server")
+		return 0
+	}
+
+	fmt.Println("  Delivery status:")
+	failed := 0
+	for _, rcpt := range sortedRecipients(status) {
+		s := status[rcpt]
+		mark := "✓"
+		switch s.Delivered {
+		case "no":
+			mark = "✗"
+			failed++
+		case "queued", "unknown":
+			mark = "⚠"
+		}
+		fmt.Printf("    %s %s: delivered=%s displayed=%s", mark, rcpt, s.Delivered, s.Displayed)
+		if s.SmtpReply != "" {
+			fmt.Printf(" (%s)", s.SmtpReply)
+		}
+		fmt.Println()
+	}
+	return failed
+}
+
+// formatDeliveryStatus formats the delivery status for the CSV log as
+// recipient=delivered pairs.
+func formatDeliveryStatus(status map[string]protocol.DeliveryStatus) string {
+	parts := make([]string, 0, len(status))
+	for _, rcpt := range sortedRecipients(status) {
+		parts = append(parts, rcpt+"="+status[rcpt].Delivered)
+	}
+	return strings.Join(parts, ";")
+}
+
+func sortedRecipients(status map[string]protocol.DeliveryStatus) []string {
+	recipients := make([]string, 0, len(status))
+	for rcpt := range status {
+		recipients = append(recipients, rcpt)
+	}
+	sort.Strings(recipients)
+	return recipients
+}
@@ -0,0 +1,233 @@
+package jmap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// serveSubmission answers Identity/get, Mailbox/get, Email/set,
+// EmailSubmission/set and EmailSubmission/get for a successful send.
+func serveSubmission(server *fakeJMAPServer, delivered string) {
+	server.handle(protocol.MethodIdentityGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodIdentityGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "I1", "name": "Tester", "email": "tester@example.com"},
+				{"id": "I2", "name": "Any", "email": "*@example.org"},
+			},
+		}
+	})
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{
+				{"id": "MB-inbox", "name": "Inbox", "role": "inbox"},
+				{"id": "MB-drafts", "name": "Drafts", "role": "drafts"},
+				{"id": "MB-sent", "name": "Sent", "role": "sent"},
+			},
+		}
+	})
+	server.handle(protocol.MethodEmailSet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailSet, map[string]interface{}{
+			"accountId": "A1",
+			"created":   map[string]interface{}{"draft": map[string]interface{}{"id": "E9", "blobId": "B9", "threadId": "T9"}},
+		}
+	})
+	server.handle(protocol.MethodEmailSubmissionSet, func(json.RawMessage) (string, interface{}) {
+		return "", []jmapResponse{
+			{protocol.MethodEmailSubmissionSet, map[string]interface{}{
+				"accountId": "A1",
+				"created":   map[string]interface{}{"send": map[string]interface{}{"id": "S1", "undoStatus": "pending"}},
+			}},
+			{protocol.MethodEmailSet, map[string]interface{}{
+				"accountId": "A1",
+				"updated":   map[string]interface{}{"E9": nil},
+			}},
+		}
+	})
+	server.handle(protocol.MethodEmailSubmissionGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailSubmissionGet, map[string]interface{}{
+			"accountId": "A1",
+			"list": []map[string]interface{}{{
+				"id": "S1", "emailId": "E9", "identityId": "I1", "undoStatus": "final",
+				"deliveryStatus": map[string]interface{}{
+					"rcpt@example.com": map[string]string{"smtpReply": "250 2.0.0 OK", "delivered": delivered, "displayed": "unknown"},
+				},
+			}},
+		}
+	})
+}
+
+// submitRequest returns the request that called EmailSubmission/set.
+func submitRequest(t *testing.T, server *fakeJMAPServer) protocol.Request {
+	t.Helper()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, request := range server.requests {
+		for _, call := range request.MethodCalls {
+			if call.Name == protocol.MethodEmailSubmissionSet {
+				return request
+			}
+		}
+	}
+	t.Fatal("no EmailSubmission/set request received")
+	return protocol.Request{}
+}
+
+func TestSendMail(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveSubmission(server, "yes")
+
+	attachment := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(attachment, []byte("report body"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config.Action = ActionSendMail
+	config.To = []string{"rcpt@example.com"}
+	config.Bcc = []string{"hidden@example.com"}
+	config.EmailSubject = "JMAP Test"
+	config.BodyHTML = "<p>Hello</p>"
+	config.Attachments = []string{attachment}
+
+	csvLog := &recordingLogger{}
+	if err := SendMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	request := submitRequest(t, server)
+	if len(request.MethodCalls) != 2 {
+		t.Fatalf("submit request has %d method calls, want 2", len(request.MethodCalls))
+	}
+
+	var emailSet struct {
+		Create map[string]struct {
+			MailboxIds  map[string]bool                    `json:"mailboxIds"`
+			From        []protocol.EmailAddress            `json:"from"`
+			Bcc         []protocol.EmailAddress            `json:"bcc"`
+			Attachments []protocol.EmailBodyPart           `json:"attachments"`
+			BodyValues  map[string]protocol.EmailBodyValue `json:"bodyValues"`
+		} `json:"create"`
+	}
+	if err := json.Unmarshal(request.MethodCalls[0].Arguments.(json.RawMessage), &emailSet); err != nil {
+		t.Fatal(err)
+	}
+	draft := emailSet.Create["draft"]
+	if !draft.MailboxIds["MB-drafts"] {
+		t.Errorf("mailboxIds = %v, want the drafts mailbox", draft.MailboxIds)
+	}
+	if len(draft.From) != 1 || draft.From[0].Email != "tester@example.com" {
+		t.Errorf("from = %v, want the first identity", draft.From)
+	}
+	if len(draft.Bcc) != 0 {
+		t.Errorf("bcc header = %v, want none", draft.Bcc)
+	}
+	if draft.BodyValues["html"].Value != "<p>Hello</p>" || draft.BodyValues["text"].Value != config.Body {
+		t.Errorf("bodyValues = %v", draft.BodyValues)
+	}
+	if len(draft.Attachments) != 1 || draft.Attachments[0].Name != "report.txt" {
+		t.Fatalf("attachments = %v, want report.txt", draft.Attachments)
+	}
+	if blob := server.blobs[string(draft.Attachments[0].BlobId)]; blob != "report body" {
+		t.Errorf("uploaded blob = %q, want the attachment", blob)
+	}
+
+	var submissionSet protocol.EmailSubmissionSetRequest
+	if err := json.Unmarshal(request.MethodCalls[1].Arguments.(json.RawMessage), &submissionSet); err != nil {
+		t.Fatal(err)
+	}
+	send := submissionSet.Create["send"]
+	if send.IdentityId != "I1" || send.EmailId != "#draft" {
+		t.Errorf("submission = %+v, want identity I1 and #draft", send)
+	}
+	var rcptTo []string
+	for _, rcpt := range send.Envelope.RcptTo {
+		rcptTo = append(rcptTo, rcpt.Email)
+	}
+	if got := strings.Join(rcptTo, ","); got != "rcpt@example.com,hidden@example.com" {
+		t.Errorf("envelope rcptTo = %s, want the bcc recipient included", got)
+	}
+	if update := submissionSet.OnSuccessUpdateEmail["#send"]; update["mailboxIds/MB-sent"] != true {
+		t.Errorf("onSuccessUpdateEmail = %v, want a move to Sent", update)
+	}
+
+	if len(csvLog.rows) != 1 {
+		t.Fatalf("rows = %v, want 1", csvLog.rows)
+	}
+	row := csvLog.rows[0]
+	if csvLog.column(row, "Status") != "SUCCESS" || csvLog.column(row, "Undo_Status") != "final" ||
+		csvLog.column(row, "Delivery_Status") != "rcpt@example.com=yes" {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestSendMail_DeliveryFailed(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveSubmission(server, "no")
+	config.Action = ActionSendMail
+	config.To = []string{"rcpt@example.com"}
+	config.EmailSubject = "JMAP Test"
+
+	err := SendMail(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "delivery failed for 1 recipient(s)") {
+		t.Errorf("SendMail() error = %v, want a delivery failure", err)
+	}
+}
+
+func TestSendMail_NotCreated(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	serveSubmission(server, "yes")
+	server.handle(protocol.MethodEmailSubmissionSet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailSubmissionSet, map[string]interface{}{
+			"accountId":  "A1",
+			"notCreated": map[string]interface{}{"send": map[string]string{"type": "forbiddenFrom", "description": "not your address"}},
+		}
+	})
+	config.Action = ActionSendMail
+	config.To = []string{"rcpt@example.com"}
+	config.EmailSubject = "JMAP Test"
+
+	csvLog := &recordingLogger{}
+	err := SendMail(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "forbiddenFrom (not your address)") {
+		t.Errorf("SendMail() error = %v, want forbiddenFrom", err)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Email_Id") != "E9" {
+		t.Errorf("rows = %v, want one failure naming the draft", csvLog.rows)
+	}
+}
+
+func TestSelectIdentity(t *testing.T) {
+	identities := []protocol.Identity{
+		{Id: "I1", Email: "*@example.org"},
+		{Id: "I2", Email: "Tester@example.com"},
+	}
+	tests := []struct {
+		from    string
+		want    protocol.Id
+		wantErr bool
+	}{
+		{"", "I2", false},
+		{"tester@EXAMPLE.com", "I2", false},
+		{"anyone@example.org", "I1", false},
+		{"other@example.com", "", true},
+	}
+	for _, tt := range tests {
+		identity, err := selectIdentity(identities, tt.from)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("selectIdentity(%q) error = %v, wantErr %v", tt.from, err, tt.wantErr)
+			continue
+		}
+		if identity != nil && identity.Id != tt.want {
+			t.Errorf("selectIdentity(%q) = %s, want %s", tt.from, identity.Id, tt.want)
+		}
+	}
+
+	if _, err := selectIdentity(identities[:1], ""); err == nil {
+		t.Error("selectIdentity() with only a wildcard identity and no --from expected an error")
+	}
+}
@@ -0,0 +1,413 @@
+package jmap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// syncTypes are the data types sync tracks, in order.
+var syncTypes = []string{"Mailbox", "Email"}
+
+// syncPageSize is the Email/query page size of a full listing; a variable
+// so tests can page through small mailboxes.
+var syncPageSize uint32 = 500
+
+// Sync operations reported in the CSV log
+const (
+	syncChanges = "CHANGES"   // a page of /changes
+	syncFull    = "FULL_SYNC" // the first sync of a type lists every id
+	syncResync  = "RESYNC"    // a full listing after cannotCalculateChanges
+	syncVerify  = "VERIFY"    // --verify compared the tracked ids with a full listing
+)
+
+// Change log issues. A client that follows /changes ends up with the wrong
+// set of objects when the server's change log breaks one of these rules
+// (RFC 8620 Section 5.2).
+const (
+	issueStateMismatch  = "STATE_MISMATCH"  // oldState is not the sinceState that was asked for
+	issueDuplicateId    = "DUPLICATE_ID"    // an id is in more than one list of one response
+	issueKnownCreated   = "KNOWN_CREATED"   // a created id was already tracked
+	issueUnknownUpdated = "UNKNOWN_UPDATED" // an updated id was never reported as created
+	issueMissedCreate   = "MISSED_CREATE"   // --verify: an id on the server was never reported as created
+	issueMissedDestroy  = "MISSED_DESTROY"  // --verify: a tracked id is gone but was never reported as destroyed
+)
+
+// syncIssue is one change log inconsistency found during a sync.
+type syncIssue struct {
+	Kind     string
+	DataType string
+	Id       protocol.Id
+	Detail   string
+}
+
+// syncCounts are the changes a sync found for one data type.
+type syncCounts struct {
+	created, updated, destroyed int
+}
+
+// syncRun is one run of syncMail.
+type syncRun struct {
+	client   *JMAPClient
+	config   *Config
+	writeRow func(dataType, operation string, changes *protocol.ChangesResponse, issue string, err error)
+	issues   []syncIssue
+	counts   map[string]*syncCounts
+}
+
+// syncMail brings the Mailbox and Email state strings kept in the state file
+// up to date with /changes, paging with --max-changes, and prints the ids
+// created, updated and destroyed since the last run. The first run of an
+// account lists every id instead; when the server answers
+// cannotCalculateChanges it falls back to such a full resync and reports the
+// difference. The tracked ids are checked against each page of changes, and
+// with --verify against a full listing; inconsistencies in the server's
+// change log fail the action once the state is saved.
+func syncMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	stateFile := config.StateFile
+	if stateFile == "" {
+		stateFile = syncStateName
+	}
+	fmt.Printf("Syncing changes on %s...\n", config.Host)
+
+	columns := []string{"Action", "Status", "Server", "Account", "Data_Type", "Operation", "Old_State", "New_State", "Created", "Updated", "Destroyed", "Issue", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	var accountId protocol.Id
+	run := &syncRun{config: config, counts: make(map[string]*syncCounts)}
+	run.writeRow = func(dataType, operation string, changes *protocol.ChangesResponse, issue string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		var oldState, newState, created, updated, destroyed string
+		if changes != nil {
+			oldState, newState = changes.OldState, changes.NewState
+			created = fmt.Sprint(len(changes.Created))
+			updated = fmt.Sprint(len(changes.Updated))
+			destroyed = fmt.Sprint(len(changes.Destroyed))
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, string(accountId), dataType, operation,
+			oldState, newState, created, updated, destroyed, issue, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	fail := func(err error) error {
+		run.writeRow("", "", nil, "", err)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0o755); err != nil {
+		return fail(fmt.Errorf("cannot create state directory: %w", err))
+	}
+	state, err := loadSyncState(stateFile)
+	if err != nil {
+		return fail(err)
+	}
+
+	run.client = NewJMAPClient(config)
+	if _, err := run.client.Discover(ctx); err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		return fail(fmt.Errorf("JMAP discovery failed: %w", err))
+	}
+	accountId, err = run.client.mailAccount(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	account := state.account(syncAccountKey(config, accountId))
+	firstSync := account.LastSync.IsZero()
+	if firstSync {
+		fmt.Printf("✓ State: first sync for account %s\n", accountId)
+	} else {
+		fmt.Printf("✓ State: account %s, last sync %s\n", accountId, account.LastSync.Local().Format(time.RFC1123))
+	}
+
+	var syncErr error
+	for _, dataType := range syncTypes {
+		run.counts[dataType] = &syncCounts{}
+		tracked := account.track(dataType)
+
+		fmt.Println()
+		if tracked.state == "" {
+			fmt.Printf("%s: no state yet, listing all ids\n", dataType)
+			err = run.full(ctx, dataType, tracked, syncFull)
+		} else {
+			fmt.Printf("%s: changes since state %s\n", dataType, tracked.state)
+			err = run.delta(ctx, dataType, tracked, true)
+			if isMethodError(err, "cannotCalculateChanges") {
+				fmt.Printf("⚠ Server cannot calculate %s changes since %s; falling back to a full resync\n", dataType, tracked.state)
+				logger.LogWarn(slogLogger, "cannotCalculateChanges, resyncing", "type", dataType, "state", tracked.state)
+				err = run.full(ctx, dataType, tracked, syncResync)
+			}
+		}
+		if err == nil && config.Verify {
+			err = run.verify(ctx, dataType, tracked)
+		}
+
+		// The tracked ids always match the tracked state, even after a
+		// failure part way through, so they are saved either way
+		account.store(dataType, tracked)
+		if err != nil {
+			fmt.Printf("✗ %s sync failed: %v\n", dataType, err)
+			logger.LogError(slogLogger, "Sync failed", "type", dataType, "error", err)
+			run.writeRow(dataType, "", nil, "", err)
+			if syncErr == nil {
+				syncErr = fmt.Errorf("%s sync failed: %w", dataType, err)
+			}
+		}
+	}
+
+	if syncErr == nil {
+		account.LastSync = time.Now()
+	}
+	if err := state.save(stateFile); err != nil {
+		return fail(fmt.Errorf("cannot save state file: %w", err))
+	}
+
+	if len(run.issues) > 0 {
+		fmt.Printf("\n⚠ Change log inconsistencies: %d issue(s)\n", len(run.issues))
+		for _, issue := range run.issues {
+			fmt.Printf("  %-15s %s %s: %s\n", issue.Kind, issue.DataType, issue.Id, issue.Detail)
+		}
+	}
+
+	fmt.Println("\nSummary:")
+	total := 0
+	for _, dataType := range syncTypes {
+		counts := run.counts[dataType]
+		stored := account.Types[dataType]
+		fmt.Printf("  %-8s %d created, %d updated, %d destroyed (%d tracked, state %s)\n",
+			dataType+":", counts.created, counts.updated, counts.destroyed, len(stored.Ids), stored.State)
+		total += counts.created + counts.updated + counts.destroyed
+	}
+
+	logger.LogInfo(slogLogger, "Sync completed",
+		"host", config.Host,
+		"account", accountId,
+		"changes", total,
+		"issues", len(run.issues))
+
+	switch {
+	case firstSync:
+		fmt.Printf("\n✓ First sync; state saved to %s\n", stateFile)
+	case total == 0:
+		fmt.Printf("\n✓ No changes since the last sync; state: %s\n", stateFile)
+	default:
+		fmt.Printf("\n✓ %d change(s) since the last sync; state: %s\n", total, stateFile)
+	}
+
+	switch {
+	case syncErr != nil:
+		return syncErr
+	case len(run.issues) > 0:
+		return fmt.Errorf("change log inconsistent: %d issue(s)", len(run.issues))
+	}
+	return nil
+}
+
+// delta applies the changes to dataType since tracked.state, a page of
+// --max-changes at a time, printing the ids. With strict, each page is
+// checked against the tracked ids; the catch-up after a full listing is not
+// strict, as changes made while listing may already be in it.
+func (r *syncRun) delta(ctx context.Context, dataType string, tracked *trackedType, strict bool) error {
+	for {
+		changes, err := r.client.Changes(ctx, dataType, tracked.state, uint32(r.config.MaxChanges))
+		if err != nil {
+			return err
+		}
+		empty := len(changes.Created)+len(changes.Updated)+len(changes.Destroyed) == 0
+		if strict {
+			r.check(dataType, tracked, changes)
+		}
+
+		for _, id := range changes.Created {
+			tracked.ids[id] = true
+		}
+		for _, id := range changes.Updated {
+			tracked.ids[id] = true
+		}
+		for _, id := range changes.Destroyed {
+			delete(tracked.ids, id)
+		}
+		counts := r.counts[dataType]
+		counts.created += len(changes.Created)
+		counts.updated += len(changes.Updated)
+		counts.destroyed += len(changes.Destroyed)
+
+		if !empty || strict {
+			printChangedIds(changes)
+			r.writeRow(dataType, syncChanges, changes, "", nil)
+		}
+
+		if changes.HasMoreChanges && changes.NewState == tracked.state {
+			return fmt.Errorf("%s/changes reported more changes without moving on from state %s", dataType, tracked.state)
+		}
+		tracked.state = changes.NewState
+		if !changes.HasMoreChanges {
+			return nil
+		}
+	}
+}
+
+// check records the issues in a page of changes.
+func (r *syncRun) check(dataType string, tracked *trackedType, changes *protocol.ChangesResponse) {
+	if changes.OldState != tracked.state {
+		r.issue(issueStateMismatch, dataType, "", fmt.Sprintf("asked for changes since %s, got oldState %s", tracked.state, changes.OldState))
+	}
+
+	seen := make(map[protocol.Id]string)
+	for _, list := range []struct {
+		name string
+		ids  []protocol.Id
+	}{{"created", changes.Created}, {"updated", changes.Updated}, {"destroyed", changes.Destroyed}} {
+		for _, id := range list.ids {
+			if other, ok := seen[id]; ok {
+				r.issue(issueDuplicateId, dataType, id, fmt.Sprintf("listed as both %s and %s", other, list.name))
+			}
+			seen[id] = list.name
+		}
+	}
+
+	for _, id := range changes.Created {
+		if tracked.ids[id] {
+			r.issue(issueKnownCreated, dataType, id, "created, but it already existed")
+		}
+	}
+	// A destroyed id that was never seen is allowed: an object created and
+	// destroyed since sinceState may be reported as destroyed only
+	for _, id := range changes.Updated {
+		if !tracked.ids[id] && !containsId(changes.Created, id) {
+			r.issue(issueUnknownUpdated, dataType, id, "updated, but never reported as created")
+		}
+	}
+}
+
+// full replaces the tracked ids with a full listing and then catches up on
+// the changes made while listing. A resync reports the difference to the
+// ids tracked before as created and destroyed.
+func (r *syncRun) full(ctx context.Context, dataType string, tracked *trackedType, operation string) error {
+	state, ids, err := r.client.ListIds(ctx, dataType, syncPageSize)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[protocol.Id]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	result := &protocol.ChangesResponse{OldState: tracked.state, NewState: state}
+	if operation == syncResync {
+		result.Created = missingIds(listed, tracked.ids)
+		result.Destroyed = missingIds(tracked.ids, listed)
+		counts := r.counts[dataType]
+		counts.created += len(result.Created)
+		counts.destroyed += len(result.Destroyed)
+		printChangedIds(result)
+	}
+
+	tracked.state = state
+	tracked.ids = listed
+	fmt.Printf("  %d %s id(s) at state %s\n", len(listed), dataType, state)
+	r.writeRow(dataType, operation, result, "", nil)
+
+	return r.delta(ctx, dataType, tracked, false)
+}
+
+// verify compares the tracked ids with a full listing. If the account
+// changed since the changes were applied, those changes are applied first
+// and the listing retried once.
+func (r *syncRun) verify(ctx context.Context, dataType string, tracked *trackedType) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		state, ids, err := r.client.ListIds(ctx, dataType, syncPageSize)
+		if err != nil {
+			return err
+		}
+		if state != tracked.state {
+			if err := r.delta(ctx, dataType, tracked, true); err != nil {
+				return err
+			}
+			if state != tracked.state {
+				continue
+			}
+		}
+
+		listed := make(map[protocol.Id]bool, len(ids))
+		for _, id := range ids {
+			listed[id] = true
+		}
+		missedCreate := missingIds(listed, tracked.ids)
+		missedDestroy := missingIds(tracked.ids, listed)
+		for _, id := range missedCreate {
+			r.issue(issueMissedCreate, dataType, id, "on the server, but never reported as created")
+		}
+		for _, id := range missedDestroy {
+			r.issue(issueMissedDestroy, dataType, id, "gone from the server, but never reported as destroyed")
+		}
+		if len(missedCreate)+len(missedDestroy) == 0 {
+			fmt.Printf("  ✓ Verified: %d tracked %s id(s) match the server\n", len(tracked.ids), dataType)
+		}
+		r.writeRow(dataType, syncVerify, &protocol.ChangesResponse{
+			OldState: tracked.state, NewState: state, Created: missedCreate, Destroyed: missedDestroy,
+		}, "", nil)
+		return nil
+	}
+
+	fmt.Printf("  ⚠ %s kept changing during verification; skipped\n", dataType)
+	return nil
+}
+
+// issue records a change log issue and logs it to the CSV file.
+func (r *syncRun) issue(kind, dataType string, id protocol.Id, detail string) {
+	r.issues = append(r.issues, syncIssue{Kind: kind, DataType: dataType, Id: id, Detail: detail})
+	r.writeRow(dataType, "", nil, kind, fmt.Errorf("%s %s", id, detail))
+}
+
+// printChangedIds prints the ids of a page of changes.
+func printChangedIds(changes *protocol.ChangesResponse) {
+	fmt.Printf("  %s → %s: %d created, %d updated, %d destroyed\n",
+		changes.OldState, changes.NewState, len(changes.Created), len(changes.Updated), len(changes.Destroyed))
+	for _, id := range changes.Created {
+		fmt.Printf("    + %s\n", id)
+	}
+	for _, id := range changes.Updated {
+		fmt.Printf("    ~ %s\n", id)
+	}
+	for _, id := range changes.Destroyed {
+		fmt.Printf("    - %s\n", id)
+	}
+}
+
+// missingIds returns the ids of set that are not in other, in order.
+func missingIds(set, other map[protocol.Id]bool) []protocol.Id {
+	missing := make(map[protocol.Id]bool)
+	for id := range set {
+		if !other[id] {
+			missing[id] = true
+		}
+	}
+	return sortedIds(missing)
+}
+
+func containsId(ids []protocol.Id, id protocol.Id) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
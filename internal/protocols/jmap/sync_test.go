@@ -0,0 +1,212 @@
+package jmap
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// fakeChanges is a /changes response of the fake server.
+type fakeChanges struct {
+	newState                    string
+	hasMore                     bool
+	created, updated, destroyed []string
+}
+
+// serveSync answers the listing and /changes calls of a sync: Mailbox/get
+// and Email/query list mailboxes and emails at mailboxState and emailState,
+// and Mailbox/changes and Email/changes answer from changes, keyed by data
+// type and sinceState. A sinceState without an entry is answered with
+// cannotCalculateChanges.
+func serveSync(server *fakeJMAPServer, mailboxState string, mailboxes []string, emailState string, emails []string, changes map[string]fakeChanges) {
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		list := []map[string]string{}
+		for _, id := range mailboxes {
+			list = append(list, map[string]string{"id": id})
+		}
+		return protocol.MethodMailboxGet, map[string]interface{}{"accountId": "A1", "state": mailboxState, "list": list}
+	})
+	server.handle(protocol.MethodEmailGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailGet, map[string]interface{}{"accountId": "A1", "state": emailState, "list": []interface{}{}}
+	})
+	server.handle(protocol.MethodEmailQuery, func(args json.RawMessage) (string, interface{}) {
+		var query protocol.QueryRequest
+		_ = json.Unmarshal(args, &query)
+		start := 0
+		if query.Anchor != nil {
+			for i, id := range emails {
+				if id == string(*query.Anchor) {
+					start = i + int(query.AnchorOffset)
+				}
+			}
+		}
+		end := min(start+int(*query.Limit), len(emails))
+		return protocol.MethodEmailQuery, map[string]interface{}{
+			"accountId": "A1", "queryState": "q1", "position": start, "total": len(emails), "ids": emails[start:end],
+		}
+	})
+	for _, dataType := range []string{"Mailbox", "Email"} {
+		name := dataType + "/changes"
+		server.handle(name, func(args json.RawMessage) (string, interface{}) {
+			var request protocol.ChangesRequest
+			_ = json.Unmarshal(args, &request)
+			page, ok := changes[dataType+" "+request.SinceState]
+			if !ok {
+				return "error", map[string]string{"type": "cannotCalculateChanges"}
+			}
+			return name, map[string]interface{}{
+				"accountId": "A1", "oldState": request.SinceState, "newState": page.newState, "hasMoreChanges": page.hasMore,
+				"created": append([]string{}, page.created...), "updated": append([]string{}, page.updated...),
+				"destroyed": append([]string{}, page.destroyed...),
+			}
+		})
+	}
+}
+
+// loadTracked returns the tracked state and ids of dataType in a state file.
+func loadTracked(t *testing.T, path string, config *Config, dataType string) *syncType {
+	t.Helper()
+	state, err := loadSyncState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracked := state.account(syncAccountKey(config, "A1")).Types[dataType]
+	if tracked == nil {
+		t.Fatalf("no %s state saved", dataType)
+	}
+	return tracked
+}
+
+func TestSyncMail_FirstSyncThenChanges(t *testing.T) {
+	defer func(size uint32) { syncPageSize = size }(syncPageSize)
+	syncPageSize = 2
+
+	config, server := startFakeJMAPServer(t)
+	config.Action = ActionSync
+	config.StateFile = filepath.Join(t.TempDir(), "state", "sync.json")
+	config.MaxChanges = 2
+	serveSync(server, "m1", []string{"MB1", "MB2"}, "e1", []string{"E1", "E2", "E3"}, map[string]fakeChanges{
+		"Mailbox m1": {newState: "m1"},
+		"Email e1":   {newState: "e2", hasMore: true, created: []string{"E4"}, updated: []string{"E1"}},
+		"Email e2":   {newState: "e3", destroyed: []string{"E2"}},
+	})
+
+	// The first sync lists the ids in pages and catches up to e3
+	if err := syncMail(t.Context(), config, &recordingLogger{}, nil); err != nil {
+		t.Fatalf("first syncMail() error = %v", err)
+	}
+	email := loadTracked(t, config.StateFile, config, "Email")
+	if email.State != "e3" || len(email.Ids) != 3 {
+		t.Errorf("Email after first sync = %+v, want state e3 with E1, E3, E4", email)
+	}
+
+	// A later sync reports the changes since e3 page by page
+	serveSync(server, "m1", []string{"MB1", "MB2"}, "e5", []string{"E1", "E3", "E4", "E5"}, map[string]fakeChanges{
+		"Mailbox m1": {newState: "m1"},
+		"Email e3":   {newState: "e4", hasMore: true, created: []string{"E5"}},
+		"Email e4":   {newState: "e5", updated: []string{"E4"}},
+	})
+	csvLog := &recordingLogger{}
+	if err := syncMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("second syncMail() error = %v", err)
+	}
+	email = loadTracked(t, config.StateFile, config, "Email")
+	if email.State != "e5" || len(email.Ids) != 4 || email.Ids[3] != "E5" {
+		t.Errorf("Email after second sync = %+v, want state e5 with E5 added", email)
+	}
+
+	var pages []string
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Data_Type") == "Email" && csvLog.column(row, "Operation") == syncChanges {
+			pages = append(pages, csvLog.column(row, "Old_State")+">"+csvLog.column(row, "New_State")+
+				" +"+csvLog.column(row, "Created")+" ~"+csvLog.column(row, "Updated"))
+		}
+	}
+	if got := strings.Join(pages, ", "); got != "e3>e4 +1 ~0, e4>e5 +0 ~1" {
+		t.Errorf("Email change pages = %s", got)
+	}
+}
+
+func TestSyncMail_CannotCalculateChanges(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	config.Action = ActionSync
+	config.StateFile = filepath.Join(t.TempDir(), "sync.json")
+
+	state, _ := loadSyncState(config.StateFile)
+	account := state.account(syncAccountKey(config, "A1"))
+	account.LastSync = time.Now().Add(-time.Hour)
+	account.Types["Mailbox"] = &syncType{State: "m1", Ids: []protocol.Id{"MB1"}}
+	account.Types["Email"] = &syncType{State: "expired", Ids: []protocol.Id{"E1", "E9"}}
+	if err := state.save(config.StateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	serveSync(server, "m1", []string{"MB1"}, "e7", []string{"E1", "E2"}, map[string]fakeChanges{
+		"Mailbox m1": {newState: "m1"},
+		"Email e7":   {newState: "e7"},
+	})
+	csvLog := &recordingLogger{}
+	if err := syncMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("syncMail() error = %v", err)
+	}
+
+	email := loadTracked(t, config.StateFile, config, "Email")
+	if email.State != "e7" || len(email.Ids) != 2 || email.Ids[1] != "E2" {
+		t.Errorf("Email after resync = %+v, want state e7 with E1 and E2", email)
+	}
+	var resync []string
+	for _, row := range csvLog.rows {
+		if csvLog.column(row, "Operation") == syncResync {
+			resync = row
+		}
+	}
+	if resync == nil || csvLog.column(resync, "Created") != "1" || csvLog.column(resync, "Destroyed") != "1" ||
+		csvLog.column(resync, "Old_State") != "expired" {
+		t.Errorf("resync row = %v, want E2 created and E9 destroyed", resync)
+	}
+}
+
+func TestSyncMail_Inconsistent(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	config.Action = ActionSync
+	config.StateFile = filepath.Join(t.TempDir(), "sync.json")
+	config.Verify = true
+
+	state, _ := loadSyncState(config.StateFile)
+	account := state.account(syncAccountKey(config, "A1"))
+	account.LastSync = time.Now().Add(-time.Hour)
+	account.Types["Mailbox"] = &syncType{State: "m1", Ids: []protocol.Id{"MB1"}}
+	account.Types["Email"] = &syncType{State: "e1", Ids: []protocol.Id{"E1", "E2"}}
+	if err := state.save(config.StateFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// E2 is gone without being destroyed, and E8 is updated without having
+	// been created
+	serveSync(server, "m1", []string{"MB1"}, "e2", []string{"E1", "E8"}, map[string]fakeChanges{
+		"Mailbox m1": {newState: "m1"},
+		"Email e1":   {newState: "e2", updated: []string{"E8"}},
+	})
+	csvLog := &recordingLogger{}
+	err := syncMail(t.Context(), config, csvLog, nil)
+	if err == nil || !strings.Contains(err.Error(), "change log inconsistent: 2 issue(s)") {
+		t.Fatalf("syncMail() error = %v, want 2 issues", err)
+	}
+
+	var issues []string
+	for _, row := range csvLog.rows {
+		if issue := csvLog.column(row, "Issue"); issue != "" {
+			issues = append(issues, issue)
+		}
+	}
+	if got := strings.Join(issues, ","); got != issueUnknownUpdated+","+issueMissedDestroy {
+		t.Errorf("issues = %s", got)
+	}
+	if email := loadTracked(t, config.StateFile, config, "Email"); email.State != "e2" {
+		t.Errorf("Email state = %s, want e2 saved despite the issues", email.State)
+	}
+}
@@ -0,0 +1,148 @@
+package jmap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// syncStateName is the default state file, in the working directory.
+const syncStateName = ".jmapsync-state.json"
+
+// syncStateVersion is written to the state file so later formats can
+// migrate older files.
+const syncStateVersion = 1
+
+// syncState is the sync state file: the state strings and ids of each data
+// type, per account.
+type syncState struct {
+	Version  int                     `json:"version"`
+	Accounts map[string]*syncAccount `json:"accounts"`
+}
+
+// syncAccount holds what sync tracks for one account.
+type syncAccount struct {
+	LastSync time.Time            `json:"last_sync"`
+	Types    map[string]*syncType `json:"types"` // keyed by data type, e.g. "Email"
+}
+
+// syncType is the state string of a data type and the ids of its objects
+// at that state.
+type syncType struct {
+	State string        `json:"state"`
+	Ids   []protocol.Id `json:"ids"`
+}
+
+// trackedType is a syncType while a sync applies changes to it.
+type trackedType struct {
+	state string
+	ids   map[protocol.Id]bool
+}
+
+// loadSyncState reads the state file at path, or returns an empty state if
+// it does not exist yet. A file that cannot be parsed is an error rather
+// than a fresh start, which would silently lose the change history.
+func loadSyncState(path string) (*syncState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &syncState{Version: syncStateVersion, Accounts: make(map[string]*syncAccount)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state file: %w", err)
+	}
+
+	state := &syncState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("state file %s is corrupt (move it away to start over): %w", path, err)
+	}
+	if state.Version > syncStateVersion {
+		return nil, fmt.Errorf("state file %s has version %d; this build supports %d", path, state.Version, syncStateVersion)
+	}
+	if state.Accounts == nil {
+		state.Accounts = make(map[string]*syncAccount)
+	}
+	return state, nil
+}
+
+// account returns the state of the named account, creating it if needed.
+func (s *syncState) account(key string) *syncAccount {
+	account, ok := s.Accounts[key]
+	if !ok || account == nil {
+		account = &syncAccount{}
+		s.Accounts[key] = account
+	}
+	if account.Types == nil {
+		account.Types = make(map[string]*syncType)
+	}
+	return account
+}
+
+// track returns the tracked state of dataType; the state is empty if the
+// type was never synced.
+func (a *syncAccount) track(dataType string) *trackedType {
+	tracked := &trackedType{ids: make(map[protocol.Id]bool)}
+	if stored := a.Types[dataType]; stored != nil {
+		tracked.state = stored.State
+		for _, id := range stored.Ids {
+			tracked.ids[id] = true
+		}
+	}
+	return tracked
+}
+
+// store records tracked as the state of dataType.
+func (a *syncAccount) store(dataType string, tracked *trackedType) {
+	a.Types[dataType] = &syncType{State: tracked.state, Ids: sortedIds(tracked.ids)}
+}
+
+// save writes the state to path atomically: to a temporary file in the
+// same directory, synced, then renamed over the old file. An interrupted
+// sync leaves either the previous or the new state, never a partial file.
+func (s *syncState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".jmapsync-state-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// syncAccountKey identifies an account in the state file. JMAP account ids
+// are only unique per server.
+func syncAccountKey(config *Config, accountId protocol.Id) string {
+	return fmt.Sprintf("%s:%d/%s", strings.ToLower(config.Host), config.Port, accountId)
+}
+
+// sortedIds returns the ids of a set in order.
+func sortedIds(set map[protocol.Id]bool) []protocol.Id {
+	ids := make([]protocol.Id, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -1,5 +1,7 @@
 package jmap
 
+import "fmt"
+
 // maskUsername masks a username for safe logging.
 // Shows first 2 and last 2 characters with **** in between.
 func maskUsername(username string) string {
@@ -39,3 +41,17 @@ func maskAccessToken(token string) string {
 	}
 	return token[:8] + "..." + token[len(token)-4:]
 }
+
+// formatBytes formats a byte count using binary units (KB, MB, GB).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
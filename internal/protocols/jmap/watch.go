@@ -0,0 +1,501 @@
+package jmap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// watchProperties are fetched for the created and updated objects of the
+// data types watch resolves; other types are only reported.
+var watchProperties = map[string][]string{
+	"Email":   {"id", "subject", "from", "receivedAt", "mailboxIds"},
+	"Mailbox": {"id", "name", "role", "totalEmails", "unreadEmails"},
+}
+
+// watchTypes are the resolved data types, in display order.
+var watchTypes = []string{"Mailbox", "Email"}
+
+// watchMaxChanges is the maxChanges of each /changes call.
+const watchMaxChanges = 100
+
+// Timing of the push connection and the probes; variables so tests can
+// shorten them.
+var (
+	reconnectDelay  = 2 * time.Second
+	firstProbeDelay = time.Second
+)
+
+// pushNotice is what the push reader hands to the watch loop.
+type pushNotice struct {
+	change    *protocol.StateChange
+	received  time.Time
+	connected bool  // the push channel was (re)opened
+	err       error // the push channel failed or was lost
+}
+
+// probe is a probe email waiting for its push notification.
+type probe struct {
+	number int
+	sent   time.Time
+}
+
+// watchStats are reported at the end of a watch.
+type watchStats struct {
+	events      int
+	disconnects int
+	probesSent  int
+	latencies   []time.Duration
+	lost        int
+}
+
+// watch subscribes to push notifications (EventSource, or a WebSocket per
+// RFC 8887 when the server supports it), prints each StateChange and
+// resolves it with Email/changes and Mailbox/changes into new, updated and
+// destroyed items. With --probes it creates probe emails in the inbox and
+// measures how long each takes to be pushed.
+func watch(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Watching %s for push notifications...\n\n", config.Host)
+
+	// CSV columns for watch
+	columns := []string{"Action", "Status", "Server", "Transport", "Event", "Data_Type", "Old_State", "New_State", "Created", "Updated", "Destroyed", "Latency_Ms", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	transport := config.Transport
+	writeRow := func(event, dataType string, changes *protocol.ChangesResponse, latency time.Duration, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		var oldState, newState, created, updated, destroyed, latencyMs string
+		if changes != nil {
+			oldState, newState = changes.OldState, changes.NewState
+			created = fmt.Sprint(len(changes.Created))
+			updated = fmt.Sprint(len(changes.Updated))
+			destroyed = fmt.Sprint(len(changes.Destroyed))
+		}
+		if latency > 0 {
+			latencyMs = fmt.Sprint(latency.Milliseconds())
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, transport, event, dataType,
+			oldState, newState, created, updated, destroyed, latencyMs, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+
+	client := NewJMAPClient(config)
+
+	session, err := client.Discover(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "JMAP discovery failed",
+			"error", err,
+			"host", config.Host)
+		writeRow("DISCOVER", "", nil, 0, err)
+		return fmt.Errorf("JMAP discovery failed: %w", err)
+	}
+	fmt.Println("✓ Session discovered")
+
+	transport, err = pushTransport(session, config.Transport)
+	if err != nil {
+		writeRow("CONNECT", "", nil, 0, err)
+		return err
+	}
+	if transport == transportWebSocket {
+		ws, _ := session.GetWebSocketCapability()
+		fmt.Printf("  Transport: WebSocket (%s)\n", ws.URL)
+	} else {
+		fmt.Printf("  Transport: EventSource (%s)\n", session.EventSourceURL)
+	}
+
+	accountId, _ := session.GetPrimaryMailAccountId()
+	states, err := client.GetStates(ctx, watchTypes...)
+	if err != nil {
+		logger.LogError(slogLogger, "Failed to get states", "error", err, "host", config.Host)
+		writeRow("STATE", "", nil, 0, err)
+		return fmt.Errorf("failed to get states: %w", err)
+	}
+	for _, dataType := range watchTypes {
+		fmt.Printf("  %s state: %s\n", dataType, states[dataType])
+	}
+
+	var inboxId protocol.Id
+	if config.Probes > 0 {
+		mailboxes, err := client.GetMailboxes(ctx)
+		if err != nil {
+			writeRow("PROBE", "", nil, 0, err)
+			return fmt.Errorf("failed to get mailboxes: %w", err)
+		}
+		inbox := findMailboxByRole(mailboxes, "inbox")
+		if inbox == nil {
+			err := fmt.Errorf("no mailbox with the inbox role for the probes")
+			writeRow("PROBE", "", nil, 0, err)
+			return err
+		}
+		inboxId = inbox.Id
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	notices := make(chan pushNotice, 16)
+	go readPush(watchCtx, client, transport, config.Ping, notices)
+
+	var timeout <-chan time.Time
+	if config.Duration > 0 {
+		timeout = time.After(config.Duration)
+		fmt.Printf("\nWatching for %s (Ctrl+C to stop)...\n", config.Duration)
+	} else if config.Probes == 0 {
+		fmt.Println("\nWatching until interrupted (Ctrl+C to stop)...")
+	}
+
+	var (
+		stats       watchStats
+		connected   bool
+		lastErr     error
+		nextProbe   <-chan time.Time
+		pending     = make(map[protocol.Id]*probe)
+		probeIds    []protocol.Id
+		expiryCheck = time.NewTicker(time.Second)
+	)
+	defer expiryCheck.Stop()
+
+	// resolve brings states[dataType] up to newState, printing the changes.
+	resolve := func(dataType, newState string, received time.Time) {
+		if _, ok := watchProperties[dataType]; !ok {
+			fmt.Printf("  %s → %s (not resolved)\n", dataType, newState)
+			writeRow("STATE_CHANGE", dataType, &protocol.ChangesResponse{NewState: newState}, 0, nil)
+			return
+		}
+		if states[dataType] == newState {
+			fmt.Printf("  %s already at %s\n", dataType, newState)
+			return
+		}
+		for {
+			set, err := client.GetChanges(ctx, dataType, states[dataType], watchMaxChanges, watchProperties[dataType])
+			if isMethodError(err, "cannotCalculateChanges") {
+				fmt.Printf("  ⚠ Server cannot calculate %s changes since %s; resynchronizing\n", dataType, states[dataType])
+				writeRow("STATE_CHANGE", dataType, nil, 0, err)
+				if fresh, err := client.GetStates(ctx, dataType); err == nil {
+					states[dataType] = fresh[dataType]
+				}
+				return
+			}
+			if err != nil {
+				fmt.Printf("  ✗ %s/changes failed: %v\n", dataType, err)
+				logger.LogError(slogLogger, "Changes failed", "type", dataType, "error", err)
+				writeRow("STATE_CHANGE", dataType, nil, 0, err)
+				return
+			}
+			printChanges(dataType, set)
+			states[dataType] = set.NewState
+
+			var latency time.Duration
+			if dataType == "Email" {
+				for _, id := range set.Created {
+					if p, ok := pending[id]; ok {
+						latency = received.Sub(p.sent)
+						stats.latencies = append(stats.latencies, latency)
+						delete(pending, id)
+						fmt.Printf("  ⏱ Probe #%d pushed after %d ms\n", p.number, latency.Milliseconds())
+						writeRow("PROBE", dataType, nil, latency, nil)
+					}
+				}
+			}
+			writeRow("STATE_CHANGE", dataType, set.ChangesResponse, latency, nil)
+			if !set.HasMoreChanges {
+				return
+			}
+		}
+	}
+
+loop:
+	for {
+		if config.Duration == 0 && config.Probes > 0 && stats.probesSent == config.Probes && len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nInterrupted")
+			break loop
+
+		case <-timeout:
+			break loop
+
+		case notice := <-notices:
+			switch {
+			case notice.err != nil:
+				lastErr = notice.err
+				if connected {
+					stats.disconnects++
+				}
+				fmt.Printf("✗ Push channel failed: %v (retrying in %s)\n", notice.err, reconnectDelay)
+				logger.LogWarn(slogLogger, "Push channel failed", "error", notice.err, "transport", transport)
+				writeRow("DISCONNECT", "", nil, 0, notice.err)
+
+			case notice.connected:
+				if !connected {
+					connected = true
+					fmt.Printf("✓ Push channel open (%s)\n", transport)
+					if config.Probes > 0 {
+						nextProbe = time.After(firstProbeDelay)
+					}
+					continue
+				}
+				// Catch up on what changed while disconnected
+				fmt.Printf("[%s] ✓ Push channel reopened; checking for missed changes\n", notice.received.Format("15:04:05"))
+				for _, dataType := range watchTypes {
+					resolve(dataType, "", notice.received)
+				}
+
+			default:
+				newStates, ok := notice.change.Changed[accountId]
+				if !ok {
+					logger.LogDebug(slogLogger, "StateChange for another account", "changed", notice.change.Changed)
+					continue
+				}
+				stats.events++
+				fmt.Printf("[%s] StateChange: %s\n", notice.received.Format("15:04:05"), formatStates(newStates))
+				for _, dataType := range sortedTypes(newStates) {
+					resolve(dataType, newStates[dataType], notice.received)
+				}
+			}
+
+		case <-nextProbe:
+			stats.probesSent++
+			number := stats.probesSent
+			email := protocol.EmailCreate{
+				MailboxIds: map[protocol.Id]bool{inboxId: true},
+				Keywords:   map[string]bool{"$seen": true},
+				From:       []protocol.EmailAddress{{Name: "gomailtest", Email: session.Username}},
+				Subject:    fmt.Sprintf("gomailtest push probe #%d %s", number, time.Now().UTC().Format(time.RFC3339)),
+				TextBody:   []protocol.EmailBodyPart{{PartId: "text", Type: "text/plain"}},
+				BodyValues: map[string]protocol.EmailBodyValue{"text": {Value: "JMAP push latency probe from jmaptool; deleted when the watch ends.\n"}},
+			}
+			sent := time.Now()
+			id, err := client.CreateEmail(ctx, email)
+			if err != nil {
+				fmt.Printf("✗ Probe #%d could not be created: %v\n", number, err)
+				writeRow("PROBE", "Email", nil, 0, err)
+				stats.lost++
+			} else {
+				fmt.Printf("[%s] → Probe #%d created: %s\n", sent.Format("15:04:05"), number, id)
+				pending[id] = &probe{number: number, sent: sent}
+				probeIds = append(probeIds, id)
+			}
+			nextProbe = nil
+			if stats.probesSent < config.Probes {
+				nextProbe = time.After(config.ProbeInterval)
+			}
+
+		case now := <-expiryCheck.C:
+			for id, p := range pending {
+				if now.Sub(p.sent) > config.ProbeTimeout {
+					stats.lost++
+					delete(pending, id)
+					err := fmt.Errorf("no push within %s", config.ProbeTimeout)
+					fmt.Printf("✗ Probe #%d: %v\n", p.number, err)
+					writeRow("PROBE", "Email", nil, 0, err)
+				}
+			}
+		}
+	}
+	stopWatch()
+
+	// Probes still waiting when the watch ends count as lost
+	stats.lost += len(pending)
+
+	if len(probeIds) > 0 {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := client.DestroyEmails(cleanupCtx, probeIds); err != nil {
+			fmt.Printf("⚠ Could not delete the probe emails: %v\n", err)
+			logger.LogWarn(slogLogger, "Probe cleanup failed", "error", err)
+		} else {
+			fmt.Printf("✓ Deleted %d probe email(s)\n", len(probeIds))
+		}
+		cancel()
+	}
+
+	printWatchSummary(transport, &stats, config.Probes > 0)
+
+	logger.LogInfo(slogLogger, "Watch completed",
+		"host", config.Host,
+		"transport", transport,
+		"events", stats.events,
+		"disconnects", stats.disconnects,
+		"probes", stats.probesSent,
+		"lost", stats.lost)
+
+	if !connected {
+		return fmt.Errorf("push channel could not be opened: %w", lastErr)
+	}
+	if stats.lost > 0 {
+		return fmt.Errorf("%d of %d probe(s) got no push notification within %s", stats.lost, stats.probesSent, config.ProbeTimeout)
+	}
+	return nil
+}
+
+// readPush keeps a push channel open until ctx is done, reopening it after
+// reconnectDelay when it fails, and hands everything it reads to notices.
+// An EventSource stream that stays silent for twice the ping interval is
+// considered dead.
+func readPush(ctx context.Context, client *JMAPClient, transport string, ping time.Duration, notices chan<- pushNotice) {
+	send := func(notice pushNotice) bool {
+		select {
+		case notices <- notice:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for ctx.Err() == nil {
+		stream, err := client.OpenPush(ctx, transport, ping)
+		if err == nil {
+			if !send(pushNotice{connected: true, received: time.Now()}) {
+				stream.Close()
+				return
+			}
+			err = readStream(ctx, stream, transport, ping, send)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !send(pushNotice{err: err, received: time.Now()}) {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// readStream reads stream until it fails or ctx is done.
+func readStream(ctx context.Context, stream pushStream, transport string, ping time.Duration, send func(pushNotice) bool) error {
+	defer stream.Close()
+	stop := context.AfterFunc(ctx, func() { stream.Close() })
+	defer stop()
+
+	var watchdog *time.Timer
+	if transport == transportEventSource && ping > 0 {
+		watchdog = time.AfterFunc(2*ping, func() { stream.Close() })
+		defer watchdog.Stop()
+	}
+
+	for {
+		change, err := stream.Next()
+		if err != nil {
+			if watchdog != nil && !watchdog.Stop() {
+				return fmt.Errorf("no event or ping for %s", 2*ping)
+			}
+			return err
+		}
+		if watchdog != nil {
+			watchdog.Reset(2 * ping)
+		}
+		if change != nil && !send(pushNotice{change: change, received: time.Now()}) {
+			return nil
+		}
+	}
+}
+
+// printChanges prints the created, updated and destroyed items of a page of
+// changes.
+func printChanges(dataType string, set *ChangeSet) {
+	kind := strings.ToLower(dataType)
+	if len(set.Created)+len(set.Updated)+len(set.Destroyed) == 0 {
+		fmt.Printf("  %s %s → %s: no changes\n", dataType, set.OldState, set.NewState)
+		return
+	}
+	fmt.Printf("  %s %s → %s:\n", dataType, set.OldState, set.NewState)
+	for _, raw := range set.CreatedObjects {
+		fmt.Printf("    + new %s %s\n", kind, describeObject(dataType, raw))
+	}
+	for _, raw := range set.UpdatedObjects {
+		fmt.Printf("    ~ updated %s %s\n", kind, describeObject(dataType, raw))
+	}
+	for _, id := range set.Destroyed {
+		fmt.Printf("    - destroyed %s %s\n", kind, id)
+	}
+}
+
+// describeObject formats an Email or Mailbox for printChanges.
+func describeObject(dataType string, raw json.RawMessage) string {
+	switch dataType {
+	case "Email":
+		var email protocol.Email
+		if err := json.Unmarshal(raw, &email); err == nil {
+			desc := fmt.Sprintf("%s: %q", email.Id, email.Subject)
+			if from := formatAddresses(email.From); from != "" {
+				desc += " from " + from
+			}
+			return desc
+		}
+	case "Mailbox":
+		var mailbox protocol.Mailbox
+		if err := json.Unmarshal(raw, &mailbox); err == nil {
+			desc := fmt.Sprintf("%s: %s", mailbox.Id, mailbox.Name)
+			if mailbox.Role != nil {
+				desc += " (" + *mailbox.Role + ")"
+			}
+			return desc + fmt.Sprintf(", %d email(s), %d unread", mailbox.TotalEmails, mailbox.UnreadEmails)
+		}
+	}
+	return string(raw)
+}
+
+// formatStates formats a StateChange's types and states.
+func formatStates(states map[string]string) string {
+	parts := make([]string, 0, len(states))
+	for _, dataType := range sortedTypes(states) {
+		parts = append(parts, dataType+"="+states[dataType])
+	}
+	return strings.Join(parts, " ")
+}
+
+func sortedTypes(states map[string]string) []string {
+	types := make([]string, 0, len(states))
+	for dataType := range states {
+		types = append(types, dataType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// printWatchSummary prints the event, reconnect and probe latency counts.
+func printWatchSummary(transport string, stats *watchStats, probes bool) {
+	fmt.Println("\nSummary:")
+	fmt.Printf("  Transport:          %s\n", transport)
+	fmt.Printf("  StateChange events: %d\n", stats.events)
+	fmt.Printf("  Disconnects:        %d\n", stats.disconnects)
+	if !probes {
+		return
+	}
+	fmt.Printf("  Probes:             %d sent, %d pushed, %d lost\n", stats.probesSent, len(stats.latencies), stats.lost)
+	if len(stats.latencies) == 0 {
+		return
+	}
+	minimum, maximum, total := stats.latencies[0], stats.latencies[0], time.Duration(0)
+	for _, latency := range stats.latencies {
+		minimum = min(minimum, latency)
+		maximum = max(maximum, latency)
+		total += latency
+	}
+	average := total / time.Duration(len(stats.latencies))
+	fmt.Printf("  Push latency:       min %d ms, avg %d ms, max %d ms\n", minimum.Milliseconds(), average.Milliseconds(), maximum.Milliseconds())
+}
@@ -0,0 +1,210 @@
+package jmap
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/jmap/protocol"
+)
+
+// serveWatch answers the state, changes and probe calls of a watch. A probe
+// created with Email/set is pushed as an Email StateChange and reported by
+// Email/changes; destroyed ids are collected in destroyed.
+func serveWatch(server *fakeJMAPServer, destroyed *[]string) {
+	var probeId string
+	server.handle(protocol.MethodMailboxGet, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxGet, map[string]interface{}{
+			"accountId": "A1",
+			"state":     "m1",
+			"list":      []map[string]interface{}{{"id": "MB-inbox", "name": "Inbox", "role": "inbox", "totalEmails": 3, "unreadEmails": 1}},
+		}
+	})
+	server.handle(protocol.MethodMailboxChanges, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodMailboxChanges, map[string]interface{}{
+			"accountId": "A1", "oldState": "m1", "newState": "m2", "hasMoreChanges": false,
+			"created": []string{}, "updated": []string{"MB-inbox"}, "destroyed": []string{"MB-old"},
+		}
+	})
+	server.handle(protocol.MethodEmailGet, func(args json.RawMessage) (string, interface{}) {
+		list := []map[string]interface{}{}
+		if probeId != "" && strings.Contains(string(args), "/created") {
+			list = append(list, map[string]interface{}{"id": probeId, "subject": "gomailtest push probe #1"})
+		}
+		return protocol.MethodEmailGet, map[string]interface{}{"accountId": "A1", "state": "e1", "list": list}
+	})
+	server.handle(protocol.MethodEmailChanges, func(json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailChanges, map[string]interface{}{
+			"accountId": "A1", "oldState": "e1", "newState": "e2", "hasMoreChanges": false,
+			"created": []string{probeId}, "updated": []string{}, "destroyed": []string{},
+		}
+	})
+	server.handle(protocol.MethodEmailSet, func(args json.RawMessage) (string, interface{}) {
+		var set protocol.SetRequest
+		_ = json.Unmarshal(args, &set)
+		if len(set.Destroy) > 0 {
+			for _, id := range set.Destroy {
+				*destroyed = append(*destroyed, string(id))
+			}
+			return protocol.MethodEmailSet, map[string]interface{}{"accountId": "A1", "destroyed": set.Destroy}
+		}
+		probeId = "E-probe"
+		server.push <- `{"@type":"StateChange","changed":{"A1":{"Email":"e2"}}}`
+		return protocol.MethodEmailSet, map[string]interface{}{
+			"accountId": "A1",
+			"created":   map[string]interface{}{"new": map[string]interface{}{"id": probeId, "blobId": "B1", "threadId": "T1"}},
+		}
+	})
+}
+
+func TestWatch_EventSourceProbe(t *testing.T) {
+	defer func(delay time.Duration) { firstProbeDelay = delay }(firstProbeDelay)
+	firstProbeDelay = 10 * time.Millisecond
+
+	config, server := startFakeJMAPServer(t)
+	var destroyed []string
+	serveWatch(server, &destroyed)
+	config.Action = ActionWatch
+	config.Probes = 1
+
+	csvLog := &recordingLogger{}
+	if err := watch(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("watch() error = %v", err)
+	}
+
+	var probeRow, changeRow []string
+	for _, row := range csvLog.rows {
+		switch csvLog.column(row, "Event") {
+		case "PROBE":
+			probeRow = row
+		case "STATE_CHANGE":
+			changeRow = row
+		}
+	}
+	if probeRow == nil || csvLog.column(probeRow, "Status") != "SUCCESS" || csvLog.column(probeRow, "Latency_Ms") == "" {
+		t.Errorf("probe row = %v, want a successful probe with a latency", probeRow)
+	}
+	if changeRow == nil || csvLog.column(changeRow, "Transport") != transportEventSource ||
+		csvLog.column(changeRow, "Data_Type") != "Email" || csvLog.column(changeRow, "New_State") != "e2" ||
+		csvLog.column(changeRow, "Created") != "1" {
+		t.Errorf("state change row = %v, want the created probe", changeRow)
+	}
+	if len(destroyed) != 1 || destroyed[0] != "E-probe" {
+		t.Errorf("destroyed = %v, want the probe deleted", destroyed)
+	}
+}
+
+func TestWatch_WebSocket(t *testing.T) {
+	config, server := startFakeJMAPServer(t)
+	var destroyed []string
+	serveWatch(server, &destroyed)
+	server.webSocket = true
+	server.push <- `{"@type":"StateChange","changed":{"A1":{"Mailbox":"m2"},"A2":{"Email":"x"}}}`
+	config.Action = ActionWatch
+	config.Duration = 500 * time.Millisecond
+
+	csvLog := &recordingLogger{}
+	if err := watch(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("watch() error = %v", err)
+	}
+
+	server.mu.Lock()
+	enabled, origin, tlsVersion := server.enabled, server.wsOrigin, server.wsTLS
+	server.mu.Unlock()
+	if len(enabled) != 1 || !strings.Contains(enabled[0], `"@type":"WebSocketPushEnable"`) {
+		t.Errorf("push enable messages = %v, want one WebSocketPushEnable", enabled)
+	}
+	if want := "https://" + server.Listener.Addr().String(); origin != want {
+		t.Errorf("Origin = %q, want %q", origin, want)
+	}
+	if tlsVersion < tls.VersionTLS12 {
+		t.Errorf("TLS version = %x, want TLS 1.2 or later", tlsVersion)
+	}
+	if len(csvLog.rows) != 1 {
+		t.Fatalf("rows = %v, want one state change", csvLog.rows)
+	}
+	row := csvLog.rows[0]
+	if csvLog.column(row, "Transport") != transportWebSocket || csvLog.column(row, "Data_Type") != "Mailbox" ||
+		csvLog.column(row, "Updated") != "1" || csvLog.column(row, "Destroyed") != "1" {
+		t.Errorf("row = %v, want the Mailbox changes over websocket", row)
+	}
+}
+
+func TestWatch_ProbeLost(t *testing.T) {
+	defer func(delay time.Duration) { firstProbeDelay = delay }(firstProbeDelay)
+	firstProbeDelay = 10 * time.Millisecond
+
+	config, server := startFakeJMAPServer(t)
+	var destroyed []string
+	serveWatch(server, &destroyed)
+	server.handle(protocol.MethodEmailSet, func(args json.RawMessage) (string, interface{}) {
+		return protocol.MethodEmailSet, map[string]interface{}{
+			"accountId": "A1",
+			"created":   map[string]interface{}{"new": map[string]interface{}{"id": "E-silent"}},
+		}
+	})
+	config.Action = ActionWatch
+	config.Probes = 1
+	config.ProbeTimeout = 100 * time.Millisecond
+
+	err := watch(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 probe(s) got no push notification") {
+		t.Errorf("watch() error = %v, want a lost probe", err)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"wss://jmap.example.com/ws", "https://jmap.example.com"},
+		{"ws://jmap.example.com:8080/ws", "http://jmap.example.com:8080"},
+	}
+	for _, tt := range tests {
+		location, _ := url.Parse(tt.url)
+		if got := webSocketOrigin(location); got != tt.want {
+			t.Errorf("webSocketOrigin(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPushTransport(t *testing.T) {
+	withWebSocket := func(supportsPush bool) string {
+		return fmt.Sprintf(`{"url":"wss://jmap.example.com/ws","supportsPush":%t}`, supportsPush)
+	}
+	tests := []struct {
+		name      string
+		webSocket string
+		eventURL  string
+		transport string
+		want      string
+		wantErr   bool
+	}{
+		{"auto prefers websocket", withWebSocket(true), "https://jmap.example.com/events", transportAuto, transportWebSocket, false},
+		{"auto without websocket push", withWebSocket(false), "https://jmap.example.com/events", transportAuto, transportEventSource, false},
+		{"auto without push", "", "", transportAuto, "", true},
+		{"eventsource forced", withWebSocket(true), "https://jmap.example.com/events", transportEventSource, transportEventSource, false},
+		{"websocket missing", "", "https://jmap.example.com/events", transportWebSocket, "", true},
+		{"websocket without push", withWebSocket(false), "", transportWebSocket, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &protocol.Session{Capabilities: map[string]json.RawMessage{}, EventSourceURL: tt.eventURL}
+			if tt.webSocket != "" {
+				session.Capabilities[protocol.WebSocketCapability] = json.RawMessage(tt.webSocket)
+			}
+			got, err := pushTransport(session, tt.transport)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pushTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pushTransport() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
 
-// NewCmd returns the "pop3" cobra.Command with all 3 action subcommands.
+// NewCmd returns the "pop3" cobra.Command with all 5 action subcommands.
 // Each subcommand shares persistent flags (server, auth, TLS, output) and adds
 // its own action-specific flags.
 func NewCmd() *cobra.Command {
@@ -20,8 +20,8 @@ func NewCmd() *cobra.Command {
 		Short: "POP3 server connectivity and authentication testing",
 		Long: `Test POP3 server connectivity, TLS configuration, authentication, and mailbox listing.
 
-Supports STLS and POP3S (implicit TLS) modes, USER/PASS, APOP, and XOAUTH2 authentication,
-and connect-address override for load balancer testing.
+Supports STLS and POP3S (implicit TLS) modes, USER/PASS and SASL PLAIN, LOGIN, NTLM, XOAUTH2
+and OAUTHBEARER authentication, and connect-address override for load balancer testing.
 
 Environment variables use the POP3 prefix (e.g. POP3HOST, POP3PORT, POP3USERNAME).`,
 	}
@@ -33,6 +33,8 @@ Environment variables use the POP3 prefix (e.g. POP3HOST, POP3PORT, POP3USERNAME
 		newTestConnectCmd(v),
 		newTestAuthCmd(v),
 		newListMailCmd(v),
+		newRetrieveCmd(v),
+		newSyncCmd(v),
 	)
 
 	return cmd
@@ -88,7 +90,8 @@ func newTestAuthCmd(v *viper.Viper) *cobra.Command {
 		Use:   "testauth",
 		Short: "Test POP3 authentication",
 		Long: `Authenticate to the POP3 server using the configured credentials and auth method.
-Supports USER/PASS, APOP, and XOAUTH2 (OAuth2 bearer token).
+Supports USER/PASS and the SASL mechanisms PLAIN, LOGIN, NTLM, XOAUTH2 and OAUTHBEARER (RFC 5034 AUTH);
+with --authmethod auto the mechanism is chosen from the SASL list the server advertises in CAPA.
 Automatically upgrades to TLS via STLS when --starttls is set.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			_ = v.BindPFlags(cmd.Flags())
@@ -177,3 +180,120 @@ Shows message count, total size, and per-message size and UIDL (if supported).`,
 
 	return cmd
 }
+
+func newRetrieveCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retrieve",
+		Short: "Preview message headers and download messages to .eml files",
+		Long: `Authenticate to the POP3 server and show what a client would download: the headers of
+the newest --preview messages via TOP (when the server advertises it), and the messages selected
+with --message (by message number or UIDL) saved as .eml files in --output-dir.
+With --delete-after, the downloaded messages are deleted with DELE and the session ends with QUIT,
+which is when the server commits the deletions.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionRetrieve
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("pop3tool", ActionRetrieve, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "POP3 Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := retrieveMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.Int("preview", 10, "Show the headers of the newest N messages via TOP; 0 disables the preview (env: POP3PREVIEW)")
+	f.StringSlice("message", nil, "Message to download, by message number or UIDL (repeatable or comma-separated) (env: POP3MESSAGE)")
+	f.String("output-dir", ".", "Directory for the downloaded .eml files (env: POP3OUTPUTDIR)")
+	f.Bool("delete-after", false, "Delete the downloaded messages from the server (DELE, committed by QUIT) (env: POP3DELETEAFTER)")
+
+	return cmd
+}
+
+func newSyncCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Download new messages to a Maildir, tracking UIDLs in a state file",
+		Long: `Authenticate to the POP3 server and download the messages whose UIDL is not yet in the
+state file into --maildir, as a client set to leave messages on the server does. The state file is
+written atomically after each message, so an interrupted sync resumes where it stopped.
+
+Messages stay on the server by default; --leave-on-server=false deletes them right after download,
+and --leave-days deletes them that many days after download. UIDL instability is reported and fails
+the action: messages without a UIDL or sharing one, a downloaded UIDL reused for a different
+message (checked by size, and by Message-ID with --verify-seen), and a downloaded message that
+reappears under a new UIDL.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionSync
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("pop3tool", ActionSync, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "POP3 Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := syncMail(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.String("maildir", "", "Maildir to deliver new messages to; created if missing (env: POP3MAILDIR)")
+	f.String("statefile", "", "State file of downloaded UIDLs (default: <maildir>/.pop3sync-state.json) (env: POP3STATEFILE)")
+	f.Bool("leave-on-server", true, "Leave downloaded messages on the server; false deletes them after download (env: POP3LEAVEONSERVER)")
+	f.Int("leave-days", 0, "Delete messages from the server N days after download; 0 keeps them (env: POP3LEAVEDAYS)")
+	f.Bool("verify-seen", false, "Compare the Message-ID of downloaded messages via TOP to detect reused UIDLs (env: POP3VERIFYSEEN)")
+
+	return cmd
+}
@@ -25,12 +25,25 @@ type Config struct {
 	// Authentication
 	Username    string
 	Password    string
-	AccessToken string // OAuth2 access token for XOAUTH2 authentication
-	AuthMethod  string // USER, APOP, XOAUTH2, or "auto"
+	AccessToken string // OAuth2 access token for XOAUTH2 or OAUTHBEARER authentication
+	AuthMethod  string // USER, PLAIN, LOGIN, NTLM, XOAUTH2, OAUTHBEARER, APOP, or "auto"
 
 	// List options
 	MaxMessages int // Maximum messages to list
 
+	// Retrieve options
+	Preview     int      // Number of newest messages whose headers are shown via TOP (0 = none)
+	Messages    []string // Messages to download, by message number or UIDL
+	OutputDir   string   // Directory for downloaded .eml files
+	DeleteAfter bool     // DELE downloaded messages and QUIT to commit the deletions
+
+	// Sync options
+	Maildir       string // Maildir that new messages are delivered to
+	StateFile     string // State file of downloaded UIDLs (default: <maildir>/.pop3sync-state.json)
+	LeaveOnServer bool   // Keep downloaded messages on the server
+	LeaveDays     int    // Delete messages this many days after download (0 = keep)
+	VerifySeen    bool   // Compare the Message-ID of downloaded messages via TOP to detect reused UIDLs
+
 	// TLS configuration
 	POP3S      bool   // Use POP3S (implicit TLS on port 995)
 	StartTLS   bool   // Force STLS
@@ -43,6 +56,8 @@ type Config struct {
 	ConnectAddress string // Override address for TCP connection (IP or hostname)
 	IPv4Only       bool   // Force resolving --host/--address to an IPv4 (A record) address
 	IPv6Only       bool   // Force resolving --host/--address to an IPv6 (AAAA record) address
+	ProxyProtocol  string // Send a HAProxy PROXY header after connect: v1, v2 (empty = disabled)
+	ProxySource    string // Client address announced in the PROXY header (ip or ip:port; default: local address)
 	ProxyURL       string
 	MaxRetries     int
 	RetryDelay     time.Duration
@@ -60,26 +75,31 @@ const (
 	ActionTestConnect = "testconnect"
 	ActionTestAuth    = "testauth"
 	ActionListMail    = "listmail"
+	ActionRetrieve    = "retrieve"
+	ActionSync        = "sync"
 )
 
 // NewConfig creates a new Config with default values.
 func NewConfig() *Config {
 	return &Config{
-		Port:         110,
-		Timeout:      30 * time.Second,
-		AuthMethod:   "auto",
-		MaxMessages:  100,
-		POP3S:        false,
-		StartTLS:     false,
-		SkipVerify:   false,
-		TLSVersion:   "1.2",
-		MaxRetries:   3,
-		RetryDelay:   2000 * time.Millisecond,
-		VerboseMode:  false,
-		LogLevel:     "INFO",
-		OutputFormat: "text",
-		LogFormat:    "csv",
-		RateLimit:    0,
+		Port:          110,
+		Timeout:       30 * time.Second,
+		AuthMethod:    "auto",
+		MaxMessages:   100,
+		Preview:       10,
+		OutputDir:     ".",
+		LeaveOnServer: true,
+		POP3S:         false,
+		StartTLS:      false,
+		SkipVerify:    false,
+		TLSVersion:    "1.2",
+		MaxRetries:    3,
+		RetryDelay:    2000 * time.Millisecond,
+		VerboseMode:   false,
+		LogLevel:      "INFO",
+		OutputFormat:  "text",
+		LogFormat:     "csv",
+		RateLimit:     0,
 	}
 }
 
@@ -96,8 +116,8 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 	// Authentication
 	f.String("username", "", "Username for authentication (env: POP3USERNAME)")
 	f.String("password", "", "Password for authentication (env: POP3PASSWORD)")
-	f.String("accesstoken", "", "OAuth2 access token for XOAUTH2 authentication (env: POP3ACCESSTOKEN)")
-	f.String("authmethod", "auto", "Authentication method: USER, APOP, XOAUTH2, auto (env: POP3AUTHMETHOD)")
+	f.String("accesstoken", "", "OAuth2 access token for XOAUTH2 or OAUTHBEARER authentication (env: POP3ACCESSTOKEN)")
+	f.String("authmethod", "auto", "Authentication method: auto, USER, PLAIN, LOGIN, NTLM, XOAUTH2, OAUTHBEARER, APOP; auto picks from the server's SASL list (env: POP3AUTHMETHOD)")
 
 	// TLS
 	f.Bool("starttls", false, "Force STLS usage (env: POP3STARTTLS)")
@@ -111,6 +131,8 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 	f.String("address", "", "Optional: connect to this IP/hostname instead of --host (e.g. to test a specific server behind a load balancer); --host is still used for SNI, certificate checks, and authentication (env: POP3ADDRESS)")
 	f.Bool("ipv4", false, "Force IPv4: resolve --host/--address to an A record and connect over IPv4 (env: POP3IPV4)")
 	f.Bool("ipv6", false, "Force IPv6: resolve --host/--address to an AAAA record and connect over IPv6 (env: POP3IPV6)")
+	f.String("proxy-protocol", "", "Send a HAProxy PROXY protocol header (v1 or v2) right after TCP connect, for listeners behind HAProxy (env: POP3PROXYPROTOCOL)")
+	f.String("proxy-source", "", "Client address to announce in the PROXY header, as ip or ip:port (default: local connection address) (env: POP3PROXYSOURCE)")
 	f.String("proxy", "", "HTTP/HTTPS proxy URL (env: POP3PROXY)")
 	f.Int("maxretries", 3, "Maximum retry attempts (env: POP3MAXRETRIES)")
 	f.Int("retrydelay", 2000, "Retry delay in milliseconds (env: POP3RETRYDELAY)")
@@ -127,29 +149,40 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 // Must be called after RegisterPersistentFlags.
 func BindEnvs(v *viper.Viper) {
 	bindings := map[string]string{
-		"host":        "POP3HOST",
-		"port":        "POP3PORT",
-		"timeout":     "POP3TIMEOUT",
-		"username":    "POP3USERNAME",
-		"password":    "POP3PASSWORD",
-		"accesstoken": "POP3ACCESSTOKEN",
-		"authmethod":  "POP3AUTHMETHOD",
-		"starttls":    "POP3STARTTLS",
-		"pop3s":       "POP3POP3S",
-		"no-starttls": "POP3NOSTARTTLS",
-		"no-pop3s":    "POP3NOPOP3S",
-		"skipverify":  "POP3SKIPVERIFY",
-		"tlsversion":  "POP3TLSVERSION",
-		"address":     "POP3ADDRESS",
-		"ipv4":        "POP3IPV4",
-		"ipv6":        "POP3IPV6",
-		"proxy":       "POP3PROXY",
-		"maxretries":  "POP3MAXRETRIES",
-		"retrydelay":  "POP3RETRYDELAY",
-		"output":      "POP3OUTPUT",
-		"logformat":   "POP3LOGFORMAT",
-		"ratelimit":   "POP3RATELIMIT",
-		"maxmessages": "POP3MAXMESSAGES",
+		"host":            "POP3HOST",
+		"port":            "POP3PORT",
+		"timeout":         "POP3TIMEOUT",
+		"username":        "POP3USERNAME",
+		"password":        "POP3PASSWORD",
+		"accesstoken":     "POP3ACCESSTOKEN",
+		"authmethod":      "POP3AUTHMETHOD",
+		"starttls":        "POP3STARTTLS",
+		"pop3s":           "POP3POP3S",
+		"no-starttls":     "POP3NOSTARTTLS",
+		"no-pop3s":        "POP3NOPOP3S",
+		"skipverify":      "POP3SKIPVERIFY",
+		"tlsversion":      "POP3TLSVERSION",
+		"address":         "POP3ADDRESS",
+		"ipv4":            "POP3IPV4",
+		"ipv6":            "POP3IPV6",
+		"proxy-protocol":  "POP3PROXYPROTOCOL",
+		"proxy-source":    "POP3PROXYSOURCE",
+		"proxy":           "POP3PROXY",
+		"maxretries":      "POP3MAXRETRIES",
+		"retrydelay":      "POP3RETRYDELAY",
+		"output":          "POP3OUTPUT",
+		"logformat":       "POP3LOGFORMAT",
+		"ratelimit":       "POP3RATELIMIT",
+		"maxmessages":     "POP3MAXMESSAGES",
+		"preview":         "POP3PREVIEW",
+		"message":         "POP3MESSAGE",
+		"output-dir":      "POP3OUTPUTDIR",
+		"delete-after":    "POP3DELETEAFTER",
+		"maildir":         "POP3MAILDIR",
+		"statefile":       "POP3STATEFILE",
+		"leave-on-server": "POP3LEAVEONSERVER",
+		"leave-days":      "POP3LEAVEDAYS",
+		"verify-seen":     "POP3VERIFYSEEN",
 	}
 	for key, env := range bindings {
 		_ = v.BindEnv(key, env)
@@ -186,6 +219,16 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		maxMessages = defaults.MaxMessages
 	}
 
+	outputDir := v.GetString("output-dir")
+	if outputDir == "" {
+		outputDir = defaults.OutputDir
+	}
+
+	leaveOnServer := defaults.LeaveOnServer
+	if v.IsSet("leave-on-server") {
+		leaveOnServer = v.GetBool("leave-on-server")
+	}
+
 	authMethod := v.GetString("authmethod")
 	if authMethod == "" {
 		authMethod = defaults.AuthMethod
@@ -220,6 +263,15 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		AccessToken:    v.GetString("accesstoken"),
 		AuthMethod:     authMethod,
 		MaxMessages:    maxMessages,
+		Preview:        v.GetInt("preview"),
+		Messages:       messageList(v, "message"),
+		OutputDir:      outputDir,
+		DeleteAfter:    v.GetBool("delete-after"),
+		Maildir:        v.GetString("maildir"),
+		StateFile:      v.GetString("statefile"),
+		LeaveOnServer:  leaveOnServer,
+		LeaveDays:      v.GetInt("leave-days"),
+		VerifySeen:     v.GetBool("verify-seen"),
 		POP3S:          v.GetBool("pop3s"),
 		StartTLS:       v.GetBool("starttls"),
 		NoStartTLS:     v.GetBool("no-starttls"),
@@ -229,6 +281,8 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		ConnectAddress: v.GetString("address"),
 		IPv4Only:       v.GetBool("ipv4"),
 		IPv6Only:       v.GetBool("ipv6"),
+		ProxyProtocol:  strings.ToLower(v.GetString("proxy-protocol")),
+		ProxySource:    v.GetString("proxy-source"),
 		ProxyURL:       v.GetString("proxy"),
 		MaxRetries:     maxRetries,
 		RetryDelay:     time.Duration(retryDelayMs) * time.Millisecond,
@@ -240,10 +294,23 @@ func ConfigFromViper(v *viper.Viper) *Config {
 	}
 }
 
+// messageList reads the --message selectors. A value coming from an
+// environment variable or config file may list several, separated by commas
+// or spaces.
+func messageList(v *viper.Viper, key string) []string {
+	var list []string
+	for _, item := range v.GetStringSlice(key) {
+		for _, field := range strings.FieldsFunc(item, func(r rune) bool { return r == ',' || r == ' ' }) {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
-	validActions := []string{ActionTestConnect, ActionTestAuth, ActionListMail}
+	validActions := []string{ActionTestConnect, ActionTestAuth, ActionListMail, ActionRetrieve, ActionSync}
 	valid := false
 	for _, a := range validActions {
 		if config.Action == a {
@@ -316,25 +383,63 @@ func validateConfiguration(config *Config) error {
 		}
 	}
 
+	// Validate PROXY protocol settings (if provided)
+	if err := network.ValidateProxyProtocol(config.ProxyProtocol, config.ProxySource); err != nil {
+		return err
+	}
+
 	// Action-specific validation
 	switch config.Action {
-	case ActionTestAuth, ActionListMail:
+	case ActionTestAuth, ActionListMail, ActionRetrieve, ActionSync:
 		if config.Username == "" {
 			return fmt.Errorf("%s requires --username", config.Action)
 		}
-		if strings.EqualFold(config.AuthMethod, "XOAUTH2") {
+		method := strings.ToUpper(config.AuthMethod)
+		switch method {
+		case "AUTO", "USER", "APOP", "PLAIN", "LOGIN", "NTLM", "XOAUTH2", "OAUTHBEARER":
+		default:
+			return fmt.Errorf("invalid --authmethod: %s (must be one of: auto, USER, PLAIN, LOGIN, NTLM, XOAUTH2, OAUTHBEARER, APOP)", config.AuthMethod)
+		}
+		if method == "XOAUTH2" || method == "OAUTHBEARER" {
 			if config.AccessToken == "" {
-				return fmt.Errorf("XOAUTH2 authentication requires --accesstoken")
+				return fmt.Errorf("%s authentication requires --accesstoken", method)
 			}
 			if config.Password != "" {
-				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored for XOAUTH2")
+				fmt.Printf("Warning: both --password and --accesstoken provided; --password will be ignored for %s\n", method)
 			}
-		} else if config.AccessToken != "" {
+		} else if method == "AUTO" && config.AccessToken != "" {
 			if config.Password != "" {
-				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored (using XOAUTH2)")
+				fmt.Println("Warning: both --password and --accesstoken provided; --password will be ignored (using XOAUTH2 or OAUTHBEARER)")
 			}
 		} else if config.Password == "" {
-			return fmt.Errorf("%s requires --password (or --accesstoken for XOAUTH2)", config.Action)
+			if method == "AUTO" {
+				return fmt.Errorf("%s requires --password (or --accesstoken for XOAUTH2)", config.Action)
+			}
+			return fmt.Errorf("%s authentication requires --password", method)
+		}
+	}
+
+	if config.Action == ActionRetrieve {
+		if config.Preview < 0 {
+			return fmt.Errorf("--preview must not be negative")
+		}
+		if config.DeleteAfter && len(config.Messages) == 0 {
+			return fmt.Errorf("--delete-after requires --message to select the messages to download")
+		}
+		if len(config.Messages) > 0 && config.OutputDir == "" {
+			return fmt.Errorf("retrieve requires --output-dir")
+		}
+	}
+
+	if config.Action == ActionSync {
+		if config.Maildir == "" {
+			return fmt.Errorf("sync requires --maildir")
+		}
+		if config.LeaveDays < 0 {
+			return fmt.Errorf("--leave-days must not be negative")
+		}
+		if config.LeaveDays > 0 && !config.LeaveOnServer {
+			return fmt.Errorf("--leave-days requires --leave-on-server (messages are otherwise deleted right after download)")
 		}
 	}
 
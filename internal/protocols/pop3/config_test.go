@@ -106,3 +106,123 @@ func TestValidateConfiguration_NoTLSFlags(t *testing.T) {
 		})
 	}
 }
+
+// TestValidateConfiguration_Retrieve tests retrieve option validation
+func TestValidateConfiguration_Retrieve(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "preview only", modify: func(c *Config) {}},
+		{name: "download and delete", modify: func(c *Config) { c.Messages = []string{"1"}; c.DeleteAfter = true }},
+		{name: "negative preview", modify: func(c *Config) { c.Preview = -1 }, errorMsg: "--preview must not be negative"},
+		{name: "delete without selection", modify: func(c *Config) { c.DeleteAfter = true }, errorMsg: "--delete-after requires --message"},
+		{name: "missing password", modify: func(c *Config) { c.Password = "" }, errorMsg: "retrieve requires --password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionRetrieve
+			config.Host = "pop3.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_Sync(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*Config)
+		errorMsg string
+	}{
+		{name: "leave on server", modify: func(c *Config) {}},
+		{name: "leave for 14 days", modify: func(c *Config) { c.LeaveDays = 14 }},
+		{name: "delete after download", modify: func(c *Config) { c.LeaveOnServer = false }},
+		{name: "missing maildir", modify: func(c *Config) { c.Maildir = "" }, errorMsg: "sync requires --maildir"},
+		{name: "negative leave days", modify: func(c *Config) { c.LeaveDays = -1 }, errorMsg: "--leave-days must not be negative"},
+		{name: "leave days without leaving", modify: func(c *Config) { c.LeaveDays = 7; c.LeaveOnServer = false }, errorMsg: "--leave-days requires --leave-on-server"},
+		{name: "missing password", modify: func(c *Config) { c.Password = "" }, errorMsg: "sync requires --password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSync
+			config.Host = "pop3.example.com"
+			config.Username = "user@example.com"
+			config.Password = "secret"
+			config.Maildir = "mail"
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_AuthMethod tests per-mechanism credential requirements
+func TestValidateConfiguration_AuthMethod(t *testing.T) {
+	tests := []struct {
+		name        string
+		authMethod  string
+		password    string
+		accessToken string
+		errorMsg    string
+	}{
+		{name: "auto with password", authMethod: "auto", password: "secret"},
+		{name: "auto with token", authMethod: "auto", accessToken: "tok"},
+		{name: "auto without credentials", authMethod: "auto", errorMsg: "testauth requires --password"},
+		{name: "PLAIN with password", authMethod: "plain", password: "secret"},
+		{name: "NTLM with token only", authMethod: "NTLM", accessToken: "tok", errorMsg: "NTLM authentication requires --password"},
+		{name: "LOGIN without password", authMethod: "LOGIN", errorMsg: "LOGIN authentication requires --password"},
+		{name: "OAUTHBEARER with token", authMethod: "OAUTHBEARER", accessToken: "tok"},
+		{name: "OAUTHBEARER without token", authMethod: "oauthbearer", password: "secret", errorMsg: "OAUTHBEARER authentication requires --accesstoken"},
+		{name: "unknown method", authMethod: "CRAM-MD5", password: "secret", errorMsg: "invalid --authmethod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestAuth
+			config.Host = "pop3.example.com"
+			config.Username = "user@example.com"
+			config.AuthMethod = tt.authMethod
+			config.Password = tt.password
+			config.AccessToken = tt.accessToken
+
+			err := validateConfiguration(config)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.errorMsg)
+			}
+		})
+	}
+}
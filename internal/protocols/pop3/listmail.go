@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"strings"
 
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
@@ -63,27 +62,9 @@ func listMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	caps, _ := client.Capabilities(ctx)
 
 	// Authenticate
-	authMethod := config.AuthMethod
-	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				authMethod = "USER"
-			}
-		} else {
-			authMethod = "USER"
-		}
-	}
+	fmt.Printf("Authenticating with method: %s\n", client.SelectAuthMethod(config.AccessToken != ""))
 
-	fmt.Printf("Authenticating with method: %s\n", authMethod)
-
-	var authErr error
-	if config.AccessToken != "" && strings.EqualFold(authMethod, "XOAUTH2") {
-		authErr = client.Auth(ctx, config.Username, "", config.AccessToken)
-	} else {
-		authErr = client.Auth(ctx, config.Username, config.Password, "")
-	}
+	authErr := client.Auth(ctx, config.Username, config.Password, config.AccessToken)
 
 	if authErr != nil {
 		logger.LogError(slogLogger, "Authentication failed",
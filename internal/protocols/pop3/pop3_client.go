@@ -6,12 +6,15 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 	"time"
 
+	"github.com/emersion/go-sasl"
 	"github.com/ziembor/gomailtesttool/internal/common/network"
 	"github.com/ziembor/gomailtesttool/internal/common/ratelimit"
+	commonsasl "github.com/ziembor/gomailtesttool/internal/common/sasl"
 	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
 )
 
@@ -64,12 +67,24 @@ func (c *POP3Client) Connect(ctx context.Context) error {
 	}
 	address := net.JoinHostPort(connectHost, fmt.Sprintf("%d", c.port))
 
-	var conn net.Conn
-
 	dialer := &net.Dialer{
 		Timeout: c.config.Timeout,
 	}
 
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("connection failed: %w", err)
+	}
+
+	// PROXY protocol: announce the client address to a HAProxy-fronted
+	// listener before anything else (including the POP3S handshake)
+	if c.config.ProxyProtocol != "" {
+		if _, err := network.WriteProxyHeader(conn, c.config.ProxyProtocol, c.config.ProxySource); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
 	if c.config.POP3S {
 		// Implicit TLS (POP3S)
 		tlsConfig := &tls.Config{
@@ -77,21 +92,23 @@ func (c *POP3Client) Connect(ctx context.Context) error {
 			InsecureSkipVerify: c.config.SkipVerify,
 			MinVersion:         parseTLSVersion(c.config.TLSVersion),
 		}
-		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
-		if err != nil {
+		// The dialer timeout only covers the TCP connect; bound the
+		// handshake too, so a stalled server cannot hang the tool
+		handshakeCtx := ctx
+		if c.config.Timeout > 0 {
+			var cancel context.CancelFunc
+			handshakeCtx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+			defer cancel()
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			conn.Close()
 			return fmt.Errorf("POP3S connection failed: %w", err)
 		}
 		// Store TLS state
-		if tlsConn, ok := conn.(*tls.Conn); ok {
-			state := tlsConn.ConnectionState()
-			c.tlsState = &state
-		}
-	} else {
-		// Plain connection
-		conn, err = dialer.DialContext(ctx, "tcp", address)
-		if err != nil {
-			return fmt.Errorf("connection failed: %w", err)
-		}
+		state := tlsConn.ConnectionState()
+		c.tlsState = &state
+		conn = tlsConn
 	}
 
 	c.conn = conn
@@ -197,7 +214,29 @@ func (c *POP3Client) GetCapabilities() *protocol.Capabilities {
 	return c.caps
 }
 
-// Auth authenticates with the server using the specified method.
+// SelectAuthMethod returns the method Auth will use: the configured
+// --authmethod, or for "auto" the best SASL mechanism the server advertises
+// in CAPA, falling back to XOAUTH2 with an access token and USER/PASS
+// without one.
+func (c *POP3Client) SelectAuthMethod(hasAccessToken bool) string {
+	method := strings.ToUpper(c.config.AuthMethod)
+	if method != "AUTO" && method != "" {
+		return method
+	}
+	if c.caps != nil {
+		if best := c.caps.SelectBestAuthMechanism(hasAccessToken); best != "" {
+			return best
+		}
+	}
+	if hasAccessToken {
+		return "XOAUTH2"
+	}
+	return "USER"
+}
+
+// Auth authenticates with the server using the method chosen by
+// SelectAuthMethod. Token-based mechanisms use accessToken, the others
+// username and password.
 func (c *POP3Client) Auth(ctx context.Context, username, password, accessToken string) error {
 	if c.limiter != nil {
 		if err := c.limiter.Wait(ctx); err != nil {
@@ -205,31 +244,112 @@ func (c *POP3Client) Auth(ctx context.Context, username, password, accessToken s
 		}
 	}
 
-	method := c.config.AuthMethod
-
-	// Auto-select auth method
-	if strings.EqualFold(method, "auto") {
-		if accessToken != "" && c.caps != nil && c.caps.SupportsXOAUTH2() {
-			method = "XOAUTH2"
-		} else if c.caps != nil && c.caps.SupportsUSER() {
-			method = "USER"
-		} else {
-			method = "USER" // Fallback
-		}
-	}
-
-	switch strings.ToUpper(method) {
+	switch method := c.SelectAuthMethod(accessToken != ""); method {
 	case "XOAUTH2":
-		return c.authXOAUTH2(username, accessToken)
+		if err := c.authSASL(&xoauth2Client{username: username, accessToken: accessToken}); err != nil {
+			return fmt.Errorf("XOAUTH2 authentication failed: %w", err)
+		}
+		return nil
+	case "OAUTHBEARER":
+		saslClient := &oauthbearerClient{username: username, accessToken: accessToken, host: c.host, port: c.port}
+		if err := c.authSASL(saslClient); err != nil {
+			if saslClient.serverError != nil {
+				return fmt.Errorf("OAUTHBEARER authentication failed (status %s): %w", saslClient.serverError.Status, err)
+			}
+			return fmt.Errorf("OAUTHBEARER authentication failed: %w", err)
+		}
+		return nil
+	case "NTLM":
+		if err := c.authSASL(&commonsasl.NTLMClient{Username: username, Password: password}); err != nil {
+			return fmt.Errorf("NTLM authentication failed: %w", err)
+		}
+		return nil
+	case "PLAIN":
+		if err := c.authSASL(sasl.NewPlainClient("", username, password)); err != nil {
+			return fmt.Errorf("PLAIN authentication failed: %w", err)
+		}
+		return nil
+	case "LOGIN":
+		if err := c.authSASL(&loginClient{username: username, password: password}); err != nil {
+			return fmt.Errorf("LOGIN authentication failed: %w", err)
+		}
+		return nil
 	case "APOP":
 		return c.authAPOP(username, password)
-	case "USER", "":
+	case "USER":
 		return c.authUSER(username, password)
 	default:
 		return fmt.Errorf("unsupported auth method: %s", method)
 	}
 }
 
+// maxAuthCommandLength is the longest AUTH command, CRLF included, that may
+// carry the initial response (RFC 5034 §4: 255 octets).
+const maxAuthCommandLength = 255
+
+// authSASL runs an RFC 5034 AUTH exchange for saslClient. The initial
+// response goes on the AUTH line unless that would exceed
+// maxAuthCommandLength; then it answers the server's first, empty
+// challenge. Challenges and responses are base64 encoded; a challenge that
+// cannot be decoded or answered cancels the exchange with "*".
+func (c *POP3Client) authSASL(saslClient sasl.Client) error {
+	mech, ir, err := saslClient.Start()
+	if err != nil {
+		return err
+	}
+
+	command := protocol.AUTH(mech, "")
+	if ir != nil {
+		encoded := "=" // RFC 5034: an empty initial response is sent as "="
+		if len(ir) > 0 {
+			encoded = base64.StdEncoding.EncodeToString(ir)
+		}
+		if withIR := protocol.AUTH(mech, encoded); len(withIR) <= maxAuthCommandLength {
+			command, ir = withIR, nil
+		}
+	}
+	if _, err := c.conn.Write([]byte(command)); err != nil {
+		return fmt.Errorf("failed to send AUTH %s: %w", mech, err)
+	}
+
+	for {
+		resp, err := protocol.ReadResponse(c.reader)
+		if err != nil {
+			return fmt.Errorf("failed to read AUTH response: %w", err)
+		}
+		if !resp.Continuation {
+			if !resp.Success {
+				return fmt.Errorf("%s", resp.Message)
+			}
+			return nil
+		}
+
+		var response []byte
+		if ir != nil {
+			response, ir = ir, nil
+		} else {
+			challenge, err := base64.StdEncoding.DecodeString(resp.Message)
+			if err == nil {
+				response, err = saslClient.Next(challenge)
+			}
+			if err != nil {
+				c.cancelAuth()
+				return err
+			}
+		}
+		if _, err := c.conn.Write([]byte(base64.StdEncoding.EncodeToString(response) + protocol.CRLF)); err != nil {
+			return fmt.Errorf("failed to send AUTH response: %w", err)
+		}
+	}
+}
+
+// cancelAuth aborts a SASL exchange with "*" and reads the server's -ERR.
+func (c *POP3Client) cancelAuth() {
+	if _, err := c.conn.Write([]byte("*" + protocol.CRLF)); err == nil {
+		_, _ = protocol.ReadResponse(c.reader)
+	}
+}
+
 // authUSER performs USER/PASS authentication.
 func (c *POP3Client) authUSER(username, password string) error {
 	// Send USER command
@@ -266,28 +386,6 @@ func (c *POP3Client) authAPOP(username, password string) error {
 	return fmt.Errorf("APOP authentication is disabled: protocol requires insecure MD5 challenge-response; use USER/PASS over TLS")
 }
 
-// authXOAUTH2 performs XOAUTH2 authentication.
-func (c *POP3Client) authXOAUTH2(username, accessToken string) error {
-	// Build XOAUTH2 token
-	token := protocol.XOAUTH2Token(username, accessToken)
-	encoded := base64.StdEncoding.EncodeToString([]byte(token))
-
-	// Send AUTH XOAUTH2 command
-	if _, err := c.conn.Write([]byte(protocol.AUTH("XOAUTH2", encoded))); err != nil {
-		return fmt.Errorf("failed to send AUTH XOAUTH2: %w", err)
-	}
-
-	resp, err := protocol.ReadResponse(c.reader)
-	if err != nil {
-		return fmt.Errorf("failed to read AUTH response: %w", err)
-	}
-	if !resp.Success {
-		return fmt.Errorf("XOAUTH2 authentication failed: %s", resp.Message)
-	}
-
-	return nil
-}
-
 // Stat returns mailbox statistics.
 func (c *POP3Client) Stat(ctx context.Context) (count int, size int64, err error) {
 	if c.limiter != nil {
@@ -348,28 +446,115 @@ func (c *POP3Client) UIDL(ctx context.Context) ([]protocol.MessageInfo, error) {
 	return protocol.ParseUIDLResponse(resp)
 }
 
-// Quit sends the QUIT command and closes the connection.
+// Top returns the headers and the first lines of body lines of message msg.
+func (c *POP3Client) Top(ctx context.Context, msg, lines int) ([]string, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if _, err := c.conn.Write([]byte(protocol.TOP(msg, lines))); err != nil {
+		return nil, fmt.Errorf("failed to send TOP: %w", err)
+	}
+
+	resp, err := protocol.ReadMultilineResponse(c.reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TOP response: %w", err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("TOP %d failed: %s", msg, resp.Message)
+	}
+
+	return resp.Lines, nil
+}
+
+// Retr retrieves message msg and writes it to w with byte-stuffing removed
+// and CRLF line endings. It returns the number of bytes written.
+func (c *POP3Client) Retr(ctx context.Context, msg int, w io.Writer) (int64, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return 0, fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if _, err := c.conn.Write([]byte(protocol.RETR(msg))); err != nil {
+		return 0, fmt.Errorf("failed to send RETR: %w", err)
+	}
+
+	resp, err := protocol.ReadResponse(c.reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read RETR response: %w", err)
+	}
+	if !resp.Success {
+		return 0, fmt.Errorf("RETR %d failed: %s", msg, resp.Message)
+	}
+
+	return protocol.CopyMultilineBody(w, c.reader)
+}
+
+// Dele marks message msg for deletion. The server only removes it when the
+// session ends with QUIT; see Quit.
+func (c *POP3Client) Dele(ctx context.Context, msg int) error {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return fmt.Errorf("rate limit wait: %w", err)
+		}
+	}
+
+	if _, err := c.conn.Write([]byte(protocol.DELE(msg))); err != nil {
+		return fmt.Errorf("failed to send DELE: %w", err)
+	}
+
+	resp, err := protocol.ReadResponse(c.reader)
+	if err != nil {
+		return fmt.Errorf("failed to read DELE response: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("DELE %d failed: %s", msg, resp.Message)
+	}
+
+	return nil
+}
+
+// Quit sends the QUIT command and closes the connection. Messages marked
+// with DELE are only removed when the server answers QUIT with +OK (the
+// UPDATE state), so an error here means deletions may not have been
+// committed. Calling Quit again after it returns is a no-op.
 func (c *POP3Client) Quit() error {
 	if c.conn == nil {
 		return nil
 	}
+	conn := c.conn
+	c.conn = nil
 
-	// Send QUIT command (ignore errors)
-	_, _ = c.conn.Write([]byte(protocol.QUIT()))
+	if _, err := conn.Write([]byte(protocol.QUIT())); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send QUIT: %w", err)
+	}
 
-	// Read response (ignore errors)
-	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-	_, _ = protocol.ReadResponse(c.reader)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := protocol.ReadResponse(c.reader)
+	closeErr := conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read QUIT response: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("QUIT failed: %s", resp.Message)
+	}
 
-	return c.conn.Close()
+	return closeErr
 }
 
-// Close closes the connection without sending QUIT.
+// Close closes the connection without sending QUIT, so messages marked
+// with DELE are kept.
 func (c *POP3Client) Close() error {
-	if c.conn != nil {
-		return c.conn.Close()
+	if c.conn == nil {
+		return nil
 	}
-	return nil
+	conn := c.conn
+	c.conn = nil
+	return conn.Close()
 }
 
 // parseTLSVersion parses a TLS version string to a constant.
@@ -0,0 +1,312 @@
+package pop3
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// Retrieve operations reported per message
+const (
+	retrievePreview = "PREVIEW"
+	retrieveSaved   = "SAVED"
+	retrieveDeleted = "DELETED"
+)
+
+// previewHeaders holds the headers shown for a message in the TOP preview.
+type previewHeaders struct {
+	From    string
+	Subject string
+	Date    string
+}
+
+// retrieveMail shows the headers of the newest --preview messages using TOP
+// and downloads the messages selected with --message to .eml files in
+// --output-dir. With --delete-after, the downloaded messages are marked with
+// DELE and the session ends with QUIT so the server commits the deletions.
+func retrieveMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Retrieving messages from %s:%d...\n", config.Host, config.Port)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Message_Number", "UIDL", "Size", "Operation", "From", "Subject", "Date", "File", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(msg protocol.MessageInfo, operation string, headers previewHeaders, file string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		number, size := "", ""
+		if msg.Number > 0 {
+			number = strconv.Itoa(msg.Number)
+			size = strconv.FormatInt(msg.Size, 10)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			number, msg.UIDL, size, operation,
+			headers.From, headers.Subject, headers.Date, file, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	fail := func(err error) error {
+		writeRow(protocol.MessageInfo{}, "", previewHeaders{}, "", err)
+		return err
+	}
+
+	client, caps, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = client.Quit() }()
+
+	count, size, err := client.Stat(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "STAT command failed", "error", err)
+		return fail(fmt.Errorf("STAT failed: %w", err))
+	}
+	fmt.Printf("\nMailbox Statistics:\n")
+	fmt.Printf("  Total messages: %d\n", count)
+	fmt.Printf("  Total size: %d bytes\n", size)
+
+	var messages []protocol.MessageInfo
+	if count > 0 {
+		if messages, err = client.List(ctx); err != nil {
+			logger.LogError(slogLogger, "LIST command failed", "error", err)
+			return fail(fmt.Errorf("LIST failed: %w", err))
+		}
+	}
+
+	// UIDLs name the downloaded files and select messages by UIDL; ask for
+	// them when selecting even if CAPA did not advertise UIDL
+	if len(messages) > 0 && (caps.SupportsUIDL() || len(config.Messages) > 0) {
+		uidls, err := client.UIDL(ctx)
+		if err != nil && !caps.SupportsUIDL() {
+			logger.LogDebug(slogLogger, "UIDL not available", "error", err)
+		} else if err != nil {
+			logger.LogError(slogLogger, "UIDL command failed", "error", err)
+			return fail(fmt.Errorf("UIDL failed: %w", err))
+		}
+		byNumber := make(map[int]string, len(uidls))
+		for _, u := range uidls {
+			byNumber[u.Number] = u.UIDL
+		}
+		for i := range messages {
+			messages[i].UIDL = byNumber[messages[i].Number]
+		}
+	}
+
+	if config.Preview > 0 && len(messages) > 0 {
+		if !caps.SupportsTOP() {
+			fmt.Println("\n⚠ Server does not advertise TOP; skipping the header preview")
+		} else {
+			n := min(config.Preview, len(messages))
+			fmt.Printf("\nNewest %d message(s):\n", n)
+			fmt.Println("  Num    Size       Date                             From / Subject")
+			for i := len(messages) - 1; i >= len(messages)-n; i-- {
+				msg := messages[i]
+				lines, err := client.Top(ctx, msg.Number, 0)
+				if err != nil {
+					fmt.Printf("  %3d    ✗ %v\n", msg.Number, err)
+					writeRow(msg, retrievePreview, previewHeaders{}, "", err)
+					continue
+				}
+				headers := parsePreviewHeaders(lines)
+				fmt.Printf("  %3d    %8d   %-32s %s\n", msg.Number, msg.Size, headers.Date, headers.From)
+				fmt.Printf("                                                  %s\n", headers.Subject)
+				writeRow(msg, retrievePreview, headers, "", nil)
+			}
+		}
+	}
+
+	if len(config.Messages) == 0 {
+		logger.LogInfo(slogLogger, "Retrieve completed", "host", config.Host, "total_messages", count)
+		fmt.Println("\n✓ Retrieve completed (use --message to download messages)")
+		return nil
+	}
+
+	selected, err := selectMessages(config.Messages, messages)
+	if err != nil {
+		return fail(err)
+	}
+	if err := os.MkdirAll(config.OutputDir, 0o755); err != nil {
+		return fail(fmt.Errorf("failed to create output directory: %w", err))
+	}
+
+	fmt.Printf("\nDownloading %d message(s) to %s:\n", len(selected), config.OutputDir)
+	var saved []protocol.MessageInfo
+	for _, msg := range selected {
+		path, written, err := saveMessage(ctx, client, config.OutputDir, msg)
+		if err != nil {
+			logger.LogError(slogLogger, "Download failed", "error", err, "message", msg.Number)
+			fmt.Printf("  ✗ Message %d: %v\n", msg.Number, err)
+			writeRow(msg, retrieveSaved, previewHeaders{}, path, err)
+			continue
+		}
+		fmt.Printf("  ✓ Message %d → %s (%d bytes)\n", msg.Number, path, written)
+		if written != msg.Size {
+			fmt.Printf("    ⚠ LIST reported %d bytes\n", msg.Size)
+		}
+		writeRow(msg, retrieveSaved, previewHeaders{}, path, nil)
+		saved = append(saved, msg)
+	}
+
+	if config.DeleteAfter && len(saved) > 0 {
+		var marked []protocol.MessageInfo
+		var deleErr error
+		for _, msg := range saved {
+			if deleErr = client.Dele(ctx, msg.Number); deleErr != nil {
+				break
+			}
+			marked = append(marked, msg)
+		}
+		// Deletions only take effect when the server accepts QUIT; after a
+		// DELE failure nothing is committed
+		if deleErr != nil {
+			_ = client.Close()
+		} else if err := client.Quit(); err != nil {
+			deleErr = fmt.Errorf("deletions not committed: %w", err)
+		}
+		for _, msg := range marked {
+			writeRow(msg, retrieveDeleted, previewHeaders{}, "", deleErr)
+		}
+		if deleErr != nil {
+			logger.LogError(slogLogger, "Delete failed", "error", deleErr)
+			return fmt.Errorf("--delete-after failed: %w", deleErr)
+		}
+		fmt.Printf("✓ Deleted %d message(s) from the server\n", len(marked))
+	}
+
+	logger.LogInfo(slogLogger, "Retrieve completed",
+		"host", config.Host,
+		"total_messages", count,
+		"downloaded", len(saved),
+		"deleted", config.DeleteAfter)
+
+	if failed := len(selected) - len(saved); failed > 0 {
+		return fmt.Errorf("%d of %d message(s) could not be downloaded", failed, len(selected))
+	}
+	fmt.Println("\n✓ Retrieve completed")
+	return nil
+}
+
+// selectMessages resolves --message selectors to messages. A number selects
+// that message number; anything else, or a number that is not a message
+// number, is looked up as a UIDL. Duplicates are dropped.
+func selectMessages(selectors []string, messages []protocol.MessageInfo) ([]protocol.MessageInfo, error) {
+	byNumber := make(map[int]protocol.MessageInfo, len(messages))
+	byUIDL := make(map[string]protocol.MessageInfo, len(messages))
+	for _, msg := range messages {
+		byNumber[msg.Number] = msg
+		if msg.UIDL != "" {
+			byUIDL[msg.UIDL] = msg
+		}
+	}
+
+	seen := make(map[int]bool)
+	var selected []protocol.MessageInfo
+	for _, sel := range selectors {
+		msg, ok := protocol.MessageInfo{}, false
+		if n, err := strconv.Atoi(sel); err == nil {
+			msg, ok = byNumber[n]
+		}
+		if !ok {
+			msg, ok = byUIDL[sel]
+		}
+		if !ok {
+			return nil, fmt.Errorf("message %q not found (mailbox has %d messages)", sel, len(messages))
+		}
+		if !seen[msg.Number] {
+			seen[msg.Number] = true
+			selected = append(selected, msg)
+		}
+	}
+	return selected, nil
+}
+
+// saveMessage downloads msg with RETR into dir. The file is written under a
+// temporary name and renamed once complete, so an interrupted download never
+// leaves a truncated .eml behind.
+func saveMessage(ctx context.Context, client *POP3Client, dir string, msg protocol.MessageInfo) (string, int64, error) {
+	path := filepath.Join(dir, emlFileName(msg))
+
+	f, err := os.CreateTemp(dir, ".retrieve-*.tmp")
+	if err != nil {
+		return path, 0, err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	written, err := client.Retr(ctx, msg.Number, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return path, written, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return path, written, err
+	}
+	return path, written, nil
+}
+
+// emlFileName names a downloaded message after its UIDL, which stays the
+// same across sessions, or its message number when there is no UIDL. UIDLs
+// with characters that are unsafe in file names get a short hash appended so
+// two different UIDLs never share a file.
+func emlFileName(msg protocol.MessageInfo) string {
+	if msg.UIDL == "" {
+		return fmt.Sprintf("message-%d.eml", msg.Number)
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, msg.UIDL)
+	if safe != msg.UIDL || strings.HasPrefix(safe, ".") {
+		sum := sha1.Sum([]byte(msg.UIDL))
+		safe += "-" + hex.EncodeToString(sum[:4])
+	}
+	return safe + ".eml"
+}
+
+// parsePreviewHeaders extracts From, Subject and Date from TOP output,
+// decoding RFC 2047 encoded words.
+func parsePreviewHeaders(lines []string) previewHeaders {
+	msg, err := mail.ReadMessage(strings.NewReader(strings.Join(lines, "\r\n") + "\r\n\r\n"))
+	if err != nil {
+		return previewHeaders{}
+	}
+	dec := new(mime.WordDecoder)
+	decode := func(name string) string {
+		value := msg.Header.Get(name)
+		if decoded, err := dec.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+	return previewHeaders{
+		From:    decode("From"),
+		Subject: decode("Subject"),
+		Date:    msg.Header.Get("Date"),
+	}
+}
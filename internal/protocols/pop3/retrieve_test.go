@@ -0,0 +1,370 @@
+//go:build !integration
+// +build !integration
+
+package pop3
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// recordingLogger captures CSV rows in memory.
+type recordingLogger struct {
+	header []string
+	rows   [][]string
+}
+
+func (l *recordingLogger) WriteHeader(columns []string) error {
+	l.header = columns
+	return nil
+}
+
+func (l *recordingLogger) WriteRow(row []string) error {
+	l.rows = append(l.rows, row)
+	return nil
+}
+
+func (l *recordingLogger) Close() error { return nil }
+
+func (l *recordingLogger) ShouldWriteHeader() (bool, error) { return l.header == nil, nil }
+
+// column returns the value of the named column in row.
+func (l *recordingLogger) column(row []string, name string) string {
+	for i, col := range l.header {
+		if col == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+// fakePOP3Message is one message of the fake server, with LF line endings
+// that the server sends as CRLF.
+type fakePOP3Message struct {
+	uidl string
+	body string
+}
+
+// fakePOP3Messages are what startFakePOP3Server serves at first, in
+// message-number order.
+var fakePOP3Messages = []fakePOP3Message{
+	{uidl: "uid-alpha", body: "From: Alice <alice@example.com>\nSubject: Alpha\nDate: Mon, 02 Jan 2026 10:00:00 +0000\n\nAlpha body\n"},
+	{uidl: "uid/beta", body: "From: =?UTF-8?Q?Bj=C3=B6rn?= <bjorn@example.com>\nSubject: =?UTF-8?B?QmV0YSDinJM=?=\nDate: Tue, 03 Jan 2026 10:00:00 +0000\n\n.leading dot\n.\n..\nend\n"},
+}
+
+// fakePOP3Server serves a maildrop that each session sees as it was at
+// login. The messages deleted in a session that ended with an accepted QUIT
+// are removed and recorded.
+type fakePOP3Server struct {
+	mu       sync.Mutex
+	messages []fakePOP3Message
+	deleted  []int
+}
+
+func (s *fakePOP3Server) committed() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.deleted...)
+}
+
+// setMessages replaces the maildrop for later sessions.
+func (s *fakePOP3Server) setMessages(messages ...fakePOP3Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append([]fakePOP3Message(nil), messages...)
+}
+
+func (s *fakePOP3Server) snapshot() []fakePOP3Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakePOP3Message(nil), s.messages...)
+}
+
+// startFakePOP3Server serves fakePOP3Messages. capa lists the CAPA response.
+func startFakePOP3Server(t *testing.T, capa ...string) (*Config, *fakePOP3Server) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	server := &fakePOP3Server{}
+	server.setMessages(fakePOP3Messages...)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, capa)
+		}
+	}()
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	config.Username = "tester"
+	config.Password = "secret"
+	return config, server
+}
+
+func (s *fakePOP3Server) serve(conn net.Conn, capa []string) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	send := func(lines ...string) {
+		for _, line := range lines {
+			fmt.Fprintf(conn, "%s\r\n", line)
+		}
+	}
+	wire := func(body string) string {
+		var b strings.Builder
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			if strings.HasPrefix(line, ".") {
+				line = "." + line
+			}
+			b.WriteString(line + "\r\n")
+		}
+		return b.String() + "."
+	}
+
+	messages := s.snapshot()
+	var marked []int
+	send("+OK fake POP3 ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var n int
+		if len(fields) > 1 {
+			fmt.Sscanf(fields[1], "%d", &n)
+		}
+		valid := n >= 1 && n <= len(messages)
+
+		switch strings.ToUpper(fields[0]) {
+		case "CAPA":
+			send("+OK")
+			send(capa...)
+			send(".")
+		case "USER", "PASS":
+			send("+OK")
+		case "STAT":
+			send(fmt.Sprintf("+OK %d 0", len(messages)))
+		case "LIST":
+			send("+OK")
+			for i, m := range messages {
+				send(fmt.Sprintf("%d %d", i+1, len(strings.ReplaceAll(m.body, "\n", "\r\n"))))
+			}
+			send(".")
+		case "UIDL":
+			send("+OK")
+			for i, m := range messages {
+				send(fmt.Sprintf("%d %s", i+1, m.uidl))
+			}
+			send(".")
+		case "TOP":
+			if !valid {
+				send("-ERR no such message")
+				continue
+			}
+			headers, _, _ := strings.Cut(messages[n-1].body, "\n\n")
+			send("+OK", wire(headers+"\n\n"))
+		case "RETR":
+			if !valid {
+				send("-ERR no such message")
+				continue
+			}
+			send("+OK", wire(messages[n-1].body))
+		case "DELE":
+			if !valid {
+				send("-ERR no such message")
+				continue
+			}
+			marked = append(marked, n)
+			send("+OK")
+		case "QUIT":
+			s.mu.Lock()
+			s.deleted = append(s.deleted, marked...)
+			for _, n := range marked {
+				for i, m := range s.messages {
+					if m == messages[n-1] {
+						s.messages = append(s.messages[:i], s.messages[i+1:]...)
+						break
+					}
+				}
+			}
+			s.mu.Unlock()
+			send("+OK bye")
+			return
+		default:
+			send("-ERR unknown command")
+		}
+	}
+}
+
+func TestSelectMessages(t *testing.T) {
+	messages := []protocol.MessageInfo{
+		{Number: 1, Size: 10, UIDL: "abc"},
+		{Number: 2, Size: 20, UIDL: "7"},
+		{Number: 3, Size: 30, UIDL: "42"},
+	}
+
+	tests := []struct {
+		name      string
+		selectors []string
+		want      []int
+		wantErr   bool
+	}{
+		{name: "number", selectors: []string{"2"}, want: []int{2}},
+		{name: "UIDL", selectors: []string{"abc"}, want: []int{1}},
+		{name: "numeric UIDL", selectors: []string{"42"}, want: []int{3}},
+		{name: "number wins over UIDL", selectors: []string{"3"}, want: []int{3}},
+		{name: "duplicates", selectors: []string{"1", "abc", "1"}, want: []int{1}},
+		{name: "unknown", selectors: []string{"missing"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectMessages(tt.selectors, messages)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("selectMessages(%v) expected error, got %v", tt.selectors, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectMessages(%v) error = %v", tt.selectors, err)
+			}
+			var numbers []int
+			for _, m := range got {
+				numbers = append(numbers, m.Number)
+			}
+			if fmt.Sprint(numbers) != fmt.Sprint(tt.want) {
+				t.Errorf("selectMessages(%v) = %v, want %v", tt.selectors, numbers, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmlFileName(t *testing.T) {
+	if got := emlFileName(protocol.MessageInfo{Number: 4}); got != "message-4.eml" {
+		t.Errorf("emlFileName(no UIDL) = %q", got)
+	}
+	if got := emlFileName(protocol.MessageInfo{Number: 4, UIDL: "uid-1.x_y"}); got != "uid-1.x_y.eml" {
+		t.Errorf("emlFileName(safe UIDL) = %q", got)
+	}
+	a := emlFileName(protocol.MessageInfo{UIDL: "a/b"})
+	b := emlFileName(protocol.MessageInfo{UIDL: "a_b"})
+	if a == b || !strings.HasPrefix(a, "a_b-") || strings.ContainsAny(a, `/\`) {
+		t.Errorf("emlFileName(a/b) = %q, emlFileName(a_b) = %q; want distinct safe names", a, b)
+	}
+}
+
+func TestRetrieveMail_PreviewAndDownload(t *testing.T) {
+	config, server := startFakePOP3Server(t, "TOP", "UIDL", "USER")
+	config.Action = ActionRetrieve
+	config.Messages = []string{"1", "uid/beta"}
+	config.OutputDir = t.TempDir()
+
+	csvLog := &recordingLogger{}
+	if err := retrieveMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("retrieveMail() error = %v", err)
+	}
+
+	var previews, saves int
+	for _, row := range csvLog.rows {
+		switch csvLog.column(row, "Operation") {
+		case retrievePreview:
+			previews++
+			if csvLog.column(row, "Message_Number") == "2" &&
+				(csvLog.column(row, "Subject") != "Beta ✓" || !strings.HasPrefix(csvLog.column(row, "From"), "Björn")) {
+				t.Errorf("preview row = %v, want decoded headers", row)
+			}
+		case retrieveSaved:
+			saves++
+		}
+	}
+	if previews != 2 || saves != 2 {
+		t.Errorf("rows = %v, want 2 previews and 2 downloads", csvLog.rows)
+	}
+
+	beta := emlFileName(protocol.MessageInfo{Number: 2, UIDL: "uid/beta"})
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, beta))
+	if err != nil {
+		t.Fatalf("read %s: %v", beta, err)
+	}
+	want := strings.ReplaceAll(fakePOP3Messages[1].body, "\n", "\r\n")
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", beta, data, want)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "uid-alpha.eml")); err != nil {
+		t.Errorf("uid-alpha.eml not saved: %v", err)
+	}
+	if deleted := server.committed(); len(deleted) != 0 {
+		t.Errorf("deleted %v without --delete-after", deleted)
+	}
+}
+
+func TestRetrieveMail_NoTOP(t *testing.T) {
+	config, _ := startFakePOP3Server(t, "UIDL", "USER")
+	config.Action = ActionRetrieve
+
+	csvLog := &recordingLogger{}
+	if err := retrieveMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("retrieveMail() error = %v", err)
+	}
+	if len(csvLog.rows) != 0 {
+		t.Errorf("rows = %v, want no preview without TOP", csvLog.rows)
+	}
+}
+
+func TestRetrieveMail_DeleteAfter(t *testing.T) {
+	config, server := startFakePOP3Server(t, "TOP", "UIDL", "USER")
+	config.Action = ActionRetrieve
+	config.Preview = 0
+	config.Messages = []string{"2"}
+	config.OutputDir = t.TempDir()
+	config.DeleteAfter = true
+
+	csvLog := &recordingLogger{}
+	if err := retrieveMail(t.Context(), config, csvLog, nil); err != nil {
+		t.Fatalf("retrieveMail() error = %v", err)
+	}
+	if deleted := server.committed(); fmt.Sprint(deleted) != "[2]" {
+		t.Errorf("committed deletions = %v, want [2]", deleted)
+	}
+	last := csvLog.rows[len(csvLog.rows)-1]
+	if csvLog.column(last, "Operation") != retrieveDeleted || csvLog.column(last, "Status") != "SUCCESS" {
+		t.Errorf("last row = %v, want a successful DELETED row", last)
+	}
+}
+
+func TestRetrieveMail_UnknownMessage(t *testing.T) {
+	config, server := startFakePOP3Server(t, "UIDL", "USER")
+	config.Action = ActionRetrieve
+	config.Messages = []string{"9"}
+	config.OutputDir = t.TempDir()
+	config.DeleteAfter = true
+
+	err := retrieveMail(t.Context(), config, &recordingLogger{}, nil)
+	if err == nil || !strings.Contains(err.Error(), `message "9" not found`) {
+		t.Fatalf("retrieveMail() error = %v, want message not found", err)
+	}
+	if deleted := server.committed(); len(deleted) != 0 {
+		t.Errorf("deleted %v after a failed selection", deleted)
+	}
+}
@@ -0,0 +1,88 @@
+package pop3
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/emersion/go-sasl"
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// xoauth2Client implements the XOAUTH2 SASL mechanism (Google/Microsoft).
+// Initial response: user=<email>\x01auth=Bearer <token>\x01\x01
+type xoauth2Client struct {
+	username    string
+	accessToken string
+}
+
+var _ sasl.Client = (*xoauth2Client)(nil)
+
+func (a *xoauth2Client) Start() (string, []byte, error) {
+	return "XOAUTH2", []byte(protocol.XOAUTH2Token(a.username, a.accessToken)), nil
+}
+
+func (a *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	// On failure the server sends a base64 JSON error as a continuation;
+	// an empty response lets it finish with -ERR.
+	return []byte{}, nil
+}
+
+// oauthbearerClient implements SASL OAUTHBEARER (RFC 7628):
+//
+//	n,a=<user>,\x01host=<host>\x01port=<port>\x01auth=Bearer <token>\x01\x01
+//
+// An error challenge is answered with the dummy \x01 response required by
+// RFC 7628 §3.2.3 so the server ends the exchange with -ERR. The decoded
+// error status is kept for reporting.
+type oauthbearerClient struct {
+	username    string
+	accessToken string
+	host        string
+	port        int
+
+	serverError *sasl.OAuthBearerError
+}
+
+var _ sasl.Client = (*oauthbearerClient)(nil)
+
+func (a *oauthbearerClient) Start() (string, []byte, error) {
+	ir := fmt.Sprintf("n,a=%s,\x01host=%s\x01port=%d\x01auth=Bearer %s\x01\x01",
+		a.username, a.host, a.port, a.accessToken)
+	return sasl.OAuthBearer, []byte(ir), nil
+}
+
+func (a *oauthbearerClient) Next(challenge []byte) ([]byte, error) {
+	serverError := &sasl.OAuthBearerError{}
+	if err := json.Unmarshal(challenge, serverError); err == nil {
+		a.serverError = serverError
+	}
+	return []byte("\x01"), nil
+}
+
+// loginClient implements the LOGIN mechanism without an initial response:
+// the server prompts for the username and then the password. go-sasl's
+// client sends the username up front, which POP3 servers such as Exchange
+// do not accept. The prompt text is not checked since servers word it
+// differently.
+type loginClient struct {
+	username string
+	password string
+	step     int
+}
+
+var _ sasl.Client = (*loginClient)(nil)
+
+func (a *loginClient) Start() (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginClient) Next(challenge []byte) ([]byte, error) {
+	a.step++
+	switch a.step {
+	case 1:
+		return []byte(a.username), nil
+	case 2:
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("LOGIN: unexpected challenge %q", challenge)
+}
@@ -0,0 +1,250 @@
+//go:build !integration
+// +build !integration
+
+package pop3
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// authStep is one client line the scripted server expects, and its replies.
+type authStep struct {
+	expect string
+	prefix bool // expect is a prefix of the line; a base64 prefix must encode a multiple of 3 bytes
+	reply  []string
+}
+
+// startScriptedAuthServer accepts one connection, answers CAPA with capa and
+// then plays steps, reporting the first unexpected client line on the
+// returned channel (nil when the script completed).
+func startScriptedAuthServer(t *testing.T, capa []string, steps []authStep) (*Config, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		send := func(lines ...string) {
+			for _, line := range lines {
+				fmt.Fprintf(conn, "%s\r\n", line)
+			}
+		}
+		read := func() string {
+			line, _ := r.ReadString('\n')
+			return strings.TrimRight(line, "\r\n")
+		}
+
+		send("+OK scripted server ready")
+		if line := read(); line != "CAPA" {
+			done <- fmt.Errorf("got %q, want CAPA", line)
+			return
+		}
+		send("+OK")
+		send(capa...)
+		send(".")
+
+		for _, step := range steps {
+			line := read()
+			if line != step.expect && !(step.prefix && strings.HasPrefix(line, step.expect)) {
+				done <- fmt.Errorf("got %q, want %q", line, step.expect)
+				return
+			}
+			send(step.reply...)
+		}
+		done <- nil
+	}()
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	return config, done
+}
+
+// runAuth connects to the scripted server and authenticates.
+func runAuth(t *testing.T, config *Config, username, password, accessToken string) (string, error) {
+	t.Helper()
+
+	client := NewPOP3Client(config)
+	if err := client.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Close()
+	if _, err := client.Capabilities(t.Context()); err != nil {
+		t.Fatalf("Capabilities() error = %v", err)
+	}
+	method := client.SelectAuthMethod(accessToken != "")
+	return method, client.Auth(t.Context(), username, password, accessToken)
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestAuth_PlainInitialResponse(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"USER", "SASL PLAIN LOGIN"}, []authStep{
+		{expect: "AUTH PLAIN " + b64("\x00tester\x00secret"), reply: []string{"+OK welcome"}},
+	})
+
+	method, err := runAuth(t, config, "tester", "secret", "")
+	if method != "PLAIN" || err != nil {
+		t.Errorf("Auth() = %s, %v; want PLAIN, nil", method, err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_Login(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"USER", "SASL LOGIN"}, []authStep{
+		{expect: "AUTH LOGIN", reply: []string{"+ " + b64("Username:")}},
+		{expect: b64("tester"), reply: []string{"+ " + b64("Password:")}},
+		{expect: b64("secret"), reply: []string{"+OK welcome"}},
+	})
+
+	method, err := runAuth(t, config, "tester", "secret", "")
+	if method != "LOGIN" || err != nil {
+		t.Errorf("Auth() = %s, %v; want LOGIN, nil", method, err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_LongInitialResponse(t *testing.T) {
+	// The XOAUTH2 initial response does not fit in a 255-octet AUTH line,
+	// so it is sent after the server's empty challenge (RFC 5034 §4)
+	token := strings.Repeat("t", 300)
+	config, done := startScriptedAuthServer(t, []string{"SASL XOAUTH2"}, []authStep{
+		{expect: "AUTH XOAUTH2", reply: []string{"+"}},
+		{expect: b64("user=tester@example.com\x01auth=Bearer " + token + "\x01\x01"), reply: []string{"+OK welcome"}},
+	})
+
+	method, err := runAuth(t, config, "tester@example.com", "", token)
+	if method != "XOAUTH2" || err != nil {
+		t.Errorf("Auth() = %s, %v; want XOAUTH2, nil", method, err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_OAuthBearerError(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"SASL PLAIN OAUTHBEARER"}, []authStep{
+		{expect: "AUTH OAUTHBEARER " + b64("n,a=tester,\x01host=127.0.0.1\x01por"), prefix: true,
+			reply: []string{"+ " + b64(`{"status":"invalid_token","scope":"pop"}`)}},
+		{expect: b64("\x01"), reply: []string{"-ERR authentication failed"}},
+	})
+
+	method, err := runAuth(t, config, "tester", "", "expired")
+	if method != "OAUTHBEARER" {
+		t.Errorf("method = %s, want OAUTHBEARER", method)
+	}
+	if err == nil || !strings.Contains(err.Error(), "status invalid_token") || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Auth() error = %v, want the server status and -ERR text", err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_NTLMRejected(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"USER", "SASL NTLM GSSAPI PLAIN"}, []authStep{
+		{expect: "AUTH NTLM " + b64("NTLMSSP\x00\x01"), prefix: true, reply: []string{"-ERR NTLM is disabled"}},
+	})
+
+	method, err := runAuth(t, config, `CONTOSO\tester`, "secret", "")
+	if method != "NTLM" {
+		t.Errorf("method = %s, want NTLM for an Exchange-style SASL list", method)
+	}
+	if err == nil || !strings.Contains(err.Error(), "NTLM authentication failed: NTLM is disabled") {
+		t.Errorf("Auth() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_InvalidChallengeCancels(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"SASL LOGIN"}, []authStep{
+		{expect: "AUTH LOGIN", reply: []string{"+ not base64!"}},
+		{expect: "*", reply: []string{"-ERR cancelled"}},
+	})
+
+	if _, err := runAuth(t, config, "tester", "secret", ""); err == nil {
+		t.Error("Auth() expected an error for an undecodable challenge")
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuth_UserFallback(t *testing.T) {
+	config, done := startScriptedAuthServer(t, []string{"USER", "UIDL"}, []authStep{
+		{expect: "USER tester", reply: []string{"+OK"}},
+		{expect: "PASS secret", reply: []string{"+OK welcome"}},
+	})
+
+	method, err := runAuth(t, config, "tester", "secret", "")
+	if method != "USER" || err != nil {
+		t.Errorf("Auth() = %s, %v; want USER, nil", method, err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConnect_POP3SHandshakeTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	// Accept the connection but never answer the TLS ClientHello
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	})
+
+	config := NewConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	config.POP3S = true
+	config.Timeout = 200 * time.Millisecond
+
+	start := time.Now()
+	err = NewPOP3Client(config).Connect(t.Context())
+	if err == nil || !strings.Contains(err.Error(), "POP3S connection failed") {
+		t.Fatalf("Connect() error = %v, want a failed POP3S handshake", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Connect() took %s, want it bounded by the 200ms timeout", elapsed)
+	}
+}
@@ -0,0 +1,54 @@
+package pop3
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// openSession connects, upgrades to TLS when --starttls is set, reads the
+// capabilities and authenticates, printing progress as listmail does. The
+// caller must Quit the returned client.
+func openSession(ctx context.Context, config *Config, slogLogger *slog.Logger) (*POP3Client, *protocol.Capabilities, error) {
+	client := NewPOP3Client(config)
+
+	if err := client.Connect(ctx); err != nil {
+		logger.LogError(slogLogger, "Connection failed",
+			"error", err,
+			"host", config.Host,
+			"port", config.Port)
+		return nil, nil, fmt.Errorf("connection failed: %w", err)
+	}
+	fmt.Printf("✓ Connected to %s:%d\n", config.Host, config.Port)
+
+	if config.StartTLS && client.GetTLSState() == nil {
+		fmt.Println("Upgrading to TLS via STLS...")
+		if err := client.StartTLS(nil); err != nil {
+			logger.LogError(slogLogger, "STLS upgrade failed", "error", err)
+			_ = client.Close()
+			return nil, nil, fmt.Errorf("STLS failed: %w", err)
+		}
+		fmt.Println("✓ TLS upgrade successful")
+	}
+
+	caps, _ := client.Capabilities(ctx)
+	if caps == nil {
+		caps = protocol.NewCapabilities(nil)
+	}
+
+	fmt.Printf("Authenticating with method: %s\n", client.SelectAuthMethod(config.AccessToken != ""))
+
+	if authErr := client.Auth(ctx, config.Username, config.Password, config.AccessToken); authErr != nil {
+		logger.LogError(slogLogger, "Authentication failed",
+			"error", authErr,
+			"username", maskUsername(config.Username))
+		_ = client.Quit()
+		return nil, nil, fmt.Errorf("authentication failed: %w", authErr)
+	}
+	fmt.Println("✓ Authentication successful")
+
+	return client, caps, nil
+}
@@ -0,0 +1,424 @@
+package pop3
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// Sync operations reported per message
+const (
+	syncDownloaded = "DOWNLOADED"
+	syncDeleted    = "DELETED"
+	syncChecked    = "CHECKED"
+)
+
+// UIDL instability issues. RFC 1939 requires a UIDL to stay the same across
+// sessions and never to be reused; clients that rely on this lose or
+// duplicate mail when a server breaks it.
+const (
+	issueMissingUIDL   = "MISSING_UIDL"   // a message has no UIDL, so it cannot be tracked
+	issueDuplicateUIDL = "DUPLICATE_UIDL" // two messages share a UIDL in one session
+	issueUIDLReused    = "UIDL_REUSED"    // a downloaded UIDL now names a different message (missing mail)
+	issueUIDLChanged   = "UIDL_CHANGED"   // a downloaded message came back under a new UIDL (duplicates)
+)
+
+// syncIssue is one UIDL instability found during a sync.
+type syncIssue struct {
+	Kind    string
+	Message protocol.MessageInfo
+	Detail  string
+}
+
+// syncPlan is what a sync will do, worked out from the server's listing and
+// the state file.
+type syncPlan struct {
+	New     []protocol.MessageInfo // Not downloaded yet
+	Seen    []protocol.MessageInfo // Already downloaded
+	Expired []protocol.MessageInfo // Downloaded more than --leave-days ago
+	Gone    []string               // UIDLs in the state that are no longer on the server
+	Issues  []syncIssue
+}
+
+// syncMail downloads the messages whose UIDL is not in the state file into
+// a Maildir, as a POP3 client set to leave messages on the server does. The
+// state file is saved after each message, so an interrupted sync resumes
+// where it stopped. Messages are deleted from the server right after download
+// with --leave-on-server=false, or --leave-days after their download. UIDL
+// instability (missing, duplicate, reused or changed UIDLs) is reported and
+// fails the action once the sync has finished.
+func syncMail(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	stateFile := config.StateFile
+	if stateFile == "" {
+		stateFile = filepath.Join(config.Maildir, syncStateName)
+	}
+	fmt.Printf("Syncing %s on %s:%d to Maildir %s...\n", maskUsername(config.Username), config.Host, config.Port, config.Maildir)
+
+	columns := []string{"Action", "Status", "Server", "Port", "Message_Number", "UIDL", "Size", "Operation", "Issue", "File", "Error"}
+	if shouldWrite, _ := csvLogger.ShouldWriteHeader(); shouldWrite {
+		if err := csvLogger.WriteHeader(columns); err != nil {
+			logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+		}
+	}
+
+	writeRow := func(msg protocol.MessageInfo, operation, issue, file string, err error) {
+		status, errMsg := "SUCCESS", ""
+		if err != nil {
+			status, errMsg = "FAILURE", err.Error()
+		}
+		number, size := "", ""
+		if msg.Number > 0 {
+			number = strconv.Itoa(msg.Number)
+			size = strconv.FormatInt(msg.Size, 10)
+		}
+		if logErr := csvLogger.WriteRow([]string{
+			config.Action, status, config.Host, fmt.Sprintf("%d", config.Port),
+			number, msg.UIDL, size, operation, issue, file, errMsg,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+	}
+	fail := func(err error) error {
+		writeRow(protocol.MessageInfo{}, "", "", "", err)
+		return err
+	}
+
+	if err := prepareMaildir(config.Maildir); err != nil {
+		return fail(fmt.Errorf("cannot create Maildir: %w", err))
+	}
+	if err := os.MkdirAll(filepath.Dir(stateFile), 0o755); err != nil {
+		return fail(fmt.Errorf("cannot create state directory: %w", err))
+	}
+	state, err := loadSyncState(stateFile)
+	if err != nil {
+		return fail(err)
+	}
+	account := state.account(syncAccountKey(config))
+	if !account.LastSync.IsZero() {
+		fmt.Printf("✓ State: %d message(s) downloaded before, last sync %s\n", len(account.Seen), account.LastSync.Local().Format(time.RFC1123))
+	} else {
+		fmt.Println("✓ State: first sync for this account")
+	}
+
+	client, caps, err := openSession(ctx, config, slogLogger)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = client.Quit() }()
+
+	count, size, err := client.Stat(ctx)
+	if err != nil {
+		logger.LogError(slogLogger, "STAT command failed", "error", err)
+		return fail(fmt.Errorf("STAT failed: %w", err))
+	}
+	fmt.Printf("✓ Mailbox: %d message(s), %d bytes\n", count, size)
+
+	var messages []protocol.MessageInfo
+	if count > 0 {
+		if messages, err = client.List(ctx); err != nil {
+			logger.LogError(slogLogger, "LIST command failed", "error", err)
+			return fail(fmt.Errorf("LIST failed: %w", err))
+		}
+		uidls, err := client.UIDL(ctx)
+		if err != nil {
+			logger.LogError(slogLogger, "UIDL command failed", "error", err)
+			return fail(fmt.Errorf("sync requires UIDL, which failed: %w", err))
+		}
+		byNumber := make(map[int]string, len(uidls))
+		for _, u := range uidls {
+			byNumber[u.Number] = u.UIDL
+		}
+		for i := range messages {
+			messages[i].UIDL = byNumber[messages[i].Number]
+		}
+	}
+
+	now := time.Now()
+	plan := planSync(account, messages, now, leaveDays(config))
+	issues := plan.Issues
+
+	// Downloaded messages by content, to recognise one that comes back
+	// under a new UIDL; taken before the UIDLs no longer listed are pruned
+	byHash := make(map[string]string, len(account.Seen))
+	byMessageID := make(map[string]string, len(account.Seen))
+	for uidl, seen := range account.Seen {
+		byHash[seen.SHA256] = uidl
+		if seen.MessageID != "" {
+			byMessageID[seen.MessageID] = uidl
+		}
+	}
+
+	for _, uidl := range plan.Gone {
+		delete(account.Seen, uidl)
+	}
+	fmt.Printf("✓ %d new, %d already downloaded, %d no longer on the server\n", len(plan.New), len(plan.Seen), len(plan.Gone))
+
+	// Message-ID comparison catches a reused UIDL whose size did not change
+	reused := make(map[string]bool)
+	for _, issue := range plan.Issues {
+		if issue.Kind == issueUIDLReused {
+			reused[issue.Message.UIDL] = true
+		}
+	}
+	if config.VerifySeen && len(plan.Seen) > 0 {
+		if !caps.SupportsTOP() {
+			fmt.Println("⚠ Server does not advertise TOP; --verify-seen only compares sizes")
+		} else {
+			verified := 0
+			for _, msg := range plan.Seen {
+				seen := account.Seen[msg.UIDL]
+				if seen.MessageID == "" || reused[msg.UIDL] {
+					continue
+				}
+				lines, err := client.Top(ctx, msg.Number, 0)
+				if err != nil {
+					writeRow(msg, syncChecked, "", "", err)
+					continue
+				}
+				verified++
+				if id := previewMessageID(lines); id != "" && id != seen.MessageID {
+					reused[msg.UIDL] = true
+					issues = append(issues, syncIssue{Kind: issueUIDLReused, Message: msg,
+						Detail: fmt.Sprintf("Message-ID was %s, now %s; clients that track UIDLs will never download this message", seen.MessageID, id)})
+				}
+			}
+			fmt.Printf("✓ Verified the Message-ID of %d downloaded message(s)\n", verified)
+		}
+	}
+
+	var downloaded []protocol.MessageInfo
+	var downloadedBytes int64
+	failed := 0
+	if len(plan.New) > 0 {
+		fmt.Printf("\nDownloading %d message(s):\n", len(plan.New))
+	}
+	for _, msg := range plan.New {
+		seen, err := downloadToMaildir(ctx, client, config.Maildir, msg)
+		if err != nil {
+			failed++
+			logger.LogError(slogLogger, "Download failed", "error", err, "message", msg.Number, "uidl", msg.UIDL)
+			fmt.Printf("  ✗ Message %d (%s): %v\n", msg.Number, msg.UIDL, err)
+			writeRow(msg, syncDownloaded, "", "", err)
+			continue
+		}
+
+		issue := ""
+		if old, ok := byHash[seen.SHA256]; ok {
+			issue = issueUIDLChanged
+			issues = append(issues, syncIssue{Kind: issueUIDLChanged, Message: msg,
+				Detail: fmt.Sprintf("same content as UIDL %s, downloaded before; clients will show a duplicate", old)})
+		} else if old, ok := byMessageID[seen.MessageID]; ok && seen.MessageID != "" {
+			issue = issueUIDLChanged
+			issues = append(issues, syncIssue{Kind: issueUIDLChanged, Message: msg,
+				Detail: fmt.Sprintf("same Message-ID as UIDL %s, downloaded before; clients will show a duplicate", old)})
+		}
+
+		account.Seen[msg.UIDL] = seen
+		if err := state.save(stateFile); err != nil {
+			writeRow(msg, syncDownloaded, issue, seen.File, err)
+			return fmt.Errorf("cannot save state file: %w", err)
+		}
+		fmt.Printf("  ✓ Message %d (%s) → %s\n", msg.Number, msg.UIDL, seen.File)
+		writeRow(msg, syncDownloaded, issue, seen.File, nil)
+		downloaded = append(downloaded, msg)
+		downloadedBytes += seen.Size
+	}
+
+	var toDelete []protocol.MessageInfo
+	if !config.LeaveOnServer {
+		toDelete = downloaded
+	} else {
+		// A reused UIDL names a message that was never downloaded
+		for _, msg := range plan.Expired {
+			if !reused[msg.UIDL] {
+				toDelete = append(toDelete, msg)
+			}
+		}
+	}
+	var deleteErr error
+	if len(toDelete) > 0 {
+		for _, msg := range toDelete {
+			if deleteErr = client.Dele(ctx, msg.Number); deleteErr != nil {
+				break
+			}
+		}
+		// Deletions only take effect when the server accepts QUIT. The
+		// messages stay in the state until a later sync no longer sees them,
+		// so a deletion the server did not commit is retried.
+		if deleteErr != nil {
+			_ = client.Close()
+		} else if err := client.Quit(); err != nil {
+			deleteErr = fmt.Errorf("deletions not committed: %w", err)
+		}
+		for _, msg := range toDelete {
+			writeRow(msg, syncDeleted, "", "", deleteErr)
+		}
+		if deleteErr == nil {
+			fmt.Printf("✓ Deleted %d message(s) from the server\n", len(toDelete))
+		} else {
+			fmt.Printf("✗ Deleting from the server failed: %v\n", deleteErr)
+		}
+	}
+
+	account.LastSync = now
+	if err := state.save(stateFile); err != nil {
+		return fail(fmt.Errorf("cannot save state file: %w", err))
+	}
+
+	if len(issues) > 0 {
+		fmt.Printf("\n⚠ UIDL instability: %d issue(s)\n", len(issues))
+		for _, issue := range issues {
+			fmt.Printf("  %-15s message %d, UIDL %q: %s\n", issue.Kind, issue.Message.Number, issue.Message.UIDL, issue.Detail)
+			if issue.Kind != issueUIDLChanged {
+				// UIDL_CHANGED is already on the message's DOWNLOADED row
+				writeRow(issue.Message, syncChecked, issue.Kind, "", fmt.Errorf("%s", issue.Detail))
+			}
+		}
+	}
+
+	logger.LogInfo(slogLogger, "Sync completed",
+		"host", config.Host,
+		"new", len(plan.New),
+		"downloaded", len(downloaded),
+		"bytes", downloadedBytes,
+		"deleted", len(toDelete),
+		"gone", len(plan.Gone),
+		"issues", len(issues))
+
+	fmt.Printf("\nDownloaded %d message(s) (%d bytes), state: %s\n", len(downloaded), downloadedBytes, stateFile)
+
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d of %d new message(s) could not be downloaded", failed, len(plan.New))
+	case deleteErr != nil:
+		return fmt.Errorf("deleting from the server failed: %w", deleteErr)
+	case len(issues) > 0:
+		return fmt.Errorf("UIDL instability detected: %d issue(s)", len(issues))
+	}
+	fmt.Println("✓ Sync completed")
+	return nil
+}
+
+// leaveDays returns --leave-days, which only applies while messages are
+// left on the server.
+func leaveDays(config *Config) int {
+	if !config.LeaveOnServer {
+		return 0
+	}
+	return config.LeaveDays
+}
+
+// planSync compares the server's listing with the downloaded messages in
+// account. Messages without a UIDL or sharing one cannot be tracked and are
+// only reported; a downloaded UIDL whose size changed is reported as reused.
+func planSync(account *syncAccount, messages []protocol.MessageInfo, now time.Time, leaveDays int) syncPlan {
+	var plan syncPlan
+
+	uses := make(map[string]int, len(messages))
+	for _, msg := range messages {
+		uses[msg.UIDL]++
+	}
+
+	for _, msg := range messages {
+		if msg.UIDL == "" {
+			plan.Issues = append(plan.Issues, syncIssue{Kind: issueMissingUIDL, Message: msg,
+				Detail: "the server returned no UIDL for this message"})
+			continue
+		}
+		if n := uses[msg.UIDL]; n > 1 {
+			plan.Issues = append(plan.Issues, syncIssue{Kind: issueDuplicateUIDL, Message: msg,
+				Detail: fmt.Sprintf("%d messages share this UIDL; clients download only one of them", n)})
+			continue
+		}
+
+		seen, ok := account.Seen[msg.UIDL]
+		if !ok {
+			plan.New = append(plan.New, msg)
+			continue
+		}
+		plan.Seen = append(plan.Seen, msg)
+
+		if seen.Size > 0 && msg.Size != seen.Size {
+			plan.Issues = append(plan.Issues, syncIssue{Kind: issueUIDLReused, Message: msg,
+				Detail: fmt.Sprintf("size was %d bytes, now %d; clients that track UIDLs will never download this message", seen.Size, msg.Size)})
+			continue
+		}
+		if leaveDays > 0 && now.Sub(seen.Downloaded) >= time.Duration(leaveDays)*24*time.Hour {
+			plan.Expired = append(plan.Expired, msg)
+		}
+	}
+
+	for uidl := range account.Seen {
+		if uses[uidl] == 0 {
+			plan.Gone = append(plan.Gone, uidl)
+		}
+	}
+	sort.Strings(plan.Gone)
+
+	return plan
+}
+
+// downloadToMaildir retrieves msg into the Maildir's new directory and
+// returns its state entry.
+func downloadToMaildir(ctx context.Context, client *POP3Client, maildir string, msg protocol.MessageInfo) (*syncSeen, error) {
+	hash := sha256.New()
+	now := time.Now()
+
+	file, err := deliverMaildir(maildir, now, func(w io.Writer) error {
+		buf := bufio.NewWriter(io.MultiWriter(w, hash))
+		if _, err := client.Retr(ctx, msg.Number, buf); err != nil {
+			return err
+		}
+		return buf.Flush()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &syncSeen{
+		Size:       msg.Size,
+		SHA256:     hex.EncodeToString(hash.Sum(nil)),
+		MessageID:  fileMessageID(filepath.Join(maildir, file)),
+		File:       file,
+		Downloaded: now,
+	}, nil
+}
+
+// fileMessageID returns the Message-ID header of the message in path, or
+// "" if it has none or cannot be parsed.
+func fileMessageID(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(msg.Header.Get("Message-ID"))
+}
+
+// previewMessageID returns the Message-ID header from TOP output.
+func previewMessageID(lines []string) string {
+	msg, err := mail.ReadMessage(strings.NewReader(strings.Join(lines, "\r\n") + "\r\n\r\n"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(msg.Header.Get("Message-ID"))
+}
@@ -0,0 +1,258 @@
+//go:build !integration
+// +build !integration
+
+package pop3
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/pop3/protocol"
+)
+
+// startFakeSync starts a fake server and configures a sync into a new
+// Maildir.
+func startFakeSync(t *testing.T, capa ...string) (*Config, *fakePOP3Server) {
+	t.Helper()
+	config, server := startFakePOP3Server(t, capa...)
+	config.Action = ActionSync
+	config.Maildir = filepath.Join(t.TempDir(), "Mail")
+	return config, server
+}
+
+func runSync(t *testing.T, config *Config) (*recordingLogger, error) {
+	t.Helper()
+	csvLog := &recordingLogger{}
+	return csvLog, syncMail(t.Context(), config, csvLog, nil)
+}
+
+// operations counts the rows per Operation, and per Issue when one is set.
+func operations(csvLog *recordingLogger) map[string]int {
+	counts := make(map[string]int)
+	for _, row := range csvLog.rows {
+		counts[csvLog.column(row, "Operation")]++
+		if issue := csvLog.column(row, "Issue"); issue != "" {
+			counts[issue]++
+		}
+	}
+	return counts
+}
+
+func TestSyncMail_Incremental(t *testing.T) {
+	config, server := startFakeSync(t, "UIDL", "USER")
+
+	csvLog, err := runSync(t, config)
+	if err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+	if n := operations(csvLog)[syncDownloaded]; n != 2 {
+		t.Fatalf("first sync downloaded %d, want 2: %v", n, csvLog.rows)
+	}
+
+	state, err := loadSyncState(filepath.Join(config.Maildir, syncStateName))
+	if err != nil {
+		t.Fatalf("loadSyncState() error = %v", err)
+	}
+	seen := state.account(syncAccountKey(config)).Seen
+	beta := seen["uid/beta"]
+	if len(seen) != 2 || beta == nil {
+		t.Fatalf("state = %v, want uid-alpha and uid/beta", seen)
+	}
+	data, err := os.ReadFile(filepath.Join(config.Maildir, beta.File))
+	if err != nil {
+		t.Fatalf("read %s: %v", beta.File, err)
+	}
+	if want := strings.ReplaceAll(fakePOP3Messages[1].body, "\n", "\r\n"); string(data) != want {
+		t.Errorf("%s = %q, want %q", beta.File, data, want)
+	}
+	if !strings.HasPrefix(beta.File, "new"+string(filepath.Separator)) {
+		t.Errorf("delivered to %s, want the Maildir new directory", beta.File)
+	}
+
+	csvLog, err = runSync(t, config)
+	if err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	if n := operations(csvLog)[syncDownloaded]; n != 0 {
+		t.Errorf("second sync downloaded %d, want 0", n)
+	}
+
+	gamma := fakePOP3Message{uidl: "uid-gamma", body: "From: carol@example.com\nSubject: Gamma\nMessage-ID: <gamma@example.com>\n\nGamma body\n"}
+	server.setMessages(append(fakePOP3Messages, gamma)...)
+	csvLog, err = runSync(t, config)
+	if err != nil {
+		t.Fatalf("third sync error = %v", err)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "UIDL") != "uid-gamma" {
+		t.Errorf("third sync rows = %v, want only uid-gamma", csvLog.rows)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(config.Maildir, "new"))
+	if len(entries) != 3 {
+		t.Errorf("Maildir new has %d messages, want 3", len(entries))
+	}
+	if deleted := server.committed(); len(deleted) != 0 {
+		t.Errorf("deleted %v while leaving messages on the server", deleted)
+	}
+}
+
+func TestSyncMail_UIDLReused(t *testing.T) {
+	config, server := startFakeSync(t, "UIDL", "USER")
+	if _, err := runSync(t, config); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	// The server hands uid-alpha to a different, longer message
+	server.setMessages(
+		fakePOP3Message{uidl: "uid-alpha", body: "From: mallory@example.com\nSubject: Not alpha\n\nSomething else entirely\n"},
+		fakePOP3Messages[1],
+	)
+	csvLog, err := runSync(t, config)
+	if err == nil || !strings.Contains(err.Error(), "UIDL instability detected: 1 issue(s)") {
+		t.Errorf("sync error = %v, want UIDL instability", err)
+	}
+	ops := operations(csvLog)
+	if ops[issueUIDLReused] != 1 || ops[syncDownloaded] != 0 {
+		t.Errorf("rows = %v, want one UIDL_REUSED and no download", csvLog.rows)
+	}
+}
+
+func TestSyncMail_VerifySeen(t *testing.T) {
+	config, server := startFakeSync(t, "TOP", "UIDL", "USER")
+	config.VerifySeen = true
+	server.setMessages(fakePOP3Message{uidl: "uid-1", body: "Message-ID: <aaa@example.com>\nSubject: First\n\nbody\n"})
+	if _, err := runSync(t, config); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	// Same UIDL and size, different message: only the Message-ID tells
+	server.setMessages(fakePOP3Message{uidl: "uid-1", body: "Message-ID: <bbb@example.com>\nSubject: Other\n\nbody\n"})
+	csvLog, err := runSync(t, config)
+	if err == nil {
+		t.Error("sync expected a UIDL instability error")
+	}
+	if ops := operations(csvLog); ops[issueUIDLReused] != 1 {
+		t.Errorf("rows = %v, want one UIDL_REUSED", csvLog.rows)
+	}
+}
+
+func TestSyncMail_UIDLChanged(t *testing.T) {
+	config, server := startFakeSync(t, "UIDL", "USER")
+	if _, err := runSync(t, config); err != nil {
+		t.Fatalf("first sync error = %v", err)
+	}
+
+	// The server renumbers: the same message comes back under a new UIDL
+	server.setMessages(fakePOP3Message{uidl: "uid-alpha-2", body: fakePOP3Messages[0].body}, fakePOP3Messages[1])
+	csvLog, err := runSync(t, config)
+	if err == nil || !strings.Contains(err.Error(), "UIDL instability") {
+		t.Errorf("sync error = %v, want UIDL instability", err)
+	}
+	if len(csvLog.rows) != 1 || csvLog.column(csvLog.rows[0], "Issue") != issueUIDLChanged {
+		t.Fatalf("rows = %v, want one download flagged UIDL_CHANGED", csvLog.rows)
+	}
+
+	state, _ := loadSyncState(filepath.Join(config.Maildir, syncStateName))
+	seen := state.account(syncAccountKey(config)).Seen
+	if _, ok := seen["uid-alpha"]; ok {
+		t.Error("uid-alpha is no longer on the server but was kept in the state")
+	}
+	if _, ok := seen["uid-alpha-2"]; !ok {
+		t.Error("uid-alpha-2 was downloaded but not recorded")
+	}
+}
+
+func TestSyncMail_DeleteAfterDownload(t *testing.T) {
+	config, server := startFakeSync(t, "UIDL", "USER")
+	config.LeaveOnServer = false
+
+	csvLog, err := runSync(t, config)
+	if err != nil {
+		t.Fatalf("sync error = %v", err)
+	}
+	if ops := operations(csvLog); ops[syncDownloaded] != 2 || ops[syncDeleted] != 2 {
+		t.Errorf("rows = %v, want 2 downloads and 2 deletions", csvLog.rows)
+	}
+	if deleted := server.committed(); fmt.Sprint(deleted) != "[1 2]" {
+		t.Errorf("committed deletions = %v, want [1 2]", deleted)
+	}
+
+	// The next sync finds the mailbox empty and prunes the state
+	if _, err := runSync(t, config); err != nil {
+		t.Fatalf("second sync error = %v", err)
+	}
+	state, _ := loadSyncState(filepath.Join(config.Maildir, syncStateName))
+	if seen := state.account(syncAccountKey(config)).Seen; len(seen) != 0 {
+		t.Errorf("state = %v, want it pruned", seen)
+	}
+}
+
+func TestSyncMail_CorruptState(t *testing.T) {
+	config, _ := startFakeSync(t, "UIDL", "USER")
+	config.StateFile = filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(config.StateFile, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runSync(t, config); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("sync error = %v, want a corrupt state file error", err)
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	account := &syncAccount{Seen: map[string]*syncSeen{
+		"old":     {Size: 100, Downloaded: now.Add(-10 * 24 * time.Hour)},
+		"recent":  {Size: 200, Downloaded: now.Add(-time.Hour)},
+		"resized": {Size: 300, Downloaded: now.Add(-10 * 24 * time.Hour)},
+		"gone":    {Size: 400, Downloaded: now},
+	}}
+	messages := []protocol.MessageInfo{
+		{Number: 1, Size: 100, UIDL: "old"},
+		{Number: 2, Size: 200, UIDL: "recent"},
+		{Number: 3, Size: 301, UIDL: "resized"},
+		{Number: 4, Size: 500, UIDL: "new"},
+		{Number: 5, Size: 600, UIDL: "twice"},
+		{Number: 6, Size: 700, UIDL: "twice"},
+		{Number: 7, Size: 800},
+	}
+
+	plan := planSync(account, messages, now, 7)
+
+	uidls := func(list []protocol.MessageInfo) string {
+		var names []string
+		for _, msg := range list {
+			names = append(names, msg.UIDL)
+		}
+		return strings.Join(names, ",")
+	}
+	if got := uidls(plan.New); got != "new" {
+		t.Errorf("New = %s, want new", got)
+	}
+	if got := uidls(plan.Seen); got != "old,recent,resized" {
+		t.Errorf("Seen = %s, want old,recent,resized", got)
+	}
+	if got := uidls(plan.Expired); got != "old" {
+		t.Errorf("Expired = %s, want old (resized is a different message)", got)
+	}
+	if got := strings.Join(plan.Gone, ","); got != "gone" {
+		t.Errorf("Gone = %s, want gone", got)
+	}
+
+	var kinds []string
+	for _, issue := range plan.Issues {
+		kinds = append(kinds, fmt.Sprintf("%d:%s", issue.Message.Number, issue.Kind))
+	}
+	want := "3:UIDL_REUSED,5:DUPLICATE_UIDL,6:DUPLICATE_UIDL,7:MISSING_UIDL"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Errorf("Issues = %s, want %s", got, want)
+	}
+
+	if plan := planSync(account, messages, now, 0); len(plan.Expired) != 0 {
+		t.Errorf("Expired = %v with --leave-days 0", plan.Expired)
+	}
+}
@@ -0,0 +1,171 @@
+package pop3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// syncStateName is the default state file, kept at the top of the Maildir
+// where Maildir readers ignore it.
+const syncStateName = ".pop3sync-state.json"
+
+// syncStateVersion is written to the state file so later formats can
+// migrate older files.
+const syncStateVersion = 1
+
+// syncState is the sync state file: the messages already downloaded, per
+// account.
+type syncState struct {
+	Version  int                     `json:"version"`
+	Accounts map[string]*syncAccount `json:"accounts"`
+}
+
+// syncAccount holds the UIDLs downloaded from one account.
+type syncAccount struct {
+	LastSync time.Time            `json:"last_sync"`
+	Seen     map[string]*syncSeen `json:"seen"` // keyed by UIDL
+}
+
+// syncSeen is one downloaded message. Size, SHA256 and MessageID identify
+// the message when the server reuses or changes its UIDL.
+type syncSeen struct {
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	MessageID  string    `json:"message_id,omitempty"`
+	File       string    `json:"file"` // Path relative to the Maildir
+	Downloaded time.Time `json:"downloaded"`
+}
+
+// loadSyncState reads the state file at path, or returns an empty state if
+// it does not exist yet. A file that cannot be parsed is an error rather
+// than a fresh start, which would download every message again.
+func loadSyncState(path string) (*syncState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &syncState{Version: syncStateVersion, Accounts: make(map[string]*syncAccount)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state file: %w", err)
+	}
+
+	state := &syncState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("state file %s is corrupt (move it away to start over): %w", path, err)
+	}
+	if state.Version > syncStateVersion {
+		return nil, fmt.Errorf("state file %s has version %d; this build supports %d", path, state.Version, syncStateVersion)
+	}
+	if state.Accounts == nil {
+		state.Accounts = make(map[string]*syncAccount)
+	}
+	return state, nil
+}
+
+// account returns the state of the named account, creating it if needed.
+func (s *syncState) account(key string) *syncAccount {
+	account, ok := s.Accounts[key]
+	if !ok || account == nil {
+		account = &syncAccount{}
+		s.Accounts[key] = account
+	}
+	if account.Seen == nil {
+		account.Seen = make(map[string]*syncSeen)
+	}
+	return account
+}
+
+// save writes the state to path atomically: to a temporary file in the
+// same directory, synced, then renamed over the old file. An interrupted
+// sync leaves either the previous or the new state, never a partial file.
+func (s *syncState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pop3sync-state-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// syncAccountKey identifies an account in the state file.
+func syncAccountKey(config *Config) string {
+	return fmt.Sprintf("%s@%s:%d", strings.ToLower(config.Username), strings.ToLower(config.Host), config.Port)
+}
+
+// maildirDelivery counts deliveries for unique Maildir file names.
+var maildirDelivery atomic.Int64
+
+// prepareMaildir creates the tmp, new and cur directories of a Maildir.
+func prepareMaildir(dir string) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maildirName returns a unique Maildir file name in the usual
+// <time>.M<usec>P<pid>Q<n>.<host> form.
+func maildirName(now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", "_", ":", "_").Replace(host)
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), maildirDelivery.Add(1), host)
+}
+
+// deliverMaildir writes a message into dir/tmp with write and moves it into
+// dir/new once complete, as Maildir delivery requires. It returns the path
+// relative to dir.
+func deliverMaildir(dir string, now time.Time, write func(io.Writer) error) (string, error) {
+	name := maildirName(now)
+	tmp := filepath.Join(dir, "tmp", name)
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	rel := filepath.Join("new", name)
+	if err := os.Rename(tmp, filepath.Join(dir, rel)); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return rel, nil
+}
@@ -62,30 +62,21 @@ func testAuth(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 	// Get capabilities to determine auth methods
 	caps, _ := client.Capabilities(ctx)
 
+	if caps != nil && len(caps.GetAuthMechanisms()) > 0 {
+		fmt.Printf("SASL mechanisms: %s\n", strings.Join(caps.GetAuthMechanisms(), ", "))
+	}
+
 	// Determine auth method
-	authMethod := config.AuthMethod
-	if strings.EqualFold(authMethod, "auto") {
-		if config.AccessToken != "" {
-			if caps != nil && caps.SupportsXOAUTH2() {
-				authMethod = "XOAUTH2"
-			} else {
-				logger.LogWarn(slogLogger, "Access token provided but XOAUTH2 not supported by server")
-				authMethod = "USER"
-			}
-		} else {
-			authMethod = "USER"
-		}
+	authMethod := client.SelectAuthMethod(config.AccessToken != "")
+	if strings.EqualFold(config.AuthMethod, "auto") && config.AccessToken != "" &&
+		(caps == nil || caps.SelectBestAuthMechanism(true) == "") {
+		logger.LogWarn(slogLogger, "Access token provided but the server does not advertise XOAUTH2 or OAUTHBEARER; trying XOAUTH2")
 	}
 
 	fmt.Printf("Authenticating with method: %s\n", authMethod)
 
 	// Authenticate
-	var authErr error
-	if config.AccessToken != "" && strings.EqualFold(authMethod, "XOAUTH2") {
-		authErr = client.Auth(ctx, config.Username, "", config.AccessToken)
-	} else {
-		authErr = client.Auth(ctx, config.Username, config.Password, "")
-	}
+	authErr := client.Auth(ctx, config.Username, config.Password, config.AccessToken)
 
 	if authErr != nil {
 		logger.LogError(slogLogger, "Authentication failed",
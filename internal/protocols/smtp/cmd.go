@@ -2,6 +2,7 @@ package smtp
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -9,7 +10,7 @@ import (
 	"github.com/ziembor/gomailtesttool/internal/common/logger"
 )
 
-// NewCmd returns the "smtp" cobra.Command with all 4 action subcommands.
+// NewCmd returns the "smtp" cobra.Command with all 6 action subcommands.
 // Each subcommand shares persistent flags (server, auth, TLS, output) and adds
 // its own action-specific flags.
 func NewCmd() *cobra.Command {
@@ -34,13 +35,15 @@ Environment variables use the SMTP prefix (e.g. SMTPHOST, SMTPPORT, SMTPUSERNAME
 		newTestStartTLSCmd(v),
 		newTestAuthCmd(v),
 		newSendMailCmd(v),
+		newTestSizeCmd(v),
+		newTestFilterCmd(v),
 	)
 
 	return cmd
 }
 
 func newTestConnectCmd(v *viper.Viper) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "testconnect",
 		Short: "Test TCP connection and server capabilities",
 		Long: `Connect to the SMTP server and verify the connection banner, EHLO capabilities,
@@ -82,6 +85,10 @@ and (for SMTPS) TLS state. Detects Exchange Online servers.`,
 			return nil
 		},
 	}
+
+	cmd.Flags().String("xclient", "", "Postfix XCLIENT/XFORWARD attributes to send after EHLO, e.g. NAME=host,ADDR=192.0.2.10,HELO=host (env: SMTPXCLIENT)")
+
+	return cmd
 }
 
 func newTestStartTLSCmd(v *viper.Viper) *cobra.Command {
@@ -230,6 +237,122 @@ upgrades to TLS automatically, and logs the result (including TLS details) to CS
 	cmd.Flags().String("inline-attachments", "", "Comma-separated file paths to embed inline via cid:<filename> (env: SMTPINLINEATTACHMENTS)")
 	cmd.Flags().StringArray("header", nil, "Custom header in 'Name: Value' form (repeatable)")
 	cmd.Flags().String("priority", "normal", "Email priority: high, normal, low; high/low add X-Priority, Importance, and Priority headers (env: SMTPPRIORITY)")
+	cmd.Flags().String("xclient", "", "Postfix XCLIENT/XFORWARD attributes to send before authentication, e.g. NAME=host,ADDR=192.0.2.10,HELO=host (env: SMTPXCLIENT)")
+	cmd.Flags().Int("count", 1, "Number of messages to send over one connection, with RSET between them (env: SMTPCOUNT)")
+	cmd.Flags().Int("interval", 0, "Pause between messages in milliseconds when --count > 1 (env: SMTPINTERVAL)")
+
+	return cmd
+}
+
+func newTestSizeCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "testsize",
+		Short: "Probe the effective message size limit",
+		Long: `Send messages with synthetic attachments of increasing size and binary-search
+the largest one the server accepts, both with SIZE= declared on MAIL FROM and without.
+Reports the effective message and attachment limits (after base64 overhead) and compares
+them with the SIZE value advertised in EHLO. Accepted probes are delivered to --to.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionTestSize
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("smtptool", ActionTestSize, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "SMTP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := testSize(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	cmd.Flags().String("from", "", "Sender email address (env: SMTPFROM)")
+	cmd.Flags().String("to", "", "Comma-separated recipient email addresses; receives every accepted probe (env: SMTPTO)")
+	cmd.Flags().Int("min-kb", 1, "Smallest attachment payload to probe, in KB (env: SMTPMINKB)")
+	cmd.Flags().Int("max-kb", 0, "Largest attachment payload to probe, in KB (0 = 1.5x the advertised SIZE, or 50 MB) (env: SMTPMAXKB)")
+	cmd.Flags().Int("precision-kb", 64, "Stop once the accepted and rejected payload sizes are within this many KB (env: SMTPPRECISIONKB)")
+	cmd.Flags().String("payload", "random", "Synthetic attachment data: random (incompressible), compressible (env: SMTPPAYLOAD)")
+	cmd.Flags().String("size-mode", "both", "Passes to run: both, declared (SIZE= on MAIL FROM), undeclared (env: SMTPSIZEMODE)")
+
+	return cmd
+}
+
+func newTestFilterCmd(v *viper.Viper) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "testfilter",
+		Short: "Probe content-filter policy with risky attachment types",
+		Long: `Send one message per class of content commonly blocked by mail gateways (EICAR
+test file, double-extension executable, password-protected zip, macro-enabled Office
+document, .iso and .lnk files, executable with a mismatched MIME type, oversize inline
+image, URL-shortener links) and record which ones the server accepts at SMTP time.
+All payloads are generated and harmless. Each message carries an X-GoMailTest-Probe
+header naming its probe. Accepted probes are delivered to --to.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = v.BindPFlags(cmd.Flags())
+			_ = v.BindPFlags(cmd.InheritedFlags())
+
+			if err := bootstrap.LoadConfigFile(v, v.GetString("config")); err != nil {
+				return err
+			}
+
+			config := ConfigFromViper(v)
+			config.Action = ActionTestFilter
+
+			if err := validateConfiguration(config); err != nil {
+				return fmt.Errorf("validation failed: %w\n\nRun '%s --help' for usage", err, cmd.CommandPath())
+			}
+
+			ctx, cancel := bootstrap.SetupSignalContext()
+			defer cancel()
+
+			slogger, csvLogger, logErr := bootstrap.InitLoggers("smtptool", ActionTestFilter, config.VerboseMode, config.LogLevel, config.LogFormat)
+			if logErr != nil {
+				slogger.Warn("Could not initialize file logging", "error", logErr)
+			}
+			if csvLogger != nil {
+				defer csvLogger.Close()
+			}
+
+			logger.LogInfo(slogger, "SMTP Connectivity Testing Tool started", "action", config.Action, "host", config.Host, "port", config.Port)
+
+			if err := testFilter(ctx, config, csvLogger, slogger); err != nil {
+				logger.LogError(slogger, "Action failed", "error", err)
+				return err
+			}
+
+			logger.LogInfo(slogger, "Action completed successfully")
+			return nil
+		},
+	}
+
+	cmd.Flags().String("from", "", "Sender email address (env: SMTPFROM)")
+	cmd.Flags().String("to", "", "Comma-separated recipient email addresses; receives every accepted probe (env: SMTPTO)")
+	cmd.Flags().String("probes", "", "Comma-separated probes to send (default all): "+strings.Join(filterProbeNames(), ", ")+" (env: SMTPPROBES)")
+	cmd.Flags().Int("image-kb", 5120, "Size of the oversize-image probe's inline PNG, in KB (env: SMTPIMAGEKB)")
 
 	return cmd
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/ziembor/gomailtesttool/internal/common/email"
 	"github.com/ziembor/gomailtesttool/internal/common/network"
 	"github.com/ziembor/gomailtesttool/internal/common/validation"
+	"github.com/ziembor/gomailtesttool/internal/smtp/protocol"
 )
 
 // Config holds all smtptool configuration.
@@ -44,6 +45,24 @@ type Config struct {
 	Headers           []string // Custom headers in "Name: Value" form
 	Priority          string   // Email priority: high, normal, low (normal adds no extra headers)
 
+	// Multi-message sessions (for sendmail)
+	Count    int           // Number of messages to send over one connection
+	Interval time.Duration // Pause between messages
+
+	// Postfix XCLIENT/XFORWARD (for sendmail and testconnect)
+	XClient string // Client attributes in "NAME=...,ADDR=...,HELO=..." form
+
+	// Size limit probing (for testsize)
+	SizeMinKB       int    // Smallest attachment payload to probe, in KB
+	SizeMaxKB       int    // Largest attachment payload to probe, in KB (0 = derive from advertised SIZE)
+	SizePrecisionKB int    // Stop the binary search once accepted/rejected sizes are this close, in KB
+	SizePayload     string // Synthetic attachment data: random, compressible
+	SizeMode        string // Which passes to run: both, declared, undeclared
+
+	// Content-filter probing (for testfilter)
+	FilterProbes  []string // Probe names to send (empty = all)
+	FilterImageKB int      // Size of the oversize inline image probe, in KB
+
 	// TLS configuration
 	StartTLS   bool   // Force STARTTLS
 	SMTPS      bool   // Use SMTPS (implicit TLS on port 465)
@@ -57,6 +76,8 @@ type Config struct {
 	IPv4Only       bool   // Force resolving --host/--address to an IPv4 (A record) address
 	IPv6Only       bool   // Force resolving --host/--address to an IPv6 (AAAA record) address
 	UseMX          bool   // Treat --host as a domain and connect to its MX record instead
+	ProxyProtocol  string // Send a HAProxy PROXY header after connect: v1, v2 (empty = disabled)
+	ProxySource    string // Client address announced in the PROXY header (ip or ip:port; default: local address)
 	ProxyURL       string
 	MaxRetries     int
 	RetryDelay     time.Duration
@@ -75,6 +96,8 @@ const (
 	ActionTestStartTLS = "teststarttls"
 	ActionTestAuth     = "testauth"
 	ActionSendMail     = "sendmail"
+	ActionTestSize     = "testsize"
+	ActionTestFilter   = "testfilter"
 )
 
 // NewConfig creates a new Config with default values.
@@ -86,6 +109,7 @@ func NewConfig() *Config {
 		Subject:      "SMTP Test",
 		Body:         "This is a test message from smtptool",
 		Priority:     "normal",
+		Count:        1,
 		StartTLS:     false, // Auto-detect
 		SkipVerify:   false,
 		TLSVersion:   "1.2",
@@ -96,6 +120,15 @@ func NewConfig() *Config {
 		OutputFormat: "text",
 		LogFormat:    "csv",
 		RateLimit:    0, // Unlimited by default
+
+		// testsize defaults
+		SizeMinKB:       1,
+		SizePrecisionKB: 64,
+		SizePayload:     "random",
+		SizeMode:        "both",
+
+		// testfilter defaults
+		FilterImageKB: 5120,
 	}
 }
 
@@ -130,6 +163,8 @@ func RegisterPersistentFlags(cmd *cobra.Command) {
 	f.Bool("ipv4", false, "Force IPv4: resolve --host/--address to an A record and connect over IPv4 (env: SMTPIPV4)")
 	f.Bool("ipv6", false, "Force IPv6: resolve --host/--address to an AAAA record and connect over IPv6 (env: SMTPIPV6)")
 	f.Bool("use-mx", false, "Treat --host as a domain name and connect to its MX record instead (env: SMTPUSEMX)")
+	f.String("proxy-protocol", "", "Send a HAProxy PROXY protocol header (v1 or v2) right after TCP connect, for listeners behind HAProxy (env: SMTPPROXYPROTOCOL)")
+	f.String("proxy-source", "", "Client address to announce in the PROXY header, as ip or ip:port (default: local connection address) (env: SMTPPROXYSOURCE)")
 	f.String("proxy", "", "HTTP/HTTPS proxy URL (env: SMTPPROXY)")
 	f.Int("maxretries", 3, "Maximum retry attempts (env: SMTPMAXRETRIES)")
 	f.Int("retrydelay", 2000, "Retry delay in milliseconds (env: SMTPRETRYDELAY)")
@@ -163,6 +198,16 @@ func BindEnvs(v *viper.Viper) {
 		"bodyhtml":          "SMTPBODYHTML",
 		"attachments":       "SMTPATTACHMENTS",
 		"inlineattachments": "SMTPINLINEATTACHMENTS",
+		"xclient":           "SMTPXCLIENT",
+		"count":             "SMTPCOUNT",
+		"interval":          "SMTPINTERVAL",
+		"min-kb":            "SMTPMINKB",
+		"max-kb":            "SMTPMAXKB",
+		"precision-kb":      "SMTPPRECISIONKB",
+		"payload":           "SMTPPAYLOAD",
+		"size-mode":         "SMTPSIZEMODE",
+		"probes":            "SMTPPROBES",
+		"image-kb":          "SMTPIMAGEKB",
 		"starttls":          "SMTPSTARTTLS",
 		"smtps":             "SMTPSMTPS",
 		"no-starttls":       "SMTPNOSTARTTLS",
@@ -173,6 +218,8 @@ func BindEnvs(v *viper.Viper) {
 		"ipv4":              "SMTPIPV4",
 		"ipv6":              "SMTPIPV6",
 		"use-mx":            "SMTPUSEMX",
+		"proxy-protocol":    "SMTPPROXYPROTOCOL",
+		"proxy-source":      "SMTPPROXYSOURCE",
 		"proxy":             "SMTPPROXY",
 		"maxretries":        "SMTPMAXRETRIES",
 		"retrydelay":        "SMTPRETRYDELAY",
@@ -257,6 +304,36 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		priority = defaults.Priority
 	}
 
+	count := v.GetInt("count")
+	if count == 0 {
+		count = defaults.Count
+	}
+
+	sizeMinKB := v.GetInt("min-kb")
+	if sizeMinKB <= 0 {
+		sizeMinKB = defaults.SizeMinKB
+	}
+
+	sizePrecisionKB := v.GetInt("precision-kb")
+	if sizePrecisionKB <= 0 {
+		sizePrecisionKB = defaults.SizePrecisionKB
+	}
+
+	sizePayload := strings.ToLower(v.GetString("payload"))
+	if sizePayload == "" {
+		sizePayload = defaults.SizePayload
+	}
+
+	sizeMode := strings.ToLower(v.GetString("size-mode"))
+	if sizeMode == "" {
+		sizeMode = defaults.SizeMode
+	}
+
+	filterImageKB := v.GetInt("image-kb")
+	if filterImageKB <= 0 {
+		filterImageKB = defaults.FilterImageKB
+	}
+
 	return &Config{
 		Host:              v.GetString("host"),
 		Port:              port,
@@ -278,6 +355,16 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		InlineAttachments: inlineAttachments,
 		Headers:           v.GetStringSlice("header"),
 		Priority:          priority,
+		XClient:           v.GetString("xclient"),
+		Count:             count,
+		Interval:          time.Duration(v.GetInt("interval")) * time.Millisecond,
+		SizeMinKB:         sizeMinKB,
+		SizeMaxKB:         v.GetInt("max-kb"),
+		SizePrecisionKB:   sizePrecisionKB,
+		SizePayload:       sizePayload,
+		SizeMode:          sizeMode,
+		FilterProbes:      splitCommaSeparated(strings.ToLower(v.GetString("probes"))),
+		FilterImageKB:     filterImageKB,
 		StartTLS:          v.GetBool("starttls"),
 		SMTPS:             v.GetBool("smtps"),
 		NoStartTLS:        v.GetBool("no-starttls"),
@@ -288,6 +375,8 @@ func ConfigFromViper(v *viper.Viper) *Config {
 		IPv4Only:          v.GetBool("ipv4"),
 		IPv6Only:          v.GetBool("ipv6"),
 		UseMX:             v.GetBool("use-mx"),
+		ProxyProtocol:     strings.ToLower(v.GetString("proxy-protocol")),
+		ProxySource:       v.GetString("proxy-source"),
 		ProxyURL:          v.GetString("proxy"),
 		MaxRetries:        maxRetries,
 		RetryDelay:        time.Duration(retryDelayMs) * time.Millisecond,
@@ -326,7 +415,7 @@ func parseBoolEnv(value string) bool {
 // validateConfiguration validates the configuration.
 func validateConfiguration(config *Config) error {
 	// Validate action
-	validActions := []string{ActionTestConnect, ActionTestStartTLS, ActionTestAuth, ActionSendMail}
+	validActions := []string{ActionTestConnect, ActionTestStartTLS, ActionTestAuth, ActionSendMail, ActionTestSize, ActionTestFilter}
 	valid := false
 	for _, a := range validActions {
 		if config.Action == a {
@@ -407,6 +496,18 @@ func validateConfiguration(config *Config) error {
 		return fmt.Errorf("cannot use both -use-mx and -address simultaneously")
 	}
 
+	// Validate PROXY protocol settings (if provided)
+	if err := network.ValidateProxyProtocol(config.ProxyProtocol, config.ProxySource); err != nil {
+		return err
+	}
+
+	// Validate XCLIENT/XFORWARD attributes (if provided)
+	if config.XClient != "" {
+		if _, err := protocol.ParseXClientAttrs(config.XClient); err != nil {
+			return fmt.Errorf("invalid -xclient: %w", err)
+		}
+	}
+
 	// Action-specific validation
 	switch config.Action {
 	case ActionTestStartTLS:
@@ -453,6 +554,13 @@ func validateConfiguration(config *Config) error {
 			}
 		}
 
+		if config.Count < 1 {
+			return fmt.Errorf("invalid -count: %d (must be at least 1)", config.Count)
+		}
+		if config.Interval < 0 {
+			return fmt.Errorf("invalid -interval: %s (must not be negative)", config.Interval)
+		}
+
 		// Validate mutual exclusion: -use-mx and -host cannot be used together
 		// for sendmail; the MX lookup domain is derived from -to instead.
 		if config.UseMX {
@@ -499,6 +607,59 @@ func validateConfiguration(config *Config) error {
 		if _, err := email.ParseHeaders(config.Headers); err != nil {
 			return fmt.Errorf("invalid -header: %w", err)
 		}
+
+	case ActionTestSize:
+		if config.From == "" {
+			return fmt.Errorf("testsize requires -from")
+		}
+		if err := validation.ValidateEmail(config.From); err != nil {
+			return fmt.Errorf("invalid sender email: %w", err)
+		}
+		if len(config.To) == 0 {
+			return fmt.Errorf("testsize requires -to")
+		}
+		for _, addr := range config.To {
+			if err := validation.ValidateEmail(strings.TrimSpace(addr)); err != nil {
+				return fmt.Errorf("invalid recipient email: %w", err)
+			}
+		}
+		if config.SizeMaxKB < 0 {
+			return fmt.Errorf("invalid -max-kb: %d", config.SizeMaxKB)
+		}
+		if config.SizeMaxKB > 0 && config.SizeMaxKB <= config.SizeMinKB {
+			return fmt.Errorf("-max-kb (%d) must be greater than -min-kb (%d)", config.SizeMaxKB, config.SizeMinKB)
+		}
+		switch config.SizePayload {
+		case "random", "compressible":
+		default:
+			return fmt.Errorf("invalid -payload: %s (must be one of: random, compressible)", config.SizePayload)
+		}
+		switch config.SizeMode {
+		case "both", "declared", "undeclared":
+		default:
+			return fmt.Errorf("invalid -size-mode: %s (must be one of: both, declared, undeclared)", config.SizeMode)
+		}
+
+	case ActionTestFilter:
+		if config.From == "" {
+			return fmt.Errorf("testfilter requires -from")
+		}
+		if err := validation.ValidateEmail(config.From); err != nil {
+			return fmt.Errorf("invalid sender email: %w", err)
+		}
+		if len(config.To) == 0 {
+			return fmt.Errorf("testfilter requires -to")
+		}
+		for _, addr := range config.To {
+			if err := validation.ValidateEmail(strings.TrimSpace(addr)); err != nil {
+				return fmt.Errorf("invalid recipient email: %w", err)
+			}
+		}
+		for _, name := range config.FilterProbes {
+			if findFilterProbe(name) == nil {
+				return fmt.Errorf("invalid -probes entry: %s (must be one of: %s)", name, strings.Join(filterProbeNames(), ", "))
+			}
+		}
 	}
 
 	return nil
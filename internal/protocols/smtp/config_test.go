@@ -6,6 +6,9 @@ package smtp
 import (
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
 )
 
 // TestValidateConfiguration_SMTPSAndSTARTTLS tests mutual exclusion of SMTPS and STARTTLS flags
@@ -146,6 +149,120 @@ func TestValidateConfiguration_UseMXAndAddress(t *testing.T) {
 	}
 }
 
+// TestValidateConfiguration_ProxyProtocol tests validation of the
+// --proxy-protocol and --proxy-source flags.
+func TestValidateConfiguration_ProxyProtocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   string
+		source    string
+		wantError bool
+	}{
+		{name: "Disabled"},
+		{name: "v1", version: "v1"},
+		{name: "v2 with source", version: "v2", source: "198.51.100.7:40000"},
+		{name: "Unknown version", version: "v3", wantError: true},
+		{name: "Source without version", source: "198.51.100.7", wantError: true},
+		{name: "Hostname source", version: "v1", source: "client.example.com", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestConnect
+			config.Host = "example.com"
+			config.ProxyProtocol = tt.version
+			config.ProxySource = tt.source
+
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateConfiguration() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_XClient tests validation of the --xclient
+// attribute list.
+func TestValidateConfiguration_XClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		xclient   string
+		wantError bool
+	}{
+		{name: "Not set"},
+		{name: "Valid attributes", xclient: "NAME=mail.example.com,ADDR=192.0.2.10,HELO=mail.example.com"},
+		{name: "Missing value separator", xclient: "ADDR", wantError: true},
+		{name: "Only separators", xclient: ",,", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestConnect
+			config.Host = "example.com"
+			config.XClient = tt.xclient
+
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateConfiguration() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestValidateConfiguration_SendMailCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		interval  time.Duration
+		wantError bool
+	}{
+		{name: "Single message", count: 1},
+		{name: "Multiple messages with interval", count: 10, interval: 500 * time.Millisecond},
+		{name: "Zero count", count: 0, wantError: true},
+		{name: "Negative interval", count: 2, interval: -time.Second, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionSendMail
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			config.Count = tt.count
+			config.Interval = tt.interval
+
+			err := validateConfiguration(config)
+			if (err != nil) != tt.wantError {
+				t.Errorf("validateConfiguration() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestSendMailCmd_CountFlags tests that --count and --interval are sendmail
+// flags and reach the configuration.
+func TestSendMailCmd_CountFlags(t *testing.T) {
+	v := viper.New()
+	cmd := newSendMailCmd(v)
+	if err := cmd.ParseFlags([]string{"--count", "2", "--interval", "10"}); err != nil {
+		t.Fatalf("ParseFlags() error = %v", err)
+	}
+	if err := v.BindPFlags(cmd.Flags()); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ConfigFromViper(v)
+	if config.Count != 2 {
+		t.Errorf("Count = %d, want 2", config.Count)
+	}
+	if config.Interval != 10*time.Millisecond {
+		t.Errorf("Interval = %s, want 10ms", config.Interval)
+	}
+}
+
 // TestValidateConfiguration_SendMailUseMXExclusions tests that --use-mx for
 // sendmail is mutually exclusive with --host, and that the MX lookup domain
 // is derived from the first --to recipient.
@@ -477,6 +594,82 @@ func TestValidateConfiguration_XOAUTH2(t *testing.T) {
 	}
 }
 
+// TestValidateConfiguration_TestSize tests testsize-specific validation
+func TestValidateConfiguration_TestSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Config)
+		wantError string
+	}{
+		{name: "Defaults", modify: func(c *Config) {}},
+		{name: "Explicit range", modify: func(c *Config) { c.SizeMinKB = 512; c.SizeMaxKB = 40960 }},
+		{name: "Missing from", modify: func(c *Config) { c.From = "" }, wantError: "-from"},
+		{name: "Missing to", modify: func(c *Config) { c.To = nil }, wantError: "-to"},
+		{name: "Max below min", modify: func(c *Config) { c.SizeMinKB = 1024; c.SizeMaxKB = 512 }, wantError: "-max-kb"},
+		{name: "Invalid payload", modify: func(c *Config) { c.SizePayload = "zeros" }, wantError: "-payload"},
+		{name: "Invalid mode", modify: func(c *Config) { c.SizeMode = "sometimes" }, wantError: "-size-mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestSize
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
+// TestValidateConfiguration_TestFilter tests testfilter-specific validation
+func TestValidateConfiguration_TestFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Config)
+		wantError string
+	}{
+		{name: "All probes", modify: func(c *Config) {}},
+		{name: "Selected probes", modify: func(c *Config) { c.FilterProbes = []string{"eicar", "encrypted-zip"} }},
+		{name: "Missing from", modify: func(c *Config) { c.From = "" }, wantError: "-from"},
+		{name: "Missing to", modify: func(c *Config) { c.To = nil }, wantError: "-to"},
+		{name: "Unknown probe", modify: func(c *Config) { c.FilterProbes = []string{"eicar", "ransomware"} }, wantError: "-probes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Action = ActionTestFilter
+			config.Host = "smtp.example.com"
+			config.From = "sender@example.com"
+			config.To = []string{"recipient@example.com"}
+			tt.modify(config)
+
+			err := validateConfiguration(config)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("validateConfiguration() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("validateConfiguration() error = %v, want error containing %q", err, tt.wantError)
+			}
+		})
+	}
+}
+
 // TestNewConfig tests default configuration values
 func TestNewConfig(t *testing.T) {
 	config := NewConfig()
@@ -509,6 +702,8 @@ func TestValidateConfiguration_Actions(t *testing.T) {
 		ActionTestStartTLS,
 		ActionTestAuth,
 		ActionSendMail,
+		ActionTestSize,
+		ActionTestFilter,
 	}
 
 	for _, action := range validActions {
@@ -528,6 +723,10 @@ func TestValidateConfiguration_Actions(t *testing.T) {
 				config.From = "sender@example.com"
 				config.To = []string{"recipient@example.com"}
 			}
+			if action == ActionTestSize || action == ActionTestFilter {
+				config.From = "sender@example.com"
+				config.To = []string{"recipient@example.com"}
+			}
 
 			err := validateConfiguration(config)
 			if err != nil {
@@ -41,7 +41,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		"TLS_Version", "Cipher_Suite", "Cipher_Strength",
 		"Cert_Subject", "Cert_Issuer", "Cert_SANs",
 		"Cert_Valid_From", "Cert_Valid_To", "Cert_Verification_Status",
-		"Error",
+		"Message_Number", "Duration_Ms", "Error",
 	}); err != nil {
 		logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
 	}
@@ -78,7 +78,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
 			config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
 			"", "", "", "", "", "", "", "", "", // No TLS info on connection failure
-			err.Error(),
+			"", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -109,7 +109,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
 			config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
 			"", "", "", "", "", "", "", "", "", // No TLS info on EHLO failure
-			err.Error(),
+			"", "", err.Error(),
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
@@ -143,7 +143,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
 				config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
 				"", "", "", "", "", "", "", "", "", // No TLS info on STARTTLS failure
-				fmt.Sprintf("STARTTLS failed: %v", err),
+				"", "", fmt.Sprintf("STARTTLS failed: %v", err),
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -164,6 +164,28 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		}
 	}
 
+	// Simulate a different client via Postfix XCLIENT/XFORWARD. Done after
+	// STARTTLS so the restarted session stays encrypted, and before AUTH so
+	// authentication is evaluated against the simulated client.
+	if config.XClient != "" {
+		caps, err = applyXClient(client, config, caps, slogLogger)
+		if err != nil {
+			logger.LogError(slogLogger, "XCLIENT failed", "error", err)
+			tlsData := formatTLSInfoForCSV(tlsState, client.GetHost())
+			if logErr := writeSMTPCSVRow(csvLogger, []string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
+				tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
+				tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
+				tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
+				"", "", fmt.Sprintf("XCLIENT failed: %v", err),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return fmt.Errorf("XCLIENT failed: %w", err)
+		}
+	}
+
 	// Authenticate if credentials provided (password or access token)
 	if config.Username != "" && (config.Password != "" || config.AccessToken != "") {
 		fmt.Println("Authenticating...")
@@ -179,7 +201,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 				tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
 				tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
 				tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
-				msg,
+				"", "", msg,
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -207,7 +229,7 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 				tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
 				tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
 				tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
-				fmt.Sprintf("Auth failed: %v", err),
+				"", "", fmt.Sprintf("Auth failed: %v", err),
 			}); logErr != nil {
 				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 			}
@@ -217,70 +239,139 @@ func SendMail(ctx context.Context, config *Config, csvLogger logger.Logger, slog
 		fmt.Println("✓ Authentication successful")
 	}
 
-	// Build email message
-	messageData, err := buildMIMEMessage(config, slogLogger)
-	if err != nil {
-		logger.LogError(slogLogger, "Failed to build email message", "error", err)
-		if logErr := writeSMTPCSVRow(csvLogger, []string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
-			"", "", "", "", "", "", "", "", "",
-			fmt.Sprintf("Failed to build message: %v", err),
-		}); logErr != nil {
-			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
-		}
-		return fmt.Errorf("failed to build email message: %w", err)
-	}
-	messageID := generateMessageID(config.Host)
-
 	// Send email. The SMTP envelope (RCPT TO) includes To, Cc, and Bcc
 	// recipients; only To and Cc appear in the message headers.
 	envelopeRecipients := collectEnvelopeRecipients(config)
+	tlsData := formatTLSInfoForCSV(tlsState, client.GetHost())
+
+	var durations []time.Duration
+	for n := 1; n <= config.Count; n++ {
+		if n > 1 {
+			if config.Interval > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(config.Interval):
+				}
+			}
 
-	fmt.Println("\nSending message...")
-	logger.LogDebug(slogLogger, "Sending email", "from", config.From, "to", config.To, "cc", config.Cc, "bcc", config.Bcc)
+			// Reuse the session: RSET clears any transaction state
+			// before the next MAIL FROM.
+			if err := client.Reset(); err != nil {
+				logger.LogError(slogLogger, "RSET failed", "message", n, "error", err)
+				if logErr := writeSMTPCSVRow(csvLogger, []string{
+					config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+					config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
+					tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
+					tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
+					tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
+					fmt.Sprintf("%d", n), "", fmt.Sprintf("RSET failed: %v", err),
+				}); logErr != nil {
+					logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+				}
+				return fmt.Errorf("RSET before message %d of %d failed: %w", n, config.Count, err)
+			}
+		}
 
-	err = client.SendMail(config.From, envelopeRecipients, messageData)
-	if err != nil {
-		logger.LogError(slogLogger, "Failed to send email", "error", err)
-		tlsData := formatTLSInfoForCSV(tlsState, client.GetHost())
+		// Build email message; numbered subjects tell the messages of a
+		// multi-message session apart in the recipient mailbox.
+		msgConfig := config
+		if config.Count > 1 {
+			numbered := *config
+			numbered.Subject = fmt.Sprintf("%s [%d/%d]", config.Subject, n, config.Count)
+			msgConfig = &numbered
+		}
+		messageData, err := buildMIMEMessage(msgConfig, slogLogger)
+		if err != nil {
+			logger.LogError(slogLogger, "Failed to build email message", "error", err)
+			if logErr := writeSMTPCSVRow(csvLogger, []string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), msgConfig.Subject, bodyType, attachmentCountStr, "", "",
+				"", "", "", "", "", "", "", "", "",
+				fmt.Sprintf("%d", n), "", fmt.Sprintf("Failed to build message: %v", err),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return fmt.Errorf("failed to build email message: %w", err)
+		}
+		messageID := extractMessageID(messageData)
+
+		if config.Count > 1 {
+			fmt.Printf("\nSending message %d of %d...\n", n, config.Count)
+		} else {
+			fmt.Println("\nSending message...")
+		}
+		logger.LogDebug(slogLogger, "Sending email", "from", config.From, "to", config.To, "cc", config.Cc, "bcc", config.Bcc, "message", n)
+
+		start := time.Now()
+		err = client.SendMail(config.From, envelopeRecipients, messageData)
+		duration := time.Since(start)
+		if err != nil {
+			logger.LogError(slogLogger, "Failed to send email", "error", err, "message", n, "messageID", messageID)
+			if logErr := writeSMTPCSVRow(csvLogger, []string{
+				config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
+				config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), msgConfig.Subject, bodyType, attachmentCountStr, "", messageID,
+				tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
+				tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
+				tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
+				fmt.Sprintf("%d", n), fmt.Sprintf("%d", duration.Milliseconds()), err.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			if n > 1 {
+				fmt.Printf("✗ Message %d of %d failed after %d accepted on this connection\n", n, config.Count, n-1)
+				return fmt.Errorf("failed to send message %d of %d: %w", n, config.Count, err)
+			}
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		durations = append(durations, duration)
+
+		fmt.Printf("✓ Message sent successfully (%d ms)\n", duration.Milliseconds())
+		fmt.Printf("  Message-ID: <%s>\n", messageID)
+		logger.LogInfo(slogLogger, "Message sent", "message", n, "count", config.Count, "messageID", messageID, "duration_ms", duration.Milliseconds())
+
+		// Log to CSV
 		if logErr := writeSMTPCSVRow(csvLogger, []string{
-			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port),
-			config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject, bodyType, attachmentCountStr, "", "",
+			config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
+			config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), msgConfig.Subject,
+			bodyType, attachmentCountStr,
+			"250", messageID,
 			tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
 			tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
 			tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
-			err.Error(),
+			fmt.Sprintf("%d", n), fmt.Sprintf("%d", duration.Milliseconds()), "",
 		}); logErr != nil {
 			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
 		}
-		return fmt.Errorf("failed to send email: %w", err)
 	}
 
-	fmt.Println("✓ Message sent successfully")
-	fmt.Printf("  Message-ID: <%s>\n", messageID)
-
-	// Log to CSV
-	tlsData := formatTLSInfoForCSV(tlsState, client.GetHost())
-	if logErr := writeSMTPCSVRow(csvLogger, []string{
-		config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port),
-		config.ConnectAddress, config.From, strings.Join(config.To, ", "), strings.Join(config.Cc, ", "), strings.Join(config.Bcc, ", "), config.Subject,
-		bodyType, attachmentCountStr,
-		"250", messageID,
-		tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
-		tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
-		tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
-		"",
-	}); logErr != nil {
-		logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+	if config.Count > 1 {
+		printSessionTimings(durations)
 	}
 
 	fmt.Println("\n✓ Email sending test completed successfully")
-	logger.LogInfo(slogLogger, "sendmail completed successfully", "messageID", messageID)
+	logger.LogInfo(slogLogger, "sendmail completed successfully", "messages", len(durations))
 
 	return nil
 }
 
+// printSessionTimings summarizes per-message send times of a multi-message
+// session.
+func printSessionTimings(durations []time.Duration) {
+	if len(durations) == 0 {
+		return
+	}
+	minD, maxD, total := durations[0], durations[0], time.Duration(0)
+	for _, d := range durations {
+		minD = min(minD, d)
+		maxD = max(maxD, d)
+		total += d
+	}
+	fmt.Printf("\n✓ %d messages sent over one connection\n", len(durations))
+	fmt.Printf("  Send time: min %d ms, avg %d ms, max %d ms\n",
+		minD.Milliseconds(), (total / time.Duration(len(durations))).Milliseconds(), maxD.Milliseconds())
+}
+
 func writeSMTPCSVHeader(csvLogger logger.Logger, columns []string) error {
 	if csvLogger == nil {
 		return nil
@@ -366,6 +457,13 @@ func buildMIMEMessage(config *Config, slogLogger *slog.Logger) ([]byte, error) {
 		return nil, fmt.Errorf("inline attachments: %w", err)
 	}
 
+	return assembleMIMEMessage(config, customHeaders, inlineAttachments, attachments)
+}
+
+// assembleMIMEMessage builds a MIME message from the config's addresses,
+// subject and bodies plus already-loaded attachments. Actions that send
+// generated payloads (rather than files from disk) call it directly.
+func assembleMIMEMessage(config *Config, customHeaders []email.Header, inlineAttachments, attachments []email.Attachment) ([]byte, error) {
 	// Build the body content (text/HTML, with inline attachments and file attachments
 	// nested as needed) and determine the top-level Content-Type.
 	contentType, body, err := buildMIMEBody(config.Body, config.BodyHTML, inlineAttachments, attachments)
@@ -621,3 +719,20 @@ func generateMessageID(host string) string {
 	}
 	return fmt.Sprintf("%d.smtptool@%s", timestamp, host)
 }
+
+// extractMessageID returns the Message-ID (without angle brackets) from the
+// header section of a built message, so logs report the ID the recipient
+// actually sees.
+func extractMessageID(message []byte) string {
+	headerEnd := bytes.Index(message, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		headerEnd = len(message)
+	}
+	for _, line := range strings.Split(string(message[:headerEnd]), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(name, "Message-ID") {
+			return strings.Trim(strings.TrimSpace(value), "<>")
+		}
+	}
+	return ""
+}
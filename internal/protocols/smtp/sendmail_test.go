@@ -4,8 +4,11 @@
 package smtp
 
 import (
+	"bufio"
 	"context"
 	"log/slog"
+	"net"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -287,6 +290,214 @@ func TestSendMail_AllowsNilCSVLogger(t *testing.T) {
 	}
 }
 
+func TestExtractMessageID(t *testing.T) {
+	cfg := newTestConfig()
+	message, err := buildMIMEMessage(cfg, slog.New(slog.NewTextHandler(&strings.Builder{}, nil)))
+	if err != nil {
+		t.Fatalf("buildMIMEMessage() error = %v", err)
+	}
+
+	msgID := extractMessageID(message)
+	if msgID == "" || strings.ContainsAny(msgID, "<>") {
+		t.Fatalf("extractMessageID() = %q, want bare message ID", msgID)
+	}
+	if !strings.Contains(string(message), "Message-ID: <"+msgID+">\r\n") {
+		t.Errorf("extractMessageID() = %q, does not match the message header", msgID)
+	}
+
+	// A Message-ID-like line in the body must not be picked up
+	if got := extractMessageID([]byte("Subject: x\r\n\r\nMessage-ID: <body@example.com>\r\n")); got != "" {
+		t.Errorf("extractMessageID() = %q, want empty for header without Message-ID", got)
+	}
+}
+
+// fakeSMTPServer accepts one connection and answers every command with a
+// success reply, recording the command lines it received. EHLO advertises
+// 8BITMIME and the given extensions.
+func fakeSMTPServer(t *testing.T, extensions ...string) (addr string, commands <-chan []string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan []string, 1)
+	go func() {
+		var lines []string
+		defer func() { ch <- lines }()
+
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake.example.com ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines = append(lines, strings.TrimRight(line, "\r\n"))
+			switch commandVerb(line) {
+			case "EHLO":
+				reply("250-fake.example.com")
+				for _, ext := range extensions {
+					reply("250-" + ext)
+				}
+				reply("250 8BITMIME")
+			case "XCLIENT":
+				reply("220 fake.example.com ESMTP")
+			case "AUTH":
+				reply("235 2.7.0 Authentication successful")
+			case "DATA":
+				reply("354 Go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+				}
+				reply("250 2.0.0 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), ch
+}
+
+// commandVerb returns the upper-cased verb of an SMTP command line.
+func commandVerb(line string) string {
+	return strings.ToUpper(strings.Fields(line + " x")[0])
+}
+
+func TestSendMail_CountReusesConnection(t *testing.T) {
+	addr, commands := fakeSMTPServer(t)
+	host, portStr, _ := net.SplitHostPort(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cfg := NewConfig()
+	cfg.Action = ActionSendMail
+	cfg.Host = host
+	cfg.Port, _ = strconv.Atoi(portStr)
+	cfg.From = "sender@example.com"
+	cfg.To = []string{"recipient@example.com"}
+	cfg.Count = 3
+	cfg.Interval = 10 * time.Millisecond
+
+	if err := SendMail(ctx, cfg, nil, slog.New(slog.NewTextHandler(&strings.Builder{}, nil))); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	// EHLO may be sent more than once (the stdlib client greets again on
+	// first use); only the transaction sequence matters here.
+	var verbs []string
+	for _, line := range <-commands {
+		if verb := commandVerb(line); verb != "EHLO" {
+			verbs = append(verbs, verb)
+		}
+	}
+	got := strings.Join(verbs, " ")
+	want := "MAIL RCPT DATA RSET MAIL RCPT DATA RSET MAIL RCPT DATA QUIT"
+	if got != want {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+}
+
+func TestSendMail_XForwardBeforeEveryMail(t *testing.T) {
+	addr, commands := fakeSMTPServer(t, "XFORWARD NAME ADDR HELO")
+	host, portStr, _ := net.SplitHostPort(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cfg := NewConfig()
+	cfg.Action = ActionSendMail
+	cfg.Host = host
+	cfg.Port, _ = strconv.Atoi(portStr)
+	cfg.From = "sender@example.com"
+	cfg.To = []string{"recipient@example.com"}
+	cfg.XClient = "NAME=mail.example.com,ADDR=192.0.2.10"
+	cfg.Count = 3
+	cfg.Interval = 10 * time.Millisecond
+
+	if err := SendMail(ctx, cfg, nil, slog.New(slog.NewTextHandler(&strings.Builder{}, nil))); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	// Postfix drops XFORWARD attributes after each transaction and on RSET
+	// or EHLO, so each MAIL must directly follow an XFORWARD
+	var verbs []string
+	for _, line := range <-commands {
+		verb := commandVerb(line)
+		if verb == "XFORWARD" && line != "XFORWARD NAME=mail.example.com ADDR=192.0.2.10" {
+			t.Errorf("server received %q, want the --xclient attributes", line)
+		}
+		verbs = append(verbs, verb)
+	}
+	got := strings.Join(verbs, " ")
+	want := "EHLO XFORWARD MAIL RCPT DATA RSET XFORWARD MAIL RCPT DATA RSET XFORWARD MAIL RCPT DATA QUIT"
+	if got != want {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+}
+
+func TestSendMail_XClientKeepsHelo(t *testing.T) {
+	addr, commands := fakeSMTPServer(t, "XCLIENT NAME ADDR HELO", "AUTH PLAIN")
+	host, portStr, _ := net.SplitHostPort(addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cfg := NewConfig()
+	cfg.Action = ActionSendMail
+	cfg.Host = host
+	cfg.Port, _ = strconv.Atoi(portStr)
+	cfg.From = "sender@example.com"
+	cfg.To = []string{"recipient@example.com"}
+	cfg.XClient = "ADDR=192.0.2.10,HELO=mail.example.com"
+	cfg.Username = "user"
+	cfg.Password = "secret"
+	cfg.AuthMethod = "PLAIN"
+
+	if err := SendMail(ctx, cfg, nil, slog.New(slog.NewTextHandler(&strings.Builder{}, nil))); err != nil {
+		t.Fatalf("SendMail() error = %v", err)
+	}
+
+	// The EHLO after XCLIENT carries the simulated HELO and is the last
+	var verbs []string
+	var ehlo string
+	for _, line := range <-commands {
+		verb := commandVerb(line)
+		if verb == "EHLO" {
+			ehlo = line
+		}
+		verbs = append(verbs, verb)
+	}
+	got := strings.Join(verbs, " ")
+	want := "EHLO XCLIENT EHLO AUTH MAIL RCPT DATA QUIT"
+	if got != want {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+	if ehlo != "EHLO mail.example.com" {
+		t.Errorf("last EHLO = %q, want the XCLIENT HELO name", ehlo)
+	}
+}
+
 // TestBuildEmailMessage_RFCCompliance tests RFC 5322 compliance
 func TestBuildEmailMessage_RFCCompliance(t *testing.T) {
 	message := buildEmailMessage(
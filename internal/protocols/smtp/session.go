@@ -0,0 +1,59 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/ziembor/gomailtesttool/internal/smtp/protocol"
+	smtptls "github.com/ziembor/gomailtesttool/internal/smtp/tls"
+)
+
+// openSession connects and prepares a session for mail transactions the same
+// way sendmail does: EHLO, STARTTLS when advertised on the usual submission
+// ports (unless -no-starttls), and AUTH when credentials are configured.
+// It is used by actions that run many transactions, where per-step CSV
+// reporting is done by the caller. The returned client must be closed.
+func openSession(ctx context.Context, config *Config) (*SMTPClient, protocol.Capabilities, error) {
+	client := NewSMTPClient(config.Host, config.Port, config)
+	if err := client.Connect(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	caps, err := client.EHLO("smtptool.local")
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+
+	if !config.SMTPS && !config.NoStartTLS && caps.SupportsSTARTTLS() &&
+		(config.StartTLS || config.Port == 25 || config.Port == 587 || config.Port == 2525 || config.Port == 2526 || config.Port == 1025) {
+		tlsVersion := smtptls.ParseTLSVersion(config.TLSVersion)
+		tlsConfig := &tls.Config{
+			ServerName:         client.GetHost(),
+			InsecureSkipVerify: config.SkipVerify,
+			MinVersion:         tlsVersion,
+			MaxVersion:         tlsVersion, // Force exact TLS version
+		}
+		if _, err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+		if caps, err = client.EHLO("smtptool.local"); err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("EHLO on encrypted connection failed: %w", err)
+		}
+	} else if config.StartTLS && !config.SMTPS {
+		client.Close()
+		return nil, nil, fmt.Errorf("server does not advertise STARTTLS")
+	}
+
+	if config.Username != "" && (config.Password != "" || config.AccessToken != "") {
+		if err := client.Auth(config.Username, config.Password, config.AccessToken, []string{config.AuthMethod}); err != nil {
+			client.Close()
+			return nil, nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	return client, caps, nil
+}
@@ -9,15 +9,12 @@ import (
 	"net/smtp"
 	"net/textproto"
 	"strings"
+	"time"
 
-	"github.com/Azure/go-ntlmssp"
-	krb5client "github.com/jcmturner/gokrb5/v8/client"
-	krb5config "github.com/jcmturner/gokrb5/v8/config"
-	"github.com/jcmturner/gokrb5/v8/gssapi"
-	"github.com/jcmturner/gokrb5/v8/spnego"
-	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/emersion/go-sasl"
 	"github.com/ziembor/gomailtesttool/internal/common/network"
 	"github.com/ziembor/gomailtesttool/internal/common/ratelimit"
+	commonsasl "github.com/ziembor/gomailtesttool/internal/common/sasl"
 	"github.com/ziembor/gomailtesttool/internal/smtp/protocol"
 	smtptls "github.com/ziembor/gomailtesttool/internal/smtp/tls"
 )
@@ -32,9 +29,12 @@ type SMTPClient struct {
 	banner       string
 	capabilities protocol.Capabilities
 	limiter      *ratelimit.Limiter
-	smtpClient   *smtp.Client         // Reusable stdlib client after STARTTLS or SMTPS
-	tlsState     *tls.ConnectionState // Stored TLS state for SMTPS connections
-	ctx          context.Context      // Context for cancellation propagation
+	smtpClient   *smtp.Client           // Reusable stdlib client for AUTH and mail transactions
+	ehlo         *protocol.SMTPResponse // Last EHLO response, adopted by the stdlib client
+	ehloName     string                 // Hostname sent with the last EHLO
+	xforward     []protocol.XClientAttr // XFORWARD attributes sent before every MAIL FROM
+	tlsState     *tls.ConnectionState   // Stored TLS state for SMTPS connections
+	ctx          context.Context        // Context for cancellation propagation
 }
 
 // debugLogCommand logs an SMTP command being sent to the server.
@@ -125,6 +125,17 @@ func (c *SMTPClient) Connect(ctx context.Context) error {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
+	// PROXY protocol: announce the client address to a HAProxy-fronted
+	// listener before anything else (including the SMTPS handshake)
+	if c.config.ProxyProtocol != "" {
+		header, err := network.WriteProxyHeader(conn, c.config.ProxyProtocol, c.config.ProxySource)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		c.debugLogMessage(fmt.Sprintf("Sent PROXY protocol %s header (%d bytes)", c.config.ProxyProtocol, len(header)))
+	}
+
 	// SMTPS mode: perform immediate TLS handshake before any SMTP protocol
 	if c.config.SMTPS {
 		c.debugLogMessage("SMTPS mode: Performing immediate TLS handshake...")
@@ -152,10 +163,6 @@ func (c *SMTPClient) Connect(ctx context.Context) error {
 		conn = tlsConn
 		state := tlsConn.ConnectionState()
 		c.tlsState = &state
-
-		// Create smtp.Client for later Auth/SendMail operations
-		wrapper := &connWrapper{reader: bufio.NewReader(conn), conn: conn}
-		c.smtpClient = &smtp.Client{Text: textproto.NewConn(wrapper)}
 	}
 
 	c.conn = conn
@@ -217,6 +224,11 @@ func (c *SMTPClient) EHLO(hostname string) (protocol.Capabilities, error) {
 	// Parse capabilities
 	c.capabilities = protocol.ParseCapabilities(resp.Lines)
 
+	// A new greeting starts a new session for the stdlib client too
+	c.ehlo = resp
+	c.ehloName = hostname
+	c.smtpClient = nil
+
 	return c.capabilities, nil
 }
 
@@ -263,13 +275,10 @@ func (c *SMTPClient) StartTLS(tlsConfig *tls.Config) (*tls.ConnectionState, erro
 	c.conn = tlsConn
 	c.reader = bufio.NewReader(tlsConn)
 
-	// Create a reusable stdlib smtp.Client for Auth and SendMail
-	// We do this here to avoid creating multiple clients with conflicting buffered readers
-	wrapper := &connWrapper{
-		reader: c.reader,
-		conn:   c.conn,
-	}
-	c.smtpClient = &smtp.Client{Text: textproto.NewConn(wrapper)}
+	// The stdlib client for Auth and SendMail is created on first use,
+	// after EHLO on the encrypted connection
+	c.smtpClient = nil
+	c.ehlo = nil
 
 	// Get connection state and store it
 	state := tlsConn.ConnectionState()
@@ -278,6 +287,69 @@ func (c *SMTPClient) StartTLS(tlsConfig *tls.Config) (*tls.ConnectionState, erro
 	return &state, nil
 }
 
+// XClient sends the Postfix XCLIENT command with the given attributes.
+// On success the server restarts the session with a new 220 greeting, which
+// replaces the stored banner; the caller must send EHLO again afterwards.
+func (c *SMTPClient) XClient(attrs []protocol.XClientAttr) (*protocol.SMTPResponse, error) {
+	resp, err := c.sendXCommand("XCLIENT", protocol.XCLIENT(attrs))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 220 {
+		return resp, fmt.Errorf("XCLIENT failed: %d %s", resp.Code, resp.Message)
+	}
+	c.banner = resp.Message
+	c.ehlo = nil
+	c.smtpClient = nil
+	return resp, nil
+}
+
+// XForward sends the Postfix XFORWARD command with the given attributes.
+// The attributes apply to the next mail transaction; no new greeting is sent.
+func (c *SMTPClient) XForward(attrs []protocol.XClientAttr) (*protocol.SMTPResponse, error) {
+	resp, err := c.sendXCommand("XFORWARD", protocol.XFORWARD(attrs))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 250 {
+		return resp, fmt.Errorf("XFORWARD failed: %d %s", resp.Code, resp.Message)
+	}
+	return resp, nil
+}
+
+// SetXForward sets XFORWARD attributes to send before every MAIL FROM.
+// Postfix forgets them after each transaction and on RSET or EHLO, so they
+// are sent again for every message of the session.
+func (c *SMTPClient) SetXForward(attrs []protocol.XClientAttr) {
+	c.xforward = attrs
+}
+
+// sendXCommand writes an XCLIENT/XFORWARD command and reads the response.
+func (c *SMTPClient) sendXCommand(name, cmd string) (*protocol.SMTPResponse, error) {
+	// Apply rate limiting using stored context
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limit wait failed: %w", err)
+	}
+
+	c.debugLogCommand(cmd)
+	if _, err := c.conn.Write([]byte(cmd)); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", name, err)
+	}
+
+	resp, err := protocol.ReadResponseWithTimeout(c.conn, c.reader, protocol.DefaultResponseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", name, err)
+	}
+
+	c.debugLogResponse(resp)
+
+	return resp, nil
+}
+
 // connWrapper wraps our existing buffered reader and connection into an io.ReadWriteCloser
 // This allows us to reuse the existing buffer while creating a proper textproto.Conn
 type connWrapper struct {
@@ -297,6 +369,58 @@ func (cw *connWrapper) Close() error {
 	return nil // Don't close the underlying connection, we'll manage it ourselves
 }
 
+// replayConn feeds a recorded server response to a textproto.Conn and
+// discards what is written to it.
+type replayConn struct {
+	*strings.Reader
+}
+
+func (rc replayConn) Write(p []byte) (n int, err error) {
+	return len(p), nil
+}
+
+func (rc replayConn) Close() error {
+	return nil
+}
+
+// session returns the stdlib client for AUTH and mail transactions,
+// creating it on first use. After EHLO the client adopts that response
+// instead of greeting the server again: another EHLO would replace the
+// HELO name (including one set with XCLIENT) and clear XFORWARD attributes.
+// Without a prior EHLO the stdlib client greets the server itself.
+func (c *SMTPClient) session() (*smtp.Client, error) {
+	if c.smtpClient != nil {
+		return c.smtpClient, nil
+	}
+
+	client := &smtp.Client{Text: textproto.NewConn(&connWrapper{reader: c.reader, conn: c.conn})}
+	if c.ehlo == nil {
+		if err := client.Hello(c.host); err != nil {
+			return nil, fmt.Errorf("EHLO failed: %w", err)
+		}
+		c.smtpClient = client
+		return client, nil
+	}
+
+	var recorded strings.Builder
+	for i, line := range c.ehlo.Lines {
+		sep := "-"
+		if i == len(c.ehlo.Lines)-1 {
+			sep = " "
+		}
+		fmt.Fprintf(&recorded, "%d%s%s\r\n", c.ehlo.Code, sep, line)
+	}
+	live := client.Text
+	client.Text = textproto.NewConn(replayConn{strings.NewReader(recorded.String())})
+	if err := client.Hello(c.ehloName); err != nil {
+		return nil, fmt.Errorf("failed to adopt EHLO response: %w", err)
+	}
+	client.Text = live
+
+	c.smtpClient = client
+	return client, nil
+}
+
 // Auth performs SMTP authentication.
 // For XOAUTH2, pass the OAuth2 access token in the accessToken parameter.
 func (c *SMTPClient) Auth(username, password, accessToken string, mechanisms []string) error {
@@ -334,39 +458,31 @@ func (c *SMTPClient) Auth(username, password, accessToken string, mechanisms []s
 	case "OAUTHBEARER":
 		auth = &oauthbearerAuth{username: username, accessToken: accessToken, host: c.host, port: c.port}
 	case "NTLM":
-		auth = &ntlmAuth{username: username, password: password}
+		auth = &saslAuth{client: &commonsasl.NTLMClient{Username: username, Password: password}}
 	case "GSSAPI":
-		auth = &gssapiAuth{
-			username:   username,
-			password:   password,
-			realm:      c.config.Realm,
-			kdcAddress: c.config.KDCAddress,
-			target:     c.host,
-		}
+		auth = &saslAuth{client: &commonsasl.GSSAPIClient{
+			Username:   username,
+			Password:   password,
+			Realm:      c.config.Realm,
+			KDCAddress: c.config.KDCAddress,
+			Service:    "smtp",
+			Target:     c.host,
+		}}
 	default:
 		return fmt.Errorf("unsupported authentication mechanism: %s", mechanism)
 	}
 
-	// Use the reusable smtp.Client created after STARTTLS
-	// If not available (no STARTTLS), create one now
-	if c.smtpClient == nil {
-		wrapper := &connWrapper{
-			reader: c.reader,
-			conn:   c.conn,
-		}
-		c.smtpClient = &smtp.Client{Text: textproto.NewConn(wrapper)}
-	}
-
-	c.debugLogMessage(fmt.Sprintf(">>> AUTH %s (credentials exchanged via SASL)", mechanism))
-
-	// Call Hello to initialize the client state properly
-	// This sends EHLO again, which is required by smtp.Client.Auth()
-	if err := c.smtpClient.Hello(c.host); err != nil {
+	// smtp.Client.Auth needs a greeted client; session reuses the last
+	// EHLO rather than sending another one
+	smtpClient, err := c.session()
+	if err != nil {
 		c.debugLogMessage("<<< EHLO for auth failed")
 		return fmt.Errorf("EHLO for auth failed: %w", err)
 	}
 
-	if err := c.smtpClient.Auth(auth); err != nil {
+	c.debugLogMessage(fmt.Sprintf(">>> AUTH %s (credentials exchanged via SASL)", mechanism))
+
+	if err := smtpClient.Auth(auth); err != nil {
 		c.debugLogMessage("<<< Authentication failed")
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -387,16 +503,18 @@ func (c *SMTPClient) SendMail(from string, to []string, data []byte) error {
 		return fmt.Errorf("rate limit wait failed: %w", err)
 	}
 
-	// Use the reusable smtp.Client created after STARTTLS (or Auth)
-	// If not available, create one now
-	if c.smtpClient == nil {
-		wrapper := &connWrapper{
-			reader: c.reader,
-			conn:   c.conn,
+	smtpClient, err := c.session()
+	if err != nil {
+		return err
+	}
+
+	// XFORWARD applies to the next transaction only, so it goes right
+	// before every MAIL FROM
+	if len(c.xforward) > 0 {
+		if _, err := c.XForward(c.xforward); err != nil {
+			return err
 		}
-		c.smtpClient = &smtp.Client{Text: textproto.NewConn(wrapper)}
 	}
-	smtpClient := c.smtpClient
 
 	// MAIL FROM
 	c.debugLogMessage(fmt.Sprintf(">>> MAIL FROM:<%s>", from))
@@ -452,6 +570,137 @@ func (c *SMTPClient) SendMail(from string, to []string, data []byte) error {
 	return nil
 }
 
+// TransactionResult describes how a raw SMTP mail transaction ended.
+type TransactionResult struct {
+	Stage    string                 // Last step reached: MAIL FROM, RCPT TO, DATA, or END OF DATA
+	Response *protocol.SMTPResponse // Last server response (nil if none was received)
+	Duration time.Duration          // Time from MAIL FROM to the final response
+}
+
+// Accepted reports whether the server accepted the message (2xx after the
+// end-of-data marker).
+func (r *TransactionResult) Accepted() bool {
+	return r.Stage == "END OF DATA" && r.Response != nil && r.Response.IsSuccess()
+}
+
+// SendRawTransaction runs one MAIL FROM / RCPT TO / DATA transaction using
+// raw protocol commands and returns the server's final response instead of
+// turning rejections into errors, so callers can tell where and how a
+// message was refused. If declareSize is true, the message size is announced
+// with the RFC 1870 SIZE parameter on MAIL FROM.
+//
+// A returned error means the connection is no longer usable (I/O failure or
+// the server dropped the connection); a rejection by the server is reported
+// through the result only, and the session can be reused after Reset.
+func (c *SMTPClient) SendRawTransaction(from string, to []string, data []byte, declareSize bool) (*TransactionResult, error) {
+	// Apply rate limiting using stored context
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limit wait failed: %w", err)
+	}
+
+	start := time.Now()
+	result := &TransactionResult{Stage: "MAIL FROM"}
+	finish := func(resp *protocol.SMTPResponse, err error) (*TransactionResult, error) {
+		result.Response = resp
+		result.Duration = time.Since(start)
+		return result, err
+	}
+
+	// MAIL FROM
+	cmd := protocol.MAILFROM(from)
+	if declareSize {
+		cmd = protocol.MAILFROMWithSize(from, int64(len(data)))
+	}
+	resp, err := c.rawCommand(cmd, protocol.DefaultResponseTimeout)
+	if err != nil || !resp.IsSuccess() {
+		return finish(resp, err)
+	}
+
+	// RCPT TO
+	result.Stage = "RCPT TO"
+	for _, recipient := range to {
+		resp, err = c.rawCommand(protocol.RCPTTO(recipient), protocol.DefaultResponseTimeout)
+		if err != nil || !resp.IsSuccess() {
+			return finish(resp, err)
+		}
+	}
+
+	// DATA
+	result.Stage = "DATA"
+	resp, err = c.rawCommand(protocol.DATA(), protocol.DefaultResponseTimeout)
+	if err != nil || resp.Code != 354 {
+		return finish(resp, err)
+	}
+
+	// Message content (dot-stuffed) and end-of-data marker. Large messages
+	// may take the server a while to scan, so allow at least the connection
+	// timeout for the final response.
+	result.Stage = "END OF DATA"
+	c.debugLogMessage(fmt.Sprintf("Sending message (%d bytes)", len(data)))
+	bw := bufio.NewWriter(c.conn)
+	dw := textproto.NewWriter(bw).DotWriter()
+	_, writeErr := dw.Write(data)
+	if writeErr == nil {
+		writeErr = dw.Close()
+	}
+	c.debugLogCommand(".\r\n")
+
+	timeout := protocol.DefaultResponseTimeout
+	if c.config.Timeout > timeout {
+		timeout = c.config.Timeout
+	}
+	resp, err = protocol.ReadResponseWithTimeout(c.conn, c.reader, timeout)
+	if err != nil {
+		// Servers that enforce a hard limit often reply and drop the
+		// connection mid-transfer; prefer reporting the write failure.
+		if writeErr != nil {
+			err = fmt.Errorf("failed to send message data: %w", writeErr)
+		}
+		return finish(nil, err)
+	}
+	c.debugLogResponse(resp)
+	if writeErr != nil {
+		// Got a response (typically 552) but the connection is broken
+		return finish(resp, fmt.Errorf("failed to send message data: %w", writeErr))
+	}
+
+	return finish(resp, nil)
+}
+
+// Reset sends RSET to abort the current transaction so the session can be
+// reused for another message.
+func (c *SMTPClient) Reset() error {
+	resp, err := c.rawCommand(protocol.RSET(), protocol.DefaultResponseTimeout)
+	if err != nil {
+		return err
+	}
+	if !resp.IsSuccess() {
+		return fmt.Errorf("RSET failed: %d %s", resp.Code, resp.Message)
+	}
+	return nil
+}
+
+// rawCommand writes a protocol command and reads its response.
+func (c *SMTPClient) rawCommand(cmd string, timeout time.Duration) (*protocol.SMTPResponse, error) {
+	c.debugLogCommand(cmd)
+	if _, err := c.conn.Write([]byte(cmd)); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", strings.Fields(cmd)[0], err)
+	}
+
+	resp, err := protocol.ReadResponseWithTimeout(c.conn, c.reader, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", strings.Fields(cmd)[0], err)
+	}
+
+	c.debugLogResponse(resp)
+
+	return resp, nil
+}
+
 // Close closes the connection.
 func (c *SMTPClient) Close() error {
 	if c.conn != nil {
@@ -618,138 +867,24 @@ func (a *oauthbearerAuth) Next(_ []byte, more bool) ([]byte, error) {
 	return nil, nil
 }
 
-// ntlmAuth implements NTLM (NTLMv2) authentication for SMTP.
-// The three-step exchange: negotiate (type 1) → challenge (type 2) → authenticate (type 3).
-// Username may be in DOMAIN\user format; the library handles domain extraction automatically.
-type ntlmAuth struct {
-	username string
-	password string
-}
-
-func (a *ntlmAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
-	negotiate, err := ntlmssp.NewNegotiateMessage("", "")
-	if err != nil {
-		return "", nil, fmt.Errorf("NTLM negotiate: %w", err)
-	}
-	return "NTLM", negotiate, nil
-}
-
-func (a *ntlmAuth) Next(fromServer []byte, more bool) ([]byte, error) {
-	if !more {
-		return nil, nil
-	}
-	authenticate, err := ntlmssp.NewAuthenticateMessage(fromServer, a.username, a.password, nil)
-	if err != nil {
-		return nil, fmt.Errorf("NTLM authenticate: %w", err)
-	}
-	return authenticate, nil
-}
-
-// parseKerberosCredentials splits a Kerberos username into the user and realm parts.
-// Supports user@REALM.COM and DOMAIN\user formats.
-func parseKerberosCredentials(username string) (user, realm string) {
-	if idx := strings.Index(username, "@"); idx >= 0 {
-		return username[:idx], strings.ToUpper(username[idx+1:])
-	}
-	if idx := strings.Index(username, "\\"); idx >= 0 {
-		return username[idx+1:], strings.ToUpper(username[:idx])
-	}
-	return username, ""
+// saslAuth adapts a go-sasl client, such as the NTLM and GSSAPI clients of
+// internal/common/sasl, to smtp.Auth. net/smtp calls Next with more=false
+// once the server accepts, which is when a GSSAPI client releases its
+// Kerberos client.
+type saslAuth struct {
+	client sasl.Client
 }
 
-// gssapiAuth implements SASL GSSAPI (RFC 4752) for SMTP using Kerberos 5.
-// Exchange: AP_REQ → [AP_REP] → security-layer negotiation (no-security-layer selected).
-// Username may be in user@REALM or DOMAIN\user format; realm is extracted automatically
-// or overridden via the Realm field. KDC is discovered via DNS SRV unless KDCAddress is set.
-type gssapiAuth struct {
-	username   string
-	password   string
-	realm      string // Kerberos realm override (e.g. CONTOSO.COM)
-	kdcAddress string // optional KDC host:port override
-	target     string // SMTP server hostname for SPN smtp/<target>
-
-	krb5Client *krb5client.Client
-	sessionKey types.EncryptionKey
-	step       int
+func (a *saslAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return a.client.Start()
 }
 
-func (a *gssapiAuth) Start(_ *smtp.ServerInfo) (string, []byte, error) {
-	user, realm := parseKerberosCredentials(a.username)
-	if a.realm != "" {
-		realm = a.realm
-	}
-	if realm == "" {
-		return "", nil, fmt.Errorf("GSSAPI: Kerberos realm required (use user@REALM format or --realm)")
-	}
-
-	krb5Cfg := krb5config.New()
-	krb5Cfg.LibDefaults.DefaultRealm = realm
-	if a.kdcAddress != "" {
-		krb5Cfg.Realms = []krb5config.Realm{{Realm: realm, KDC: []string{a.kdcAddress}}}
-	} else {
-		krb5Cfg.LibDefaults.DNSLookupKDC = true
-	}
-
-	a.krb5Client = krb5client.NewWithPassword(user, realm, a.password, krb5Cfg,
-		krb5client.DisablePAFXFAST(true))
-	if err := a.krb5Client.Login(); err != nil {
-		return "", nil, fmt.Errorf("GSSAPI: Kerberos login failed: %w", err)
-	}
-
-	spn := fmt.Sprintf("smtp/%s", a.target)
-	tkt, skey, err := a.krb5Client.GetServiceTicket(spn)
-	if err != nil {
-		a.krb5Client.Destroy()
-		return "", nil, fmt.Errorf("GSSAPI: service ticket for %s failed: %w", spn, err)
-	}
-	a.sessionKey = skey
-
-	gssToken, err := spnego.NewKRB5TokenAPREQ(a.krb5Client, tkt, skey,
-		[]int{gssapi.ContextFlagMutual}, []int{})
-	if err != nil {
-		a.krb5Client.Destroy()
-		return "", nil, fmt.Errorf("GSSAPI: AP_REQ token failed: %w", err)
-	}
-
-	b, err := gssToken.Marshal()
-	if err != nil {
-		a.krb5Client.Destroy()
-		return "", nil, fmt.Errorf("GSSAPI: AP_REQ marshal failed: %w", err)
-	}
-
-	a.step = 1
-	return "GSSAPI", b, nil
-}
-
-func (a *gssapiAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+func (a *saslAuth) Next(fromServer []byte, more bool) ([]byte, error) {
 	if !more {
-		if a.krb5Client != nil {
-			a.krb5Client.Destroy()
+		if closer, ok := a.client.(interface{ Close() }); ok {
+			closer.Close()
 		}
 		return nil, nil
 	}
-
-	switch a.step {
-	case 1:
-		// Server optionally sends AP_REP (mutual auth); skip verification (testing tool)
-		a.step = 2
-		return nil, nil
-
-	case 2:
-		// Server sends GSS-wrapped security layer options: [flags, buf_hi, buf_mid, buf_lo]
-		// Respond with no-security-layer: bitmask=1, max buf size=0
-		secLayer := []byte{0x01, 0x00, 0x00, 0x00}
-		wt, err := gssapi.NewInitiatorWrapToken(secLayer, a.sessionKey)
-		if err != nil {
-			return nil, fmt.Errorf("GSSAPI: security layer wrap failed: %w", err)
-		}
-		b, err := wt.Marshal()
-		if err != nil {
-			return nil, fmt.Errorf("GSSAPI: security layer marshal failed: %w", err)
-		}
-		a.step = 3
-		return b, nil
-	}
-
-	return nil, nil
+	return a.client.Next(fromServer)
 }
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	commonsasl "github.com/ziembor/gomailtesttool/internal/common/sasl"
 	"github.com/ziembor/gomailtesttool/internal/smtp/protocol"
 )
 
@@ -838,7 +839,7 @@ func TestParseKerberosCredentials(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			user, realm := parseKerberosCredentials(tt.username)
+			user, realm := commonsasl.ParseKerberosCredentials(tt.username)
 			if user != tt.wantUser {
 				t.Errorf("parseKerberosCredentials(%q) user = %q, want %q", tt.username, user, tt.wantUser)
 			}
@@ -852,12 +853,13 @@ func TestParseKerberosCredentials(t *testing.T) {
 // TestGSSAPIAuth tests GSSAPI auth struct behaviour without a live KDC
 func TestGSSAPIAuth(t *testing.T) {
 	t.Run("Start returns GSSAPI mechanism name", func(t *testing.T) {
-		auth := &gssapiAuth{
-			username: "alice@CONTOSO.COM",
-			password: "secret",
-			target:   "exchange.contoso.com",
+		auth := &saslAuth{client: &commonsasl.GSSAPIClient{
+			Username: "alice@CONTOSO.COM",
+			Password: "secret",
+			Service:  "smtp",
+			Target:   "exchange.contoso.com",
 			// No KDC — Start() will fail on KDC login, but mechanism name is returned first
-		}
+		}}
 		// mechanism name is what matters for unit testing; network failure is expected
 		mechanism, _, _ := auth.Start(nil)
 		if mechanism != "GSSAPI" && mechanism != "" {
@@ -866,11 +868,12 @@ func TestGSSAPIAuth(t *testing.T) {
 	})
 
 	t.Run("Start fails with descriptive error when realm cannot be determined", func(t *testing.T) {
-		auth := &gssapiAuth{
-			username: "alice", // plain username, no realm, no --realm flag
-			password: "secret",
-			target:   "exchange.contoso.com",
-		}
+		auth := &saslAuth{client: &commonsasl.GSSAPIClient{
+			Username: "alice", // plain username, no realm, no --realm flag
+			Password: "secret",
+			Service:  "smtp",
+			Target:   "exchange.contoso.com",
+		}}
 		_, _, err := auth.Start(nil)
 		if err == nil {
 			t.Error("gssapiAuth.Start() expected error for missing realm, got nil")
@@ -881,7 +884,7 @@ func TestGSSAPIAuth(t *testing.T) {
 	})
 
 	t.Run("Next with more=false returns nil (auth complete)", func(t *testing.T) {
-		auth := &gssapiAuth{}
+		auth := &saslAuth{client: &commonsasl.GSSAPIClient{}}
 		resp, err := auth.Next(nil, false)
 		if err != nil {
 			t.Errorf("gssapiAuth.Next(more=false) error = %v", err)
@@ -918,10 +921,10 @@ func TestNTLMAuth(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			auth := &ntlmAuth{
-				username: tt.username,
-				password: tt.password,
-			}
+			auth := &saslAuth{client: &commonsasl.NTLMClient{
+				Username: tt.username,
+				Password: tt.password,
+			}}
 
 			mechanism, resp, err := auth.Start(nil)
 			if err != nil {
@@ -100,6 +100,27 @@ func testConnect(ctx context.Context, config *Config, csvLogger logger.Logger, s
 		return err
 	}
 
+	// Simulate a different client via Postfix XCLIENT/XFORWARD
+	if config.XClient != "" {
+		caps, err = applyXClient(client, config, caps, slogLogger)
+		if err != nil {
+			logger.LogError(slogLogger, "XCLIENT failed", "error", err)
+			tlsData := formatTLSInfoForCSV(tlsState, config.Host)
+			if logErr := csvLogger.WriteRow([]string{
+				config.Action, "FAILURE", config.Host,
+				fmt.Sprintf("%d", config.Port), config.ConnectAddress, "true", client.GetBanner(), caps.String(), "false",
+				tlsData.TLSVersion, tlsData.CipherSuite, tlsData.CipherStrength,
+				tlsData.CertSubject, tlsData.CertIssuer, tlsData.CertSANs,
+				tlsData.CertValidFrom, tlsData.CertValidTo, tlsData.VerificationStatus,
+				err.Error(),
+			}); logErr != nil {
+				logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+			}
+			return err
+		}
+		fmt.Println()
+	}
+
 	// Display capabilities
 	fmt.Println("Server Capabilities:")
 	for cap, params := range caps {
@@ -0,0 +1,525 @@
+package smtp
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/email"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// filterProbeHeader marks every testfilter message so probes can be found
+// (and cleaned up) in the recipient mailbox.
+const filterProbeHeader = "X-GoMailTest-Probe"
+
+// filterZipPassword is the password of the encrypted-zip probe; it is also
+// stated in the message body, the way phishing campaigns do.
+const filterZipPassword = "gomailtest"
+
+// eicarTestString is the industry-standard anti-malware test file. It is
+// harmless, but every AV engine is expected to detect it.
+const eicarTestString = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// filterProbeContent is the generated content of one testfilter message.
+type filterProbeContent struct {
+	Body        string
+	BodyHTML    string
+	Inline      []email.Attachment
+	Attachments []email.Attachment
+}
+
+// filterProbe is one class of content commonly blocked by mail gateways.
+type filterProbe struct {
+	Name        string
+	Description string
+	Build       func(config *Config) (*filterProbeContent, error)
+}
+
+// filterProbeResult records how the server answered one probe.
+type filterProbeResult struct {
+	Probe        *filterProbe
+	MessageBytes int
+	Accepted     bool
+	Stage        string
+	Code         int
+	Message      string
+	Err          string
+}
+
+// filterProbes lists all probes in the order they are sent. The baseline
+// probe carries nothing suspicious and tells policy rejections apart from
+// general delivery problems.
+var filterProbes = []*filterProbe{
+	{
+		Name:        "baseline",
+		Description: "Plain text message with no attachments (control)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{Body: "Control message: contains nothing a content filter should block."}, nil
+		},
+	},
+	{
+		Name:        "eicar",
+		Description: "EICAR anti-malware test file (eicar.com)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{
+				Body: "Attached is the EICAR anti-malware test file. It is harmless but should be detected by every AV engine.",
+				Attachments: []email.Attachment{
+					{Name: "eicar.com", ContentType: "application/octet-stream", Data: []byte(eicarTestString)},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "double-extension",
+		Description: "Executable disguised with a double extension (invoice.pdf.exe)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{
+				Body: "Please find the invoice attached.",
+				Attachments: []email.Attachment{
+					{Name: "invoice.pdf.exe", ContentType: "application/octet-stream", Data: peStub()},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "encrypted-zip",
+		Description: "Password-protected zip archive (documents.zip)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			data, err := buildEncryptedZip("documents.txt", []byte("gomailtest encrypted archive probe\r\n"), filterZipPassword)
+			if err != nil {
+				return nil, err
+			}
+			return &filterProbeContent{
+				Body: fmt.Sprintf("The attached archive is protected. Password: %s", filterZipPassword),
+				Attachments: []email.Attachment{
+					{Name: "documents.zip", ContentType: "application/zip", Data: data},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "macro-office",
+		Description: "Macro-enabled Office document placeholder (report.docm)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			data, err := buildMacroDocument()
+			if err != nil {
+				return nil, err
+			}
+			return &filterProbeContent{
+				Body: "Please enable content to view the attached report.",
+				Attachments: []email.Attachment{
+					{Name: "report.docm", ContentType: "application/vnd.ms-word.document.macroEnabled.12", Data: data},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "iso",
+		Description: "ISO 9660 disk image (setup.iso)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{
+				Body: "Installer image attached.",
+				Attachments: []email.Attachment{
+					{Name: "setup.iso", ContentType: "application/x-iso9660-image", Data: isoStub()},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "lnk",
+		Description: "Windows shortcut file (shortcut.lnk)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{
+				Body: "Shortcut to the shared folder attached.",
+				Attachments: []email.Attachment{
+					{Name: "shortcut.lnk", ContentType: "application/x-ms-shortcut", Data: lnkStub()},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "mime-mismatch",
+		Description: "Executable content declared as application/pdf (statement.pdf)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			return &filterProbeContent{
+				Body: "Your statement is attached.",
+				Attachments: []email.Attachment{
+					{Name: "statement.pdf", ContentType: "application/pdf", Data: peStub()},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "oversize-image",
+		Description: "Oversize inline image in an HTML body (photo.png)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			data, err := generateNoisePNG(config.FilterImageKB * 1024)
+			if err != nil {
+				return nil, err
+			}
+			return &filterProbeContent{
+				Body:     "See the image below.",
+				BodyHTML: `<html><body><p>See the image below.</p><img src="cid:photo.png" alt="photo"></body></html>`,
+				Inline: []email.Attachment{
+					{Name: "photo.png", ContentType: "image/png", Data: data, ContentID: "photo.png", Inline: true},
+				},
+			}, nil
+		},
+	},
+	{
+		Name:        "url-shortener",
+		Description: "Links through URL-shortener services (bit.ly, tinyurl.com, t.co)",
+		Build: func(config *Config) (*filterProbeContent, error) {
+			links := []string{"https://bit.ly/3gomailtest", "https://tinyurl.com/gomailtest", "https://t.co/gomailtest"}
+			var html strings.Builder
+			html.WriteString("<html><body><p>Please review the documents:</p><ul>")
+			for _, link := range links {
+				fmt.Fprintf(&html, `<li><a href="%s">%s</a></li>`, link, link)
+			}
+			html.WriteString("</ul></body></html>")
+			return &filterProbeContent{
+				Body:     "Please review the documents:\r\n" + strings.Join(links, "\r\n"),
+				BodyHTML: html.String(),
+			}, nil
+		},
+	},
+}
+
+// findFilterProbe returns the probe with the given name, or nil.
+func findFilterProbe(name string) *filterProbe {
+	for _, p := range filterProbes {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// filterProbeNames returns the names of all probes.
+func filterProbeNames() []string {
+	names := make([]string, len(filterProbes))
+	for i, p := range filterProbes {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// testFilter sends one message per content-filter probe and records which
+// ones the server accepted at SMTP time.
+func testFilter(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Probing content filter policy of %s:%d...\n\n", config.Host, config.Port)
+
+	if err := writeSMTPCSVHeader(csvLogger, []string{
+		"Action", "Status", "Server", "Port", "Probe", "Description",
+		"Message_Bytes", "Accepted", "Stage", "SMTP_Response_Code", "SMTP_Response", "Error",
+	}); err != nil {
+		logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+	}
+
+	probes := filterProbes
+	if len(config.FilterProbes) > 0 {
+		probes = nil
+		for _, name := range config.FilterProbes {
+			probes = append(probes, findFilterProbe(name))
+		}
+	}
+
+	client, _, err := openSession(ctx, config)
+	if err != nil {
+		logger.LogError(slogLogger, "Session setup failed", "error", err)
+		if logErr := writeSMTPCSVRow(csvLogger, []string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), "", "",
+			"", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+	defer func() {
+		if client != nil {
+			client.Close()
+		}
+	}()
+
+	fmt.Printf("✓ Connected; sending %d probe message(s)\n", len(probes))
+	fmt.Println("Note: accepted probes are delivered to -to; use a mailbox you can inspect for quarantine/stripping.")
+	fmt.Println()
+
+	var results []*filterProbeResult
+	for _, probe := range probes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if client == nil {
+			if client, _, err = openSession(ctx, config); err != nil {
+				return fmt.Errorf("reconnect failed: %w", err)
+			}
+		}
+
+		result := &filterProbeResult{Probe: probe}
+		data, err := buildFilterProbeMessage(config, probe)
+		if err != nil {
+			return fmt.Errorf("failed to build %s probe: %w", probe.Name, err)
+		}
+		result.MessageBytes = len(data)
+
+		res, txErr := client.SendRawTransaction(config.From, config.To, data, false)
+		if res == nil {
+			return txErr
+		}
+		result.Accepted = res.Accepted()
+		result.Stage = res.Stage
+		if res.Response != nil {
+			result.Code = res.Response.Code
+			result.Message = res.Response.Message
+		}
+
+		if txErr != nil {
+			// Some gateways hang up on malicious content; count it as a
+			// rejection and reconnect for the next probe.
+			result.Err = txErr.Error()
+			if res.Response == nil {
+				result.Message = "connection dropped"
+			}
+			client.Close()
+			client = nil
+		} else if !result.Accepted {
+			if err := client.Reset(); err != nil {
+				logger.LogDebug(slogLogger, "RSET failed; reconnecting for next probe", "error", err)
+				client.Close()
+				client = nil
+			}
+		}
+
+		if result.Accepted {
+			fmt.Printf("  ✓ %-16s accepted (%d %s)\n", probe.Name, result.Code, result.Message)
+		} else {
+			fmt.Printf("  ✗ %-16s rejected at %s (%d %s)\n", probe.Name, result.Stage, result.Code, result.Message)
+		}
+		logger.LogInfo(slogLogger, "Filter probe", "probe", probe.Name, "bytes", result.MessageBytes,
+			"accepted", result.Accepted, "stage", result.Stage, "code", result.Code)
+
+		if logErr := writeSMTPCSVRow(csvLogger, []string{
+			config.Action, "SUCCESS", config.Host, fmt.Sprintf("%d", config.Port), probe.Name, probe.Description,
+			fmt.Sprintf("%d", result.MessageBytes), fmt.Sprintf("%t", result.Accepted), result.Stage,
+			fmt.Sprintf("%d", result.Code), result.Message, result.Err,
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+
+		results = append(results, result)
+	}
+
+	printFilterSummary(results)
+
+	logger.LogInfo(slogLogger, "testfilter completed successfully", "probes", len(results))
+	return nil
+}
+
+// buildFilterProbeMessage builds the message for one probe, tagged with the
+// X-GoMailTest-Probe header.
+func buildFilterProbeMessage(config *Config, probe *filterProbe) ([]byte, error) {
+	content, err := probe.Build(config)
+	if err != nil {
+		return nil, err
+	}
+
+	probeConfig := *config
+	probeConfig.Subject = fmt.Sprintf("gomailtest filter probe: %s", probe.Name)
+	probeConfig.Body = content.Body
+	probeConfig.BodyHTML = content.BodyHTML
+
+	headers := []email.Header{{Name: filterProbeHeader, Value: probe.Name}}
+	return assembleMIMEMessage(&probeConfig, headers, content.Inline, content.Attachments)
+}
+
+// printFilterSummary prints which probes were accepted and rejected.
+func printFilterSummary(results []*filterProbeResult) {
+	accepted := 0
+	fmt.Println()
+	fmt.Println("Content Filter Summary:")
+	for _, r := range results {
+		status := "REJECTED"
+		if r.Accepted {
+			status = "ACCEPTED"
+			accepted++
+		}
+		fmt.Printf("  %-16s %-8s  %s\n", r.Probe.Name, status, r.Probe.Description)
+	}
+	fmt.Printf("\n  %d of %d probe(s) accepted at SMTP time\n", accepted, len(results))
+
+	for _, r := range results {
+		if r.Probe.Name == "baseline" && !r.Accepted {
+			fmt.Println("  ⚠ The baseline message was rejected; other rejections may not be content-related")
+		}
+	}
+	if accepted > 0 {
+		fmt.Println("  Note: acceptance at SMTP time does not rule out later quarantine or attachment stripping;")
+		fmt.Println("        check the recipient mailbox for messages tagged with the " + filterProbeHeader + " header.")
+	}
+}
+
+// peStub returns a minimal DOS/PE header: enough for content sniffers to
+// identify an executable, but not a runnable program.
+func peStub() []byte {
+	stub := make([]byte, 128)
+	copy(stub, "MZ")
+	binary.LittleEndian.PutUint32(stub[0x3C:], 0x40) // e_lfanew
+	copy(stub[0x40:], "PE\x00\x00")
+	binary.LittleEndian.PutUint16(stub[0x44:], 0x8664) // Machine: AMD64
+	return stub
+}
+
+// isoStub returns the start of an ISO 9660 image: the 32 KB system area
+// followed by a primary volume descriptor carrying the CD001 signature.
+func isoStub() []byte {
+	data := make([]byte, 32768+2048)
+	pvd := data[32768:]
+	pvd[0] = 1 // primary volume descriptor
+	copy(pvd[1:], "CD001")
+	pvd[6] = 1
+	copy(pvd[40:72], fmt.Sprintf("%-32s", "GOMAILTEST"))
+	return data
+}
+
+// lnkStub returns a Windows Shell Link header (MS-SHLLINK 2.1).
+func lnkStub() []byte {
+	data := make([]byte, 76)
+	binary.LittleEndian.PutUint32(data[0:], 0x4C) // HeaderSize
+	// LinkCLSID 00021401-0000-0000-C000-000000000046
+	copy(data[4:], []byte{0x01, 0x14, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46})
+	return data
+}
+
+// buildMacroDocument returns an Office Open XML package that declares a VBA
+// project part. The vbaProject.bin part is a placeholder with no code.
+func buildMacroDocument() ([]byte, error) {
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.ms-word.document.macroEnabled.main+xml"/>` +
+			`</Types>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+			`<w:body><w:p><w:r><w:t>gomailtest macro probe</w:t></w:r></w:p></w:body></w:document>`},
+		{"word/vbaProject.bin", "gomailtest placeholder: no macro code"},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildEncryptedZip returns a zip archive with a single stored file
+// encrypted using traditional PKWARE (ZipCrypto) encryption, which every
+// unzip tool can open and gateways cannot inspect.
+func buildEncryptedZip(name string, content []byte, password string) ([]byte, error) {
+	crc := crc32.ChecksumIEEE(content)
+
+	// 12-byte encryption header: random bytes, with the last byte set to
+	// the high byte of the CRC for password verification.
+	header := make([]byte, 12)
+	if _, err := rand.Read(header); err != nil {
+		return nil, err
+	}
+	header[11] = byte(crc >> 24)
+
+	keys := newZipCryptoKeys(password)
+	encrypted := make([]byte, 0, len(header)+len(content))
+	for _, b := range append(header, content...) {
+		encrypted = append(encrypted, b^keys.streamByte())
+		keys.update(b)
+	}
+
+	fh := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Flags:              0x1, // encrypted
+		CRC32:              crc,
+		CompressedSize64:   uint64(len(encrypted)),
+		UncompressedSize64: uint64(len(content)),
+		Modified:           time.Now(),
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(fh)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(encrypted); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zipCryptoKeys is the key state of traditional PKWARE encryption
+// (APPNOTE.TXT section 6.1).
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32.IEEETable[byte(k[0])^b] ^ (k[0] >> 8)
+	k[1] = (k[1]+(k[0]&0xFF))*134775813 + 1
+	k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ (k[2] >> 8)
+}
+
+func (k *zipCryptoKeys) streamByte() byte {
+	temp := uint16(k[2] | 2)
+	return byte((temp * (temp ^ 1)) >> 8)
+}
+
+// generateNoisePNG returns a valid PNG of roughly size bytes. Random pixels
+// keep the image incompressible, so the encoded size tracks the pixel count.
+func generateNoisePNG(size int) ([]byte, error) {
+	side := int(math.Sqrt(float64(size) / 4))
+	if side < 1 {
+		side = 1
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, side, side))
+	if _, err := rand.Read(img.Pix); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
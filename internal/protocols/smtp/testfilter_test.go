@@ -0,0 +1,225 @@
+//go:build !integration
+// +build !integration
+
+package smtp
+
+import (
+	"archive/zip"
+	"bytes"
+	"hash/crc32"
+	"image/png"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFilterProbes_Unique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, name := range filterProbeNames() {
+		if seen[name] {
+			t.Errorf("duplicate probe name %q", name)
+		}
+		seen[name] = true
+		if findFilterProbe(name) == nil {
+			t.Errorf("findFilterProbe(%q) = nil", name)
+		}
+	}
+	if findFilterProbe("unknown") != nil {
+		t.Error("findFilterProbe(\"unknown\") should return nil")
+	}
+}
+
+func TestBuildFilterProbeMessage(t *testing.T) {
+	config := newTestConfig()
+	config.FilterImageKB = 64
+
+	for _, probe := range filterProbes {
+		t.Run(probe.Name, func(t *testing.T) {
+			data, err := buildFilterProbeMessage(config, probe)
+			if err != nil {
+				t.Fatalf("buildFilterProbeMessage() error = %v", err)
+			}
+
+			msg, _ := parseMessage(t, data)
+			if got := msg.Header.Get(filterProbeHeader); got != probe.Name {
+				t.Errorf("%s = %q, want %q", filterProbeHeader, got, probe.Name)
+			}
+			if got := msg.Header.Get("Subject"); !strings.Contains(got, probe.Name) {
+				t.Errorf("Subject = %q, want it to contain %q", got, probe.Name)
+			}
+		})
+	}
+}
+
+func TestBuildEncryptedZip(t *testing.T) {
+	content := []byte("secret content\r\n")
+	data, err := buildEncryptedZip("secret.txt", content, "pass123")
+	if err != nil {
+		t.Fatalf("buildEncryptedZip() error = %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("archive has %d files, want 1", len(zr.File))
+	}
+	f := zr.File[0]
+	if f.Flags&0x1 == 0 {
+		t.Error("encryption flag not set")
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		t.Fatalf("OpenRaw() error = %v", err)
+	}
+	encrypted, err := io.ReadAll(raw)
+	if err != nil {
+		t.Fatalf("read raw data: %v", err)
+	}
+
+	// Decrypt with the same key schedule and verify the check byte and CRC.
+	keys := newZipCryptoKeys("pass123")
+	plain := make([]byte, len(encrypted))
+	for i, c := range encrypted {
+		plain[i] = c ^ keys.streamByte()
+		keys.update(plain[i])
+	}
+	if plain[11] != byte(f.CRC32>>24) {
+		t.Errorf("check byte = %#x, want %#x", plain[11], byte(f.CRC32>>24))
+	}
+	if !bytes.Equal(plain[12:], content) {
+		t.Errorf("decrypted content = %q, want %q", plain[12:], content)
+	}
+	if crc32.ChecksumIEEE(plain[12:]) != f.CRC32 {
+		t.Error("CRC32 mismatch after decryption")
+	}
+}
+
+func TestBuildMacroDocument(t *testing.T) {
+	data, err := buildMacroDocument()
+	if err != nil {
+		t.Fatalf("buildMacroDocument() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	found := false
+	for _, f := range zr.File {
+		if f.Name == "word/vbaProject.bin" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("macro document has no word/vbaProject.bin part")
+	}
+}
+
+func TestGenerateNoisePNG(t *testing.T) {
+	const size = 256 * 1024
+	data, err := generateNoisePNG(size)
+	if err != nil {
+		t.Fatalf("generateNoisePNG() error = %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if len(data) < size*9/10 || len(data) > size*11/10 {
+		t.Errorf("PNG size = %d, want about %d", len(data), size)
+	}
+}
+
+func TestFileStubs(t *testing.T) {
+	if pe := peStub(); !bytes.HasPrefix(pe, []byte("MZ")) || !bytes.Equal(pe[0x40:0x44], []byte("PE\x00\x00")) {
+		t.Error("peStub() missing MZ/PE signatures")
+	}
+	if iso := isoStub(); string(iso[32769:32774]) != "CD001" {
+		t.Error("isoStub() missing CD001 signature")
+	}
+	if lnk := lnkStub(); len(lnk) != 76 || lnk[0] != 0x4C {
+		t.Error("lnkStub() has an invalid header")
+	}
+}
+
+// recordingLogger is an in-memory logger.Logger that keeps the CSV rows.
+type recordingLogger struct {
+	header []string
+	rows   [][]string
+}
+
+func (l *recordingLogger) WriteHeader(columns []string) error { l.header = columns; return nil }
+func (l *recordingLogger) WriteRow(row []string) error        { l.rows = append(l.rows, row); return nil }
+func (l *recordingLogger) Close() error                       { return nil }
+func (l *recordingLogger) ShouldWriteHeader() (bool, error)   { return l.header == nil, nil }
+
+// column returns the value of the named column in row.
+func (l *recordingLogger) column(row []string, name string) string {
+	for i, c := range l.header {
+		if c == name && i < len(row) {
+			return row[i]
+		}
+	}
+	return ""
+}
+
+func TestFilter_RejectionAndDroppedConnection(t *testing.T) {
+	probeHeader := regexp.MustCompile(`(?m)^` + filterProbeHeader + `: (\S+)\r$`)
+	mta := &fakeMTA{
+		// The gateway rejects the EICAR probe and hangs up on the executable
+		endOfData: func(data []byte) string {
+			probe := ""
+			if m := probeHeader.FindSubmatch(data); m != nil {
+				probe = string(m[1])
+			}
+			switch probe {
+			case "eicar":
+				return "554 5.7.1 Virus found: Eicar-Signature"
+			case "double-extension":
+				return ""
+			}
+			return "250 2.0.0 Queued"
+		},
+	}
+	config := mta.start(t)
+	config.Action = ActionTestFilter
+	config.FilterProbes = []string{"baseline", "eicar", "double-extension", "url-shortener"}
+
+	csvLog := &recordingLogger{}
+	if err := testFilter(t.Context(), config, csvLog, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+		t.Fatalf("testFilter() error = %v", err)
+	}
+
+	want := map[string]string{
+		"baseline":         "true END OF DATA 250",
+		"eicar":            "false END OF DATA 554",
+		"double-extension": "false END OF DATA 0",
+		"url-shortener":    "true END OF DATA 250",
+	}
+	if len(csvLog.rows) != len(want) {
+		t.Fatalf("%d CSV row(s), want %d", len(csvLog.rows), len(want))
+	}
+	for _, row := range csvLog.rows {
+		probe := csvLog.column(row, "Probe")
+		got := strings.Join([]string{csvLog.column(row, "Accepted"), csvLog.column(row, "Stage"), csvLog.column(row, "SMTP_Response_Code")}, " ")
+		if got != want[probe] {
+			t.Errorf("%s verdict = %q, want %q", probe, got, want[probe])
+		}
+		if probe == "double-extension" && csvLog.column(row, "SMTP_Response") != "connection dropped" {
+			t.Errorf("%s response = %q, want the dropped connection", probe, csvLog.column(row, "SMTP_Response"))
+		}
+	}
+
+	// RSET after the rejection, and the last probe on a new connection
+	sessions := mta.recorded()
+	if drops := checkRejections(t, sessions); drops != 1 || len(sessions) != 2 {
+		t.Errorf("%d dropped connection(s) in %d session(s), want 1 in 2: %v", drops, len(sessions), sessions)
+	}
+	if len(sessions) > 0 && !strings.Contains(strings.Join(sessions[0], " "), ". 554 RSET 250") {
+		t.Errorf("first session = %v, want RSET after the 554", sessions[0])
+	}
+}
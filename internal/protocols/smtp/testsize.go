@@ -0,0 +1,353 @@
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+
+	"github.com/ziembor/gomailtesttool/internal/common/email"
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+)
+
+// base64LineOverhead is the size of base64-encoded attachment data relative
+// to the raw data: every 57 raw bytes become a 76-character line plus CRLF.
+const base64LineOverhead = 78.0 / 57.0
+
+// sizeProber runs size probes for testsize, reusing one session across
+// probes and reconnecting whenever the server drops the connection (common
+// after an oversized DATA transfer).
+type sizeProber struct {
+	ctx        context.Context
+	config     *Config
+	csvLogger  logger.Logger
+	slogLogger *slog.Logger
+	client     *SMTPClient
+	payload    []byte // Pre-generated data; probes use a prefix of it
+	probes     int
+}
+
+// sizeProbeResult is the outcome of a single probe.
+type sizeProbeResult struct {
+	PayloadBytes int
+	MessageBytes int
+	Accepted     bool
+	Stage        string
+	Code         int
+	Message      string
+}
+
+// sizePassResult summarizes one binary-search pass.
+type sizePassResult struct {
+	Mode             string
+	LargestAccepted  *sizeProbeResult
+	SmallestRejected *sizeProbeResult
+}
+
+// testSize binary-searches the largest message the server accepts, with and
+// without declaring the size via the SIZE parameter on MAIL FROM.
+func testSize(ctx context.Context, config *Config, csvLogger logger.Logger, slogLogger *slog.Logger) error {
+	fmt.Printf("Probing message size limit of %s:%d...\n\n", config.Host, config.Port)
+
+	if err := writeSMTPCSVHeader(csvLogger, []string{
+		"Action", "Status", "Server", "Port", "Mode", "Probe",
+		"Payload_Bytes", "Message_Bytes", "Accepted", "Stage",
+		"SMTP_Response_Code", "SMTP_Response", "Error",
+	}); err != nil {
+		logger.LogError(slogLogger, "Failed to write CSV header", "error", err)
+	}
+
+	client, caps, err := openSession(ctx, config)
+	if err != nil {
+		logger.LogError(slogLogger, "Session setup failed", "error", err)
+		if logErr := writeSMTPCSVRow(csvLogger, []string{
+			config.Action, "FAILURE", config.Host, fmt.Sprintf("%d", config.Port), config.SizeMode, "",
+			"", "", "", "", "", "", err.Error(),
+		}); logErr != nil {
+			logger.LogError(slogLogger, "Failed to write CSV row", "error", logErr)
+		}
+		return err
+	}
+
+	advertised := caps.GetMaxMessageSize()
+	if advertised > 0 {
+		fmt.Printf("✓ Connected; server advertises SIZE %d bytes (%s)\n", advertised, formatBytes(advertised))
+	} else {
+		fmt.Println("✓ Connected; server does not advertise a SIZE limit")
+	}
+
+	minBytes := config.SizeMinKB * 1024
+	maxBytes := config.SizeMaxKB * 1024
+	if maxBytes == 0 {
+		// Probe well past the advertised limit (the encoded message is ~37%
+		// larger than the payload), or 50 MB if nothing is advertised.
+		maxBytes = 50 * 1024 * 1024
+		if advertised > 0 {
+			maxBytes = int(advertised) * 3 / 2
+		}
+	}
+	if maxBytes <= minBytes {
+		maxBytes = minBytes * 2
+	}
+	precision := config.SizePrecisionKB * 1024
+
+	fmt.Printf("  Search range: %s – %s attachment payload (%s data, precision %s)\n\n",
+		formatBytes(int64(minBytes)), formatBytes(int64(maxBytes)), config.SizePayload, formatBytes(int64(precision)))
+	fmt.Println("Note: accepted probe messages are delivered to -to; use a sink mailbox.")
+	fmt.Println()
+
+	prober := &sizeProber{
+		ctx:        ctx,
+		config:     config,
+		csvLogger:  csvLogger,
+		slogLogger: slogLogger,
+		client:     client,
+		payload:    generateSizePayload(config.SizePayload, maxBytes),
+	}
+	defer prober.close()
+
+	var modes []string
+	switch config.SizeMode {
+	case "declared":
+		modes = []string{"declared"}
+	case "undeclared":
+		modes = []string{"undeclared"}
+	default:
+		modes = []string{"undeclared", "declared"}
+	}
+
+	var passes []*sizePassResult
+	for _, mode := range modes {
+		fmt.Printf("Pass: %s\n", sizeModeLabel(mode))
+		pass, err := prober.binarySearch(mode, minBytes, maxBytes, precision)
+		if err != nil {
+			logger.LogError(slogLogger, "Size probe failed", "mode", mode, "error", err)
+			return fmt.Errorf("%s pass failed: %w", mode, err)
+		}
+		passes = append(passes, pass)
+		fmt.Println()
+	}
+
+	printSizeSummary(advertised, passes)
+
+	logger.LogInfo(slogLogger, "testsize completed successfully", "probes", prober.probes)
+	return nil
+}
+
+// binarySearch finds the boundary between accepted and rejected payload
+// sizes in [minBytes, maxBytes], stopping once the gap is <= precision.
+func (p *sizeProber) binarySearch(mode string, minBytes, maxBytes, precision int) (*sizePassResult, error) {
+	pass := &sizePassResult{Mode: mode}
+
+	lo, err := p.probe(mode, minBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !lo.Accepted {
+		pass.SmallestRejected = lo
+		return pass, nil
+	}
+	pass.LargestAccepted = lo
+
+	hi, err := p.probe(mode, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if hi.Accepted {
+		pass.LargestAccepted = hi
+		return pass, nil
+	}
+	pass.SmallestRejected = hi
+
+	for pass.SmallestRejected.PayloadBytes-pass.LargestAccepted.PayloadBytes > precision {
+		mid := (pass.LargestAccepted.PayloadBytes + pass.SmallestRejected.PayloadBytes) / 2
+		res, err := p.probe(mode, mid)
+		if err != nil {
+			return nil, err
+		}
+		if res.Accepted {
+			pass.LargestAccepted = res
+		} else {
+			pass.SmallestRejected = res
+		}
+	}
+
+	return pass, nil
+}
+
+// probe sends one message with an attachment of payloadBytes and reports
+// whether the server accepted it.
+func (p *sizeProber) probe(mode string, payloadBytes int) (*sizeProbeResult, error) {
+	if err := p.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if p.client == nil {
+		client, _, err := openSession(p.ctx, p.config)
+		if err != nil {
+			return nil, fmt.Errorf("reconnect failed: %w", err)
+		}
+		p.client = client
+	}
+
+	p.probes++
+	data, err := buildSizeProbeMessage(p.config, p.payload[:payloadBytes], mode)
+	if err != nil {
+		return nil, err
+	}
+
+	res, txErr := p.client.SendRawTransaction(p.config.From, p.config.To, data, mode == "declared")
+	if res == nil {
+		return nil, txErr
+	}
+
+	probe := &sizeProbeResult{
+		PayloadBytes: payloadBytes,
+		MessageBytes: len(data),
+		Accepted:     res.Accepted(),
+		Stage:        res.Stage,
+	}
+	if res.Response != nil {
+		probe.Code = res.Response.Code
+		probe.Message = res.Response.Message
+	}
+
+	errStr := ""
+	if txErr != nil {
+		// The connection is gone. A drop while streaming DATA counts as a
+		// rejection (some servers hang up once a hard limit is exceeded);
+		// anywhere else the result is inconclusive.
+		p.close()
+		if res.Response == nil && res.Stage != "END OF DATA" {
+			return nil, txErr
+		}
+		errStr = txErr.Error()
+		if res.Response == nil {
+			probe.Message = "connection dropped during data transfer"
+		}
+	} else if !probe.Accepted {
+		if err := p.client.Reset(); err != nil {
+			logger.LogDebug(p.slogLogger, "RSET failed; reconnecting for next probe", "error", err)
+			p.close()
+		}
+	}
+
+	if probe.Accepted {
+		fmt.Printf("  ✓ %-10s payload → %-10s message: accepted\n",
+			formatBytes(int64(payloadBytes)), formatBytes(int64(len(data))))
+	} else {
+		fmt.Printf("  ✗ %-10s payload → %-10s message: rejected at %s (%d %s)\n",
+			formatBytes(int64(payloadBytes)), formatBytes(int64(len(data))), probe.Stage, probe.Code, probe.Message)
+	}
+	logger.LogDebug(p.slogLogger, "Size probe", "mode", mode, "payload", payloadBytes, "message", len(data),
+		"accepted", probe.Accepted, "stage", probe.Stage, "code", probe.Code)
+
+	if logErr := writeSMTPCSVRow(p.csvLogger, []string{
+		p.config.Action, "SUCCESS", p.config.Host, fmt.Sprintf("%d", p.config.Port), mode, fmt.Sprintf("%d", p.probes),
+		fmt.Sprintf("%d", payloadBytes), fmt.Sprintf("%d", len(data)), fmt.Sprintf("%t", probe.Accepted), probe.Stage,
+		fmt.Sprintf("%d", probe.Code), probe.Message, errStr,
+	}); logErr != nil {
+		logger.LogError(p.slogLogger, "Failed to write CSV row", "error", logErr)
+	}
+
+	return probe, nil
+}
+
+// close ends the current session, if any.
+func (p *sizeProber) close() {
+	if p.client != nil {
+		p.client.Close()
+		p.client = nil
+	}
+}
+
+// buildSizeProbeMessage builds a message carrying data as a single
+// base64-encoded attachment.
+func buildSizeProbeMessage(config *Config, data []byte, mode string) ([]byte, error) {
+	probeConfig := *config
+	probeConfig.Subject = fmt.Sprintf("gomailtest size probe: %d bytes (SIZE %s)", len(data), mode)
+	probeConfig.Body = "Synthetic message generated by gomailtest smtp testsize."
+	probeConfig.BodyHTML = ""
+
+	attachment := email.Attachment{
+		Name:        "size-probe.bin",
+		ContentType: "application/octet-stream",
+		Data:        data,
+	}
+	return assembleMIMEMessage(&probeConfig, nil, nil, []email.Attachment{attachment})
+}
+
+// generateSizePayload returns n bytes of either random (incompressible) data
+// or highly compressible repeating text.
+func generateSizePayload(kind string, n int) []byte {
+	if kind == "compressible" {
+		pattern := []byte("gomailtest size probe payload - compressible filler text\n")
+		return bytes.Repeat(pattern, n/len(pattern)+1)[:n]
+	}
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return buf
+}
+
+// printSizeSummary prints the effective limits found by each pass.
+func printSizeSummary(advertised int64, passes []*sizePassResult) {
+	fmt.Println("Size Limit Summary:")
+	if advertised > 0 {
+		fmt.Printf("  Advertised SIZE:     %d bytes (%s); allows ≈ %s of attachment data after base64 encoding\n",
+			advertised, formatBytes(advertised), formatBytes(int64(float64(advertised)/base64LineOverhead)))
+	} else {
+		fmt.Println("  Advertised SIZE:     (none)")
+	}
+
+	for _, pass := range passes {
+		fmt.Printf("  %s:\n", sizeModeLabel(pass.Mode))
+
+		switch {
+		case pass.LargestAccepted == nil:
+			fmt.Printf("    ✗ Rejected even the smallest probe (%s message, %d %s)\n",
+				formatBytes(int64(pass.SmallestRejected.MessageBytes)), pass.SmallestRejected.Code, pass.SmallestRejected.Message)
+		case pass.SmallestRejected == nil:
+			fmt.Printf("    ✓ Accepted the largest probe (%s message); limit is above the search range\n",
+				formatBytes(int64(pass.LargestAccepted.MessageBytes)))
+		default:
+			acc, rej := pass.LargestAccepted, pass.SmallestRejected
+			fmt.Printf("    Effective message limit:    between %s and %s\n",
+				formatBytes(int64(acc.MessageBytes)), formatBytes(int64(rej.MessageBytes)))
+			fmt.Printf("    Effective attachment limit: ≈ %s (after base64 overhead)\n", formatBytes(int64(acc.PayloadBytes)))
+			fmt.Printf("    Rejected at %s with %d %s\n", rej.Stage, rej.Code, rej.Message)
+			if advertised > 0 {
+				switch {
+				case int64(rej.MessageBytes) <= advertised:
+					fmt.Println("    ⚠ Server rejects messages smaller than the advertised SIZE")
+				case int64(acc.MessageBytes) > advertised:
+					fmt.Println("    ⚠ Server accepts messages larger than the advertised SIZE")
+				default:
+					fmt.Println("    ✓ Consistent with the advertised SIZE")
+				}
+			}
+		}
+	}
+}
+
+// sizeModeLabel describes a testsize pass for display.
+func sizeModeLabel(mode string) string {
+	if mode == "declared" {
+		return "SIZE declared on MAIL FROM"
+	}
+	return "SIZE not declared"
+}
+
+// formatBytes formats a byte count using binary units (KB, MB, GB).
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,322 @@
+//go:build !integration
+// +build !integration
+
+package smtp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{35882577, "34.2 MB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GB"},
+	}
+
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateSizePayload(t *testing.T) {
+	const n = 64 * 1024
+
+	for _, kind := range []string{"random", "compressible"} {
+		if got := len(generateSizePayload(kind, n)); got != n {
+			t.Errorf("generateSizePayload(%q) length = %d, want %d", kind, got, n)
+		}
+	}
+
+	compressedSize := func(data []byte) int {
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+		_, _ = w.Write(data)
+		_ = w.Close()
+		return buf.Len()
+	}
+
+	if got := compressedSize(generateSizePayload("compressible", n)); got > n/10 {
+		t.Errorf("compressible payload compressed to %d bytes, expected well under %d", got, n/10)
+	}
+	if got := compressedSize(generateSizePayload("random", n)); got < n*9/10 {
+		t.Errorf("random payload compressed to %d bytes, expected close to %d", got, n)
+	}
+}
+
+func TestBuildSizeProbeMessage(t *testing.T) {
+	config := newTestConfig()
+	payload := generateSizePayload("random", 100*1024)
+
+	data, err := buildSizeProbeMessage(config, payload, "declared")
+	if err != nil {
+		t.Fatalf("buildSizeProbeMessage() error = %v", err)
+	}
+
+	msg, mr := parseMessage(t, data)
+	if mr == nil {
+		t.Fatal("expected a multipart message")
+	}
+	if subject := msg.Header.Get("Subject"); !bytes.Contains([]byte(subject), []byte("102400 bytes")) {
+		t.Errorf("Subject = %q, want it to mention the payload size", subject)
+	}
+
+	// The encoded message must be larger than the payload by roughly the
+	// base64 line overhead, plus headers and MIME boundaries.
+	minSize := int(float64(len(payload)) * base64LineOverhead)
+	if len(data) < minSize || len(data) > minSize+4096 {
+		t.Errorf("message size = %d, want between %d and %d", len(data), minSize, minSize+4096)
+	}
+}
+
+// fakeMTA is a fake SMTP server that accepts any number of connections, one
+// at a time, and lets the test decide how each transaction ends. It records
+// every session as "VERB code" entries; the end of DATA is recorded as ".",
+// with "drop" as the code when the connection was dropped instead.
+type fakeMTA struct {
+	extensions []string                 // Extensions advertised after 8BITMIME
+	mailFrom   func(line string) string // Reply to MAIL FROM; nil accepts it
+	endOfData  func(data []byte) string // Reply to the end of DATA; "" drops the connection
+
+	mu       sync.Mutex
+	sessions [][]string
+}
+
+// start listens on a local port and returns a config pointing at it.
+func (m *fakeMTA) start(t *testing.T) *Config {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			m.serve(conn)
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	config := NewConfig()
+	config.Host = host
+	config.Port, _ = strconv.Atoi(portStr)
+	config.From = "sender@example.com"
+	config.To = []string{"recipient@example.com"}
+	return config
+}
+
+func (m *fakeMTA) serve(conn net.Conn) {
+	defer conn.Close()
+
+	m.mu.Lock()
+	m.sessions = append(m.sessions, nil)
+	session := len(m.sessions) - 1
+	m.mu.Unlock()
+	record := func(verb, code string) {
+		m.mu.Lock()
+		m.sessions[session] = append(m.sessions[session], verb+" "+code)
+		m.mu.Unlock()
+	}
+	reply := func(verb, s string) {
+		record(verb, s[:3])
+		_, _ = conn.Write([]byte(s + "\r\n"))
+	}
+
+	r := bufio.NewReader(conn)
+	_, _ = conn.Write([]byte("220 fake.example.com ESMTP\r\n"))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch verb := commandVerb(line); verb {
+		case "EHLO":
+			_, _ = conn.Write([]byte("250-fake.example.com\r\n"))
+			for _, ext := range m.extensions {
+				_, _ = conn.Write([]byte("250-" + ext + "\r\n"))
+			}
+			reply(verb, "250 8BITMIME")
+		case "MAIL":
+			if m.mailFrom != nil {
+				reply(verb, m.mailFrom(line))
+			} else {
+				reply(verb, "250 OK")
+			}
+		case "DATA":
+			reply(verb, "354 Go ahead")
+			var data []byte
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data = append(data, strings.TrimPrefix(line, ".")...)
+			}
+			end := m.endOfData(data)
+			if end == "" {
+				record(".", "drop")
+				return
+			}
+			reply(".", end)
+		case "QUIT":
+			reply(verb, "221 Bye")
+			return
+		default:
+			reply(verb, "250 OK")
+		}
+	}
+}
+
+// recorded returns the recorded sessions.
+func (m *fakeMTA) recorded() [][]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := make([][]string, len(m.sessions))
+	for i, s := range m.sessions {
+		sessions[i] = append([]string(nil), s...)
+	}
+	return sessions
+}
+
+// checkRejections checks that the client sent RSET right after every 5xx
+// reply and opened a new session after every dropped connection. It returns
+// the number of dropped connections.
+func checkRejections(t *testing.T, sessions [][]string) int {
+	t.Helper()
+
+	drops := 0
+	for i, session := range sessions {
+		for j, entry := range session {
+			if strings.HasSuffix(entry, " drop") {
+				drops++
+				if i == len(sessions)-1 {
+					t.Errorf("session %d: no reconnect after the dropped connection", i+1)
+				}
+				continue
+			}
+			if !strings.HasSuffix(entry, " 552") && !strings.HasSuffix(entry, " 554") {
+				continue
+			}
+			// The client's QUIT may not be recorded yet when the run ends
+			if j+1 < len(session) && !strings.HasPrefix(session[j+1], "RSET ") {
+				t.Errorf("session %d: %q followed by %q, want RSET", i+1, entry, session[j+1])
+			} else if j+1 == len(session) && i < len(sessions)-1 {
+				t.Errorf("session %d: ended after %q without RSET", i+1, entry)
+			}
+		}
+	}
+	return drops
+}
+
+func TestSizeProber_FindsLimit(t *testing.T) {
+	const limit = 64 * 1024 // The server rejects messages above this size
+	const precision = 1024
+
+	for _, advertise := range []bool{true, false} {
+		t.Run(fmt.Sprintf("advertise=%t", advertise), func(t *testing.T) {
+			mta := &fakeMTA{
+				// Oversized messages are rejected, and ones over twice the
+				// limit make the server hang up
+				endOfData: func(data []byte) string {
+					switch {
+					case len(data) > 2*limit:
+						return ""
+					case len(data) > limit:
+						return "552 5.3.4 Message size exceeds fixed limit"
+					}
+					return "250 2.0.0 Queued"
+				},
+			}
+			if advertise {
+				mta.extensions = []string{fmt.Sprintf("SIZE %d", limit)}
+				mta.mailFrom = func(line string) string {
+					_, size, _ := strings.Cut(strings.ToUpper(line), " SIZE=")
+					if n, _ := strconv.Atoi(strings.TrimSpace(size)); n > limit {
+						return "552 5.3.4 Message size exceeds fixed limit"
+					}
+					return "250 OK"
+				}
+			}
+			config := mta.start(t)
+			config.Action = ActionTestSize
+			config.SizePayload = "compressible"
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			client, _, err := openSession(ctx, config)
+			if err != nil {
+				t.Fatalf("openSession() error = %v", err)
+			}
+			prober := &sizeProber{
+				ctx:        ctx,
+				config:     config,
+				slogLogger: slog.New(slog.NewTextHandler(&strings.Builder{}, nil)),
+				client:     client,
+				payload:    generateSizePayload(config.SizePayload, 128*1024),
+			}
+			defer prober.close()
+
+			for _, mode := range []string{"undeclared", "declared"} {
+				pass, err := prober.binarySearch(mode, 1024, 128*1024, precision)
+				if err != nil {
+					t.Fatalf("binarySearch(%s) error = %v", mode, err)
+				}
+				acc, rej := pass.LargestAccepted, pass.SmallestRejected
+				if acc == nil || rej == nil {
+					t.Fatalf("binarySearch(%s) = %+v, want both an accepted and a rejected probe", mode, pass)
+				}
+				if acc.MessageBytes > limit || rej.MessageBytes <= limit {
+					t.Errorf("%s: limit between %d and %d bytes, want %d in between", mode, acc.MessageBytes, rej.MessageBytes, limit)
+				}
+				if rej.PayloadBytes-acc.PayloadBytes > precision {
+					t.Errorf("%s: payloads %d and %d are further apart than %d", mode, acc.PayloadBytes, rej.PayloadBytes, precision)
+				}
+				wantStage := "END OF DATA"
+				if mode == "declared" && advertise {
+					wantStage = "MAIL FROM"
+				}
+				if rej.Stage != wantStage || rej.Code != 552 {
+					t.Errorf("%s: rejected at %s with %d, want %s with 552", mode, rej.Stage, rej.Code, wantStage)
+				}
+			}
+			prober.close()
+
+			sessions := mta.recorded()
+			drops := checkRejections(t, sessions)
+			if advertise && drops != 1 || !advertise && drops != 2 {
+				t.Errorf("%d dropped connection(s) in %v", drops, sessions)
+			}
+			if len(sessions) != drops+1 {
+				t.Errorf("%d session(s), want one more than the %d dropped", len(sessions), drops)
+			}
+		})
+	}
+}
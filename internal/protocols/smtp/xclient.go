@@ -0,0 +1,91 @@
+package smtp
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/smtp/protocol"
+)
+
+// applyXClient sends the --xclient attributes using Postfix XCLIENT when the
+// server advertises it, falling back to XFORWARD otherwise. XCLIENT restarts
+// the session with a new greeting, so EHLO is re-issued, under the simulated
+// HELO name when one is given, and the refreshed capabilities are returned.
+// XFORWARD attributes only last for one transaction, so they are handed to
+// the client to send before every MAIL FROM; the capabilities are unchanged.
+func applyXClient(client *SMTPClient, config *Config, caps protocol.Capabilities, slogLogger *slog.Logger) (protocol.Capabilities, error) {
+	attrs, err := protocol.ParseXClientAttrs(config.XClient)
+	if err != nil {
+		return caps, fmt.Errorf("invalid -xclient: %w", err)
+	}
+
+	var command string
+	switch {
+	case caps.SupportsXCLIENT():
+		command = "XCLIENT"
+	case caps.SupportsXFORWARD():
+		command = "XFORWARD"
+	default:
+		return caps, fmt.Errorf("server does not advertise XCLIENT or XFORWARD (check smtpd_authorized_xclient_hosts / smtpd_authorized_xforward_hosts)")
+	}
+
+	// Postfix lists the attribute names it accepts; reject unknown ones
+	// up front rather than getting a bare 501 from the server.
+	if advertised := caps.Get(command); len(advertised) > 0 {
+		for _, attr := range attrs {
+			if !containsFold(advertised, attr.Name) {
+				return caps, fmt.Errorf("server does not accept %s attribute %s (advertised: %s)", command, attr.Name, strings.Join(advertised, " "))
+			}
+		}
+	}
+
+	if command == "XFORWARD" {
+		client.SetXForward(attrs)
+		fmt.Println("XFORWARD attributes will be sent before each MAIL FROM")
+		logger.LogDebug(slogLogger, "XFORWARD attributes set", "attributes", config.XClient)
+		return caps, nil
+	}
+
+	fmt.Printf("Sending %s attributes...\n", command)
+	logger.LogDebug(slogLogger, "Sending "+command, "attributes", config.XClient)
+
+	if _, err := client.XClient(attrs); err != nil {
+		return caps, err
+	}
+	fmt.Println("✓ XCLIENT accepted")
+	fmt.Printf("  Banner: %s\n", client.GetBanner())
+
+	// XCLIENT resets the session: re-read capabilities as the simulated
+	// client. This EHLO is the last one of the session, so the HELO name it
+	// sends is the one the server keeps.
+	newCaps, err := client.EHLO(xclientHelo(attrs))
+	if err != nil {
+		return caps, fmt.Errorf("EHLO after XCLIENT failed: %w", err)
+	}
+	logger.LogInfo(slogLogger, "XCLIENT applied", "attributes", config.XClient, "banner", client.GetBanner())
+
+	return newCaps, nil
+}
+
+// xclientHelo returns the HELO name to greet with after XCLIENT: the HELO
+// attribute when it holds a name, or the default hostname.
+func xclientHelo(attrs []protocol.XClientAttr) string {
+	for _, attr := range attrs {
+		if strings.EqualFold(attr.Name, "HELO") && attr.Value != "" && !strings.HasPrefix(attr.Value, "[") {
+			return attr.Value
+		}
+	}
+	return "smtptool.local"
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/viper"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	"github.com/ziembor/gomailtesttool/internal/common/bootstrap"
+	"github.com/ziembor/gomailtesttool/internal/protocols/jmap"
 	"github.com/ziembor/gomailtesttool/internal/protocols/msgraph"
 	"github.com/ziembor/gomailtesttool/internal/protocols/smtp"
 )
@@ -21,13 +22,14 @@ func NewCmd() *cobra.Command {
 		Short: "Start an HTTP server for sending emails via REST API",
 		Long: `Start an HTTP REST server that exposes email sending endpoints.
 
-Credentials are loaded from environment variables at startup (SMTP*, MSGRAPH*).
+Credentials are loaded from environment variables at startup (SMTP*, MSGRAPH*, JMAP*).
 Each request carries only message content — no credentials in request bodies.
 
 Endpoints:
   GET  /health           health check
   POST /smtp/sendmail    send email via SMTP
   POST /msgraph/sendmail send email via Microsoft Graph
+  POST /jmap/sendmail    send email via JMAP submission
   POST /ews/sendmail     not yet implemented (501)
 
 All non-health endpoints require the X-API-Key header.`,
@@ -99,7 +101,21 @@ All non-health endpoints require the X-API-Key header.`,
 				}
 			}
 
-			return New(cfg, smtpBase, msgraphBase, graphClient, slogger).Run(ctx)
+			// Load JMAP base config from JMAP* env vars, with defaults from the
+			// "jmap" section of --config (if provided).
+			jmapViper := viper.New()
+			jmap.BindEnvs(jmapViper)
+			if err := bootstrap.LoadConfigFileSection(jmapViper, configPath, "jmap"); err != nil {
+				return err
+			}
+			jmapBase := jmap.ConfigFromViper(jmapViper)
+			jmapBase.Action = jmap.ActionSendMail
+			if jmapBase.Host == "" || (jmapBase.AccessToken == "" && jmapBase.Password == "") {
+				slogger.Warn("JMAPHOST or JMAP credentials not set — POST /jmap/sendmail will return 503")
+				jmapBase = nil
+			}
+
+			return New(cfg, smtpBase, msgraphBase, graphClient, jmapBase, slogger).Run(ctx)
 		},
 	}
 
@@ -0,0 +1,96 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ziembor/gomailtesttool/internal/common/logger"
+	"github.com/ziembor/gomailtesttool/internal/common/validation"
+	"github.com/ziembor/gomailtesttool/internal/protocols/jmap"
+)
+
+// jmapSendRequest is the JSON body for POST /jmap/sendmail.
+// Attachments are intentionally omitted for the same reason as in
+// msgraphSendRequest: they would be read from server-side file paths.
+type jmapSendRequest struct {
+	To       []string `json:"to"`
+	Cc       []string `json:"cc,omitempty"`
+	Bcc      []string `json:"bcc,omitempty"`
+	From     string   `json:"from,omitempty"` // optional override for JMAPIDENTITY; selects the identity
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body,omitempty"`
+	BodyHTML string   `json:"bodyHTML,omitempty"`
+}
+
+func (s *Server) handleJMAPSendMail(w http.ResponseWriter, r *http.Request) {
+	if s.jmapBase == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiResponse{Status: "error", Message: "JMAP not configured (set JMAPHOST and JMAPACCESSTOKEN or JMAPPASSWORD)"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1 MB limit
+
+	var req jmapSendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+
+	if len(req.To) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", Message: "to is required"})
+		return
+	}
+	if req.Subject == "" {
+		writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", Message: "subject is required"})
+		return
+	}
+	if req.From != "" {
+		if err := validation.ValidateEmail(req.From); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", Message: "invalid from address: " + err.Error()})
+			return
+		}
+	}
+	for _, list := range [][]string{req.To, req.Cc, req.Bcc} {
+		for _, addr := range list {
+			if err := validation.ValidateEmail(addr); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiResponse{Status: "error", Message: "invalid recipient address " + addr + ": " + err.Error()})
+				return
+			}
+		}
+	}
+
+	// Clone base config and overlay request content
+	cfg := *s.jmapBase
+	cfg.Action = jmap.ActionSendMail
+	cfg.To = req.To
+	cfg.Cc = req.Cc
+	cfg.Bcc = req.Bcc
+	cfg.EmailSubject = sanitizeEmailSubjectInput(req.Subject)
+	cfg.Body = sanitizeEmailBodyInput(req.Body)
+	cfg.BodyHTML = req.BodyHTML
+	cfg.Attachments = nil
+	cfg.Wait = 0
+	if req.From != "" {
+		cfg.Identity = req.From
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	csvLogger, err := logger.NewLogger(logger.LogFormatCSV, "servetool", "jmap-sendmail")
+	if err != nil {
+		s.logger.Warn("Could not initialise CSV logger for JMAP send", "error", err)
+	} else {
+		defer csvLogger.Close()
+	}
+
+	if err := jmap.SendMail(ctx, &cfg, csvLogger, s.logger); err != nil {
+		s.logger.Error("JMAP sendmail failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, apiResponse{Status: "error", Message: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiResponse{Status: "ok"})
+}
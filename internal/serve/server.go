@@ -12,6 +12,7 @@ import (
 
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	"github.com/ziembor/gomailtesttool/internal/common/version"
+	"github.com/ziembor/gomailtesttool/internal/protocols/jmap"
 	"github.com/ziembor/gomailtesttool/internal/protocols/msgraph"
 	"github.com/ziembor/gomailtesttool/internal/protocols/smtp"
 )
@@ -22,17 +23,19 @@ type Server struct {
 	smtpBase    *smtp.Config                     // nil when SMTP env vars are absent
 	msgraphBase *msgraph.Config                  // nil when MSGRAPH env vars are absent
 	graphClient *msgraphsdk.GraphServiceClient   // nil when msgraphBase is nil
+	jmapBase    *jmap.Config                     // nil when JMAP env vars are absent
 	logger      *slog.Logger
 }
 
-// New creates a Server. smtpBase and msgraphBase may be nil when the
-// corresponding credentials were not configured at startup.
-func New(cfg *Config, smtpBase *smtp.Config, msgraphBase *msgraph.Config, graphClient *msgraphsdk.GraphServiceClient, logger *slog.Logger) *Server {
+// New creates a Server. smtpBase, msgraphBase and jmapBase may be nil when
+// the corresponding credentials were not configured at startup.
+func New(cfg *Config, smtpBase *smtp.Config, msgraphBase *msgraph.Config, graphClient *msgraphsdk.GraphServiceClient, jmapBase *jmap.Config, logger *slog.Logger) *Server {
 	return &Server{
 		config:      cfg,
 		smtpBase:    smtpBase,
 		msgraphBase: msgraphBase,
 		graphClient: graphClient,
+		jmapBase:    jmapBase,
 		logger:      logger,
 	}
 }
@@ -45,6 +48,7 @@ func (s *Server) Run(ctx context.Context) error {
 	mux.HandleFunc("POST /smtp/sendmail", s.handleSMTPSendMail)
 	mux.HandleFunc("POST /msgraph/sendmail", s.handleMsgraphSendMail)
 	mux.HandleFunc("POST /ews/sendmail", s.handleEWSSendMail)
+	mux.HandleFunc("POST /jmap/sendmail", s.handleJMAPSendMail)
 
 	addr := net.JoinHostPort(s.config.Listen, strconv.Itoa(s.config.Port))
 	srv := &http.Server{
@@ -113,6 +117,7 @@ func (s *Server) handleSummary(w http.ResponseWriter, r *http.Request) {
 			{Method: "GET", Path: "/health", Description: "Health check (no API key required)", Available: true},
 			{Method: "POST", Path: "/smtp/sendmail", Description: "Send email via SMTP (X-API-Key required)", Available: s.smtpBase != nil},
 			{Method: "POST", Path: "/msgraph/sendmail", Description: "Send email via Microsoft Graph (X-API-Key required)", Available: s.msgraphBase != nil && s.graphClient != nil},
+			{Method: "POST", Path: "/jmap/sendmail", Description: "Send email via JMAP submission (X-API-Key required)", Available: s.jmapBase != nil},
 			{Method: "POST", Path: "/ews/sendmail", Description: "Send email via EWS — not yet implemented", Available: false},
 		},
 	})
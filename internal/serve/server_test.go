@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/spf13/viper"
+	"github.com/ziembor/gomailtesttool/internal/protocols/jmap"
 	"github.com/ziembor/gomailtesttool/internal/protocols/msgraph"
 	"github.com/ziembor/gomailtesttool/internal/protocols/smtp"
 )
@@ -25,6 +26,7 @@ func newTestServer(smtpBase *smtp.Config, msgraphBase *msgraph.Config) *Server {
 		smtpBase,
 		msgraphBase,
 		nil, // graphClient — tests that need it set it manually
+		nil, // jmapBase — JMAP tests set it manually
 		slog.New(slog.NewTextHandler(io.Discard, nil)),
 	)
 }
@@ -71,6 +73,7 @@ func serve(t *testing.T, srv *Server, method, path string, body any, apiKey stri
 	mux.HandleFunc("POST /smtp/sendmail", srv.handleSMTPSendMail)
 	mux.HandleFunc("POST /msgraph/sendmail", srv.handleMsgraphSendMail)
 	mux.HandleFunc("POST /ews/sendmail", srv.handleEWSSendMail)
+	mux.HandleFunc("POST /jmap/sendmail", srv.handleJMAPSendMail)
 	srv.apiKeyMiddleware(mux).ServeHTTP(rr, req)
 	return rr
 }
@@ -86,6 +89,7 @@ func serveRaw(t *testing.T, srv *Server, method, path, rawBody, apiKey string) *
 	mux := http.NewServeMux()
 	mux.HandleFunc("POST /smtp/sendmail", srv.handleSMTPSendMail)
 	mux.HandleFunc("POST /msgraph/sendmail", srv.handleMsgraphSendMail)
+	mux.HandleFunc("POST /jmap/sendmail", srv.handleJMAPSendMail)
 	srv.apiKeyMiddleware(mux).ServeHTTP(rr, req)
 	return rr
 }
@@ -431,3 +435,79 @@ func TestHandleMsgraphSendMail_AttachmentsFieldIgnored(t *testing.T) {
 		t.Errorf("status = %d, want 503 (body: %s)", rr.Code, rr.Body)
 	}
 }
+
+// --- JMAP handler ---
+
+// baseJmapConfig returns a jmap.Config sufficient to pass handler validation.
+// Port 1 ensures session discovery fails immediately.
+func baseJmapConfig() *jmap.Config {
+	cfg := jmap.NewConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 1
+	cfg.AccessToken = "token"
+	return cfg
+}
+
+func TestHandleJMAPSendMail_NilBase_Returns503(t *testing.T) {
+	srv := newTestServer(nil, nil)
+	rr := serve(t, srv, http.MethodPost, "/jmap/sendmail",
+		map[string]any{"to": []string{"a@b.com"}, "subject": "hi"},
+		"testkey")
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rr.Code)
+	}
+}
+
+func TestHandleJMAPSendMail_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    any
+		rawBody string // used instead of body when non-empty
+		wantMsg string
+	}{
+		{"Missing to field", map[string]any{"subject": "Test"}, "", "to is required"},
+		{"Missing subject", map[string]any{"to": []string{"a@b.com"}}, "", "subject is required"},
+		{"Invalid email in to", map[string]any{"to": []string{"notanemail"}, "subject": "Test"}, "", "invalid recipient address"},
+		{"Invalid email in bcc", map[string]any{"to": []string{"a@b.com"}, "bcc": []string{"notanemail"}, "subject": "Test"}, "", "invalid recipient address"},
+		{"Invalid from in request body", map[string]any{"to": []string{"a@b.com"}, "subject": "Test", "from": "notanemail"}, "", "invalid from address"},
+		{"Invalid JSON body", nil, `{bad json`, "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(nil, nil)
+			srv.jmapBase = baseJmapConfig()
+
+			var rr *httptest.ResponseRecorder
+			if tt.rawBody != "" {
+				rr = serveRaw(t, srv, http.MethodPost, "/jmap/sendmail", tt.rawBody, "testkey")
+			} else {
+				rr = serve(t, srv, http.MethodPost, "/jmap/sendmail", tt.body, "testkey")
+			}
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400 (body: %s)", rr.Code, rr.Body)
+			}
+			resp := decodeResp(t, rr)
+			if !strings.Contains(resp.Message, tt.wantMsg) {
+				t.Errorf("message = %q, want to contain %q", resp.Message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestHandleJMAPSendMail_ValidRequest(t *testing.T) {
+	// A valid request passes validation without a from (the identity comes
+	// from the server) and fails only when connecting to the server.
+	srv := newTestServer(nil, nil)
+	srv.jmapBase = baseJmapConfig()
+
+	rr := serve(t, srv, http.MethodPost, "/jmap/sendmail",
+		map[string]any{"to": []string{"a@b.com"}, "subject": "Test", "attachments": []string{"/etc/passwd"}},
+		"testkey")
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500 from the failed connection (body: %s)", rr.Code, rr.Body)
+	}
+}
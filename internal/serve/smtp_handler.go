@@ -110,6 +110,8 @@ func (s *Server) handleSMTPSendMail(w http.ResponseWriter, r *http.Request) {
 	cfg.Subject = sanitizeEmailSubjectInput(req.Subject)
 	cfg.Body = sanitizeEmailBodyInput(req.Body)
 	cfg.Action = smtp.ActionSendMail
+	cfg.Count = 1 // one message per API request, regardless of SMTPCOUNT
+	cfg.Interval = 0
 	if req.From != "" {
 		cfg.From = req.From
 	}
@@ -89,8 +89,7 @@ func (c Capabilities) GetMaxMessageSize() int64 {
 	}
 
 	// Parse size parameter
-	var size int64
-	_, err := parseSize(sizeParams[0])
+	size, err := parseSize(sizeParams[0])
 	if err != nil {
 		return 0
 	}
@@ -128,6 +127,16 @@ func (c Capabilities) SupportsSMTPUTF8() bool {
 	return c.Has("SMTPUTF8")
 }
 
+// SupportsXCLIENT checks if the server supports the Postfix XCLIENT extension.
+func (c Capabilities) SupportsXCLIENT() bool {
+	return c.Has("XCLIENT")
+}
+
+// SupportsXFORWARD checks if the server supports the Postfix XFORWARD extension.
+func (c Capabilities) SupportsXFORWARD() bool {
+	return c.Has("XFORWARD")
+}
+
 // String returns a formatted string representation of all capabilities.
 func (c Capabilities) String() string {
 	var result []string
@@ -0,0 +1,43 @@
+//go:build !integration
+// +build !integration
+
+package protocol
+
+import "testing"
+
+// TestGetMaxMessageSize tests parsing of the SIZE capability parameter
+func TestGetMaxMessageSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  int64
+	}{
+		{"SIZE with limit", []string{"mx.example.com Hello", "SIZE 35882577", "8BITMIME"}, 35882577},
+		{"SIZE without parameter", []string{"mx.example.com Hello", "SIZE"}, 0},
+		{"SIZE not advertised", []string{"mx.example.com Hello", "PIPELINING"}, 0},
+		{"SIZE with garbage", []string{"mx.example.com Hello", "SIZE unlimited"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := ParseCapabilities(tt.lines)
+			if got := caps.GetMaxMessageSize(); got != tt.want {
+				t.Errorf("GetMaxMessageSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSupportsXCLIENT tests detection of the Postfix XCLIENT/XFORWARD extensions
+func TestSupportsXCLIENT(t *testing.T) {
+	caps := ParseCapabilities([]string{"mx.example.com Hello", "XCLIENT NAME ADDR PROTO HELO", "PIPELINING"})
+	if !caps.SupportsXCLIENT() {
+		t.Error("SupportsXCLIENT() = false, want true")
+	}
+	if caps.SupportsXFORWARD() {
+		t.Error("SupportsXFORWARD() = true, want false")
+	}
+	if got := caps.Get("XCLIENT"); len(got) != 4 || got[1] != "ADDR" {
+		t.Errorf("Get(XCLIENT) = %v, want advertised attribute names", got)
+	}
+}
@@ -57,6 +57,14 @@ func MAILFROM(address string) string {
 	return fmt.Sprintf("MAIL FROM:<%s>\r\n", sanitizeCRLF(address))
 }
 
+// MAILFROMWithSize sends the MAIL FROM command with the RFC 1870 SIZE
+// parameter declaring the message size in bytes, letting the server reject
+// an oversized message before any data is transferred.
+// Example: MAIL FROM:<sender@example.com> SIZE=1048576
+func MAILFROMWithSize(address string, size int64) string {
+	return fmt.Sprintf("MAIL FROM:<%s> SIZE=%d\r\n", sanitizeCRLF(address), size)
+}
+
 // RCPTTO sends the RCPT TO command specifying a recipient address.
 // The address should NOT include angle brackets - they're added automatically.
 // Example: RCPT TO:<recipient@example.com>
@@ -104,3 +112,80 @@ func EXPN(mailingList string) string {
 func HELP() string {
 	return "HELP\r\n"
 }
+
+// XClientAttr is a single NAME=value attribute sent with the Postfix XCLIENT
+// or XFORWARD extension commands.
+type XClientAttr struct {
+	Name  string
+	Value string
+}
+
+// ParseXClientAttrs parses a comma-separated attribute list such as
+// "NAME=mail.example.com,ADDR=192.0.2.10,HELO=mail.example.com".
+// Attribute names are upper-cased; values are kept verbatim (the special
+// Postfix values [UNAVAILABLE] and [TEMPUNAVAIL] are passed through).
+func ParseXClientAttrs(s string) ([]XClientAttr, error) {
+	var attrs []XClientAttr
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid attribute %q (expected NAME=value)", item)
+		}
+		for _, r := range name {
+			if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+				return nil, fmt.Errorf("invalid attribute name %q", name)
+			}
+		}
+		attrs = append(attrs, XClientAttr{Name: name, Value: strings.TrimSpace(value)})
+	}
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("no attributes specified")
+	}
+	return attrs, nil
+}
+
+// XCLIENT sends the Postfix XCLIENT command, which overrides the client
+// attributes (name, address, HELO, ...) the server uses for access policy.
+// On success the server replies with a new 220 greeting and the client must
+// send EHLO again.
+// Example: XCLIENT NAME=mail.example.com ADDR=192.0.2.10
+func XCLIENT(attrs []XClientAttr) string {
+	return "XCLIENT " + formatXClientAttrs(attrs) + "\r\n"
+}
+
+// XFORWARD sends the Postfix XFORWARD command, which passes original client
+// attributes for logging and content filtering of the next mail transaction.
+// Example: XFORWARD NAME=mail.example.com ADDR=192.0.2.10
+func XFORWARD(attrs []XClientAttr) string {
+	return "XFORWARD " + formatXClientAttrs(attrs) + "\r\n"
+}
+
+// formatXClientAttrs joins attributes as space-separated NAME=value pairs
+// with xtext-encoded values.
+func formatXClientAttrs(attrs []XClientAttr) string {
+	parts := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		parts = append(parts, sanitizeCRLF(a.Name)+"="+xtextEncode(sanitizeCRLF(a.Value)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// xtextEncode encodes a value as RFC 3461 xtext: characters outside
+// '!'..'~', and '+' and '=', are written as "+XX" hex escapes.
+func xtextEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
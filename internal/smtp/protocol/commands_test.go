@@ -167,6 +167,20 @@ func TestMAILFROM(t *testing.T) {
 	}
 }
 
+// TestMAILFROMWithSize tests the MAIL FROM command builder with SIZE parameter
+func TestMAILFROMWithSize(t *testing.T) {
+	want := "MAIL FROM:<sender@example.com> SIZE=1048576\r\n"
+	if got := MAILFROMWithSize("sender@example.com", 1048576); got != want {
+		t.Errorf("MAILFROMWithSize() = %q, want %q", got, want)
+	}
+
+	// Security: CRLF injection
+	want = "MAIL FROM:<a@example.comRSET> SIZE=10\r\n"
+	if got := MAILFROMWithSize("a@example.com\r\nRSET", 10); got != want {
+		t.Errorf("MAILFROMWithSize() with CRLF = %q, want %q", got, want)
+	}
+}
+
 // TestRCPTTO tests the RCPT TO command builder
 func TestRCPTTO(t *testing.T) {
 	tests := []struct {
@@ -292,6 +306,77 @@ func TestHELP(t *testing.T) {
 	}
 }
 
+// TestParseXClientAttrs tests parsing of the --xclient attribute list
+func TestParseXClientAttrs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []XClientAttr
+		wantErr bool
+	}{
+		{
+			name:  "Typical attributes",
+			input: "NAME=mail.example.com,ADDR=192.0.2.10,HELO=mail.example.com",
+			want: []XClientAttr{
+				{"NAME", "mail.example.com"},
+				{"ADDR", "192.0.2.10"},
+				{"HELO", "mail.example.com"},
+			},
+		},
+		{
+			name:  "Lowercase names and spaces",
+			input: " addr = 192.0.2.10 , name=[UNAVAILABLE] ",
+			want:  []XClientAttr{{"ADDR", "192.0.2.10"}, {"NAME", "[UNAVAILABLE]"}},
+		},
+		{name: "Empty", input: "", wantErr: true},
+		{name: "Missing value separator", input: "ADDR", wantErr: true},
+		{name: "Invalid name", input: "AD DR=1.2.3.4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseXClientAttrs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseXClientAttrs(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseXClientAttrs(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("attr[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestXCLIENT tests the XCLIENT and XFORWARD command builders
+func TestXCLIENT(t *testing.T) {
+	attrs := []XClientAttr{
+		{"NAME", "mail.example.com"},
+		{"ADDR", "2001:db8::1"},
+		{"HELO", "odd name+=x"},
+	}
+
+	wantArgs := "NAME=mail.example.com ADDR=2001:db8::1 HELO=odd+20name+2B+3Dx"
+	if got := XCLIENT(attrs); got != "XCLIENT "+wantArgs+"\r\n" {
+		t.Errorf("XCLIENT() = %q, want %q", got, "XCLIENT "+wantArgs+"\r\n")
+	}
+	if got := XFORWARD(attrs); got != "XFORWARD "+wantArgs+"\r\n" {
+		t.Errorf("XFORWARD() = %q, want %q", got, "XFORWARD "+wantArgs+"\r\n")
+	}
+
+	// Security: CRLF injection
+	injected := XCLIENT([]XClientAttr{{"ADDR", "192.0.2.1\r\nQUIT"}})
+	if injected != "XCLIENT ADDR=192.0.2.1QUIT\r\n" {
+		t.Errorf("XCLIENT() with CRLF = %q", injected)
+	}
+}
+
 // TestCommandCRLFEndings verifies all commands end with CRLF
 func TestCommandCRLFEndings(t *testing.T) {
 	commands := []struct {
@@ -303,6 +388,7 @@ func TestCommandCRLFEndings(t *testing.T) {
 		{"STARTTLS", STARTTLS()},
 		{"AUTH", AUTH("PLAIN", "")},
 		{"MAILFROM", MAILFROM("test@example.com")},
+		{"MAILFROMWithSize", MAILFROMWithSize("test@example.com", 1024)},
 		{"RCPTTO", RCPTTO("test@example.com")},
 		{"DATA", DATA()},
 		{"RSET", RSET()},
@@ -311,6 +397,8 @@ func TestCommandCRLFEndings(t *testing.T) {
 		{"VRFY", VRFY("admin")},
 		{"EXPN", EXPN("list")},
 		{"HELP", HELP()},
+		{"XCLIENT", XCLIENT([]XClientAttr{{"ADDR", "192.0.2.1"}})},
+		{"XFORWARD", XFORWARD([]XClientAttr{{"ADDR", "192.0.2.1"}})},
 	}
 
 	for _, tc := range commands {